@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// dataPlaceholder stands for the -d payload in the built-in tests
+const dataPlaceholder = "__data__"
+
+// config describes a benchmark run
+type config struct {
+	address  string
+	password string
+	clients  int
+	requests int
+	pipeline int
+	// keyspace is the range of the numbers replacing randPlaceholder, 0
+	// to send the placeholder literally
+	keyspace int
+	dataSize int
+}
+
+// test is a named command to benchmark
+type test struct {
+	name string
+	args []string
+	// inline sends the command as an inline command
+	inline bool
+}
+
+// builtinTests are the tests run by default, in order. Each can be
+// selected with -t by its name or by its command name.
+var builtinTests = []test{
+	{name: "PING_INLINE", args: []string{"PING"}, inline: true},
+	{name: "PING_MBULK", args: []string{"PING"}},
+	{name: "ECHO", args: []string{"ECHO", dataPlaceholder}},
+	{name: "SET", args: []string{"SET", "key:" + randPlaceholder, dataPlaceholder}},
+	{name: "GET", args: []string{"GET", "key:" + randPlaceholder}},
+	{name: "PUBLISH", args: []string{"PUBLISH", "channel:" + randPlaceholder, dataPlaceholder}},
+}
+
+// selectTests returns the built-in tests named in a comma-separated list,
+// in their usual order. An empty list selects them all.
+func selectTests(list string) ([]test, error) {
+	if list == "" {
+		return builtinTests, nil
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		found := false
+		for _, t := range builtinTests {
+			if t.name == name || t.args[0] == name {
+				selected[t.name] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown test %q", name)
+		}
+	}
+
+	var tests []test
+	for _, t := range builtinTests {
+		if selected[t.name] {
+			tests = append(tests, t)
+		}
+	}
+	return tests, nil
+}
+
+// customTest benchmarks a command given on the command line, named after
+// the command line as redis-benchmark does
+func customTest(args []string) test {
+	return test{name: strings.Join(args, " "), args: args}
+}
+
+// benchClient is a connection sending pipelined requests and timing the
+// replies
+type benchClient struct {
+	conn   net.Conn
+	writer *bufio.Writer
+	parser *resp.Parser
+	random *rand.Rand
+	buf    []byte
+
+	latencies []time.Duration
+	errors    int
+	// firstError keeps the first error reply, to report it
+	firstError string
+}
+
+// dialClient connects and authenticates a client. seed makes the random
+// keys of each client repeatable across runs.
+func dialClient(cfg config, seed uint64) (*benchClient, error) {
+	conn, err := net.Dial("tcp", cfg.address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &benchClient{
+		conn:   conn,
+		writer: bufio.NewWriter(conn),
+		parser: resp.NewParser(conn),
+		random: rand.New(rand.NewPCG(seed, 0)),
+	}
+
+	if cfg.password != "" {
+		if err := c.auth(cfg.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// auth authenticates the client with password. A failed write is reported
+// as such rather than as the failed read of the reply.
+func (c *benchClient) auth(password string) error {
+	auth := newCommandTemplate([]string{"AUTH", password}, false, 0)
+	if _, err := c.writer.Write(auth.append(nil, c.random)); err != nil {
+		return err
+	}
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+
+	reply, err := c.parser.Parse()
+	if err != nil {
+		return err
+	}
+	if reply.Type == resp.Error {
+		return fmt.Errorf("%s", reply.Value)
+	}
+	return nil
+}
+
+// run sends requests until the remaining count is exhausted, claiming up
+// to pipeline requests at a time. Each request's latency runs from the
+// write of its batch to its reply.
+func (c *benchClient) run(template *commandTemplate, remaining *atomic.Int64, pipeline int) error {
+	for {
+		n := claim(remaining, pipeline)
+		if n == 0 {
+			return nil
+		}
+
+		c.buf = c.buf[:0]
+		for i := 0; i < n; i++ {
+			c.buf = template.append(c.buf, c.random)
+		}
+
+		start := time.Now()
+		if _, err := c.writer.Write(c.buf); err != nil {
+			return err
+		}
+		if err := c.writer.Flush(); err != nil {
+			return err
+		}
+
+		for i := 0; i < n; i++ {
+			reply, err := c.parser.Parse()
+			if err != nil {
+				return err
+			}
+			c.latencies = append(c.latencies, time.Since(start))
+
+			if reply.Type == resp.Error {
+				if c.errors == 0 {
+					c.firstError = reply.Value.(string)
+				}
+				c.errors++
+			}
+		}
+	}
+}
+
+// claim takes up to n requests from the remaining count and returns how
+// many were taken
+func claim(remaining *atomic.Int64, n int) int {
+	for {
+		left := remaining.Load()
+		if left <= 0 {
+			return 0
+		}
+		take := min(left, int64(n))
+		if remaining.CompareAndSwap(left, left-take) {
+			return int(take)
+		}
+	}
+}
+
+// runTest runs a test with the configured clients, all connected before
+// the clock starts
+func runTest(cfg config, t test) (*result, error) {
+	payload := strings.Repeat("x", cfg.dataSize)
+	args := make([]string, len(t.args))
+	for i, arg := range t.args {
+		args[i] = strings.ReplaceAll(arg, dataPlaceholder, payload)
+	}
+	template := newCommandTemplate(args, t.inline, cfg.keyspace)
+
+	clients := make([]*benchClient, cfg.clients)
+	defer func() {
+		for _, c := range clients {
+			if c != nil {
+				c.conn.Close()
+			}
+		}
+	}()
+	for i := range clients {
+		c, err := dialClient(cfg, uint64(i))
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to %s: %w", cfg.address, err)
+		}
+		clients[i] = c
+	}
+
+	var remaining atomic.Int64
+	remaining.Store(int64(cfg.requests))
+
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i, c := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.run(template, &remaining, cfg.pipeline)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	r := &result{name: t.name, elapsed: elapsed}
+	for i, c := range clients {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, errs[i])
+		}
+		r.latencies = append(r.latencies, c.latencies...)
+		if c.errors > 0 && r.errors == 0 {
+			r.firstError = c.firstError
+		}
+		r.errors += c.errors
+	}
+	slices.Sort(r.latencies)
+	return r, nil
+}
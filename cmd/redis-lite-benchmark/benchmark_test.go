@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+func TestSelectTests(t *testing.T) {
+	tests := []struct {
+		list     string
+		expected []string
+		err      bool
+	}{
+		{"", []string{"PING_INLINE", "PING_MBULK", "ECHO", "SET", "GET", "PUBLISH"}, false},
+		{"get,set", []string{"SET", "GET"}, false},
+		{"ping", []string{"PING_INLINE", "PING_MBULK"}, false},
+		{"ping_mbulk, GET", []string{"PING_MBULK", "GET"}, false},
+		{"set,bogus", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			selected, err := selectTests(tt.list)
+			if tt.err {
+				if err == nil {
+					t.Errorf("Expected error, got %d tests", len(selected))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			names := make([]string, len(selected))
+			for i, test := range selected {
+				names[i] = test.name
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestClaim(t *testing.T) {
+	var remaining atomic.Int64
+	remaining.Store(10)
+
+	var claims []int
+	for n := claim(&remaining, 4); n > 0; n = claim(&remaining, 4) {
+		claims = append(claims, n)
+	}
+
+	if len(claims) != 3 || claims[0] != 4 || claims[1] != 4 || claims[2] != 2 {
+		t.Errorf("Expected claims [4 4 2], got %v", claims)
+	}
+}
+
+func TestRunTest(t *testing.T) {
+	address, stop, err := startServer()
+	if err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer stop()
+
+	cfg := config{address: address, clients: 3, requests: 100, pipeline: 7, keyspace: 10, dataSize: 8}
+
+	for _, bench := range append(builtinTests, customTest([]string{"BOGUS"})) {
+		t.Run(bench.name, func(t *testing.T) {
+			r, err := runTest(cfg, bench)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(r.latencies) != cfg.requests {
+				t.Errorf("Expected %d requests, got %d", cfg.requests, len(r.latencies))
+			}
+
+			expectedErrors := 0
+			if bench.name == "BOGUS" {
+				expectedErrors = cfg.requests
+			}
+			if r.errors != expectedErrors {
+				t.Errorf("Expected %d errors, got %d (%s)", expectedErrors, r.errors, r.firstError)
+			}
+		})
+	}
+}
+
+func TestBenchClient_AuthWriteError(t *testing.T) {
+	conn, _ := net.Pipe()
+	conn.Close()
+	c := &benchClient{conn: conn, writer: bufio.NewWriter(conn), parser: resp.NewParser(conn), random: rand.New(rand.NewPCG(1, 0))}
+
+	// The write fails, and is reported rather than the read that follows
+	if err := c.auth("secret"); err != io.ErrClosedPipe {
+		t.Errorf("Expected %v, got %v", io.ErrClosedPipe, err)
+	}
+}
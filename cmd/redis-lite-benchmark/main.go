@@ -0,0 +1,142 @@
+// Command redis-lite-benchmark measures the throughput and latency of a
+// redis-lite server in the style of redis-benchmark.
+//
+// Usage:
+//
+//	redis-lite-benchmark [options] [command [arg ...]]
+//
+// Without a command it runs the built-in tests, or those selected with -t.
+// In a command, __rand_int__ is replaced by a random number below the -r
+// keyspace length. --in-process benchmarks a server started in the same
+// process, for repeatable runs that do not depend on a deployed server.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	serverconfig "github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/server"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the benchmark and returns the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("redis-lite-benchmark", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: redis-lite-benchmark [options] [command [arg ...]]")
+		flags.PrintDefaults()
+	}
+
+	var cfg config
+	host := flags.String("h", "127.0.0.1", "server hostname")
+	port := flags.Int("p", 6379, "server port")
+	flags.StringVar(&cfg.password, "a", "", "password to authenticate with")
+	flags.IntVar(&cfg.clients, "c", 50, "number of parallel connections")
+	flags.IntVar(&cfg.requests, "n", 100000, "total number of requests")
+	flags.IntVar(&cfg.pipeline, "P", 1, "number of requests pipelined per round trip")
+	flags.IntVar(&cfg.keyspace, "r", 0, "replace __rand_int__ with random numbers below this keyspace length")
+	flags.IntVar(&cfg.dataSize, "d", 3, "data size in bytes of SET, ECHO and PUBLISH values")
+	tests := flags.String("t", "", "comma-separated list of tests to run, such as ping,set,get")
+	quiet := flags.Bool("q", false, "quiet: print the requests per second and median latency only")
+	csv := flags.Bool("csv", false, "print the results as CSV")
+	inProcess := flags.Bool("in-process", false, "benchmark a server started in this process, ignoring -h and -p")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(stderr, "Invalid options: %v\n", err)
+		return 2
+	}
+
+	var selected []test
+	if flags.NArg() > 0 {
+		selected = []test{customTest(flags.Args())}
+	} else {
+		var err error
+		if selected, err = selectTests(*tests); err != nil {
+			fmt.Fprintf(stderr, "Invalid options: %v\n", err)
+			return 2
+		}
+	}
+
+	cfg.address = net.JoinHostPort(*host, strconv.Itoa(*port))
+	if *inProcess {
+		address, stop, err := startServer()
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not start the server: %v\n", err)
+			return 1
+		}
+		defer stop()
+		cfg.address = address
+	}
+
+	if *csv {
+		fmt.Fprintln(stdout, csvHeader)
+	}
+	for _, t := range selected {
+		r, err := runTest(cfg, t)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+
+		switch {
+		case *csv:
+			writeCSV(stdout, r)
+		case *quiet:
+			writeQuiet(stdout, r)
+		default:
+			writeReport(stdout, cfg, r)
+		}
+	}
+	return 0
+}
+
+// validate checks the numeric options
+func (c config) validate() error {
+	switch {
+	case c.clients < 1:
+		return errors.New("-c must be at least 1")
+	case c.requests < 1:
+		return errors.New("-n must be at least 1")
+	case c.pipeline < 1:
+		return errors.New("-P must be at least 1")
+	case c.keyspace < 0:
+		return errors.New("-r must not be negative")
+	case c.dataSize < 0:
+		return errors.New("-d must not be negative")
+	}
+	return nil
+}
+
+// startServer starts a server on a random local port and returns its
+// address and a function stopping it. The server's log is silenced so
+// that it does not mix with the report, and it saves no snapshots, which
+// would skew the results.
+func startServer() (string, func(), error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+
+	log.SetOutput(io.Discard)
+	cfg := serverconfig.Default()
+	cfg.Bind = "127.0.0.1"
+	cfg.Save = nil
+	srv := server.NewServerWithConfig(cfg)
+	go srv.Serve(listener)
+
+	return listener.Addr().String(), func() { srv.Stop() }, nil
+}
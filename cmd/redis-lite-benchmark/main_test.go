@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var stdout, stderr strings.Builder
+	status := run([]string{"--in-process", "-c", "2", "-n", "50", "-t", "set,get", "--csv"}, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("Expected status 0, got %d (stderr %q)", status, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %q", lines)
+	}
+	if lines[0] != csvHeader {
+		t.Errorf("Expected CSV header, got %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `"SET",`) || !strings.HasPrefix(lines[2], `"GET",`) {
+		t.Errorf("Expected SET and GET rows, got %q", lines[1:])
+	}
+}
+
+func TestRun_InvalidOptions(t *testing.T) {
+	tests := [][]string{
+		{"-c", "0"},
+		{"-n", "-1"},
+		{"-P", "0"},
+		{"-t", "nosuchtest"},
+	}
+
+	for _, args := range tests {
+		var stdout, stderr strings.Builder
+		if status := run(args, &stdout, &stderr); status != 2 {
+			t.Errorf("Expected status 2 for %v, got %d", args, status)
+		}
+		if !strings.HasPrefix(stderr.String(), "Invalid options:") {
+			t.Errorf("Expected invalid options message for %v, got %q", args, stderr.String())
+		}
+	}
+}
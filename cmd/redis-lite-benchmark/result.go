@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// reportPercentiles are the latency percentiles of the detailed report
+var reportPercentiles = []float64{50, 75, 90, 95, 99, 99.9, 100}
+
+// result holds the measurements of a test
+type result struct {
+	name    string
+	elapsed time.Duration
+	// latencies is sorted in increasing order
+	latencies  []time.Duration
+	errors     int
+	firstError string
+}
+
+// requestsPerSecond returns the throughput of the test
+func (r *result) requestsPerSecond() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+	return float64(len(r.latencies)) / r.elapsed.Seconds()
+}
+
+// percentile returns the latency below which p percent of the requests
+// completed, using the nearest-rank method
+func (r *result) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(r.latencies))))
+	rank = min(max(rank, 1), len(r.latencies))
+	return r.latencies[rank-1]
+}
+
+// average returns the mean latency
+func (r *result) average() time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, latency := range r.latencies {
+		total += latency
+	}
+	return total / time.Duration(len(r.latencies))
+}
+
+// min returns the lowest latency
+func (r *result) min() time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	return r.latencies[0]
+}
+
+// milliseconds renders a latency in milliseconds
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
+
+// writeReport writes the detailed report of a test in the layout of
+// redis-benchmark
+func writeReport(w io.Writer, cfg config, r *result) {
+	fmt.Fprintf(w, "====== %s ======\n", r.name)
+	fmt.Fprintf(w, "  %d requests completed in %.2f seconds\n", len(r.latencies), r.elapsed.Seconds())
+	fmt.Fprintf(w, "  %d parallel clients\n", cfg.clients)
+	fmt.Fprintf(w, "  %d bytes payload\n", cfg.dataSize)
+	fmt.Fprintf(w, "  pipeline: %d\n", cfg.pipeline)
+	if r.errors > 0 {
+		fmt.Fprintf(w, "  %d errors, first: %s\n", r.errors, r.firstError)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Latency by percentile distribution:")
+	for _, p := range reportPercentiles {
+		fmt.Fprintf(w, "%.3f%% <= %s milliseconds\n", p, milliseconds(r.percentile(p)))
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "  throughput summary: %.2f requests per second\n", r.requestsPerSecond())
+	fmt.Fprintln(w, "  latency summary (msec):")
+	fmt.Fprintf(w, "  %9s %9s %9s %9s %9s %9s\n", "avg", "min", "p50", "p95", "p99", "max")
+	fmt.Fprintf(w, "  %9s %9s %9s %9s %9s %9s\n\n",
+		milliseconds(r.average()), milliseconds(r.min()), milliseconds(r.percentile(50)),
+		milliseconds(r.percentile(95)), milliseconds(r.percentile(99)), milliseconds(r.percentile(100)))
+}
+
+// writeQuiet writes a one-line summary of a test
+func writeQuiet(w io.Writer, r *result) {
+	fmt.Fprintf(w, "%s: %.2f requests per second, p50=%s msec\n",
+		r.name, r.requestsPerSecond(), milliseconds(r.percentile(50)))
+}
+
+// csvHeader is the first line of the CSV output
+const csvHeader = `"test","rps","avg_latency_ms","min_latency_ms","p50_latency_ms","p95_latency_ms","p99_latency_ms","max_latency_ms","errors"`
+
+// writeCSV writes the results of a test as a CSV line
+func writeCSV(w io.Writer, r *result) {
+	fmt.Fprintf(w, "%s,\"%.2f\",\"%s\",\"%s\",\"%s\",\"%s\",\"%s\",\"%s\",\"%d\"\n",
+		csvQuote(r.name), r.requestsPerSecond(),
+		milliseconds(r.average()), milliseconds(r.min()), milliseconds(r.percentile(50)),
+		milliseconds(r.percentile(95)), milliseconds(r.percentile(99)), milliseconds(r.percentile(100)),
+		r.errors)
+}
+
+// csvQuote quotes a CSV field, doubling the quotes it contains
+func csvQuote(field string) string {
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
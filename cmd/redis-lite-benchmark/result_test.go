@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResult_Percentile(t *testing.T) {
+	r := &result{}
+	for i := 1; i <= 100; i++ {
+		r.latencies = append(r.latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	tests := []struct {
+		percentile float64
+		expected   time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{95, 95 * time.Millisecond},
+		{99.9, 100 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := r.percentile(tt.percentile); got != tt.expected {
+			t.Errorf("Expected p%v %v, got %v", tt.percentile, tt.expected, got)
+		}
+	}
+
+	if got := r.average(); got != 50500*time.Microsecond {
+		t.Errorf("Expected average 50.5ms, got %v", got)
+	}
+	if got := r.min(); got != time.Millisecond {
+		t.Errorf("Expected min 1ms, got %v", got)
+	}
+}
+
+func TestResult_Empty(t *testing.T) {
+	r := &result{}
+	if r.percentile(50) != 0 || r.average() != 0 || r.min() != 0 || r.requestsPerSecond() != 0 {
+		t.Error("Expected zero statistics without requests")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := &result{
+		name:      `SET "a"`,
+		elapsed:   time.Second,
+		latencies: []time.Duration{time.Millisecond, 3 * time.Millisecond},
+		errors:    1,
+	}
+
+	var out strings.Builder
+	writeCSV(&out, r)
+
+	expected := `"SET ""a""","2.00","2.000","1.000","1.000","3.000","3.000","3.000","1"` + "\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestWriteQuiet(t *testing.T) {
+	r := &result{name: "GET", elapsed: time.Second / 2, latencies: []time.Duration{time.Millisecond}}
+
+	var out strings.Builder
+	writeQuiet(&out, r)
+
+	expected := "GET: 2.00 requests per second, p50=1.000 msec\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// randPlaceholder is replaced in command arguments by a random number
+// below the keyspace length given with -r
+const randPlaceholder = "__rand_int__"
+
+// randDigits is the width of the numbers replacing randPlaceholder, so
+// that every request has the same size
+const randDigits = 12
+
+// commandTemplate is a command whose requests can be rendered quickly,
+// with randPlaceholder replaced by a new number in each request
+type commandTemplate struct {
+	// args holds each argument split around its placeholders
+	args [][]string
+	// inline sends the command as an inline command rather than an array
+	inline   bool
+	keyspace int
+}
+
+// newCommandTemplate prepares a command. With a keyspace of 0 the
+// placeholders are sent literally, as redis-benchmark does.
+func newCommandTemplate(args []string, inline bool, keyspace int) *commandTemplate {
+	t := &commandTemplate{args: make([][]string, len(args)), inline: inline, keyspace: keyspace}
+	for i, arg := range args {
+		if keyspace > 0 {
+			t.args[i] = strings.Split(arg, randPlaceholder)
+		} else {
+			t.args[i] = []string{arg}
+		}
+	}
+	return t
+}
+
+// append renders a request at the end of buf
+func (t *commandTemplate) append(buf []byte, random *rand.Rand) []byte {
+	if !t.inline {
+		buf = append(buf, '*')
+		buf = strconv.AppendInt(buf, int64(len(t.args)), 10)
+		buf = append(buf, "\r\n"...)
+	}
+
+	for i, parts := range t.args {
+		switch {
+		case !t.inline:
+			length := randDigits * (len(parts) - 1)
+			for _, part := range parts {
+				length += len(part)
+			}
+			buf = append(buf, '$')
+			buf = strconv.AppendInt(buf, int64(length), 10)
+			buf = append(buf, "\r\n"...)
+		case i > 0:
+			buf = append(buf, ' ')
+		}
+
+		for j, part := range parts {
+			if j > 0 {
+				buf = appendPadded(buf, random.IntN(t.keyspace))
+			}
+			buf = append(buf, part...)
+		}
+
+		if !t.inline {
+			buf = append(buf, "\r\n"...)
+		}
+	}
+
+	if t.inline {
+		buf = append(buf, "\r\n"...)
+	}
+	return buf
+}
+
+// appendPadded appends a number padded with zeros to randDigits digits
+func appendPadded(buf []byte, n int) []byte {
+	digits := strconv.Itoa(n)
+	for i := len(digits); i < randDigits; i++ {
+		buf = append(buf, '0')
+	}
+	return append(buf, digits...)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"testing"
+)
+
+func TestCommandTemplate_Append(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		inline   bool
+		keyspace int
+		expected string
+	}{
+		{"array", []string{"SET", "key", "xyz"}, false, 0, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$3\r\nxyz\r\n"},
+		{"inline", []string{"PING"}, true, 0, "PING\r\n"},
+		{"inline with arguments", []string{"ECHO", "hi"}, true, 0, "ECHO hi\r\n"},
+		{"literal placeholder", []string{"GET", "key:__rand_int__"}, false, 0, "*2\r\n$3\r\nGET\r\n$16\r\nkey:__rand_int__\r\n"},
+		{"random key", []string{"GET", "key:__rand_int__"}, false, 1, "*2\r\n$3\r\nGET\r\n$16\r\nkey:000000000000\r\n"},
+		{"several placeholders", []string{"SET", "__rand_int__-__rand_int__"}, false, 1, "*2\r\n$3\r\nSET\r\n$25\r\n000000000000-000000000000\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := newCommandTemplate(tt.args, tt.inline, tt.keyspace)
+			got := string(template.append(nil, rand.New(rand.NewPCG(1, 2))))
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCommandTemplate_Keyspace(t *testing.T) {
+	template := newCommandTemplate([]string{"GET", "key:__rand_int__"}, false, 100)
+	random := rand.New(rand.NewPCG(1, 2))
+	pattern := regexp.MustCompile(`^\*2\r\n\$3\r\nGET\r\n\$16\r\nkey:0000000000\d\d\r\n$`)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		request := string(template.append(nil, random))
+		if !pattern.MatchString(request) {
+			t.Fatalf("Expected a key below 100, got %q", request)
+		}
+		seen[request] = true
+	}
+	if len(seen) < 50 {
+		t.Errorf("Expected keys spread over the keyspace, got %d distinct", len(seen))
+	}
+}
@@ -0,0 +1,133 @@
+// Command redis-lite-check-aof checks an append-only file without starting
+// a server, in the style of redis-check-aof, and reports the first invalid
+// data found in it.
+//
+// Usage:
+//
+//	redis-lite-check-aof [--fix] <file.aof|file.manifest>
+//
+// Given the manifest of a multi-part append-only file, it checks the files
+// it lists in order, stopping at the first invalid one. --fix truncates an
+// invalid file to its last valid command, as aof-load-truncated does at
+// startup; of a multi-part file, only the last file can be fixed, since
+// the commands of the files that follow would be replayed out of order.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run checks the file and returns the exit status: 0 if it is valid or was
+// fixed, 1 otherwise
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("redis-lite-check-aof", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: redis-lite-check-aof [--fix] <file.aof|file.manifest>")
+		flags.PrintDefaults()
+	}
+	fix := flags.Bool("fix", false, "truncate an invalid file to its last valid command")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	files := []string{path}
+	if strings.HasSuffix(path, ".manifest") {
+		var err error
+		if files, err = manifestFiles(path); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	for i, file := range files {
+		check, err := persistence.CheckAOF(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		name := filepath.Base(file)
+		report(stdout, name, check)
+		if check.Err == nil {
+			continue
+		}
+
+		switch {
+		case !check.Fixable():
+			fmt.Fprintln(stdout, "The RDB preamble is invalid and cannot be fixed")
+		case i < len(files)-1:
+			fmt.Fprintln(stdout, "Only the last file of a multi-part AOF can be fixed")
+		case !*fix && check.Truncated():
+			fmt.Fprintln(stdout, "The file ends with an incomplete command, run with --fix to truncate it")
+		case !*fix:
+			fmt.Fprintln(stdout, "Run with --fix to truncate the file, discarding the commands after the invalid data")
+		default:
+			if err := os.Truncate(file, check.Valid); err != nil {
+				fmt.Fprintf(stderr, "Error: truncating %s: %v\n", name, err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "Successfully truncated %s to %d bytes\n", name, check.Valid)
+			continue
+		}
+		fmt.Fprintln(stdout, "AOF is not valid")
+		return 1
+	}
+	fmt.Fprintln(stdout, "AOF is valid")
+	return 0
+}
+
+// manifestFiles returns the paths of the files listed by the manifest at
+// path, in the order they are loaded
+func manifestFiles(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	manifest, err := persistence.ReadAOFManifest(file)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range manifest.Files() {
+		files = append(files, filepath.Join(filepath.Dir(path), f.Name))
+	}
+	return files, nil
+}
+
+// report writes the result of checking a file to w: what it holds, then
+// where the first invalid data is, if any
+func report(w io.Writer, name string, check persistence.AOFCheck) {
+	description := fmt.Sprintf("%d bytes, %d commands", check.Size, check.Commands)
+	if check.RDB {
+		description = fmt.Sprintf("RDB preamble with %d keys, %s", check.RDBKeys, description)
+	}
+	if check.Err == nil {
+		fmt.Fprintf(w, "%s: %s: OK\n", name, description)
+		return
+	}
+
+	fmt.Fprintf(w, "%s: %s\n", name, description)
+	fmt.Fprintf(w, "%s: %v\n", name, check.Err)
+	if check.Fixable() {
+		fmt.Fprintf(w, "AOF analyzed: size=%d, ok_up_to=%d, diff=%d\n", check.Size, check.Valid, check.Size-check.Valid)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	setCommand = "*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n"
+	delCommand = "*2\r\n$3\r\nDEL\r\n$1\r\na\r\n"
+)
+
+// writeFile writes a file to a temporary directory and returns its path
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		fix    bool
+		status int
+		output string
+		size   int
+	}{
+		{"valid", setCommand + delCommand, false, 0, "appendonly.aof: 47 bytes, 2 commands: OK", 47},
+		{"truncated", setCommand + delCommand[:10], false, 1, "ok_up_to=27, diff=10", 37},
+		{"fixed", setCommand + delCommand[:10], true, 0, "Successfully truncated appendonly.aof to 27 bytes", 27},
+		{"garbage", "garbage\r\n" + setCommand, false, 1, "corrupt data at offset 0", 36},
+		{"garbage fixed", setCommand + "garbage\r\n" + setCommand, true, 0, "truncated appendonly.aof to 27 bytes", 27},
+		{"huge bulk length", setCommand + "*1\r\n$999999999999999999\r\n", false, 1, "corrupt data at offset 27", 52},
+		{"huge multibulk length", setCommand + "*80000000000\r\n", false, 1, "ok_up_to=27, diff=14", 41},
+		{"invalid rdb", "REDIS0011\xfe", true, 1, "cannot be fixed", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "appendonly.aof", tt.data)
+			args := []string{path}
+			if tt.fix {
+				args = []string{"--fix", path}
+			}
+
+			var stdout, stderr strings.Builder
+			if status := run(args, &stdout, &stderr); status != tt.status {
+				t.Errorf("Expected status %d, got %d (stdout %q, stderr %q)", tt.status, status, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.output) {
+				t.Errorf("Expected output containing %q, got %q", tt.output, stdout.String())
+			}
+			if info, _ := os.Stat(path); info.Size() != int64(tt.size) {
+				t.Errorf("Expected the file to be %d bytes, got %d", tt.size, info.Size())
+			}
+		})
+	}
+}
+
+func TestRun_Manifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "appendonly.aof.1.base.aof", setCommand)
+	writeFile(t, dir, "appendonly.aof.1.incr.aof", delCommand[:10])
+	writeFile(t, dir, "appendonly.aof.2.incr.aof", setCommand+delCommand[:10])
+	manifest := writeFile(t, dir, "appendonly.aof.manifest",
+		"file appendonly.aof.1.base.aof seq 1 type b\n"+
+			"file appendonly.aof.1.incr.aof seq 1 type i\n"+
+			"file appendonly.aof.2.incr.aof seq 2 type i\n")
+
+	// Only the last file can be fixed
+	var stdout, stderr strings.Builder
+	if status := run([]string{"--fix", manifest}, &stdout, &stderr); status != 1 {
+		t.Errorf("Expected status 1, got %d", status)
+	}
+	if !strings.Contains(stdout.String(), "Only the last file") {
+		t.Errorf("Expected the first incremental file not to be fixed, got %q", stdout.String())
+	}
+
+	writeFile(t, dir, "appendonly.aof.1.incr.aof", delCommand)
+	stdout.Reset()
+	if status := run([]string{"--fix", manifest}, &stdout, &stderr); status != 0 {
+		t.Errorf("Expected status 0, got %d (stdout %q)", status, stdout.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "appendonly.aof.1.base.aof:") || lines[len(lines)-1] != "AOF is valid" {
+		t.Errorf("Expected each file to be checked, got %q", lines)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "appendonly.aof.2.incr.aof")); info.Size() != int64(len(setCommand)) {
+		t.Errorf("Expected the last file to be truncated, got %d bytes", info.Size())
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"a", "b"},
+		{"--nosuchflag", "a"},
+	}
+
+	for _, args := range tests {
+		var stdout, stderr strings.Builder
+		if status := run(args, &stdout, &stderr); status != 2 {
+			t.Errorf("Expected status 2 for %v, got %d", args, status)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	if status := run([]string{filepath.Join(t.TempDir(), "missing.aof")}, &stdout, &stderr); status != 1 {
+		t.Errorf("Expected status 1 for a missing file, got %d", status)
+	}
+	if !strings.HasPrefix(stderr.String(), "Error:") {
+		t.Errorf("Expected an error message, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+)
+
+// jsonEntry is a key of a snapshot as written by --json. Expiry times are
+// in Unix milliseconds. Streams and module values have no value, since
+// their contents are not decoded.
+type jsonEntry struct {
+	DB        int                   `json:"db"`
+	Key       persistence.JSONBytes `json:"key"`
+	Type      string                `json:"type"`
+	ExpiresAt int64                 `json:"expires_at,omitempty"`
+	Idle      *int64                `json:"idle,omitempty"`
+	Freq      *int                  `json:"freq,omitempty"`
+	Value     any                   `json:"value,omitempty"`
+}
+
+// jsonField is a field of a hash
+type jsonField struct {
+	Field     persistence.JSONBytes `json:"field"`
+	Value     persistence.JSONBytes `json:"value"`
+	ExpiresAt int64                 `json:"expires_at,omitempty"`
+}
+
+// jsonMember is a member of a sorted set
+type jsonMember struct {
+	Member persistence.JSONBytes `json:"member"`
+	Score  jsonScore             `json:"score"`
+}
+
+// jsonScore is a sorted set score, written as a number when it is finite
+// and as "inf", "-inf" or "nan" otherwise, which JSON cannot represent
+type jsonScore float64
+
+func (s jsonScore) MarshalJSON() ([]byte, error) {
+	f := float64(s)
+	switch {
+	case math.IsInf(f, 1):
+		return []byte(`"inf"`), nil
+	case math.IsInf(f, -1):
+		return []byte(`"-inf"`), nil
+	case math.IsNaN(f):
+		return []byte(`"nan"`), nil
+	default:
+		return strconv.AppendFloat(nil, f, 'g', -1, 64), nil
+	}
+}
+
+// newJSONEntry converts an entry read from a snapshot
+func newJSONEntry(entry *persistence.Entry) jsonEntry {
+	out := jsonEntry{DB: entry.DB, Key: persistence.JSONBytes(entry.Key), Type: entry.Type.String()}
+	if !entry.ExpiresAt.IsZero() {
+		out.ExpiresAt = entry.ExpiresAt.UnixMilli()
+	}
+	if entry.Idle >= 0 {
+		out.Idle = &entry.Idle
+	}
+	if entry.Freq >= 0 {
+		out.Freq = &entry.Freq
+	}
+
+	switch entry.Type {
+	case persistence.TypeString:
+		out.Value = persistence.JSONBytes(entry.String)
+	case persistence.TypeList, persistence.TypeSet:
+		elements := make([]persistence.JSONBytes, len(entry.List))
+		for i, element := range entry.List {
+			elements[i] = element
+		}
+		out.Value = elements
+	case persistence.TypeHash:
+		fields := make([]jsonField, len(entry.Hash))
+		for i, field := range entry.Hash {
+			fields[i] = jsonField{Field: field.Field, Value: field.Value}
+			if !field.ExpiresAt.IsZero() {
+				fields[i].ExpiresAt = field.ExpiresAt.UnixMilli()
+			}
+		}
+		out.Value = fields
+	case persistence.TypeZSet:
+		members := make([]jsonMember, len(entry.ZSet))
+		for i, member := range entry.ZSet {
+			members[i] = jsonMember{Member: member.Member, Score: jsonScore(member.Score)}
+		}
+		out.Value = members
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+// Command redis-lite-check-rdb checks an RDB snapshot without starting a
+// server, in the style of redis-check-rdb, and reports the first invalid
+// data found in it.
+//
+// Usage:
+//
+//	redis-lite-check-rdb [--json] <dump.rdb>
+//
+// --json writes the keys of the snapshot to standard output as JSON lines,
+// one object per key, for inspection with tools such as jq; the report is
+// then written to standard error.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run checks the file and returns the exit status: 0 if it is valid, 1
+// otherwise
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("redis-lite-check-rdb", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: redis-lite-check-rdb [--json] <dump.rdb>")
+		flags.PrintDefaults()
+	}
+	dump := flags.Bool("json", false, "write the keys to standard output as JSON lines, and the report to standard error")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	report := stdout
+	out := bufio.NewWriter(stdout)
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	if *dump {
+		report = stderr
+	}
+
+	types := make(map[persistence.Type]int)
+	rdb, err := persistence.ReadRDB(file, func(entry *persistence.Entry) error {
+		types[entry.Type]++
+		if *dump {
+			return encoder.Encode(newJSONEntry(entry))
+		}
+		return nil
+	})
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+
+	name := filepath.Base(path)
+	if rdb.Version > 0 {
+		fmt.Fprintf(report, "%s: RDB version %d, %d bytes\n", name, rdb.Version, info.Size())
+	}
+	for _, aux := range rdb.Aux {
+		fmt.Fprintf(report, "%s: %s\n", aux.Key, aux.Value)
+	}
+	fmt.Fprintf(report, "%d keys%s\n", rdb.Keys, countTypes(types))
+	if err != nil {
+		fmt.Fprintf(report, "%s: %v\n", name, err)
+		fmt.Fprintln(report, "RDB is not valid")
+		return 1
+	}
+
+	if rdb.Checksum == 0 {
+		fmt.Fprintln(report, "Checksum: none")
+	} else {
+		fmt.Fprintf(report, "Checksum: %016x\n", rdb.Checksum)
+	}
+	fmt.Fprintln(report, "RDB is valid")
+	return 0
+}
+
+// countTypes describes the number of keys of each type, such as ": 2
+// hash, 8 string", or returns "" if there are none
+func countTypes(types map[persistence.Type]int) string {
+	var parts []string
+	for valueType, count := range types {
+		parts = append(parts, fmt.Sprintf("%d %s", count, valueType))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	sort.Strings(parts)
+	return ": " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+)
+
+// writeRDB writes a snapshot of the given entries to a temporary file and
+// returns its path and contents
+func writeRDB(t *testing.T, entries ...*persistence.Entry) (string, []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := persistence.NewRDBWriter(&buf, persistence.RDBOptions{Checksum: true})
+	w.WriteHeader(persistence.AuxField{Key: "redis-ver", Value: "7.2.0"})
+	w.SelectDB(0)
+	for _, entry := range entries {
+		w.WriteEntry(entry)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	os.WriteFile(path, buf.Bytes(), 0644)
+	return path, buf.Bytes()
+}
+
+func TestRun(t *testing.T) {
+	path, _ := writeRDB(t,
+		&persistence.Entry{Key: "a", Type: persistence.TypeString, String: []byte("1")},
+		&persistence.Entry{Key: "b", Type: persistence.TypeString, String: []byte("2")},
+		&persistence.Entry{Key: "list", Type: persistence.TypeList, List: [][]byte{[]byte("x")}},
+	)
+
+	var stdout, stderr strings.Builder
+	if status := run([]string{path}, &stdout, &stderr); status != 0 {
+		t.Fatalf("Expected status 0, got %d (stdout %q, stderr %q)", status, stdout.String(), stderr.String())
+	}
+	for _, line := range []string{"redis-ver: 7.2.0", "3 keys: 1 list, 2 string", "RDB is valid"} {
+		if !strings.Contains(stdout.String(), line+"\n") {
+			t.Errorf("Expected output containing %q, got %q", line, stdout.String())
+		}
+	}
+}
+
+func TestRun_Corrupt(t *testing.T) {
+	path, data := writeRDB(t, &persistence.Entry{Key: "a", Type: persistence.TypeString, String: []byte("1")})
+
+	tests := []struct {
+		name   string
+		data   []byte
+		output string
+	}{
+		{"truncated", data[:len(data)-3], "unexpected EOF"},
+		{"checksum", append(bytes.Clone(data[:len(data)-1]), data[len(data)-1]^1), "checksum"},
+		{"not rdb", []byte("*1\r\n$4\r\nPING\r\n"), "not an RDB file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(path, tt.data, 0644)
+
+			var stdout, stderr strings.Builder
+			if status := run([]string{path}, &stdout, &stderr); status != 1 {
+				t.Errorf("Expected status 1, got %d", status)
+			}
+			if !strings.Contains(stdout.String(), tt.output) || !strings.HasSuffix(stdout.String(), "RDB is not valid\n") {
+				t.Errorf("Expected output reporting %q, got %q", tt.output, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_JSON(t *testing.T) {
+	expiresAt := time.UnixMilli(4102444800000)
+	path, _ := writeRDB(t,
+		&persistence.Entry{Key: "s", Type: persistence.TypeString, String: []byte("value"), ExpiresAt: expiresAt, Idle: 5, Freq: -1},
+		&persistence.Entry{Key: "\xff", Type: persistence.TypeString, String: []byte{}, Idle: -1, Freq: -1},
+		&persistence.Entry{Key: "h", Type: persistence.TypeHash, Hash: []persistence.HashField{{Field: []byte("f"), Value: []byte("v")}}, Idle: -1, Freq: 3},
+		&persistence.Entry{Key: "z", Type: persistence.TypeZSet, ZSet: []persistence.ZSetMember{{Member: []byte("m"), Score: math.Inf(1)}, {Member: []byte("n"), Score: 1.5}}, Idle: -1, Freq: -1},
+	)
+
+	var stdout, stderr strings.Builder
+	if status := run([]string{"--json", path}, &stdout, &stderr); status != 0 {
+		t.Fatalf("Expected status 0, got %d (stderr %q)", status, stderr.String())
+	}
+
+	expected := []string{
+		`{"db":0,"key":"s","type":"string","expires_at":4102444800000,"idle":5,"value":"value"}`,
+		`{"db":0,"key":{"base64":"/w=="},"type":"string","value":""}`,
+		`{"db":0,"key":"h","type":"hash","freq":3,"value":[{"field":"f","value":"v"}]}`,
+		`{"db":0,"key":"z","type":"zset","value":[{"member":"m","score":"inf"},{"member":"n","score":1.5}]}`,
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		if !json.Valid([]byte(line)) || line != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], line)
+		}
+	}
+	if !strings.HasSuffix(stderr.String(), "RDB is valid\n") {
+		t.Errorf("Expected the report on standard error, got %q", stderr.String())
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"a", "b"},
+		{"--fix", "a"},
+	}
+
+	for _, args := range tests {
+		var stdout, stderr strings.Builder
+		if status := run(args, &stdout, &stderr); status != 2 {
+			t.Errorf("Expected status 2 for %v, got %d", args, status)
+		}
+	}
+}
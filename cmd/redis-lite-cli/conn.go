@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// replyLimits lifts the parser limits meant to protect the server, since
+// replies can legitimately be larger than any request
+var replyLimits = resp.Limits{
+	MaxBulkLength:      math.MaxInt32,
+	MaxMultiBulkLength: math.MaxInt32,
+	MaxDepth:           resp.DefaultMaxDepth,
+	MaxInlineSize:      math.MaxInt32,
+}
+
+// connOptions describes how to connect and authenticate
+type connOptions struct {
+	host     string
+	port     int
+	user     string
+	password string
+	// resp3 negotiates RESP3 with HELLO 3
+	resp3   bool
+	timeout time.Duration
+}
+
+// address returns the host:port address of the server
+func (o connOptions) address() string {
+	return net.JoinHostPort(o.host, strconv.Itoa(o.port))
+}
+
+// cliConn is a connection to the server that returns replies as messages,
+// errors included, so that they can be displayed
+type cliConn struct {
+	netConn    net.Conn
+	parser     *resp.Parser
+	serializer *resp.Serializer
+}
+
+// dial connects and authenticates. Errors replied by the handshake are
+// returned as Go errors.
+func dial(options connOptions) (*cliConn, error) {
+	netConn, err := net.DialTimeout("tcp", options.address(), options.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := resp.NewParser(netConn)
+	parser.SetLimits(replyLimits)
+	conn := &cliConn{
+		netConn:    netConn,
+		parser:     parser,
+		serializer: resp.NewSerializer(netConn),
+	}
+
+	var handshake []string
+	switch {
+	case options.resp3:
+		handshake = []string{"HELLO", "3"}
+		if options.password != "" {
+			user := options.user
+			if user == "" {
+				user = "default"
+			}
+			handshake = append(handshake, "AUTH", user, options.password)
+		}
+	case options.password != "" && options.user != "":
+		handshake = []string{"AUTH", options.user, options.password}
+	case options.password != "":
+		handshake = []string{"AUTH", options.password}
+	}
+
+	if handshake != nil {
+		reply, err := conn.do(handshake)
+		if err != nil {
+			conn.close()
+			return nil, err
+		}
+		if reply.Type == resp.Error {
+			conn.close()
+			return nil, fmt.Errorf("%s", reply.Value)
+		}
+	}
+
+	return conn, nil
+}
+
+// do sends a command and returns its reply
+func (c *cliConn) do(args []string) (*resp.Message, error) {
+	if err := c.send(args); err != nil {
+		return nil, err
+	}
+	return c.receive()
+}
+
+// send writes a command without waiting for the reply
+func (c *cliConn) send(args []string) error {
+	elements := make([]*resp.Message, len(args))
+	for i, arg := range args {
+		elements[i] = resp.NewBulkString(arg)
+	}
+
+	if err := c.serializer.Serialize(resp.NewArray(elements)); err != nil {
+		return err
+	}
+	return c.serializer.Flush()
+}
+
+// receive reads the next reply or pushed message
+func (c *cliConn) receive() (*resp.Message, error) {
+	return c.parser.Parse()
+}
+
+// close closes the connection
+func (c *cliConn) close() error {
+	return c.netConn.Close()
+}
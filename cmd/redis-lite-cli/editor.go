@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// Control keys
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyBackspace = 8
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// editor reads lines from a terminal in raw mode, with cursor movement and
+// history navigation in the style of readline
+type editor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *history
+}
+
+// newEditor creates an editor reading key presses from in
+func newEditor(in io.Reader, out io.Writer, history *history) *editor {
+	return &editor{in: bufio.NewReader(in), out: out, history: history}
+}
+
+// lineState is the line being edited
+type lineState struct {
+	prompt string
+	buffer []rune
+	cursor int
+	// historyIndex is the history entry shown, len(history) for the new line
+	historyIndex int
+	// pending keeps the new line while browsing the history
+	pending []rune
+}
+
+// readLine shows the prompt and returns the line entered. It returns
+// io.EOF on Ctrl-D at an empty line and errInterrupted on Ctrl-C.
+func (e *editor) readLine(prompt string) (string, error) {
+	state := &lineState{prompt: prompt, historyIndex: len(e.history.lines)}
+	e.refresh(state)
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(state.buffer), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(state.buffer) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			state.deleteAt(state.cursor)
+		case keyBackspace, keyDelete:
+			if state.cursor > 0 {
+				state.cursor--
+				state.deleteAt(state.cursor)
+			}
+		case keyCtrlA:
+			state.cursor = 0
+		case keyCtrlE:
+			state.cursor = len(state.buffer)
+		case keyCtrlB:
+			state.cursor = max(state.cursor-1, 0)
+		case keyCtrlF:
+			state.cursor = min(state.cursor+1, len(state.buffer))
+		case keyCtrlK:
+			state.buffer = state.buffer[:state.cursor]
+		case keyCtrlU:
+			state.buffer = state.buffer[state.cursor:]
+			state.cursor = 0
+		case keyCtrlW:
+			state.deleteWord()
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlP:
+			e.browse(state, -1)
+		case keyCtrlN:
+			e.browse(state, 1)
+		case keyEscape:
+			e.escape(state)
+		default:
+			if unicode.IsPrint(r) {
+				state.insert(r)
+			}
+		}
+
+		e.refresh(state)
+	}
+}
+
+// escape handles the escape sequences sent by arrow, home, end and delete
+func (e *editor) escape(state *lineState) {
+	kind, err := e.in.ReadByte()
+	if err != nil || (kind != '[' && kind != 'O') {
+		return
+	}
+
+	key, err := e.in.ReadByte()
+	if err != nil {
+		return
+	}
+
+	// Sequences such as "\x1b[3~" carry a number
+	if key >= '0' && key <= '9' {
+		if terminator, err := e.in.ReadByte(); err != nil || terminator != '~' {
+			return
+		}
+		switch key {
+		case '1', '7':
+			key = 'H'
+		case '4', '8':
+			key = 'F'
+		case '3':
+			state.deleteAt(state.cursor)
+			return
+		default:
+			return
+		}
+	}
+
+	switch key {
+	case 'A':
+		e.browse(state, -1)
+	case 'B':
+		e.browse(state, 1)
+	case 'C':
+		state.cursor = min(state.cursor+1, len(state.buffer))
+	case 'D':
+		state.cursor = max(state.cursor-1, 0)
+	case 'H':
+		state.cursor = 0
+	case 'F':
+		state.cursor = len(state.buffer)
+	}
+}
+
+// browse moves through the history, -1 towards older entries
+func (e *editor) browse(state *lineState, direction int) {
+	lines := e.history.lines
+	index := state.historyIndex + direction
+	if index < 0 || index > len(lines) {
+		return
+	}
+
+	if state.historyIndex == len(lines) {
+		state.pending = state.buffer
+	}
+	state.historyIndex = index
+
+	if index == len(lines) {
+		state.buffer = state.pending
+	} else {
+		state.buffer = []rune(lines[index])
+	}
+	state.cursor = len(state.buffer)
+}
+
+// refresh redraws the prompt and the line, and places the cursor
+func (e *editor) refresh(state *lineState) {
+	var out strings.Builder
+	out.WriteString("\r")
+	out.WriteString(state.prompt)
+	out.WriteString(string(state.buffer))
+	out.WriteString("\x1b[0K")
+
+	out.WriteString("\r")
+	if column := len([]rune(state.prompt)) + state.cursor; column > 0 {
+		fmt.Fprintf(&out, "\x1b[%dC", column)
+	}
+
+	io.WriteString(e.out, out.String())
+}
+
+// insert inserts a character at the cursor
+func (s *lineState) insert(r rune) {
+	s.buffer = append(s.buffer, 0)
+	copy(s.buffer[s.cursor+1:], s.buffer[s.cursor:])
+	s.buffer[s.cursor] = r
+	s.cursor++
+}
+
+// deleteAt deletes the character at the index, if any
+func (s *lineState) deleteAt(index int) {
+	if index < len(s.buffer) {
+		s.buffer = append(s.buffer[:index], s.buffer[index+1:]...)
+	}
+}
+
+// deleteWord deletes the word before the cursor and the spaces after it
+func (s *lineState) deleteWord() {
+	start := s.cursor
+	for start > 0 && s.buffer[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && s.buffer[start-1] != ' ' {
+		start--
+	}
+
+	s.buffer = append(s.buffer[:start], s.buffer[s.cursor:]...)
+	s.cursor = start
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEditor_ReadLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     string
+		expected string
+	}{
+		{"plain line", "get foo\r", "get foo"},
+		{"newline", "get foo\n", "get foo"},
+		{"backspace", "gett\x7f foo\r", "get foo"},
+		{"insert after moving left", "gt\x1b[De\r", "get"},
+		{"home and end", "et\x1b[Hg\x1b[F!\r", "get!"},
+		{"control start and end", "et\x01g\x05!\r", "get!"},
+		{"delete under cursor", "gxet\x01\x06\x1b[3~\r", "get"},
+		{"kill to end", "get foo\x01\x06\x06\x06\x0b\r", "get"},
+		{"kill to start", "xx get\x02\x02\x02\x15\x05\r", "get"},
+		{"delete word", "get foo bar\x17\r", "get foo "},
+		{"ctrl-d deletes under cursor", "gext\x02\x02\x04\r", "get"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEditor(strings.NewReader(tt.keys), io.Discard, &history{max: 10})
+			line, err := e.readLine("> ")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if line != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, line)
+			}
+		})
+	}
+}
+
+func TestEditor_History(t *testing.T) {
+	h := &history{lines: []string{"first", "second"}, max: 10}
+
+	tests := []struct {
+		name     string
+		keys     string
+		expected string
+	}{
+		{"previous", "\x1b[A\r", "second"},
+		{"oldest", "\x1b[A\x1b[A\x1b[A\r", "first"},
+		{"back to the new line", "new\x1b[A\x1b[B\r", "new"},
+		{"edit an entry", "\x10!\r", "second!"},
+		{"next after previous", "\x10\x10\x0e\r", "second"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEditor(strings.NewReader(tt.keys), io.Discard, h)
+			line, err := e.readLine("> ")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if line != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, line)
+			}
+		})
+	}
+}
+
+func TestEditor_Interrupts(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     string
+		expected error
+	}{
+		{"ctrl-c", "get\x03", errInterrupted},
+		{"ctrl-d on empty line", "\x04", io.EOF},
+		{"end of input", "get", io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEditor(strings.NewReader(tt.keys), io.Discard, &history{max: 10})
+			if _, err := e.readLine("> "); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestEditor_Refresh(t *testing.T) {
+	var out strings.Builder
+	e := newEditor(strings.NewReader("ab\x02\r"), &out, &history{max: 10})
+	if _, err := e.readLine("> "); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The last redraw shows the whole line with the cursor on "b"
+	expected := "\r> ab\x1b[0K\r\x1b[3C\r\n"
+	if got := out.String(); !strings.HasSuffix(got, expected) {
+		t.Errorf("Expected output to end with %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// runExport writes the keys matching pattern, or every key, to stdout as
+// JSON lines, with EXPORT
+func runExport(conn *cliConn, pattern string, stdout, stderr io.Writer) int {
+	args := []string{"EXPORT"}
+	if pattern != "" {
+		args = append(args, "MATCH", pattern)
+	}
+
+	reply, err := conn.do(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if reply.Type == resp.Error {
+		fmt.Fprintf(stderr, "(error) %s\n", reply.Value)
+		return 1
+	}
+	data, ok := reply.Value.([]byte)
+	if reply.Type != resp.BulkString || !ok {
+		fmt.Fprintf(stderr, "Error: unexpected EXPORT reply %s\n", reply)
+		return 1
+	}
+	stdout.Write(data)
+	return 0
+}
+
+// runImport imports the JSON lines read from in with IMPORT, keeping only
+// the keys matching pattern if set, and replacing the existing keys that
+// match it rather than merging into them if replace is set
+func runImport(conn *cliConn, in io.Reader, replace bool, pattern string, stdout, stderr io.Writer) int {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	args := []string{"IMPORT", string(data)}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	if pattern != "" {
+		args = append(args, "MATCH", pattern)
+	}
+
+	reply, err := conn.do(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if reply.Type == resp.Error {
+		fmt.Fprintf(stderr, "(error) %s\n", reply.Value)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Imported %d keys\n", reply.Value)
+	return 0
+}
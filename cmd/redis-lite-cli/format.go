@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// formatReply renders a reply for display. Formatted output follows
+// redis-cli in a terminal: strings are quoted, types are annotated and
+// nested aggregates are numbered and indented. Raw output, used when the
+// output is not a terminal, prints the bare values one per line.
+func formatReply(message *resp.Message, raw bool) string {
+	if raw {
+		return formatRaw(message) + "\n"
+	}
+	return formatTTY(message, "")
+}
+
+// formatTTY renders a reply the way redis-cli does in a terminal. prefix is
+// the indentation of the lines following the first one. The result ends
+// with a newline.
+func formatTTY(message *resp.Message, prefix string) string {
+	switch message.Type {
+	case resp.Error, resp.BlobError:
+		text, _ := message.AsString()
+		return "(error) " + text + "\n"
+	case resp.SimpleString:
+		return message.Value.(string) + "\n"
+	case resp.Integer:
+		return "(integer) " + strconv.FormatInt(message.Value.(int64), 10) + "\n"
+	case resp.Double:
+		return "(double) " + formatDouble(message.Value.(float64)) + "\n"
+	case resp.BigNumber:
+		return "(big number) " + message.Value.(string) + "\n"
+	case resp.Boolean:
+		if message.Value.(bool) {
+			return "(true)\n"
+		}
+		return "(false)\n"
+	case resp.VerbatimString:
+		return message.Value.(resp.Verbatim).Text + "\n"
+	case resp.BulkString:
+		if message.IsNull() {
+			return "(nil)\n"
+		}
+		return quote(message.Value.([]byte)) + "\n"
+	case resp.Null:
+		return "(nil)\n"
+	case resp.Array, resp.Set, resp.Push, resp.Map, resp.Attribute:
+		if message.IsNull() {
+			return "(nil)\n"
+		}
+		return formatAggregate(message, prefix)
+	default:
+		return fmt.Sprintf("(unknown reply type %s)\n", message.Type)
+	}
+}
+
+// emptyAggregates holds the text printed for empty aggregates of each type
+var emptyAggregates = map[resp.MessageType]string{
+	resp.Array:     "(empty array)\n",
+	resp.Set:       "(empty set)\n",
+	resp.Push:      "(empty push)\n",
+	resp.Map:       "(empty hash)\n",
+	resp.Attribute: "(empty hash)\n",
+}
+
+// formatAggregate numbers the entries of an aggregate as "1) ", or "1~ "
+// for sets and "1# key => value" for maps. Nested entries are indented
+// past the number of their parent.
+func formatAggregate(message *resp.Message, prefix string) string {
+	elements := message.Value.([]*resp.Message)
+	if len(elements) == 0 {
+		return emptyAggregates[message.Type]
+	}
+
+	isMap := message.Type == resp.Map || message.Type == resp.Attribute
+	entries := len(elements)
+	if isMap {
+		entries /= 2
+	}
+
+	separator := ')'
+	switch {
+	case isMap:
+		separator = '#'
+	case message.Type == resp.Set:
+		separator = '~'
+	}
+
+	width := len(strconv.Itoa(entries))
+	childPrefix := prefix + strings.Repeat(" ", width+2)
+
+	var out strings.Builder
+	for i := 0; i < entries; i++ {
+		// The parent has already written the prefix of the first line
+		if i > 0 {
+			out.WriteString(prefix)
+		}
+		fmt.Fprintf(&out, "%*d%c ", width, i+1, separator)
+
+		if !isMap {
+			out.WriteString(formatTTY(elements[i], childPrefix))
+			continue
+		}
+
+		key := formatTTY(elements[2*i], childPrefix)
+		out.WriteString(strings.TrimSuffix(key, "\n"))
+		out.WriteString(" => ")
+		out.WriteString(formatTTY(elements[2*i+1], childPrefix))
+	}
+	return out.String()
+}
+
+// formatRaw renders a reply as bare values, one per line
+func formatRaw(message *resp.Message) string {
+	switch message.Type {
+	case resp.Integer:
+		return strconv.FormatInt(message.Value.(int64), 10)
+	case resp.Double:
+		return formatDouble(message.Value.(float64))
+	case resp.Boolean:
+		if message.Value.(bool) {
+			return "(true)"
+		}
+		return "(false)"
+	case resp.Null:
+		return ""
+	case resp.BulkString:
+		if message.IsNull() {
+			return ""
+		}
+		return string(message.Value.([]byte))
+	case resp.Array, resp.Set, resp.Push, resp.Map, resp.Attribute:
+		if message.IsNull() {
+			return ""
+		}
+
+		elements := message.Value.([]*resp.Message)
+		lines := make([]string, len(elements))
+		for i, element := range elements {
+			lines[i] = formatRaw(element)
+		}
+		return strings.Join(lines, "\n")
+	default:
+		text, err := message.AsString()
+		if err != nil {
+			return message.String()
+		}
+		return text
+	}
+}
+
+// formatDouble renders a double in the shortest form that reads back
+// exactly, with infinities and NaN spelled as in RESP3
+func formatDouble(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// quote renders a string in double quotes, escaping quotes, backslashes and
+// non-printable bytes, as redis-cli does
+func quote(value []byte) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, b := range value {
+		switch b {
+		case '\\', '"':
+			out.WriteByte('\\')
+			out.WriteByte(b)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\a':
+			out.WriteString(`\a`)
+		case '\b':
+			out.WriteString(`\b`)
+		default:
+			if b < 0x20 || b >= 0x7f {
+				fmt.Fprintf(&out, `\x%02x`, b)
+			} else {
+				out.WriteByte(b)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+func TestFormatReply(t *testing.T) {
+	nested := resp.NewArray([]*resp.Message{
+		resp.NewBulkString("a"),
+		resp.NewArray([]*resp.Message{
+			resp.NewInteger(1),
+			resp.NewNullBulkString(),
+		}),
+	})
+
+	tests := []struct {
+		name     string
+		message  *resp.Message
+		expected string
+		raw      string
+	}{
+		{"simple string", resp.NewSimpleString("OK"), "OK\n", "OK\n"},
+		{"error", resp.NewError("ERR boom"), "(error) ERR boom\n", "ERR boom\n"},
+		{"integer", resp.NewInteger(42), "(integer) 42\n", "42\n"},
+		{"bulk string", resp.NewBulkString("say \"hi\"\n"), `"say \"hi\"\n"` + "\n", "say \"hi\"\n\n"},
+		{"binary bulk string", resp.NewBulkString("\x00\xff"), `"\x00\xff"` + "\n", "\x00\xff\n"},
+		{"null bulk string", resp.NewNullBulkString(), "(nil)\n", "\n"},
+		{"null", resp.NewNull(), "(nil)\n", "\n"},
+		{"double", resp.NewDouble(1.5), "(double) 1.5\n", "1.5\n"},
+		{"infinite double", resp.NewDouble(math.Inf(-1)), "(double) -inf\n", "-inf\n"},
+		{"boolean", resp.NewBoolean(true), "(true)\n", "(true)\n"},
+		{"empty array", resp.NewArray([]*resp.Message{}), "(empty array)\n", "\n"},
+		{"nested array", nested, "1) \"a\"\n2) 1) (integer) 1\n   2) (nil)\n", "a\n1\n\n"},
+		{
+			"set",
+			resp.NewSet([]*resp.Message{resp.NewBulkString("x")}),
+			"1~ \"x\"\n",
+			"x\n",
+		},
+		{
+			"map",
+			resp.NewMap([]*resp.Message{resp.NewBulkString("k"), resp.NewInteger(3)}),
+			"1# \"k\" => (integer) 3\n",
+			"k\n3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatReply(tt.message, false); got != tt.expected {
+				t.Errorf("Expected formatted %q, got %q", tt.expected, got)
+			}
+			if got := formatReply(tt.message, true); got != tt.raw {
+				t.Errorf("Expected raw %q, got %q", tt.raw, got)
+			}
+		})
+	}
+}
+
+func TestFormatReply_Alignment(t *testing.T) {
+	elements := make([]*resp.Message, 10)
+	for i := range elements {
+		elements[i] = resp.NewArray([]*resp.Message{resp.NewInteger(int64(i)), resp.NewInteger(0)})
+	}
+
+	got := formatReply(resp.NewArray(elements), false)
+	expected := " 1) 1) (integer) 0\n    2) (integer) 0\n"
+	if got[:len(expected)] != expected {
+		t.Errorf("Expected output to start with %q, got %q", expected, got)
+	}
+	last := "10) 1) (integer) 9\n    2) (integer) 0\n"
+	if got[len(got)-len(last):] != last {
+		t.Errorf("Expected output to end with %q, got %q", last, got)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultHistorySize is the number of lines kept in the history file
+const DefaultHistorySize = 1000
+
+// historyFileEnv overrides the history file location. "/dev/null"
+// disables the history file.
+const historyFileEnv = "REDISLITECLI_HISTFILE"
+
+// history holds previously entered lines, oldest first, and keeps them in
+// a file across sessions
+type history struct {
+	lines []string
+	max   int
+	// path is the history file, empty to keep the history in memory only
+	path string
+}
+
+// defaultHistoryPath returns the history file location, or "" if there is
+// none
+func defaultHistoryPath() string {
+	if path, ok := os.LookupEnv(historyFileEnv); ok {
+		if path == os.DevNull {
+			return ""
+		}
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".redis-lite-cli_history")
+}
+
+// loadHistory reads the history file. A missing or unreadable file starts
+// an empty history.
+func loadHistory(path string, max int) *history {
+	h := &history{max: max, path: path}
+	if path == "" {
+		return h
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		h.append(scanner.Text())
+	}
+	return h
+}
+
+// add records a line and saves the history file. Blank lines, repeats of
+// the previous line and lines carrying passwords are not recorded.
+func (h *history) add(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || sensitive(line) {
+		return
+	}
+	if len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return
+	}
+
+	h.append(line)
+	h.save()
+}
+
+// append adds a line, dropping the oldest once the history is full
+func (h *history) append(line string) {
+	h.lines = append(h.lines, line)
+	if len(h.lines) > h.max {
+		h.lines = h.lines[len(h.lines)-h.max:]
+	}
+}
+
+// save writes the history file, readable by its owner only. Failures are
+// ignored: the history is a convenience.
+func (h *history) save() {
+	if h.path == "" {
+		return
+	}
+	os.WriteFile(h.path, []byte(strings.Join(h.lines, "\n")+"\n"), 0600)
+}
+
+// sensitive reports whether a line may carry a password: AUTH, and HELLO
+// with its AUTH option
+func sensitive(line string) bool {
+	args := strings.Fields(strings.ToUpper(line))
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "AUTH":
+		return true
+	case "HELLO":
+		for _, arg := range args[1:] {
+			if arg == "AUTH" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHistory_Add(t *testing.T) {
+	h := &history{max: 3}
+	for _, line := range []string{"get a", "  ", "get a", "AUTH secret", "hello 3 auth user secret", "get b", "get c", "get d"} {
+		h.add(line)
+	}
+
+	expected := []string{"get b", "get c", "get d"}
+	if !reflect.DeepEqual(h.lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, h.lines)
+	}
+}
+
+func TestHistory_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := loadHistory(path, 10)
+	if len(h.lines) != 0 {
+		t.Fatalf("Expected empty history, got %q", h.lines)
+	}
+	h.add("set a 1")
+	h.add("get a")
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected history file, got %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("Expected mode 0600, got %o", mode)
+	}
+
+	reloaded := loadHistory(path, 1)
+	expected := []string{"get a"}
+	if !reflect.DeepEqual(reloaded.lines, expected) {
+		t.Errorf("Expected %q, got %q", expected, reloaded.lines)
+	}
+}
+
+func TestDefaultHistoryPath(t *testing.T) {
+	t.Setenv(historyFileEnv, os.DevNull)
+	if path := defaultHistoryPath(); path != "" {
+		t.Errorf("Expected no history file, got %q", path)
+	}
+
+	t.Setenv(historyFileEnv, "/tmp/custom")
+	if path := defaultHistoryPath(); path != "/tmp/custom" {
+		t.Errorf("Expected /tmp/custom, got %q", path)
+	}
+}
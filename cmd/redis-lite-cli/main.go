@@ -0,0 +1,105 @@
+// Command redis-lite-cli is a command-line client for redis-lite in the
+// style of redis-cli.
+//
+// Usage:
+//
+//	redis-lite-cli [options] [command [arg ...]]
+//
+// Given a command, it runs it and prints the reply. Without one, it starts
+// an interactive session, or runs the commands read from standard input
+// if that is not a terminal. --pipe sends raw protocol from standard input
+// for mass insertion, and --scan lists the keys matching --pattern.
+// --export writes the keys as JSON lines, and --import loads them back
+// from standard input, merging them into the keyspace unless --replace is
+// given.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultConnectTimeout bounds connecting to the server
+const DefaultConnectTimeout = 5 * time.Second
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the client and returns the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("redis-lite-cli", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: redis-lite-cli [options] [command [arg ...]]")
+		flags.PrintDefaults()
+	}
+
+	options := connOptions{timeout: DefaultConnectTimeout}
+	flags.StringVar(&options.host, "h", "127.0.0.1", "server hostname")
+	flags.IntVar(&options.port, "p", 6379, "server port")
+	flags.StringVar(&options.password, "a", "", "password to authenticate with")
+	flags.StringVar(&options.user, "user", "", "username to authenticate with")
+	flags.BoolVar(&options.resp3, "3", false, "negotiate RESP3 with HELLO 3")
+
+	forceRaw := flags.Bool("raw", false, "print raw replies, even to a terminal")
+	noRaw := flags.Bool("no-raw", false, "print formatted replies, even when not writing to a terminal")
+	pipe := flags.Bool("pipe", false, "send raw protocol read from standard input (mass insertion)")
+	scan := flags.Bool("scan", false, "list keys with SCAN")
+	export := flags.Bool("export", false, "write the keys to standard output as JSON lines")
+	importKeys := flags.Bool("import", false, "import keys exported as JSON lines from standard input")
+	replace := flags.Bool("replace", false, "with --import, remove the existing keys matching --pattern first")
+	pattern := flags.String("pattern", "", "key pattern for --scan, --export and --import")
+	count := flags.Int("count", 10, "COUNT hint for --scan")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	stdinFile, stdinIsFile := stdin.(*os.File)
+	interactiveInput := stdinIsFile && isTerminal(stdinFile)
+	stdoutFile, stdoutIsFile := stdout.(*os.File)
+
+	// Like redis-cli, replies are formatted for terminals and raw otherwise
+	raw := !(stdoutIsFile && isTerminal(stdoutFile))
+	if *forceRaw {
+		raw = true
+	}
+	if *noRaw {
+		raw = false
+	}
+
+	s := &session{options: options, out: stdout, raw: raw}
+	defer s.close()
+
+	// The interactive session starts even if the server is down, and
+	// connects once it is back
+	if flags.NArg() == 0 && !*pipe && !*scan && !*export && !*importKeys && interactiveInput {
+		s.connect()
+		s.repl(stdinFile, loadHistory(defaultHistoryPath(), DefaultHistorySize))
+		return 0
+	}
+
+	if err := s.connect(); err != nil {
+		fmt.Fprintf(stderr, "Could not connect to redis-lite at %s: %v\n", options.address(), err)
+		return 1
+	}
+
+	switch {
+	case *pipe:
+		return runPipe(s.conn, stdin, stdout, stderr)
+	case *scan:
+		return runScan(s.conn, *pattern, *count, stdout, stderr)
+	case *export:
+		return runExport(s.conn, *pattern, stdout, stderr)
+	case *importKeys:
+		return runImport(s.conn, stdin, *replace, *pattern, stdout, stderr)
+	case flags.NArg() > 0:
+		return s.runCommand(flags.Args(), stderr)
+	default:
+		return s.runScript(stdin, stderr)
+	}
+}
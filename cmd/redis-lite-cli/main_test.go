@@ -0,0 +1,190 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/server"
+)
+
+// startServer starts a server on a random port and returns the -h and -p
+// flags that reach it
+func startServer(t *testing.T) []string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	srv := server.NewServer("127.0.0.1", 0)
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Stop() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return []string{"-h", host, "-p", port}
+}
+
+// runCLI runs the client with the given flags and standard input, and
+// returns its exit status and output
+func runCLI(t *testing.T, args []string, stdin string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr strings.Builder
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRun_Command(t *testing.T) {
+	address := startServer(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		status   int
+		expected string
+	}{
+		{"raw", []string{"SET", "greeting", "hello world"}, 0, "OK\n"},
+		{"raw bulk string", []string{"GET", "greeting"}, 0, "hello world\n"},
+		{"formatted", []string{"--no-raw", "GET", "greeting"}, 0, "\"hello world\"\n"},
+		{"formatted nil", []string{"--no-raw", "GET", "missing"}, 0, "(nil)\n"},
+		{"error reply", []string{"--no-raw", "NOSUCHCOMMAND"}, 1, "(error) ERR unknown command 'NOSUCHCOMMAND'\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, stdout, stderr := runCLI(t, append(address, tt.args...), "")
+			if status != tt.status {
+				t.Errorf("Expected status %d, got %d (stderr %q)", tt.status, status, stderr)
+			}
+			if stdout != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, stdout)
+			}
+		})
+	}
+}
+
+func TestRun_Script(t *testing.T) {
+	address := startServer(t)
+
+	script := "SET a \"x y\"\n\nGET a\nECHO 'unbalanced\nGET missing\n"
+	status, stdout, stderr := runCLI(t, append(address, "--no-raw"), script)
+
+	if status != 1 {
+		t.Errorf("Expected status 1, got %d", status)
+	}
+	expected := "OK\n\"x y\"\n(nil)\n"
+	if stdout != expected {
+		t.Errorf("Expected %q, got %q", expected, stdout)
+	}
+	if stderr != "Invalid argument(s)\n" {
+		t.Errorf("Expected invalid argument message, got %q", stderr)
+	}
+}
+
+func TestRun_Pipe(t *testing.T) {
+	address := startServer(t)
+
+	var input strings.Builder
+	for _, key := range []string{"a", "b", "c"} {
+		input.WriteString("*3\r\n$3\r\nSET\r\n$1\r\n" + key + "\r\n$1\r\n1\r\n")
+	}
+	input.WriteString("*1\r\n$5\r\nBOGUS\r\n")
+
+	status, stdout, stderr := runCLI(t, append(address, "--pipe"), input.String())
+
+	if status != 1 {
+		t.Errorf("Expected status 1, got %d", status)
+	}
+	if !strings.HasSuffix(stdout, "errors: 1, replies: 4\n") {
+		t.Errorf("Expected reply counts, got %q", stdout)
+	}
+	if !strings.Contains(stderr, "unknown command") {
+		t.Errorf("Expected the error reply on stderr, got %q", stderr)
+	}
+
+	if _, stdout, _ := runCLI(t, append(address, "GET", "c"), ""); stdout != "1\n" {
+		t.Errorf("Expected piped key to be set, got %q", stdout)
+	}
+}
+
+func TestRun_Scan(t *testing.T) {
+	address := startServer(t)
+
+	var script strings.Builder
+	for _, key := range []string{"user:1", "user:2", "user:3", "order:1"} {
+		script.WriteString("SET " + key + " v\n")
+	}
+	if status, _, stderr := runCLI(t, address, script.String()); status != 0 {
+		t.Fatalf("Failed to set keys: %s", stderr)
+	}
+
+	status, stdout, stderr := runCLI(t, append(address, "--scan", "--pattern", "user:*", "--count", "1"), "")
+	if status != 0 {
+		t.Fatalf("Expected status 0, got %d (stderr %q)", status, stderr)
+	}
+
+	keys := strings.Fields(stdout)
+	if len(keys) != 3 {
+		t.Fatalf("Expected 3 keys, got %q", keys)
+	}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "user:") {
+			t.Errorf("Expected only user keys, got %q", key)
+		}
+	}
+}
+
+func TestRun_ExportImport(t *testing.T) {
+	address := startServer(t)
+
+	fixture := `{"key":"user:1","type":"string","value":"alice"}
+{"key":"user:2","type":"string","value":"bob","ttl":60000}
+{"key":"order:1","type":"string","value":"book"}
+`
+	status, stdout, stderr := runCLI(t, append(address, "--import"), fixture)
+	if status != 0 || stdout != "Imported 3 keys\n" {
+		t.Fatalf("Expected 3 keys imported, got status %d and %q (stderr %q)", status, stdout, stderr)
+	}
+
+	status, stdout, stderr = runCLI(t, append(address, "--export", "--pattern", "user:*"), "")
+	if status != 0 {
+		t.Fatalf("Expected status 0, got %d (stderr %q)", status, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || lines[0] != `{"key":"user:1","type":"string","value":"alice"}` || !strings.Contains(lines[1], `"ttl":`) {
+		t.Errorf("Expected the user keys, got %q", lines)
+	}
+
+	// Replacing the user keys with the first line keeps the order
+	status, stdout, _ = runCLI(t, append(address, "--import", "--replace", "--pattern", "user:*"), lines[0])
+	if status != 0 || stdout != "Imported 1 keys\n" {
+		t.Errorf("Expected 1 key imported, got status %d and %q", status, stdout)
+	}
+	if _, stdout, _ := runCLI(t, append(address, "--export"), ""); strings.Count(stdout, "\n") != 2 || strings.Contains(stdout, "user:2") {
+		t.Errorf("Expected order:1 and user:1, got %q", stdout)
+	}
+
+	status, _, stderr = runCLI(t, append(address, "--import"), "not json\n")
+	if status != 1 || !strings.HasPrefix(stderr, "(error) ERR invalid import data: line 1: ") {
+		t.Errorf("Expected an import error, got status %d and %q", status, stderr)
+	}
+}
+
+func TestRun_ConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	status, _, stderr := runCLI(t, []string{"-h", host, "-p", port, "PING"}, "")
+	if status != 1 {
+		t.Errorf("Expected status 1, got %d", status)
+	}
+	if !strings.HasPrefix(stderr, "Could not connect to redis-lite at") {
+		t.Errorf("Expected connection error, got %q", stderr)
+	}
+}
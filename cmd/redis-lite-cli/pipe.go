@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// pipeMarkerSize is the number of random bytes in the marker echoed after
+// the piped data
+const pipeMarkerSize = 20
+
+// runPipe sends the raw protocol read from in, as produced for mass
+// insertion, while counting the replies. An ECHO of a random marker is sent
+// after the data so that its reply identifies the last one. Error replies
+// are printed to stderr and make the exit status 1.
+func runPipe(conn *cliConn, in io.Reader, stdout, stderr io.Writer) int {
+	random := make([]byte, pipeMarkerSize)
+	rand.Read(random)
+	marker := []byte(hex.EncodeToString(random))
+
+	type result struct {
+		replies, errors int
+		err             error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		var r result
+		for {
+			reply, err := conn.receive()
+			if err != nil {
+				r.err = err
+				done <- r
+				return
+			}
+			if reply.Type == resp.BulkString && bytes.Equal(reply.Value.([]byte), marker) {
+				done <- r
+				return
+			}
+
+			r.replies++
+			if reply.Type == resp.Error || reply.Type == resp.BlobError {
+				r.errors++
+				text, _ := reply.AsString()
+				fmt.Fprintln(stderr, text)
+			}
+		}
+	}()
+
+	if _, err := io.Copy(conn.netConn, in); err != nil {
+		fmt.Fprintf(stderr, "Error writing to the server: %v\n", err)
+		return 1
+	}
+	if err := conn.send([]string{"ECHO", string(marker)}); err != nil {
+		fmt.Fprintf(stderr, "Error writing to the server: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "All data transferred. Waiting for the last reply...")
+
+	r := <-done
+	if r.err != nil {
+		fmt.Fprintf(stderr, "Error reading from the server: %v\n", r.err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "Last reply received from server.")
+	fmt.Fprintf(stdout, "errors: %d, replies: %d\n", r.errors, r.replies)
+
+	if r.errors > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// runScan iterates the keyspace with SCAN and prints the keys matching
+// pattern, one per line. An empty pattern matches every key.
+func runScan(conn *cliConn, pattern string, count int, stdout, stderr io.Writer) int {
+	cursor := "0"
+	for {
+		args := []string{"SCAN", cursor}
+		if pattern != "" {
+			args = append(args, "MATCH", pattern)
+		}
+		args = append(args, "COUNT", strconv.Itoa(count))
+
+		reply, err := conn.do(args)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		if reply.Type == resp.Error {
+			fmt.Fprintf(stderr, "(error) %s\n", reply.Value)
+			return 1
+		}
+
+		next, keys, err := parseScanReply(reply)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		for _, key := range keys {
+			fmt.Fprintln(stdout, key)
+		}
+
+		if next == "0" {
+			return 0
+		}
+		cursor = next
+	}
+}
+
+// parseScanReply splits a SCAN reply into the next cursor and the keys
+func parseScanReply(reply *resp.Message) (string, []string, error) {
+	elements, err := reply.AsArray()
+	if err != nil || len(elements) != 2 {
+		return "", nil, fmt.Errorf("unexpected SCAN reply %s", reply)
+	}
+
+	cursor, err := elements[0].AsString()
+	if err != nil {
+		return "", nil, fmt.Errorf("unexpected SCAN cursor %s", elements[0])
+	}
+
+	items, err := elements[1].AsArray()
+	if err != nil {
+		return "", nil, fmt.Errorf("unexpected SCAN keys %s", elements[1])
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		if keys[i], err = item.AsString(); err != nil {
+			return "", nil, fmt.Errorf("unexpected SCAN key %s", item)
+		}
+	}
+	return cursor, keys, nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// maxScriptLine bounds the lines read from a script on standard input
+const maxScriptLine = 64 * 1024 * 1024
+
+// session runs commands on a connection and prints their replies. The
+// connection is opened again after it is lost.
+type session struct {
+	options connOptions
+	conn    *cliConn
+	out     io.Writer
+	raw     bool
+	// inTransaction is set between MULTI and EXEC or DISCARD
+	inTransaction bool
+}
+
+// connect opens the connection, closing the previous one if any
+func (s *session) connect() error {
+	s.close()
+
+	conn, err := dial(s.options)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// close closes the connection, if any
+func (s *session) close() {
+	if s.conn != nil {
+		s.conn.close()
+		s.conn = nil
+	}
+	s.inTransaction = false
+}
+
+// execute runs a command and prints its reply. It reports whether the
+// reply was an error, and returns an error if the connection failed, in
+// which case the connection is closed. Subscribe commands print the
+// messages received until the connection fails.
+func (s *session) execute(args []string) (bool, error) {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return false, err
+		}
+	}
+
+	reply, err := s.conn.do(args)
+	if err != nil {
+		s.close()
+		return false, err
+	}
+	s.print(reply)
+
+	if reply.Type == resp.Error || reply.Type == resp.BlobError {
+		return true, nil
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "MULTI":
+		s.inTransaction = true
+	case "EXEC", "DISCARD":
+		s.inTransaction = false
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		return false, s.listen()
+	}
+	return false, nil
+}
+
+// listen prints the messages pushed to a subscribed connection
+func (s *session) listen() error {
+	if !s.raw {
+		fmt.Fprintln(s.out, "Reading messages... (press Ctrl-C to quit)")
+	}
+
+	for {
+		message, err := s.conn.receive()
+		if err != nil {
+			s.close()
+			return err
+		}
+		s.print(message)
+	}
+}
+
+// print writes a reply in the session's output format
+func (s *session) print(reply *resp.Message) {
+	io.WriteString(s.out, formatReply(reply, s.raw))
+}
+
+// prompt returns the interactive prompt, showing the server address and
+// whether a transaction is open
+func (s *session) prompt() string {
+	if s.conn == nil {
+		return "not connected> "
+	}
+	if s.inTransaction {
+		return s.options.address() + "(TX)> "
+	}
+	return s.options.address() + "> "
+}
+
+// runCommand runs the command given on the command line
+func (s *session) runCommand(args []string, stderr io.Writer) int {
+	failed, err := s.execute(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// runScript runs the commands read from in, one per line. It stops at the
+// first connection error and returns 1 if any command failed.
+func (s *session) runScript(in io.Reader, stderr io.Writer) int {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, maxScriptLine)
+
+	status := 0
+	for scanner.Scan() {
+		args, err := resp.SplitArgs(scanner.Text())
+		if err != nil {
+			fmt.Fprintln(stderr, "Invalid argument(s)")
+			status = 1
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		failed, err := s.execute(args)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		if failed {
+			status = 1
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	return status
+}
+
+// lineReader reads the lines entered in an interactive session
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// terminalReader puts the terminal in raw mode while a line is edited
+type terminalReader struct {
+	file   *os.File
+	editor *editor
+}
+
+func (r *terminalReader) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(r.file.Fd())
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	return r.editor.readLine(prompt)
+}
+
+// plainReader reads whole lines, for terminals that cannot be put in raw
+// mode
+type plainReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// replHelp is printed by the help command
+const replHelp = `redis-lite-cli
+Type a command and its arguments, quoting arguments with spaces or escapes
+as in "hello world" or 'it\'s'.
+
+  help          show this help
+  clear         clear the screen
+  quit, exit    leave
+
+Up and down browse the history, Ctrl-A and Ctrl-E jump to the start and end
+of the line, Ctrl-U and Ctrl-K delete before and after the cursor, Ctrl-W
+deletes the previous word, Ctrl-L clears the screen and Ctrl-D on an empty
+line leaves.
+`
+
+// repl runs the interactive session until the user leaves
+func (s *session) repl(terminal *os.File, history *history) {
+	var reader lineReader = &terminalReader{
+		file:   terminal,
+		editor: newEditor(terminal, s.out, history),
+	}
+	if restore, err := makeRaw(terminal.Fd()); err != nil {
+		reader = &plainReader{scanner: bufio.NewScanner(terminal), out: s.out}
+	} else {
+		restore()
+	}
+
+	for {
+		line, err := reader.readLine(s.prompt())
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		args, err := resp.SplitArgs(line)
+		if err != nil {
+			fmt.Fprintln(s.out, "Invalid argument(s)")
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		history.add(line)
+
+		switch strings.ToLower(args[0]) {
+		case "quit", "exit":
+			return
+		case "clear":
+			fmt.Fprint(s.out, "\x1b[H\x1b[2J")
+			continue
+		case "help":
+			fmt.Fprint(s.out, replHelp)
+			continue
+		}
+
+		if _, err := s.execute(args); err != nil {
+			fmt.Fprintf(s.out, "Could not connect to redis-lite at %s: %v\n", s.options.address(), err)
+		}
+	}
+}
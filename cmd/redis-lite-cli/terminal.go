@@ -0,0 +1,13 @@
+package main
+
+import "os"
+
+// isTerminal reports whether the file is a terminal rather than a pipe or
+// a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -1,24 +1,55 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/tsinivuo/redis-lite/pkg/config"
 	"github.com/tsinivuo/redis-lite/pkg/server"
 )
 
-const (
-	// DefaultAddress is the default address the server listens on
-	DefaultAddress = "127.0.0.1"
-	// DefaultPort is the default port the server listens on (Redis standard)
-	DefaultPort = 6379
-)
+const usage = `Usage: redis-lite [/path/to/redis.conf] [options]
+       redis-lite --version
+       redis-lite --help
+
+Every configuration directive can be given as an option, overriding the
+configuration file:
+
+       redis-lite --port 7000
+       redis-lite /etc/redis-lite.conf --maxmemory 100mb --save ""
+`
 
 func main() {
+	args := os.Args[1:]
+	if len(args) == 1 {
+		switch args[0] {
+		case "--help", "-h":
+			fmt.Print(usage)
+			return
+		case "--version", "-v":
+			fmt.Printf("redis-lite v=%s\n", server.Version)
+			return
+		}
+	}
+
+	// Load the configuration file, if any, and the overrides that follow it
+	path, overrides := config.SplitCommandLine(args)
+	cfg, err := config.Load(path, overrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "*** FATAL CONFIG ERROR ***\n%v\n", err)
+		os.Exit(1)
+	}
+
 	// Create server
-	srv := server.NewServer(DefaultAddress, DefaultPort)
+	srv := server.NewServerWithConfig(cfg)
+
+	// Load the keyspace saved by the previous run
+	if err := srv.LoadData(); err != nil {
+		log.Fatalf("Failed loading the data: %v", err)
+	}
 
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -35,8 +66,8 @@ func main() {
 	<-sigChan
 	log.Println("Received shutdown signal, stopping server...")
 
-	// Stop server gracefully
-	if err := srv.Stop(); err != nil {
+	// Stop server gracefully, saving the keyspace
+	if err := srv.Shutdown(); err != nil {
 		log.Printf("Error stopping server: %v", err)
 	}
 
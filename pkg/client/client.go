@@ -0,0 +1,245 @@
+// Package client is a Go client for redis-lite, built on the resp package.
+// It only relies on standard Redis commands and protocol features, so it
+// works against Redis as well.
+//
+// A Client is safe for concurrent use. It keeps a pool of connections and
+// runs each command on a connection taken from the pool:
+//
+//	c := client.NewClient(client.Options{Addr: "localhost:6379"})
+//	defer c.Close()
+//
+//	if err := c.Set(ctx, "key", "value", 0); err != nil {
+//		return err
+//	}
+//	value, err := c.Get(ctx, "key")
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// Default option values
+const (
+	DefaultAddr         = "localhost:6379"
+	DefaultPoolSize     = 10
+	DefaultPoolTimeout  = 4 * time.Second
+	DefaultIdleTimeout  = 5 * time.Minute
+	DefaultDialTimeout  = 5 * time.Second
+	DefaultReadTimeout  = 3 * time.Second
+	DefaultWriteTimeout = 3 * time.Second
+)
+
+var (
+	// ErrNil is returned when the server replies with a null, such as GET
+	// on a missing key
+	ErrNil = errors.New("redis-lite: nil reply")
+
+	// ErrClosed is returned when using a closed client or pub/sub receiver
+	ErrClosed = errors.New("redis-lite: client is closed")
+
+	// ErrPoolTimeout is returned when no connection became available in
+	// the pool within the pool timeout
+	ErrPoolTimeout = errors.New("redis-lite: connection pool timeout")
+
+	// ErrTxAborted is returned when EXEC does not run the transaction
+	// because a watched key was modified
+	ErrTxAborted = errors.New("redis-lite: transaction aborted")
+)
+
+// Error is an error reply from the server, such as
+// "WRONGTYPE Operation against a key holding the wrong kind of value"
+type Error struct {
+	Message string
+}
+
+// Error returns the error reply
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Prefix returns the error code, the first word of the error reply, such
+// as "ERR" or "WRONGPASS"
+func (e *Error) Prefix() string {
+	prefix, _, _ := strings.Cut(e.Message, " ")
+	return prefix
+}
+
+// Options configures a client. The zero value of every field selects its
+// default.
+type Options struct {
+	// Network is "tcp" or "unix"
+	Network string
+	// Addr is the host:port address, or socket path, of the server
+	Addr string
+	// Dialer creates the network connections, replacing the default dialer
+	Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Username and Password authenticate each new connection. An empty
+	// username authenticates as the default user.
+	Username string
+	Password string
+	// ClientName is set on each new connection with HELLO SETNAME
+	ClientName string
+	// Protocol is resp.RESP2 or resp.RESP3. RESP3 connections are
+	// negotiated with HELLO and receive the RESP3 reply types.
+	Protocol int
+
+	// PoolSize is the maximum number of open connections
+	PoolSize int
+	// PoolTimeout is how long a command waits for a connection when all
+	// of them are in use
+	PoolTimeout time.Duration
+	// IdleTimeout closes connections left unused for longer. A negative
+	// value keeps idle connections open forever.
+	IdleTimeout time.Duration
+
+	// DialTimeout bounds establishing a new connection
+	DialTimeout time.Duration
+	// ReadTimeout and WriteTimeout bound each network read and write. A
+	// context deadline that expires earlier takes precedence. A negative
+	// value disables the timeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// withDefaults returns the options with every unset field defaulted
+func (o Options) withDefaults() Options {
+	if o.Network == "" {
+		o.Network = "tcp"
+	}
+	if o.Addr == "" {
+		o.Addr = DefaultAddr
+	}
+	if o.Protocol == 0 {
+		o.Protocol = resp.RESP2
+	}
+	if o.PoolSize <= 0 {
+		o.PoolSize = DefaultPoolSize
+	}
+	if o.PoolTimeout == 0 {
+		o.PoolTimeout = DefaultPoolTimeout
+	}
+	if o.IdleTimeout == 0 {
+		o.IdleTimeout = DefaultIdleTimeout
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = DefaultDialTimeout
+	}
+	if o.ReadTimeout == 0 {
+		o.ReadTimeout = DefaultReadTimeout
+	}
+	if o.WriteTimeout == 0 {
+		o.WriteTimeout = DefaultWriteTimeout
+	}
+	if o.Dialer == nil {
+		dialer := &net.Dialer{Timeout: o.DialTimeout, KeepAlive: 5 * time.Minute}
+		o.Dialer = dialer.DialContext
+	}
+	return o
+}
+
+// Client is a pooled client. It is safe for concurrent use.
+type Client struct {
+	cmdable
+	options Options
+	pool    *pool
+}
+
+// NewClient creates a client. Connections are established lazily, when
+// the first command runs.
+func NewClient(options Options) *Client {
+	c := &Client{options: options.withDefaults()}
+	c.pool = newPool(c.options, c.dial)
+	c.cmdable = roundTripper(c.roundTrip).do
+	return c
+}
+
+// Do sends a command and returns its reply. Arguments may be strings, byte
+// slices, integers, floats and booleans. Error replies are returned as
+// *Error.
+func (c *Client) Do(ctx context.Context, args ...any) (*resp.Message, error) {
+	return c.cmdable(ctx, args...)
+}
+
+// Close closes the client and its idle connections. Connections in use
+// are closed as soon as they are released.
+func (c *Client) Close() error {
+	c.pool.close()
+	return nil
+}
+
+// PoolStats reports the state of the connection pool
+type PoolStats struct {
+	// TotalConns is the number of open connections
+	TotalConns int
+	// IdleConns is the number of open connections not in use
+	IdleConns int
+}
+
+// PoolStats returns the state of the connection pool
+func (c *Client) PoolStats() PoolStats {
+	return c.pool.stats()
+}
+
+// Options returns the options of the client, with defaults applied
+func (c *Client) Options() Options {
+	return c.options
+}
+
+// roundTrip sends commands on a pooled connection and reads their replies
+func (c *Client) roundTrip(ctx context.Context, commands []*resp.Message) ([]*resp.Message, error) {
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.pool.put(cn)
+
+	return cn.roundTrip(ctx, commands)
+}
+
+// dial opens a new connection and prepares it with HELLO or AUTH
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	netConn, err := c.options.Dialer(ctx, c.options.Network, c.options.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := newConn(netConn, c.options)
+	if err := cn.handshake(ctx); err != nil {
+		cn.close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// roundTripper sends a batch of commands and returns their replies
+type roundTripper func(ctx context.Context, commands []*resp.Message) ([]*resp.Message, error)
+
+// do sends a single command and converts an error reply into an *Error
+func (rt roundTripper) do(ctx context.Context, args ...any) (*resp.Message, error) {
+	command, err := encodeCommand(args)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := rt(ctx, []*resp.Message{command})
+	if err != nil {
+		return nil, err
+	}
+	return replyError(replies[0])
+}
+
+// replyError returns an error reply as an *Error and any other reply as is
+func replyError(reply *resp.Message) (*resp.Message, error) {
+	if reply.Type == resp.Error || reply.Type == resp.BlobError {
+		message, _ := reply.AsString()
+		return nil, &Error{Message: message}
+	}
+	return reply, nil
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/server"
+)
+
+// startServer serves an in-process server on a random port and returns it
+// together with its address
+func startServer(t *testing.T) (*server.Server, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	srv := server.NewServer("127.0.0.1", 0)
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Stop() })
+
+	return srv, listener.Addr().String()
+}
+
+// newTestClient starts a server and returns a client connected to it
+func newTestClient(t *testing.T, options Options) *Client {
+	t.Helper()
+
+	_, addr := startServer(t)
+	options.Addr = addr
+
+	c := NewClient(options)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_Commands(t *testing.T) {
+	for _, protocol := range []int{resp.RESP2, resp.RESP3} {
+		t.Run(fmt.Sprintf("RESP%d", protocol), func(t *testing.T) {
+			ctx := context.Background()
+			c := newTestClient(t, Options{Protocol: protocol})
+
+			if pong, err := c.Ping(ctx); err != nil || pong != "PONG" {
+				t.Fatalf("Expected PONG, got %q (%v)", pong, err)
+			}
+
+			if echo, err := c.Echo(ctx, "hello"); err != nil || echo != "hello" {
+				t.Errorf("Expected %q, got %q (%v)", "hello", echo, err)
+			}
+
+			if err := c.Set(ctx, "key", "value", 0); err != nil {
+				t.Fatalf("Set returned error: %v", err)
+			}
+			if value, err := c.Get(ctx, "key"); err != nil || value != "value" {
+				t.Errorf("Expected %q, got %q (%v)", "value", value, err)
+			}
+
+			if err := c.Set(ctx, "counter", 42, time.Minute); err != nil {
+				t.Fatalf("Set returned error: %v", err)
+			}
+			if value, err := c.GetBytes(ctx, "counter"); err != nil || string(value) != "42" {
+				t.Errorf("Expected %q, got %q (%v)", "42", value, err)
+			}
+
+			if _, err := c.Get(ctx, "missing"); !errors.Is(err, ErrNil) {
+				t.Errorf("Expected ErrNil, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_ErrorReply(t *testing.T) {
+	c := newTestClient(t, Options{})
+
+	_, err := c.Do(context.Background(), "NOSUCHCOMMAND")
+	var replyErr *Error
+	if !errors.As(err, &replyErr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if replyErr.Prefix() != "ERR" {
+		t.Errorf("Expected prefix ERR, got %q", replyErr.Prefix())
+	}
+
+	// An error reply leaves the connection usable
+	if _, err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Ping returned error: %v", err)
+	}
+}
+
+func TestClient_Protocol(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		protocol int
+		wantType resp.MessageType
+	}{
+		{resp.RESP2, resp.Array},
+		{resp.RESP3, resp.Map},
+	}
+
+	for _, test := range tests {
+		c := newTestClient(t, Options{Protocol: test.protocol})
+
+		reply, err := c.Do(ctx, "PUBSUB", "NUMSUB", "news")
+		if err != nil {
+			t.Fatalf("Do returned error: %v", err)
+		}
+		if reply.Type != test.wantType {
+			t.Errorf("Expected %s reply under RESP%d, got %s", test.wantType, test.protocol, reply.Type)
+		}
+
+		// The typed helper accepts both forms
+		counts, err := c.PubSubNumSub(ctx, "news")
+		if err != nil || counts["news"] != 0 || len(counts) != 1 {
+			t.Errorf("Expected map[news:0], got %v (%v)", counts, err)
+		}
+	}
+}
+
+func TestClient_Auth(t *testing.T) {
+	srv, addr := startServer(t)
+	srv.SetPassword("secret")
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		options    Options
+		wantPrefix string
+	}{
+		{"No password", Options{}, "NOAUTH"},
+		{"Wrong password", Options{Password: "wrong"}, "WRONGPASS"},
+		{"Wrong password with HELLO", Options{Password: "wrong", Protocol: resp.RESP3}, "WRONGPASS"},
+		{"Password", Options{Password: "secret"}, ""},
+		{"Username and password", Options{Username: "default", Password: "secret"}, ""},
+		{"Password with HELLO", Options{Password: "secret", Protocol: resp.RESP3, ClientName: "tests"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.options.Addr = addr
+			c := NewClient(test.options)
+			defer c.Close()
+
+			_, err := c.Ping(ctx)
+			if test.wantPrefix == "" {
+				if err != nil {
+					t.Fatalf("Ping returned error: %v", err)
+				}
+				return
+			}
+
+			var replyErr *Error
+			if !errors.As(err, &replyErr) || replyErr.Prefix() != test.wantPrefix {
+				t.Fatalf("Expected %s error, got %v", test.wantPrefix, err)
+			}
+		})
+	}
+}
+
+func TestClient_Closed(t *testing.T) {
+	c := newTestClient(t, Options{})
+	c.Close()
+
+	if _, err := c.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// cmdable sends a command and returns its reply. The typed command helpers
+// are defined on it so that Client and Tx share them.
+type cmdable func(ctx context.Context, args ...any) (*resp.Message, error)
+
+// Ping sends PING and returns the reply, normally "PONG"
+func (c cmdable) Ping(ctx context.Context) (string, error) {
+	return String(c(ctx, "PING"))
+}
+
+// Echo returns the message echoed back by the server
+func (c cmdable) Echo(ctx context.Context, message string) (string, error) {
+	return String(c(ctx, "ECHO", message))
+}
+
+// Get returns the value of a key, or ErrNil if the key does not exist
+func (c cmdable) Get(ctx context.Context, key string) (string, error) {
+	return String(c(ctx, "GET", key))
+}
+
+// GetBytes returns the value of a key as bytes, or ErrNil if the key does
+// not exist
+func (c cmdable) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	return Bytes(c(ctx, "GET", key))
+}
+
+// Set stores a value. A positive expiration sets the key's time to live,
+// with millisecond precision.
+func (c cmdable) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	var err error
+	if expiration > 0 {
+		_, err = c(ctx, "SET", key, value, "PX", max(expiration.Milliseconds(), 1))
+	} else {
+		_, err = c(ctx, "SET", key, value)
+	}
+	return err
+}
+
+// Scan returns a batch of keys matching the pattern, starting at cursor,
+// and the cursor of the next batch, which is 0 once the iteration is
+// complete. An empty pattern matches every key; count is a hint of the
+// batch size, 0 for the server default.
+func (c cmdable) Scan(ctx context.Context, cursor uint64, pattern string, count int) ([]string, uint64, error) {
+	args := []any{"SCAN", cursor}
+	if pattern != "" {
+		args = append(args, "MATCH", pattern)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+
+	reply, err := checkReply(c(ctx, args...))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	elements, err := reply.AsArray()
+	if err != nil || len(elements) != 2 {
+		return nil, 0, fmt.Errorf("redis-lite: unexpected reply %s", reply)
+	}
+
+	// Cursors are unsigned 64-bit integers
+	text, err := String(elements[0], nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	next, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	keys, err := Strings(elements[1], nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return keys, next, nil
+}
+
+// Publish posts a message to a channel and returns the number of
+// subscribers that received it
+func (c cmdable) Publish(ctx context.Context, channel string, message any) (int64, error) {
+	return Int64(c(ctx, "PUBLISH", channel, message))
+}
+
+// SPublish posts a message to a shard channel and returns the number of
+// subscribers that received it
+func (c cmdable) SPublish(ctx context.Context, channel string, message any) (int64, error) {
+	return Int64(c(ctx, "SPUBLISH", channel, message))
+}
+
+// PubSubChannels returns the active channels matching the pattern. An
+// empty pattern matches every channel.
+func (c cmdable) PubSubChannels(ctx context.Context, pattern string) ([]string, error) {
+	if pattern == "" {
+		return Strings(c(ctx, "PUBSUB", "CHANNELS"))
+	}
+	return Strings(c(ctx, "PUBSUB", "CHANNELS", pattern))
+}
+
+// PubSubNumSub returns the number of subscribers of each channel
+func (c cmdable) PubSubNumSub(ctx context.Context, channels ...string) (map[string]int64, error) {
+	return Int64Map(c(ctx, stringArgs("PUBSUB", "NUMSUB", channels)...))
+}
+
+// PubSubNumPat returns the number of patterns subscribed to
+func (c cmdable) PubSubNumPat(ctx context.Context) (int64, error) {
+	return Int64(c(ctx, "PUBSUB", "NUMPAT"))
+}
+
+// PubSubShardChannels returns the active shard channels matching the
+// pattern. An empty pattern matches every shard channel.
+func (c cmdable) PubSubShardChannels(ctx context.Context, pattern string) ([]string, error) {
+	if pattern == "" {
+		return Strings(c(ctx, "PUBSUB", "SHARDCHANNELS"))
+	}
+	return Strings(c(ctx, "PUBSUB", "SHARDCHANNELS", pattern))
+}
+
+// PubSubShardNumSub returns the number of subscribers of each shard channel
+func (c cmdable) PubSubShardNumSub(ctx context.Context, channels ...string) (map[string]int64, error) {
+	return Int64Map(c(ctx, stringArgs("PUBSUB", "SHARDNUMSUB", channels)...))
+}
+
+// stringArgs builds the arguments of a command taking a list of names
+func stringArgs(command, subcommand string, names []string) []any {
+	args := make([]any, 0, len(names)+2)
+	args = append(args, command, subcommand)
+	for _, name := range names {
+		args = append(args, name)
+	}
+	return args
+}
+
+// The reply conversions take the results of Do directly, as in
+// client.String(c.Do(ctx, "GET", "key")), and can also be applied to the
+// replies of a pipeline. They return ErrNil for null replies and an *Error
+// for error replies.
+
+// String converts a string reply
+func String(reply *resp.Message, err error) (string, error) {
+	if reply, err = checkReply(reply, err); err != nil {
+		return "", err
+	}
+
+	value, err := reply.AsString()
+	if err != nil {
+		return "", fmt.Errorf("redis-lite: unexpected reply %s", reply)
+	}
+	return value, nil
+}
+
+// Bytes converts a string reply to bytes
+func Bytes(reply *resp.Message, err error) ([]byte, error) {
+	if reply, err = checkReply(reply, err); err != nil {
+		return nil, err
+	}
+
+	if value, err := reply.AsBytes(); err == nil {
+		return value, nil
+	}
+	value, err := String(reply, nil)
+	return []byte(value), err
+}
+
+// Int64 converts an integer reply, or a string holding an integer
+func Int64(reply *resp.Message, err error) (int64, error) {
+	if reply, err = checkReply(reply, err); err != nil {
+		return 0, err
+	}
+
+	if value, err := reply.AsInteger(); err == nil {
+		return value, nil
+	}
+
+	text, err := String(reply, nil)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(text, 10, 64)
+}
+
+// Float64 converts a RESP3 double reply, or a string holding a number
+func Float64(reply *resp.Message, err error) (float64, error) {
+	if reply, err = checkReply(reply, err); err != nil {
+		return 0, err
+	}
+
+	if value, err := reply.AsFloat(); err == nil {
+		return value, nil
+	}
+	if value, err := reply.AsInteger(); err == nil {
+		return float64(value), nil
+	}
+
+	text, err := String(reply, nil)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// Bool converts a RESP3 boolean reply, or the 1 or 0 integer RESP2 uses
+// in its place
+func Bool(reply *resp.Message, err error) (bool, error) {
+	if reply, err = checkReply(reply, err); err != nil {
+		return false, err
+	}
+
+	if value, err := reply.AsBool(); err == nil {
+		return value, nil
+	}
+
+	value, err := Int64(reply, nil)
+	return value != 0, err
+}
+
+// Strings converts an array or set reply of strings. Null elements become
+// empty strings.
+func Strings(reply *resp.Message, err error) ([]string, error) {
+	if reply, err = checkReply(reply, err); err != nil {
+		return nil, err
+	}
+
+	elements, err := reply.AsArray()
+	if err != nil {
+		return nil, fmt.Errorf("redis-lite: unexpected reply %s", reply)
+	}
+
+	values := make([]string, len(elements))
+	for i, element := range elements {
+		if element.IsNull() {
+			continue
+		}
+		if values[i], err = String(element, nil); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Int64Map converts a map reply, or the flat array of name and integer
+// pairs RESP2 uses in its place
+func Int64Map(reply *resp.Message, err error) (map[string]int64, error) {
+	if reply, err = checkReply(reply, err); err != nil {
+		return nil, err
+	}
+
+	elements, err := reply.AsArray()
+	if err != nil || len(elements)%2 != 0 {
+		return nil, fmt.Errorf("redis-lite: unexpected reply %s", reply)
+	}
+
+	values := make(map[string]int64, len(elements)/2)
+	for i := 0; i < len(elements); i += 2 {
+		name, err := String(elements[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		if values[name], err = Int64(elements[i+1], nil); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// checkReply returns the error of a command, the error reply as an *Error,
+// or ErrNil for a null reply
+func checkReply(reply *resp.Message, err error) (*resp.Message, error) {
+	if err != nil {
+		return nil, err
+	}
+	if reply, err = replyError(reply); err != nil {
+		return nil, err
+	}
+	if reply.IsNull() {
+		return nil, ErrNil
+	}
+	return reply, nil
+}
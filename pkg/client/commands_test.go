@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+func TestReplyConversions(t *testing.T) {
+	if value, err := Int64(resp.NewBulkString("12"), nil); err != nil || value != 12 {
+		t.Errorf("Expected 12, got %d (%v)", value, err)
+	}
+
+	if value, err := Float64(resp.NewDouble(1.5), nil); err != nil || value != 1.5 {
+		t.Errorf("Expected 1.5, got %v (%v)", value, err)
+	}
+	if value, err := Float64(resp.NewBulkString("2.5"), nil); err != nil || value != 2.5 {
+		t.Errorf("Expected 2.5, got %v (%v)", value, err)
+	}
+
+	if value, err := Bool(resp.NewBoolean(true), nil); err != nil || !value {
+		t.Errorf("Expected true, got %v (%v)", value, err)
+	}
+	if value, err := Bool(resp.NewInteger(0), nil); err != nil || value {
+		t.Errorf("Expected false, got %v (%v)", value, err)
+	}
+
+	set := resp.NewSet([]*resp.Message{resp.NewBulkString("a"), resp.NewNullBulkString()})
+	if value, err := Strings(set, nil); err != nil || !reflect.DeepEqual(value, []string{"a", ""}) {
+		t.Errorf("Expected [a ], got %q (%v)", value, err)
+	}
+
+	if _, err := String(resp.NewNull(), nil); !errors.Is(err, ErrNil) {
+		t.Errorf("Expected ErrNil, got %v", err)
+	}
+
+	var replyErr *Error
+	if _, err := Int64(resp.NewBlobError("SYNTAX invalid"), nil); !errors.As(err, &replyErr) || replyErr.Prefix() != "SYNTAX" {
+		t.Errorf("Expected SYNTAX error, got %v", err)
+	}
+
+	failure := errors.New("network down")
+	if _, err := String(nil, failure); err != failure {
+		t.Errorf("Expected the command error to be passed through, got %v", err)
+	}
+}
+
+func TestClient_PubSubIntrospection(t *testing.T) {
+	c := newTestClient(t, Options{})
+	ctx := context.Background()
+
+	p, err := c.Subscribe(ctx, "news")
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	defer p.Close()
+	p.SSubscribe(ctx, "orders")
+	p.PSubscribe(ctx, "news.*")
+
+	// Wait for the confirmations so the server has processed every request
+	for i := 0; i < 3; i++ {
+		if _, err := p.Receive(ctx); err != nil {
+			t.Fatalf("Receive returned error: %v", err)
+		}
+	}
+
+	if channels, err := c.PubSubChannels(ctx, ""); err != nil || !reflect.DeepEqual(channels, []string{"news"}) {
+		t.Errorf("Expected [news], got %q (%v)", channels, err)
+	}
+	if channels, err := c.PubSubShardChannels(ctx, "ord*"); err != nil || !reflect.DeepEqual(channels, []string{"orders"}) {
+		t.Errorf("Expected [orders], got %q (%v)", channels, err)
+	}
+	if counts, err := c.PubSubShardNumSub(ctx, "orders"); err != nil || counts["orders"] != 1 {
+		t.Errorf("Expected map[orders:1], got %v (%v)", counts, err)
+	}
+	if count, err := c.PubSubNumPat(ctx); err != nil || count != 1 {
+		t.Errorf("Expected 1 pattern, got %d (%v)", count, err)
+	}
+	if receivers, err := c.SPublish(ctx, "orders", "created"); err != nil || receivers != 1 {
+		t.Errorf("Expected 1 receiver, got %d (%v)", receivers, err)
+	}
+}
+
+func TestClient_Scan(t *testing.T) {
+	c := newTestClient(t, Options{})
+	ctx := context.Background()
+
+	for _, key := range []string{"user:1", "user:2", "user:3", "other"} {
+		c.Set(ctx, key, "value", 0)
+	}
+
+	var keys []string
+	cursor := uint64(0)
+	for {
+		batch, next, err := c.Scan(ctx, cursor, "user:*", 1)
+		if err != nil {
+			t.Fatalf("Scan returned error: %v", err)
+		}
+		keys = append(keys, batch...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"user:1", "user:2", "user:3"}) {
+		t.Errorf("Expected the user keys, got %q", keys)
+	}
+}
@@ -0,0 +1,270 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// replyLimits are the parser limits applied to replies. The client trusts
+// the server, so only the nesting depth keeps its default.
+var replyLimits = resp.Limits{
+	MaxBulkLength:      math.MaxInt32,
+	MaxMultiBulkLength: math.MaxInt32,
+	MaxDepth:           resp.DefaultMaxDepth,
+	MaxInlineSize:      math.MaxInt32,
+}
+
+// conn is a single connection to the server
+type conn struct {
+	netConn    net.Conn
+	parser     *resp.Parser
+	serializer *resp.Serializer
+	options    Options
+	// protocol is the protocol negotiated by the handshake
+	protocol int
+	// usedAt is when the connection was last released to the pool
+	usedAt time.Time
+	// broken is set after a network or protocol error, when the replies
+	// can no longer be matched to the commands. Pub/sub connections read
+	// and write concurrently, so it is atomic.
+	broken atomic.Bool
+}
+
+// newConn wraps a network connection
+func newConn(netConn net.Conn, options Options) *conn {
+	parser := resp.NewParser(netConn)
+	parser.SetLimits(replyLimits)
+
+	return &conn{
+		netConn:    netConn,
+		parser:     parser,
+		serializer: resp.NewSerializer(netConn),
+		options:    options,
+		protocol:   resp.RESP2,
+		usedAt:     time.Now(),
+	}
+}
+
+// handshake negotiates the protocol, authenticates and sets the client name.
+// HELLO is only sent when needed, so plain RESP2 connections also work with
+// servers that predate it.
+func (cn *conn) handshake(ctx context.Context) error {
+	options := cn.options
+
+	var args []any
+	switch {
+	case options.Protocol != resp.RESP2 || options.ClientName != "":
+		args = []any{"HELLO", options.Protocol}
+		if options.Password != "" {
+			username := options.Username
+			if username == "" {
+				username = "default"
+			}
+			args = append(args, "AUTH", username, options.Password)
+		}
+		if options.ClientName != "" {
+			args = append(args, "SETNAME", options.ClientName)
+		}
+	case options.Password != "" && options.Username != "":
+		args = []any{"AUTH", options.Username, options.Password}
+	case options.Password != "":
+		args = []any{"AUTH", options.Password}
+	default:
+		return nil
+	}
+
+	command, err := encodeCommand(args)
+	if err != nil {
+		return err
+	}
+
+	replies, err := cn.roundTrip(ctx, []*resp.Message{command})
+	if err != nil {
+		return err
+	}
+	if _, err := replyError(replies[0]); err != nil {
+		return err
+	}
+
+	cn.protocol = options.Protocol
+	return nil
+}
+
+// roundTrip writes the commands in a single flush and reads one reply per
+// command. Error replies are returned as messages.
+func (cn *conn) roundTrip(ctx context.Context, commands []*resp.Message) ([]*resp.Message, error) {
+	if err := cn.write(ctx, commands...); err != nil {
+		return nil, err
+	}
+
+	replies := make([]*resp.Message, len(commands))
+	for i := range replies {
+		reply, err := cn.readReply(ctx)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// write serializes the commands and flushes them
+func (cn *conn) write(ctx context.Context, commands ...*resp.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stop := cn.setDeadline(ctx, cn.options.WriteTimeout, cn.netConn.SetWriteDeadline)
+	defer stop()
+
+	for _, command := range commands {
+		if err := cn.serializer.Serialize(command); err != nil {
+			return cn.fail(ctx, err)
+		}
+	}
+	if err := cn.serializer.Flush(); err != nil {
+		return cn.fail(ctx, err)
+	}
+	return nil
+}
+
+// readReply reads the reply to a command. Push messages, which RESP3
+// servers may send at any time, are skipped.
+func (cn *conn) readReply(ctx context.Context) (*resp.Message, error) {
+	for {
+		message, err := cn.read(ctx, cn.options.ReadTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if message.Type != resp.Push {
+			return message, nil
+		}
+	}
+}
+
+// read reads the next message. A non-positive timeout waits until the
+// context is done.
+func (cn *conn) read(ctx context.Context, timeout time.Duration) (*resp.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stop := cn.setDeadline(ctx, timeout, cn.netConn.SetReadDeadline)
+	defer stop()
+
+	message, err := cn.parser.Parse()
+	if err != nil {
+		return nil, cn.fail(ctx, err)
+	}
+	return message, nil
+}
+
+// setDeadline sets the earlier of the timeout and the context deadline, and
+// interrupts the pending operation if the context is canceled. The returned
+// function must be called once the operation completes.
+//
+// A connection whose context was canceled while an operation ran is marked
+// broken, since the interrupting deadline may be set after the operation
+// completes.
+func (cn *conn) setDeadline(ctx context.Context, timeout time.Duration, set func(time.Time) error) func() {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	set(deadline)
+
+	stop := context.AfterFunc(ctx, func() {
+		// A deadline in the past fails the pending operation immediately
+		set(time.Unix(1, 0))
+	})
+	return func() {
+		if !stop() {
+			cn.broken.Store(true)
+		}
+	}
+}
+
+// fail marks the connection broken and returns the context error in place
+// of the timeout caused by a canceled or expired context
+func (cn *conn) fail(ctx context.Context, err error) error {
+	cn.broken.Store(true)
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		return err
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	// The network deadline may pass just before the context notices
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// close closes the network connection
+func (cn *conn) close() error {
+	return cn.netConn.Close()
+}
+
+// encodeCommand builds the array of bulk strings sent for a command
+func encodeCommand(args []any) (*resp.Message, error) {
+	elements := make([]*resp.Message, len(args))
+	for i, arg := range args {
+		element, err := encodeArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = element
+	}
+	return resp.NewArray(elements), nil
+}
+
+// encodeArg converts a command argument into a bulk string
+func encodeArg(arg any) (*resp.Message, error) {
+	switch value := arg.(type) {
+	case string:
+		return resp.NewBulkString(value), nil
+	case []byte:
+		return resp.NewBulkBytes(bytes.Clone(value)), nil
+	case int:
+		return resp.NewBulkString(strconv.Itoa(value)), nil
+	case int64:
+		return resp.NewBulkString(strconv.FormatInt(value, 10)), nil
+	case int32:
+		return resp.NewBulkString(strconv.FormatInt(int64(value), 10)), nil
+	case uint:
+		return resp.NewBulkString(strconv.FormatUint(uint64(value), 10)), nil
+	case uint64:
+		return resp.NewBulkString(strconv.FormatUint(value, 10)), nil
+	case uint32:
+		return resp.NewBulkString(strconv.FormatUint(uint64(value), 10)), nil
+	case float64:
+		return resp.NewBulkString(strconv.FormatFloat(value, 'f', -1, 64)), nil
+	case float32:
+		return resp.NewBulkString(strconv.FormatFloat(float64(value), 'f', -1, 32)), nil
+	case bool:
+		if value {
+			return resp.NewBulkString("1"), nil
+		}
+		return resp.NewBulkString("0"), nil
+	case fmt.Stringer:
+		return resp.NewBulkString(value.String()), nil
+	default:
+		return nil, fmt.Errorf("redis-lite: can't encode argument of type %T", arg)
+	}
+}
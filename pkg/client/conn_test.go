@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// silentServer accepts connections and never replies
+func silentServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestEncodeCommand(t *testing.T) {
+	tests := []struct {
+		arg      any
+		expected string
+	}{
+		{"text", "text"},
+		{[]byte("bytes"), "bytes"},
+		{42, "42"},
+		{int64(-7), "-7"},
+		{uint32(7), "7"},
+		{1.5, "1.5"},
+		{true, "1"},
+		{false, "0"},
+		{time.Second, "1s"},
+	}
+
+	for _, test := range tests {
+		command, err := encodeCommand([]any{test.arg})
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v", test.arg, err)
+		}
+
+		elements, _ := command.AsArray()
+		if value, _ := elements[0].AsString(); value != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, value)
+		}
+	}
+
+	if _, err := encodeCommand([]any{struct{}{}}); err == nil {
+		t.Error("Expected an error for an unsupported argument type")
+	}
+}
+
+func TestClient_ReadTimeout(t *testing.T) {
+	c := NewClient(Options{Addr: silentServer(t), ReadTimeout: 20 * time.Millisecond})
+	defer c.Close()
+
+	var netErr net.Error
+	if _, err := c.Ping(context.Background()); !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+
+	// The connection may still receive the late reply, so it is discarded
+	if stats := c.PoolStats(); stats.TotalConns != 0 {
+		t.Errorf("Expected the timed out connection to be closed, got %+v", stats)
+	}
+}
+
+func TestClient_ContextDeadline(t *testing.T) {
+	c := NewClient(Options{Addr: silentServer(t), ReadTimeout: -1})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Ping(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestClient_ContextCanceled(t *testing.T) {
+	c := NewClient(Options{Addr: silentServer(t), ReadTimeout: -1})
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	if _, err := c.Ping(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if _, err := c.Ping(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled for a canceled context, got %v", err)
+	}
+}
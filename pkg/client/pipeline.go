@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// Pipeline queues commands and sends them in a single round trip. A
+// pipeline is not safe for concurrent use.
+type Pipeline struct {
+	roundTrip roundTripper
+	commands  []*resp.Message
+	// err is the first error encoding a queued command
+	err error
+}
+
+// Pipeline creates an empty pipeline running on the client's pool
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{roundTrip: c.roundTrip}
+}
+
+// Pipelined runs fn to queue commands and sends them in a single round trip
+func (c *Client) Pipelined(ctx context.Context, fn func(p *Pipeline)) ([]*resp.Message, error) {
+	p := c.Pipeline()
+	fn(p)
+	return p.Exec(ctx)
+}
+
+// TxPipelined runs fn to queue commands and executes them atomically,
+// wrapped in MULTI and EXEC, in a single round trip. It returns the replies
+// of the queued commands.
+func (c *Client) TxPipelined(ctx context.Context, fn func(p *Pipeline)) ([]*resp.Message, error) {
+	p := c.Pipeline()
+	fn(p)
+	return p.execTransaction(ctx)
+}
+
+// Do queues a command. Encoding errors are reported by Exec.
+func (p *Pipeline) Do(args ...any) {
+	command, err := encodeCommand(args)
+	if err != nil {
+		if p.err == nil {
+			p.err = err
+		}
+		return
+	}
+	p.commands = append(p.commands, command)
+}
+
+// Len returns the number of queued commands
+func (p *Pipeline) Len() int {
+	return len(p.commands)
+}
+
+// Exec sends the queued commands and returns their replies in order. Error
+// replies are kept among the replies, and the first one is also returned
+// as an *Error. The pipeline is emptied, so it can be reused.
+func (p *Pipeline) Exec(ctx context.Context) ([]*resp.Message, error) {
+	commands, err := p.take()
+	if err != nil || len(commands) == 0 {
+		return nil, err
+	}
+
+	replies, err := p.roundTrip(ctx, commands)
+	if err != nil {
+		return nil, err
+	}
+	return replies, firstError(replies)
+}
+
+// execTransaction sends the queued commands between MULTI and EXEC and
+// returns the replies EXEC collected. ErrTxAborted is returned if a watched
+// key was modified.
+func (p *Pipeline) execTransaction(ctx context.Context) ([]*resp.Message, error) {
+	commands, err := p.take()
+	if err != nil {
+		return nil, err
+	}
+
+	batch := make([]*resp.Message, 0, len(commands)+2)
+	batch = append(batch, resp.NewArray([]*resp.Message{resp.NewBulkString("MULTI")}))
+	batch = append(batch, commands...)
+	batch = append(batch, resp.NewArray([]*resp.Message{resp.NewBulkString("EXEC")}))
+
+	replies, err := p.roundTrip(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	execReply := replies[len(replies)-1]
+	if _, err := replyError(execReply); err != nil {
+		// A command rejected while queueing explains the EXECABORT better
+		if queueErr := firstError(replies[:len(replies)-1]); queueErr != nil {
+			return nil, queueErr
+		}
+		return nil, err
+	}
+
+	if execReply.IsNull() {
+		return nil, ErrTxAborted
+	}
+
+	results, err := execReply.AsArray()
+	if err != nil {
+		return nil, err
+	}
+	return results, firstError(results)
+}
+
+// take empties the pipeline and returns its commands
+func (p *Pipeline) take() ([]*resp.Message, error) {
+	commands, err := p.commands, p.err
+	p.commands, p.err = nil, nil
+	return commands, err
+}
+
+// firstError returns the first error reply as an *Error, or nil
+func firstError(replies []*resp.Message) error {
+	for _, reply := range replies {
+		if _, err := replyError(reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tx runs commands on a single connection, between WATCH and EXEC. It is
+// only valid inside the function passed to Client.Watch.
+type Tx struct {
+	cmdable
+	conn *conn
+	// unwatched is set once EXEC has released the watched keys
+	unwatched bool
+}
+
+// Watch runs fn on a dedicated connection after watching the keys, for
+// check-and-set transactions. If any watched key is modified before the
+// transaction run with Tx.TxPipelined, that call returns ErrTxAborted and
+// the caller may retry:
+//
+//	err := c.Watch(ctx, func(tx *client.Tx) error {
+//		value, err := tx.Get(ctx, "counter")
+//		...
+//		_, err = tx.TxPipelined(ctx, func(p *client.Pipeline) {
+//			p.Do("SET", "counter", next)
+//		})
+//		return err
+//	}, "counter")
+func (c *Client) Watch(ctx context.Context, fn func(tx *Tx) error, keys ...string) error {
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.pool.put(cn)
+
+	tx := &Tx{conn: cn}
+	tx.cmdable = roundTripper(cn.roundTrip).do
+
+	if len(keys) > 0 {
+		args := make([]any, 0, len(keys)+1)
+		args = append(args, "WATCH")
+		for _, key := range keys {
+			args = append(args, key)
+		}
+		if _, err := tx.Do(ctx, args...); err != nil {
+			return err
+		}
+	}
+
+	err = fn(tx)
+
+	// Keys still watched would abort the next transaction run on the
+	// pooled connection
+	if !tx.unwatched {
+		if _, unwatchErr := tx.Do(ctx, "UNWATCH"); unwatchErr != nil {
+			cn.broken.Store(true)
+		}
+	}
+	return err
+}
+
+// Do sends a command on the transaction's connection
+func (tx *Tx) Do(ctx context.Context, args ...any) (*resp.Message, error) {
+	return tx.cmdable(ctx, args...)
+}
+
+// Pipeline creates an empty pipeline running on the transaction's connection
+func (tx *Tx) Pipeline() *Pipeline {
+	return &Pipeline{roundTrip: tx.conn.roundTrip}
+}
+
+// TxPipelined runs fn to queue commands and executes them with MULTI and
+// EXEC. It returns ErrTxAborted if a watched key was modified.
+func (tx *Tx) TxPipelined(ctx context.Context, fn func(p *Pipeline)) ([]*resp.Message, error) {
+	p := tx.Pipeline()
+	fn(p)
+
+	results, err := p.execTransaction(ctx)
+	// EXEC releases the watched keys whether or not it ran the transaction,
+	// so they stay watched only if EXEC was never received
+	var replyErr *Error
+	if err == nil || errors.Is(err, ErrTxAborted) || errors.As(err, &replyErr) {
+		tx.unwatched = true
+	}
+	return results, err
+}
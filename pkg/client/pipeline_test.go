@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClient_Pipelined(t *testing.T) {
+	c := newTestClient(t, Options{})
+	ctx := context.Background()
+
+	replies, err := c.Pipelined(ctx, func(p *Pipeline) {
+		p.Do("SET", "key", "value")
+		p.Do("GET", "key")
+		p.Do("NOSUCHCOMMAND")
+		p.Do("ECHO", "after")
+	})
+
+	var replyErr *Error
+	if !errors.As(err, &replyErr) {
+		t.Fatalf("Expected the error reply to be returned, got %v", err)
+	}
+	if len(replies) != 4 {
+		t.Fatalf("Expected 4 replies, got %d", len(replies))
+	}
+
+	if value, err := String(replies[1], nil); err != nil || value != "value" {
+		t.Errorf("Expected %q, got %q (%v)", "value", value, err)
+	}
+	if value, err := String(replies[3], nil); err != nil || value != "after" {
+		t.Errorf("Expected %q, got %q (%v)", "after", value, err)
+	}
+}
+
+func TestPipeline_Reuse(t *testing.T) {
+	c := newTestClient(t, Options{})
+	ctx := context.Background()
+	p := c.Pipeline()
+
+	p.Do("PING")
+	p.Do(struct{}{})
+	if _, err := p.Exec(ctx); err == nil {
+		t.Fatal("Expected an encoding error")
+	}
+
+	// Exec empties the pipeline, errors included
+	if p.Len() != 0 {
+		t.Errorf("Expected an empty pipeline, got %d commands", p.Len())
+	}
+
+	p.Do("PING")
+	replies, err := p.Exec(ctx)
+	if err != nil || len(replies) != 1 {
+		t.Errorf("Expected 1 reply, got %d (%v)", len(replies), err)
+	}
+}
+
+func TestClient_TxPipelined(t *testing.T) {
+	c := newTestClient(t, Options{})
+	ctx := context.Background()
+
+	results, err := c.TxPipelined(ctx, func(p *Pipeline) {
+		p.Do("SET", "key", "value")
+		p.Do("GET", "key")
+	})
+	if err != nil {
+		t.Fatalf("TxPipelined returned error: %v", err)
+	}
+	if value, _ := String(results[1], nil); len(results) != 2 || value != "value" {
+		t.Errorf("Expected the GET reply %q, got %v", "value", results)
+	}
+
+	// A command rejected while queueing aborts the whole transaction
+	_, err = c.TxPipelined(ctx, func(p *Pipeline) {
+		p.Do("SET", "key", "changed")
+		p.Do("NOSUCHCOMMAND")
+	})
+	var replyErr *Error
+	if !errors.As(err, &replyErr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if value, _ := c.Get(ctx, "key"); value != "value" {
+		t.Errorf("Expected the aborted transaction not to run, got %q", value)
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	c := newTestClient(t, Options{})
+	ctx := context.Background()
+	c.Set(ctx, "counter", 1, 0)
+
+	increment := func(tx *Tx) error {
+		value, err := Int64(tx.Do(ctx, "GET", "counter"))
+		if err != nil {
+			return err
+		}
+
+		// Another client modifies the watched key before EXEC
+		if value == 1 {
+			c.Set(ctx, "counter", 10, 0)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(p *Pipeline) {
+			p.Do("SET", "counter", value+1)
+		})
+		return err
+	}
+
+	if err := c.Watch(ctx, increment, "counter"); !errors.Is(err, ErrTxAborted) {
+		t.Fatalf("Expected ErrTxAborted, got %v", err)
+	}
+
+	// Retrying sees the new value
+	if err := c.Watch(ctx, increment, "counter"); err != nil {
+		t.Fatalf("Watch returned error: %v", err)
+	}
+	if value, _ := c.Get(ctx, "counter"); value != "11" {
+		t.Errorf("Expected %q, got %q", "11", value)
+	}
+}
+
+func TestClient_WatchReleasesKeys(t *testing.T) {
+	c := newTestClient(t, Options{PoolSize: 1})
+	ctx := context.Background()
+
+	// fn returns without running EXEC, so the keys must be unwatched
+	// before the connection is reused
+	c.Watch(ctx, func(tx *Tx) error { return nil }, "key")
+	c.Set(ctx, "key", "changed", 0)
+
+	if _, err := c.TxPipelined(ctx, func(p *Pipeline) { p.Do("PING") }); err != nil {
+		t.Errorf("Expected the next transaction to run, got %v", err)
+	}
+}
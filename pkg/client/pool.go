@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pool keeps up to a fixed number of open connections and reuses idle ones
+type pool struct {
+	dial        func(ctx context.Context) (*conn, error)
+	timeout     time.Duration
+	idleTimeout time.Duration
+	// slots holds a token for every connection in use, bounding their number
+	slots chan struct{}
+
+	mutex  sync.Mutex
+	idle   []*conn
+	total  int
+	closed bool
+}
+
+// newPool creates a pool that opens connections with dial
+func newPool(options Options, dial func(ctx context.Context) (*conn, error)) *pool {
+	return &pool{
+		dial:        dial,
+		timeout:     options.PoolTimeout,
+		idleTimeout: options.IdleTimeout,
+		slots:       make(chan struct{}, options.PoolSize),
+	}
+}
+
+// get returns an idle connection, or a new one if none is idle. It waits
+// for a connection to be released if the pool is full.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		<-p.slots
+		return nil, ErrClosed
+	}
+
+	for len(p.idle) > 0 {
+		cn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+
+		if p.idleTimeout > 0 && time.Since(cn.usedAt) > p.idleTimeout {
+			cn.close()
+			p.total--
+			continue
+		}
+
+		p.mutex.Unlock()
+		return cn, nil
+	}
+
+	p.total++
+	p.mutex.Unlock()
+
+	cn, err := p.dial(ctx)
+	if err != nil {
+		p.mutex.Lock()
+		p.total--
+		p.mutex.Unlock()
+		<-p.slots
+		return nil, err
+	}
+	return cn, nil
+}
+
+// acquire takes a slot, waiting up to the pool timeout
+func (p *pool) acquire(ctx context.Context) error {
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ErrPoolTimeout
+	}
+}
+
+// put releases a connection. Broken connections, and every connection once
+// the pool is closed, are closed instead of kept for reuse.
+func (p *pool) put(cn *conn) {
+	p.mutex.Lock()
+	if cn.broken.Load() || p.closed {
+		cn.close()
+		p.total--
+	} else {
+		cn.usedAt = time.Now()
+		p.idle = append(p.idle, cn)
+	}
+	p.mutex.Unlock()
+
+	<-p.slots
+}
+
+// close closes the idle connections and makes get fail from now on
+func (p *pool) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+
+	for _, cn := range p.idle {
+		cn.close()
+		p.total--
+	}
+	p.idle = nil
+}
+
+// stats returns the number of open and idle connections
+func (p *pool) stats() PoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return PoolStats{TotalConns: p.total, IdleConns: len(p.idle)}
+}
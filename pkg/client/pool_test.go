@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool_ReusesConnections(t *testing.T) {
+	c := newTestClient(t, Options{PoolSize: 2})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			key := "key" + strconv.Itoa(i)
+			if err := c.Set(ctx, key, i, 0); err != nil {
+				t.Errorf("Set returned error: %v", err)
+			}
+			if value, err := c.Get(ctx, key); err != nil || value != strconv.Itoa(i) {
+				t.Errorf("Expected %d, got %q (%v)", i, value, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := c.PoolStats()
+	if stats.TotalConns > 2 || stats.TotalConns != stats.IdleConns {
+		t.Errorf("Expected at most 2 idle connections, got %+v", stats)
+	}
+}
+
+func TestPool_Timeout(t *testing.T) {
+	c := newTestClient(t, Options{PoolSize: 1, PoolTimeout: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	// Hold the only connection inside Watch
+	held := make(chan struct{})
+	release := make(chan struct{})
+	go c.Watch(ctx, func(tx *Tx) error {
+		close(held)
+		<-release
+		return nil
+	})
+	<-held
+
+	if _, err := c.Ping(ctx); !errors.Is(err, ErrPoolTimeout) {
+		t.Errorf("Expected ErrPoolTimeout, got %v", err)
+	}
+
+	close(release)
+	if _, err := c.Ping(ctx); err != nil {
+		t.Errorf("Ping returned error after release: %v", err)
+	}
+}
+
+func TestPool_IdleTimeout(t *testing.T) {
+	_, addr := startServer(t)
+	ctx := context.Background()
+
+	dials := 0
+	dialer := &net.Dialer{}
+	c := NewClient(Options{
+		Addr:        addr,
+		IdleTimeout: time.Millisecond,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials++
+			return dialer.DialContext(ctx, network, addr)
+		},
+	})
+	defer c.Close()
+
+	c.Ping(ctx)
+	time.Sleep(5 * time.Millisecond)
+	c.Ping(ctx)
+
+	// The expired connection was replaced rather than reused
+	if dials != 2 {
+		t.Errorf("Expected 2 dials, got %d", dials)
+	}
+	if stats := c.PoolStats(); stats.TotalConns != 1 {
+		t.Errorf("Expected 1 connection, got %+v", stats)
+	}
+}
@@ -0,0 +1,409 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// DefaultChannelSize is the number of messages PubSub.Channel buffers
+const DefaultChannelSize = 100
+
+// reconnectDelay is how long PubSub.Channel waits before reconnecting
+// after a network error
+const reconnectDelay = 100 * time.Millisecond
+
+// Message is a message published on a channel
+type Message struct {
+	// Kind is "message", "pmessage" or "smessage"
+	Kind    string
+	Channel string
+	// Pattern is the pattern that matched the channel of a "pmessage"
+	Pattern string
+	Payload string
+}
+
+// Subscription confirms a subscribe or unsubscribe request
+type Subscription struct {
+	// Kind is "subscribe", "unsubscribe", "psubscribe", "punsubscribe",
+	// "ssubscribe" or "sunsubscribe"
+	Kind string
+	// Channel is the channel or pattern, empty when unsubscribing from
+	// nothing
+	Channel string
+	// Count is the number of subscriptions the connection still has
+	Count int64
+}
+
+// Pong is the reply to PubSub.Ping
+type Pong struct {
+	Payload string
+}
+
+// PubSub receives messages on a dedicated connection. It tracks its
+// subscriptions, and restores them when it reconnects after a network
+// error. It is safe for concurrent use, but messages must be received by a
+// single goroutine, either with Receive or through Channel.
+type PubSub struct {
+	client *Client
+
+	mutex    sync.Mutex
+	conn     *conn
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	shards   map[string]struct{}
+	closed   bool
+
+	messages     chan *Message
+	startChannel sync.Once
+	done         chan struct{}
+}
+
+// NewPubSub creates a receiver without subscriptions. It connects when
+// first used.
+func (c *Client) NewPubSub() *PubSub {
+	return &PubSub{
+		client:   c,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		shards:   make(map[string]struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Subscribe creates a receiver subscribed to the channels
+func (c *Client) Subscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	p := c.NewPubSub()
+	if err := p.Subscribe(ctx, channels...); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// PSubscribe creates a receiver subscribed to the patterns
+func (c *Client) PSubscribe(ctx context.Context, patterns ...string) (*PubSub, error) {
+	p := c.NewPubSub()
+	if err := p.PSubscribe(ctx, patterns...); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// SSubscribe creates a receiver subscribed to the shard channels
+func (c *Client) SSubscribe(ctx context.Context, channels ...string) (*PubSub, error) {
+	p := c.NewPubSub()
+	if err := p.SSubscribe(ctx, channels...); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Subscribe subscribes to channels. The confirmations are received as
+// *Subscription.
+func (p *PubSub) Subscribe(ctx context.Context, channels ...string) error {
+	return p.subscribe(ctx, "SUBSCRIBE", p.channels, channels)
+}
+
+// PSubscribe subscribes to glob-style patterns
+func (p *PubSub) PSubscribe(ctx context.Context, patterns ...string) error {
+	return p.subscribe(ctx, "PSUBSCRIBE", p.patterns, patterns)
+}
+
+// SSubscribe subscribes to shard channels
+func (p *PubSub) SSubscribe(ctx context.Context, channels ...string) error {
+	return p.subscribe(ctx, "SSUBSCRIBE", p.shards, channels)
+}
+
+// Unsubscribe unsubscribes from channels, or from every channel if none
+// are given
+func (p *PubSub) Unsubscribe(ctx context.Context, channels ...string) error {
+	return p.unsubscribe(ctx, "UNSUBSCRIBE", p.channels, channels)
+}
+
+// PUnsubscribe unsubscribes from patterns, or from every pattern if none
+// are given
+func (p *PubSub) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	return p.unsubscribe(ctx, "PUNSUBSCRIBE", p.patterns, patterns)
+}
+
+// SUnsubscribe unsubscribes from shard channels, or from every shard
+// channel if none are given
+func (p *PubSub) SUnsubscribe(ctx context.Context, channels ...string) error {
+	return p.unsubscribe(ctx, "SUNSUBSCRIBE", p.shards, channels)
+}
+
+// Ping sends PING, whose reply is received as a *Pong
+func (p *PubSub) Ping(ctx context.Context, payload ...string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	cn, _, err := p.connection(ctx)
+	if err != nil {
+		return err
+	}
+	return p.send(ctx, cn, "PING", payload)
+}
+
+// subscribe records the subscriptions and sends the command
+func (p *PubSub) subscribe(ctx context.Context, command string, set map[string]struct{}, names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("redis-lite: %s requires at least one name", strings.ToLower(command))
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+
+	cn, fresh, err := p.connection(ctx)
+	if err != nil || fresh {
+		// A new connection has already subscribed to every recorded name
+		return err
+	}
+	return p.send(ctx, cn, command, names)
+}
+
+// unsubscribe forgets the subscriptions and sends the command
+func (p *PubSub) unsubscribe(ctx context.Context, command string, set map[string]struct{}, names []string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(names) == 0 {
+		clear(set)
+	}
+	for _, name := range names {
+		delete(set, name)
+	}
+
+	cn, fresh, err := p.connection(ctx)
+	if err != nil || fresh {
+		return err
+	}
+	return p.send(ctx, cn, command, names)
+}
+
+// connection returns the current connection, connecting and restoring the
+// subscriptions if there is none or it is broken. fresh reports whether a
+// new connection was made. Must be called with the mutex held.
+func (p *PubSub) connection(ctx context.Context) (cn *conn, fresh bool, err error) {
+	if p.closed {
+		return nil, false, ErrClosed
+	}
+	if p.conn != nil && !p.conn.broken.Load() {
+		return p.conn, false, nil
+	}
+
+	if p.conn != nil {
+		p.conn.close()
+		p.conn = nil
+	}
+
+	cn, err = p.client.dial(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for command, set := range map[string]map[string]struct{}{
+		"SUBSCRIBE":  p.channels,
+		"PSUBSCRIBE": p.patterns,
+		"SSUBSCRIBE": p.shards,
+	} {
+		if len(set) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(set))
+		for name := range set {
+			names = append(names, name)
+		}
+		if err := p.send(ctx, cn, command, names); err != nil {
+			cn.close()
+			return nil, false, err
+		}
+	}
+
+	p.conn = cn
+	return cn, true, nil
+}
+
+// send writes a command without waiting for its replies
+func (p *PubSub) send(ctx context.Context, cn *conn, command string, names []string) error {
+	args := make([]any, 0, len(names)+1)
+	args = append(args, command)
+	for _, name := range names {
+		args = append(args, name)
+	}
+
+	message, err := encodeCommand(args)
+	if err != nil {
+		return err
+	}
+	return cn.write(ctx, message)
+}
+
+// Receive waits until the context is done for the next message, which is
+// a *Message, *Subscription or *Pong. After a network error, the next call
+// reconnects.
+func (p *PubSub) Receive(ctx context.Context) (any, error) {
+	p.mutex.Lock()
+	cn, _, err := p.connection(ctx)
+	p.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := cn.read(ctx, 0)
+	if err != nil {
+		if p.isClosed() {
+			return nil, ErrClosed
+		}
+		return nil, err
+	}
+	return parsePubSubMessage(message)
+}
+
+// ReceiveMessage waits for the next published message, skipping
+// subscription confirmations and pongs
+func (p *PubSub) ReceiveMessage(ctx context.Context) (*Message, error) {
+	for {
+		received, err := p.Receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if message, ok := received.(*Message); ok {
+			return message, nil
+		}
+	}
+}
+
+// Channel returns a channel receiving the published messages. It is closed
+// when the receiver is closed. Messages are dropped while reconnecting
+// after a network error, as pub/sub does not store them.
+func (p *PubSub) Channel() <-chan *Message {
+	p.startChannel.Do(func() {
+		p.messages = make(chan *Message, DefaultChannelSize)
+		go p.receiveMessages()
+	})
+	return p.messages
+}
+
+// receiveMessages feeds the channel returned by Channel
+func (p *PubSub) receiveMessages() {
+	defer close(p.messages)
+
+	for {
+		message, err := p.ReceiveMessage(context.Background())
+		if errors.Is(err, ErrClosed) {
+			return
+		}
+		if err != nil {
+			select {
+			case <-time.After(reconnectDelay):
+				continue
+			case <-p.done:
+				return
+			}
+		}
+
+		select {
+		case p.messages <- message:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Close closes the connection and stops Channel
+func (p *PubSub) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+
+	if p.conn != nil {
+		return p.conn.close()
+	}
+	return nil
+}
+
+// isClosed reports whether Close was called
+func (p *PubSub) isClosed() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.closed
+}
+
+// parsePubSubMessage converts a message received by a subscribed
+// connection. Pushed messages are arrays under RESP2 and push messages
+// under RESP3.
+func parsePubSubMessage(message *resp.Message) (any, error) {
+	if _, err := replyError(message); err != nil {
+		return nil, err
+	}
+
+	// Under RESP3, PING gets its regular reply even while subscribed
+	if message.Type == resp.SimpleString || message.Type == resp.BulkString {
+		payload, _ := message.AsString()
+		if payload == "PONG" && message.Type == resp.SimpleString {
+			payload = ""
+		}
+		return &Pong{Payload: payload}, nil
+	}
+
+	elements, err := message.AsArray()
+	if err != nil || len(elements) < 2 {
+		return nil, fmt.Errorf("redis-lite: unexpected pub/sub message %s", message)
+	}
+
+	kind, err := elements[0].AsString()
+	if err != nil {
+		return nil, fmt.Errorf("redis-lite: unexpected pub/sub message %s", message)
+	}
+	kind = strings.ToLower(kind)
+
+	text := func(i int) string {
+		value, _ := elements[i].AsString()
+		return value
+	}
+
+	switch kind {
+	case "message", "smessage":
+		if len(elements) != 3 {
+			break
+		}
+		return &Message{Kind: kind, Channel: text(1), Payload: text(2)}, nil
+	case "pmessage":
+		if len(elements) != 4 {
+			break
+		}
+		return &Message{Kind: kind, Pattern: text(1), Channel: text(2), Payload: text(3)}, nil
+	case "subscribe", "unsubscribe", "psubscribe", "punsubscribe", "ssubscribe", "sunsubscribe":
+		if len(elements) != 3 {
+			break
+		}
+		count, err := elements[2].AsInteger()
+		if err != nil {
+			break
+		}
+		return &Subscription{Kind: kind, Channel: text(1), Count: count}, nil
+	case "pong":
+		return &Pong{Payload: text(1)}, nil
+	}
+
+	return nil, fmt.Errorf("redis-lite: unexpected pub/sub message %s", message)
+}
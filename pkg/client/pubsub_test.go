@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// receive waits for the next message and checks it
+func receive(t *testing.T, p *PubSub, want any) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	got, err := p.Receive(ctx)
+	if err != nil {
+		t.Fatalf("Receive returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestPubSub_Receive(t *testing.T) {
+	for _, protocol := range []int{resp.RESP2, resp.RESP3} {
+		t.Run(fmt.Sprintf("RESP%d", protocol), func(t *testing.T) {
+			c := newTestClient(t, Options{Protocol: protocol})
+			ctx := context.Background()
+
+			p, err := c.Subscribe(ctx, "news")
+			if err != nil {
+				t.Fatalf("Subscribe returned error: %v", err)
+			}
+			defer p.Close()
+
+			receive(t, p, &Subscription{Kind: "subscribe", Channel: "news", Count: 1})
+
+			p.PSubscribe(ctx, "news.*")
+			receive(t, p, &Subscription{Kind: "psubscribe", Channel: "news.*", Count: 2})
+
+			if receivers, err := c.Publish(ctx, "news", "hello"); err != nil || receivers != 1 {
+				t.Fatalf("Expected 1 receiver, got %d (%v)", receivers, err)
+			}
+			receive(t, p, &Message{Kind: "message", Channel: "news", Payload: "hello"})
+
+			c.Publish(ctx, "news.sports", "goal")
+			receive(t, p, &Message{Kind: "pmessage", Pattern: "news.*", Channel: "news.sports", Payload: "goal"})
+
+			p.Ping(ctx, "hi")
+			receive(t, p, &Pong{Payload: "hi"})
+
+			p.Unsubscribe(ctx)
+			receive(t, p, &Subscription{Kind: "unsubscribe", Channel: "news", Count: 1})
+		})
+	}
+}
+
+func TestPubSub_Channel(t *testing.T) {
+	c := newTestClient(t, Options{})
+	ctx := context.Background()
+
+	p, err := c.Subscribe(ctx, "news")
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	messages := p.Channel()
+
+	// Publish once the subscription is active
+	for {
+		if receivers, _ := c.Publish(ctx, "news", "hello"); receivers == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case message := <-messages:
+		if message.Channel != "news" || message.Payload != "hello" {
+			t.Errorf("Unexpected message %+v", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No message received")
+	}
+
+	p.Close()
+	select {
+	case _, open := <-messages:
+		if open {
+			// Drain a message published before Close
+			if _, open = <-messages; open {
+				t.Error("Expected the channel to be closed")
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Channel was not closed")
+	}
+}
+
+func TestPubSub_Reconnect(t *testing.T) {
+	c := newTestClient(t, Options{})
+	ctx := context.Background()
+
+	p, err := c.Subscribe(ctx, "news")
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	defer p.Close()
+	receive(t, p, &Subscription{Kind: "subscribe", Channel: "news", Count: 1})
+
+	// Break the connection, as a network failure would
+	p.mutex.Lock()
+	p.conn.netConn.Close()
+	p.mutex.Unlock()
+
+	if _, err := p.Receive(ctx); err == nil {
+		t.Fatal("Expected an error from the closed connection")
+	}
+
+	// The next receive reconnects and restores the subscription
+	receive(t, p, &Subscription{Kind: "subscribe", Channel: "news", Count: 1})
+}
+
+func TestPubSub_Closed(t *testing.T) {
+	c := newTestClient(t, Options{})
+	p := c.NewPubSub()
+	p.Close()
+
+	if err := p.Subscribe(context.Background(), "news"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	if _, err := p.Receive(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
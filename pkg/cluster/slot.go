@@ -0,0 +1,44 @@
+package cluster
+
+// SlotCount is the number of hash slots the keyspace is divided into
+const SlotCount = 16384
+
+// KeySlot returns the hash slot of a key, or of a shard channel name.
+//
+// As in Redis Cluster, if the key contains a non-empty hash tag between the
+// first '{' and the following '}', only the tag is hashed, so that related
+// keys can be forced into the same slot.
+func KeySlot(key string) uint16 {
+	for start := 0; start < len(key); start++ {
+		if key[start] != '{' {
+			continue
+		}
+		for end := start + 1; end < len(key); end++ {
+			if key[end] == '}' {
+				if end > start+1 {
+					key = key[start+1 : end]
+				}
+				return crc16(key) % SlotCount
+			}
+		}
+		break
+	}
+
+	return crc16(key) % SlotCount
+}
+
+// crc16 computes the CRC-16/XMODEM checksum used by Redis Cluster
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,47 @@
+package cluster
+
+import "testing"
+
+func TestKeySlot(t *testing.T) {
+	tests := []struct {
+		key  string
+		want uint16
+	}{
+		// Values from the Redis Cluster specification and CLUSTER KEYSLOT
+		{key: "123456789", want: 12739},
+		{key: "foo", want: 12182},
+		{key: "bar", want: 5061},
+		{key: "", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := KeySlot(tt.key); got != tt.want {
+			t.Errorf("KeySlot(%q) = %d, want %d", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestKeySlot_HashTags(t *testing.T) {
+	if KeySlot("{user1000}.following") != KeySlot("{user1000}.followers") {
+		t.Error("Keys with the same hash tag must map to the same slot")
+	}
+
+	if KeySlot("{user1000}.following") != KeySlot("user1000") {
+		t.Error("Only the hash tag must be hashed")
+	}
+
+	// An empty tag means the whole key is hashed
+	if KeySlot("foo{}{bar}") != crc16("foo{}{bar}")%SlotCount {
+		t.Error("Empty hash tag must hash the whole key")
+	}
+
+	// Only the first tag counts
+	if KeySlot("foo{{bar}}zap") != KeySlot("{bar") {
+		t.Error("Expected tag to end at the first closing brace")
+	}
+
+	// An unterminated tag hashes the whole key
+	if KeySlot("foo{bar") != crc16("foo{bar")%SlotCount {
+		t.Error("Unterminated hash tag must hash the whole key")
+	}
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// AOFRewriter rewrites the append-only file in the background
+type AOFRewriter interface {
+	RewriteAOF() error
+}
+
+// BgRewriteAOFCommand implements the BGREWRITEAOF command
+type BgRewriteAOFCommand struct {
+	rewriter AOFRewriter
+}
+
+// NewBgRewriteAOFCommand creates a new BGREWRITEAOF command rewriting
+// through rewriter
+func NewBgRewriteAOFCommand(rewriter AOFRewriter) *BgRewriteAOFCommand {
+	return &BgRewriteAOFCommand{rewriter: rewriter}
+}
+
+// Name returns the command name
+func (c *BgRewriteAOFCommand) Name() string {
+	return "BGREWRITEAOF"
+}
+
+// Validate checks if the BGREWRITEAOF command arguments are valid
+func (c *BgRewriteAOFCommand) Validate(args []*resp.Message) error {
+	// BGREWRITEAOF takes no arguments
+	if len(args) != 0 {
+		return fmt.Errorf("wrong number of arguments for 'bgrewriteaof' command")
+	}
+	return nil
+}
+
+// Execute starts rewriting the append-only file. Clients keep being served
+// and their changes logged while it runs.
+func (c *BgRewriteAOFCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	err := c.rewriter.RewriteAOF()
+	if errors.Is(err, persistence.ErrRewriteInProgress) {
+		return resp.NewError("ERR Background append only file rewriting already in progress"), nil
+	}
+	if err != nil {
+		return resp.NewError("ERR Background append only file rewriting failed: " + err.Error()), nil
+	}
+	return resp.NewSimpleString("Background append only file rewriting started"), nil
+}
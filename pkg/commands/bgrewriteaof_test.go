@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// fakeRewriter counts rewrites and fails them with err
+type fakeRewriter struct {
+	rewrites int
+	err      error
+}
+
+func (f *fakeRewriter) RewriteAOF() error {
+	f.rewrites++
+	return f.err
+}
+
+func TestBgRewriteAOFCommand_Validate(t *testing.T) {
+	cmd := NewBgRewriteAOFCommand(&fakeRewriter{})
+
+	if cmd.Name() != "BGREWRITEAOF" {
+		t.Errorf("Expected command name 'BGREWRITEAOF', got '%s'", cmd.Name())
+	}
+	if err := cmd.Validate(nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := cmd.Validate(stringArgs("now")); err == nil {
+		t.Error("Expected an error for an argument")
+	}
+}
+
+func TestBgRewriteAOFCommand_Execute(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected *resp.Message
+	}{
+		{"started", nil, resp.NewSimpleString("Background append only file rewriting started")},
+		{"in progress", persistence.ErrRewriteInProgress, resp.NewError("ERR Background append only file rewriting already in progress")},
+		{"failed", errors.New("disk full"), resp.NewError("ERR Background append only file rewriting failed: disk full")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewriter := &fakeRewriter{err: tt.err}
+			response, err := NewBgRewriteAOFCommand(rewriter).Execute(nil, storage.NewMemoryStore())
+			if err != nil {
+				t.Fatalf("Execute() returned error: %v", err)
+			}
+			if response.Type != tt.expected.Type || response.Value != tt.expected.Value {
+				t.Errorf("Expected %s, got %s", tt.expected, response)
+			}
+			if rewriter.rewrites != 1 {
+				t.Errorf("Expected 1 rewrite, got %d", rewriter.rewrites)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// configHelp is the reply of CONFIG HELP
+var configHelp = []string{
+	"CONFIG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"GET <pattern> [<pattern> ...]",
+	"    Return parameters matching the glob-like <pattern> and their values.",
+	"SET <directive> <value> [<directive> <value> ...]",
+	"    Set the configuration <directive> to <value>.",
+	"RESETSTAT",
+	"    Reset statistics reported by the server.",
+	"REWRITE",
+	"    Rewrite the configuration file.",
+	"HELP",
+	"    Print this help.",
+}
+
+// ConfigCommand implements CONFIG GET, SET, RESETSTAT and REWRITE
+type ConfigCommand struct {
+	manager    *config.Manager
+	resetStats func()
+}
+
+// NewConfigCommand creates a new CONFIG command on the given configuration.
+// resetStats is called by CONFIG RESETSTAT.
+func NewConfigCommand(manager *config.Manager, resetStats func()) *ConfigCommand {
+	return &ConfigCommand{manager: manager, resetStats: resetStats}
+}
+
+// Name returns the command name
+func (c *ConfigCommand) Name() string {
+	return "CONFIG"
+}
+
+// Validate checks if the CONFIG command arguments are valid
+func (c *ConfigCommand) Validate(args []*resp.Message) error {
+	// CONFIG requires a subcommand
+	if len(args) == 0 {
+		return fmt.Errorf("wrong number of arguments for 'config' command")
+	}
+	return nil
+}
+
+// Execute processes the CONFIG command
+func (c *ConfigCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		value, ok := messageString(arg)
+		if !ok {
+			return resp.NewError("ERR invalid argument type for CONFIG"), nil
+		}
+		values[i] = value
+	}
+
+	subcommand, values := values[0], values[1:]
+	switch strings.ToUpper(subcommand) {
+	case "GET":
+		if len(values) == 0 {
+			return resp.NewError("ERR wrong number of arguments for 'config|get' command"), nil
+		}
+		return c.get(values), nil
+
+	case "SET":
+		if len(values) == 0 || len(values)%2 != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'config|set' command"), nil
+		}
+		return c.set(values), nil
+
+	case "RESETSTAT":
+		if len(values) != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'config|resetstat' command"), nil
+		}
+		if c.resetStats != nil {
+			c.resetStats()
+		}
+		return resp.NewSimpleString("OK"), nil
+
+	case "REWRITE":
+		if len(values) != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'config|rewrite' command"), nil
+		}
+		if err := c.manager.Rewrite(); err != nil {
+			return resp.NewError("ERR Rewriting config file: " + err.Error()), nil
+		}
+		return resp.NewSimpleString("OK"), nil
+
+	case "HELP":
+		return stringArray(configHelp), nil
+
+	default:
+		return resp.NewError(fmt.Sprintf("ERR unknown subcommand '%s'. Try CONFIG HELP.", subcommand)), nil
+	}
+}
+
+// get replies with the parameters matching the patterns and their values
+func (c *ConfigCommand) get(patterns []string) *resp.Message {
+	settings := c.manager.Get(patterns...)
+	elements := make([]*resp.Message, 0, 2*len(settings))
+	for _, setting := range settings {
+		elements = append(elements, resp.NewBulkString(setting.Name), resp.NewBulkString(setting.Value))
+	}
+	return resp.NewMap(elements)
+}
+
+// set changes the parameters given as name, value pairs, all or none
+func (c *ConfigCommand) set(pairs []string) *resp.Message {
+	settings := make([]config.Setting, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		settings = append(settings, config.Setting{Name: pairs[i], Value: pairs[i+1]})
+	}
+
+	err := c.manager.Set(settings...)
+	if err == nil {
+		return resp.NewSimpleString("OK")
+	}
+
+	var paramErr *config.ParameterError
+	if !errors.As(err, &paramErr) {
+		return resp.NewError("ERR CONFIG SET failed - " + err.Error())
+	}
+	if errors.Is(err, config.ErrUnknownParameter) {
+		return resp.NewError(fmt.Sprintf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", paramErr.Name))
+	}
+	return resp.NewError(fmt.Sprintf("ERR CONFIG SET failed (possibly related to argument '%s') - %v", paramErr.Name, paramErr.Err))
+}
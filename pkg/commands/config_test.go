@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func TestConfigCommand_Name(t *testing.T) {
+	cmd := NewConfigCommand(config.NewManager(config.Default()), nil)
+	if cmd.Name() != "CONFIG" {
+		t.Errorf("Expected command name 'CONFIG', got '%s'", cmd.Name())
+	}
+}
+
+func TestConfigCommand_Validate(t *testing.T) {
+	cmd := NewConfigCommand(config.NewManager(config.Default()), nil)
+
+	if err := cmd.Validate([]*resp.Message{}); err == nil {
+		t.Error("Expected error for missing subcommand")
+	}
+
+	if err := cmd.Validate(stringArgs("GET", "*")); err != nil {
+		t.Errorf("Expected valid arguments, got %v", err)
+	}
+}
+
+func TestConfigCommand_Get(t *testing.T) {
+	cmd := NewConfigCommand(config.NewManager(config.Default()), nil)
+	store := storage.NewMemoryStore()
+
+	response, _ := cmd.Execute(stringArgs("GET", "maxmemory*", "port"), store)
+	if response.Type != resp.Map {
+		t.Fatalf("Expected a map, got %s", response)
+	}
+
+	elements, _ := response.AsArray()
+	var got []string
+	for _, element := range elements {
+		value, _ := element.AsString()
+		got = append(got, value)
+	}
+	expected := "port 6379 maxmemory 0 maxmemory-policy noeviction maxmemory-samples 5"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("Expected %q, got %q", expected, strings.Join(got, " "))
+	}
+}
+
+func TestConfigCommand_Set(t *testing.T) {
+	manager := config.NewManager(config.Default())
+	cmd := NewConfigCommand(manager, nil)
+	store := storage.NewMemoryStore()
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"single", []string{"SET", "maxmemory", "100mb"}, "OK"},
+		{"several", []string{"set", "timeout", "30", "maxmemory-policy", "allkeys-lru"}, "OK"},
+		{"odd arguments", []string{"SET", "timeout"}, "ERR wrong number of arguments for 'config|set' command"},
+		{"unknown", []string{"SET", "nosuch", "1"}, "ERR Unknown option or number of arguments for CONFIG SET - 'nosuch'"},
+		{"invalid", []string{"SET", "maxclients", "0"},
+			"ERR CONFIG SET failed (possibly related to argument 'maxclients') - argument must be between 1 and 2147483647 inclusive"},
+		{"immutable", []string{"SET", "port", "7000"},
+			"ERR CONFIG SET failed (possibly related to argument 'port') - can't set immutable config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, _ := cmd.Execute(stringArgs(tt.args...), store)
+			if got, _ := response.AsString(); got != tt.expected {
+				t.Errorf("Expected %q, got %s", tt.expected, response)
+			}
+		})
+	}
+
+	c := manager.Config()
+	if c.MaxMemory != 100*1024*1024 || c.Timeout.Seconds() != 30 || c.MaxMemoryPolicy != config.PolicyAllKeysLRU {
+		t.Errorf("Expected the settings to apply, got %+v", c)
+	}
+}
+
+func TestConfigCommand_ResetStat(t *testing.T) {
+	reset := false
+	cmd := NewConfigCommand(config.NewManager(config.Default()), func() { reset = true })
+
+	response, _ := cmd.Execute(stringArgs("RESETSTAT"), storage.NewMemoryStore())
+	if response.Type != resp.SimpleString || !reset {
+		t.Errorf("Expected OK and stats reset, got %s", response)
+	}
+}
+
+func TestConfigCommand_Rewrite(t *testing.T) {
+	store := storage.NewMemoryStore()
+
+	cmd := NewConfigCommand(config.NewManager(config.Default()), nil)
+	response, _ := cmd.Execute(stringArgs("REWRITE"), store)
+	if response.Type != resp.Error {
+		t.Errorf("Expected error without a config file, got %s", response)
+	}
+
+	path := filepath.Join(t.TempDir(), "redis-lite.conf")
+	if err := os.WriteFile(path, []byte("# keep me\ntimeout 0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	c, err := config.Load(path, nil)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	cmd = NewConfigCommand(config.NewManager(c), nil)
+	cmd.Execute(stringArgs("SET", "timeout", "10"), store)
+	response, _ = cmd.Execute(stringArgs("REWRITE"), store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "# keep me\ntimeout 10\n" {
+		t.Errorf("Expected the file to be rewritten, got %q", content)
+	}
+}
+
+func TestConfigCommand_UnknownSubcommand(t *testing.T) {
+	cmd := NewConfigCommand(config.NewManager(config.Default()), nil)
+
+	response, _ := cmd.Execute(stringArgs("bogus"), storage.NewMemoryStore())
+	if got, _ := response.AsString(); got != "ERR unknown subcommand 'bogus'. Try CONFIG HELP." {
+		t.Errorf("Expected unknown subcommand error, got %s", response)
+	}
+
+	response, _ = cmd.Execute(stringArgs("HELP"), storage.NewMemoryStore())
+	if lines, err := response.AsArray(); err != nil || len(lines) == 0 {
+		t.Errorf("Expected help lines, got %s", response)
+	}
+}
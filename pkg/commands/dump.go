@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// DumpCommand implements the DUMP command
+type DumpCommand struct{}
+
+// NewDumpCommand creates a new DUMP command
+func NewDumpCommand() *DumpCommand {
+	return &DumpCommand{}
+}
+
+// Name returns the command name
+func (c *DumpCommand) Name() string {
+	return "DUMP"
+}
+
+// Validate checks if the DUMP command arguments are valid
+func (c *DumpCommand) Validate(args []*resp.Message) error {
+	if len(args) != 1 {
+		return fmt.Errorf("wrong number of arguments for 'dump' command")
+	}
+	return nil
+}
+
+// Execute replies with the value of a key serialized as a DUMP payload,
+// which RESTORE loads back here or in Redis, or nil if the key does not
+// exist. The payload does not hold the key's TTL.
+func (c *DumpCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	key, ok := messageString(args[0])
+	if !ok {
+		return resp.NewError("ERR invalid key type"), nil
+	}
+
+	value, exists := store.Get(key)
+	if !exists {
+		return resp.NewNullBulkString(), nil
+	}
+
+	payload, err := persistence.EncodeDump(&persistence.Entry{Type: persistence.TypeString, String: value}, true)
+	if err != nil {
+		return resp.NewError("ERR " + err.Error()), nil
+	}
+	return resp.NewBulkBytes(payload), nil
+}
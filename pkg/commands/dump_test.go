@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func TestDumpCommand_Validate(t *testing.T) {
+	cmd := NewDumpCommand()
+
+	if cmd.Name() != "DUMP" {
+		t.Errorf("Expected command name 'DUMP', got '%s'", cmd.Name())
+	}
+	if err := cmd.Validate(stringArgs("key")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := cmd.Validate(stringArgs("key", "extra")); err == nil {
+		t.Error("Expected an error for two arguments")
+	}
+}
+
+func TestDumpCommand_Execute(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.SetWithExpiry("key", []byte("10"), time.Now().Add(time.Hour))
+
+	// The payload of Redis 6.2 for the same value
+	expected := "\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n"
+	response, _ := NewDumpCommand().Execute(stringArgs("key"), store)
+	if response.Type != resp.BulkString || string(response.Value.([]byte)) != expected {
+		t.Errorf("Expected %q, got %s", expected, response)
+	}
+
+	response, _ = NewDumpCommand().Execute(stringArgs("missing"), store)
+	if !response.IsNull() {
+		t.Errorf("Expected nil for a missing key, got %s", response)
+	}
+}
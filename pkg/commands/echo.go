@@ -38,7 +38,7 @@ func (c *EchoCommand) Execute(args []*resp.Message, store storage.Store) (*resp.
 		if arg.Value == nil {
 			return resp.NewNullBulkString(), nil
 		}
-		return resp.NewBulkString(arg.Value.(string)), nil
+		return resp.NewBulkBytes(arg.Value.([]byte)), nil
 	case resp.SimpleString:
 		return resp.NewBulkString(arg.Value.(string)), nil
 	case resp.Integer:
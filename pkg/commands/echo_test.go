@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/tsinivuo/redis-lite/pkg/resp"
@@ -69,19 +70,19 @@ func TestEchoCommand_Execute(t *testing.T) {
 			name:         "bulk string argument",
 			args:         []*resp.Message{resp.NewBulkString("hello world")},
 			expectedType: resp.BulkString,
-			expectedVal:  "hello world",
+			expectedVal:  []byte("hello world"),
 		},
 		{
 			name:         "simple string argument",
 			args:         []*resp.Message{resp.NewSimpleString("test")},
 			expectedType: resp.BulkString,
-			expectedVal:  "test",
+			expectedVal:  []byte("test"),
 		},
 		{
 			name:         "integer argument",
 			args:         []*resp.Message{resp.NewInteger(42)},
 			expectedType: resp.BulkString,
-			expectedVal:  "42",
+			expectedVal:  []byte("42"),
 		},
 		{
 			name:         "null bulk string argument",
@@ -102,7 +103,7 @@ func TestEchoCommand_Execute(t *testing.T) {
 				t.Errorf("Expected response type %s, got %s", tc.expectedType, response.Type)
 			}
 
-			if response.Value != tc.expectedVal {
+			if !reflect.DeepEqual(response.Value, tc.expectedVal) {
 				t.Errorf("Expected response value %v, got %v", tc.expectedVal, response.Value)
 			}
 		})
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// ExportCommand implements the EXPORT command, which replies with the
+// keyspace as JSON lines, in the format of persistence.WriteJSON
+type ExportCommand struct {
+	source persistence.Source
+}
+
+// NewExportCommand creates a new EXPORT command exporting the keys of
+// source
+func NewExportCommand(source persistence.Source) *ExportCommand {
+	return &ExportCommand{source: source}
+}
+
+// Name returns the command name
+func (c *ExportCommand) Name() string {
+	return "EXPORT"
+}
+
+// Validate checks if the EXPORT command arguments are valid
+func (c *ExportCommand) Validate(args []*resp.Message) error {
+	_, err := parseExportPattern(args)
+	return err
+}
+
+// parseExportPattern parses "[MATCH pattern]"
+func parseExportPattern(args []*resp.Message) (string, error) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	option, ok := messageString(args[0])
+	if len(args) != 2 || !ok || strings.ToUpper(option) != "MATCH" {
+		return "", fmt.Errorf("syntax error")
+	}
+	pattern, ok := messageString(args[1])
+	if !ok {
+		return "", fmt.Errorf("syntax error")
+	}
+	return pattern, nil
+}
+
+// Execute replies with the keys matching the pattern, or every key, as
+// JSON lines in a bulk string
+func (c *ExportCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	pattern, err := parseExportPattern(args)
+	if err != nil {
+		return resp.NewError("ERR " + err.Error()), nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := persistence.WriteJSON(&buf, c.source, pattern, time.Now()); err != nil {
+		return resp.NewError("ERR " + err.Error()), nil
+	}
+	return resp.NewBulkBytes(buf.Bytes()), nil
+}
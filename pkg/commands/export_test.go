@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func TestExportCommand_Validate(t *testing.T) {
+	cmd := NewExportCommand(storage.NewMemoryStore())
+
+	if cmd.Name() != "EXPORT" {
+		t.Errorf("Expected command name 'EXPORT', got '%s'", cmd.Name())
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"no args", []string{}, false},
+		{"match", []string{"match", "user:*"}, false},
+		{"missing pattern", []string{"MATCH"}, true},
+		{"unknown option", []string{"COUNT", "10"}, true},
+		{"extra args", []string{"MATCH", "a", "b"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := cmd.Validate(stringArgs(tt.args...)); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExportCommand_Execute(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Set("user:1", []byte("alice"))
+	store.Set("user:2", []byte("bob"))
+	store.Set("session", []byte("x"))
+	store.SetWithExpiry("temp", []byte("y"), time.Now().Add(time.Hour))
+	cmd := NewExportCommand(store)
+
+	response, _ := cmd.Execute(stringArgs("MATCH", "user:*"), store)
+	expected := `{"key":"user:1","type":"string","value":"alice"}
+{"key":"user:2","type":"string","value":"bob"}
+`
+	if response.Type != resp.BulkString || string(response.Value.([]byte)) != expected {
+		t.Errorf("Expected %q, got %s", expected, response)
+	}
+
+	// The export imports back into an empty store
+	response, _ = cmd.Execute(nil, store)
+	target := storage.NewMemoryStore()
+	reply, _ := NewImportCommand().Execute([]*resp.Message{response}, target)
+	if reply.Type != resp.Integer || reply.Value.(int64) != 4 {
+		t.Fatalf("Expected 4 keys imported, got %s", reply)
+	}
+	if value, _ := target.Get("user:2"); string(value) != "bob" {
+		t.Errorf("Expected 'bob', got %q", value)
+	}
+	target.Snapshot(func(records []storage.Record) error {
+		for _, record := range records {
+			ttl := time.Until(record.ExpiresAt)
+			if record.Key == "temp" && (ttl <= 59*time.Minute || ttl > time.Hour) {
+				t.Errorf("Expected the TTL to be kept, got %v", ttl)
+			}
+		}
+		return nil
+	})
+}
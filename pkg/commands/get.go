@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 
+	"github.com/tsinivuo/redis-lite/pkg/events"
 	"github.com/tsinivuo/redis-lite/pkg/resp"
 	"github.com/tsinivuo/redis-lite/pkg/storage"
 )
@@ -41,7 +42,7 @@ func (c *GetCommand) Execute(args []*resp.Message, store storage.Store) (*resp.M
 		if keyArg.Value == nil {
 			return resp.NewError("ERR key cannot be null"), nil
 		}
-		key = keyArg.Value.(string)
+		key = string(keyArg.Value.([]byte))
 	case resp.SimpleString:
 		key = keyArg.Value.(string)
 	default:
@@ -51,10 +52,12 @@ func (c *GetCommand) Execute(args []*resp.Message, store storage.Store) (*resp.M
 	// Retrieve the value from storage
 	value, exists := store.Get(key)
 	if !exists {
+		store.Notify(events.KeyMiss, "keymiss", key)
+
 		// Return null bulk string for non-existent keys
 		return resp.NewNullBulkString(), nil
 	}
 
-	// Return the value as a bulk string
-	return resp.NewBulkString(value), nil
+	// Return the stored value as a bulk string, without copying it
+	return resp.NewBulkBytes(value), nil
 }
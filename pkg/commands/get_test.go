@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/tsinivuo/redis-lite/pkg/resp"
@@ -54,8 +55,8 @@ func TestGetCommand_Execute(t *testing.T) {
 	store := storage.NewMemoryStore()
 
 	// Pre-populate store with test data
-	store.Set("existing_key", "existing_value")
-	store.Set("empty_value", "")
+	store.Set("existing_key", []byte("existing_value"))
+	store.Set("empty_value", []byte(""))
 
 	tests := []struct {
 		name         string
@@ -124,14 +125,14 @@ func TestGetCommand_Execute(t *testing.T) {
 					if response.Value == nil && tt.wantResponse.Value == nil {
 						// Both null, OK
 					} else if response.Value != nil && tt.wantResponse.Value != nil {
-						if response.Value != tt.wantResponse.Value {
+						if !reflect.DeepEqual(response.Value, tt.wantResponse.Value) {
 							t.Errorf("Execute() response value = %v, want %v", response.Value, tt.wantResponse.Value)
 						}
 					} else {
 						t.Errorf("Execute() response value = %v, want %v", response.Value, tt.wantResponse.Value)
 					}
 				} else {
-					if response.Value != tt.wantResponse.Value {
+					if !reflect.DeepEqual(response.Value, tt.wantResponse.Value) {
 						t.Errorf("Execute() response value = %v, want %v", response.Value, tt.wantResponse.Value)
 					}
 				}
@@ -169,8 +170,8 @@ func TestGetCommand_Integration(t *testing.T) {
 		t.Errorf("Expected BulkString response, got %v", getResponse.Type)
 	}
 
-	if getResponse.Value != "integrationvalue" {
-		t.Errorf("Expected value 'integrationvalue', got '%v'", getResponse.Value)
+	if value, _ := getResponse.AsString(); value != "integrationvalue" {
+		t.Errorf("Expected value 'integrationvalue', got '%s'", value)
 	}
 }
 
@@ -200,7 +201,7 @@ func TestGetCommand_KeyTypes(t *testing.T) {
 	store := storage.NewMemoryStore()
 
 	// Test different key types that should work
-	store.Set("test", "value")
+	store.Set("test", []byte("value"))
 
 	tests := []struct {
 		name    string
@@ -233,10 +234,24 @@ func TestGetCommand_KeyTypes(t *testing.T) {
 				if response.Type != resp.BulkString {
 					t.Errorf("Expected BulkString response, got %v", response.Type)
 				}
-				if response.Value != "value" {
-					t.Errorf("Expected value 'value', got %v", response.Value)
+				if value, _ := response.AsString(); value != "value" {
+					t.Errorf("Expected value 'value', got %q", value)
 				}
 			}
 		})
 	}
 }
+
+func TestGetCommand_KeyMissEvent(t *testing.T) {
+	cmd := NewGetCommand()
+	store, notifier := newNotifyingStore()
+	store.Set("present", []byte("value"))
+
+	cmd.Execute(stringArgs("present"), store)
+	cmd.Execute(stringArgs("missing"), store)
+
+	want := []string{"keymiss:missing"}
+	if !reflect.DeepEqual(notifier.events, want) {
+		t.Errorf("Expected events %v, got %v", want, notifier.events)
+	}
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
+	"github.com/tsinivuo/redis-lite/pkg/glob"
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// ImportCommand implements the IMPORT command, which loads keys exported
+// by EXPORT
+type ImportCommand struct{}
+
+// NewImportCommand creates a new IMPORT command
+func NewImportCommand() *ImportCommand {
+	return &ImportCommand{}
+}
+
+// Name returns the command name
+func (c *ImportCommand) Name() string {
+	return "IMPORT"
+}
+
+// exclusive makes IMPORT run alone, so that no client sees the keyspace
+// half replaced or half imported
+func (c *ImportCommand) exclusive() {}
+
+// importOptions holds the parsed arguments of IMPORT
+type importOptions struct {
+	data []byte
+	// replace removes the existing keys matching the pattern first,
+	// rather than merging the imported keys into them
+	replace bool
+	pattern string
+}
+
+// Validate checks if the IMPORT command arguments are valid
+func (c *ImportCommand) Validate(args []*resp.Message) error {
+	if len(args) == 0 {
+		return fmt.Errorf("wrong number of arguments for 'import' command")
+	}
+
+	_, err := parseImportOptions(args)
+	return err
+}
+
+// parseImportOptions parses "data [MERGE|REPLACE] [MATCH pattern]"
+func parseImportOptions(args []*resp.Message) (importOptions, error) {
+	var options importOptions
+	data, ok := messageBytes(args[0])
+	if !ok {
+		return options, fmt.Errorf("invalid data")
+	}
+	options.data = data
+
+	for i := 1; i < len(args); i++ {
+		option, ok := messageString(args[i])
+		if !ok {
+			return options, fmt.Errorf("syntax error")
+		}
+
+		switch strings.ToUpper(option) {
+		case "MERGE":
+			options.replace = false
+		case "REPLACE":
+			options.replace = true
+		case "MATCH":
+			if i+1 == len(args) {
+				return options, fmt.Errorf("syntax error")
+			}
+			i++
+			if options.pattern, ok = messageString(args[i]); !ok {
+				return options, fmt.Errorf("syntax error")
+			}
+		default:
+			return options, fmt.Errorf("syntax error")
+		}
+	}
+
+	return options, nil
+}
+
+// Execute imports the keys matching the pattern, or every key, and replies
+// with the number imported. Keys of the same name are overwritten. With
+// REPLACE, the existing keys matching the pattern are removed first. The
+// data is checked before any key changes, so an invalid line imports
+// nothing.
+func (c *ImportCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	options, err := parseImportOptions(args)
+	if err != nil {
+		return resp.NewError("ERR " + err.Error()), nil
+	}
+
+	// TTLs count from when the command runs, however long reading takes
+	now := time.Now()
+	records, err := persistence.ReadJSON(bytes.NewReader(options.data))
+	if err != nil {
+		return resp.NewError("ERR invalid import data: " + err.Error()), nil
+	}
+
+	if options.replace {
+		removeMatching(store, options.pattern)
+	}
+
+	imported, err := importRecords(store, records, options.pattern, now)
+	if err != nil {
+		return storeError(err), nil
+	}
+	return resp.NewInteger(int64(imported)), nil
+}
+
+// importRecords stores the records matching pattern, with their TTLs
+// counted from now, and returns the number of keys imported. Records whose
+// TTL has passed by the time they are stored are skipped.
+func importRecords(store storage.Store, records []persistence.JSONRecord, pattern string, now time.Time) (int, error) {
+	imported := 0
+	for _, record := range records {
+		key := string(record.Key)
+		if pattern != "" && !glob.Match(pattern, key) {
+			continue
+		}
+
+		expiresAt := record.ExpiresAt(now)
+		if !expiresAt.IsZero() && !time.Now().Before(expiresAt) {
+			continue
+		}
+		if err := store.SetWithExpiry(key, record.Value, expiresAt); err != nil {
+			return imported, err
+		}
+		store.Notify(events.String, "set", key)
+		if !expiresAt.IsZero() {
+			store.Notify(events.Generic, "expire", key)
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// removeMatching deletes the keys matching pattern, or every key if
+// pattern is empty
+func removeMatching(store storage.Store, pattern string) {
+	if pattern == "" {
+		store.Clear()
+		return
+	}
+
+	// The keys are collected first, since deleting while scanning could
+	// make the scan miss some
+	var matching []string
+	for cursor := uint64(0); ; {
+		keys, next := store.Scan(cursor, 1000)
+		for _, key := range keys {
+			if glob.Match(pattern, key) {
+				matching = append(matching, key)
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for _, key := range matching {
+		if store.Delete(key) {
+			store.Notify(events.Generic, "del", key)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+const importData = `{"key":"user:1","type":"string","value":"alice"}
+{"key":"user:2","type":"string","value":"bob","ttl":60000}
+{"key":"session","type":"string","value":"x"}
+`
+
+func TestImportCommand_Validate(t *testing.T) {
+	cmd := NewImportCommand()
+
+	if cmd.Name() != "IMPORT" {
+		t.Errorf("Expected command name 'IMPORT', got '%s'", cmd.Name())
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"data only", []string{importData}, ""},
+		{"all options", []string{importData, "replace", "MATCH", "user:*"}, ""},
+		{"merge", []string{importData, "MERGE"}, ""},
+		{"no args", []string{}, "wrong number of arguments for 'import' command"},
+		{"missing pattern", []string{importData, "MATCH"}, "syntax error"},
+		{"unknown option", []string{importData, "NX"}, "syntax error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmd.Validate(stringArgs(tt.args...))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestImportCommand_Execute(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []string
+		imported int64
+		keys     []string
+	}{
+		{"merge", nil, 3, []string{"session", "user:1", "user:2", "user:3", "other"}},
+		{"merge matching", []string{"MATCH", "user:*"}, 2, []string{"user:1", "user:2", "user:3", "other"}},
+		{"replace", []string{"REPLACE"}, 3, []string{"session", "user:1", "user:2"}},
+		{"replace matching", []string{"REPLACE", "MATCH", "user:*"}, 2, []string{"user:1", "user:2", "other"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			store.Set("user:1", []byte("old"))
+			store.Set("user:3", []byte("carol"))
+			store.Set("other", []byte("kept"))
+
+			response, _ := NewImportCommand().Execute(stringArgs(append([]string{importData}, tt.options...)...), store)
+			if response.Type != resp.Integer || response.Value.(int64) != tt.imported {
+				t.Fatalf("Expected %d keys imported, got %s", tt.imported, response)
+			}
+			if store.Size() != len(tt.keys) {
+				t.Errorf("Expected %d keys, got %d", len(tt.keys), store.Size())
+			}
+			for _, key := range tt.keys {
+				if !store.Exists(key) {
+					t.Errorf("Expected key %s to exist", key)
+				}
+			}
+			if value, _ := store.Get("user:1"); string(value) != "alice" {
+				t.Errorf("Expected imported keys to overwrite existing ones, got %q", value)
+			}
+		})
+	}
+}
+
+func TestImportCommand_InvalidData(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Set("existing", []byte("1"))
+	data := importData + `{"key":"list","type":"list","value":"1"}` + "\n"
+
+	response, _ := NewImportCommand().Execute(stringArgs(data, "REPLACE"), store)
+	if response.Type != resp.Error || !strings.HasPrefix(response.Value.(string), "ERR invalid import data: line 4: ") {
+		t.Errorf("Expected an error on line 4, got %s", response)
+	}
+	// Nothing is imported or removed
+	if store.Size() != 1 || !store.Exists("existing") {
+		t.Errorf("Expected the keyspace to be unchanged, got %d keys", store.Size())
+	}
+}
+
+func TestImportCommand_ExpiredRecords(t *testing.T) {
+	store := storage.NewMemoryStore()
+	records := []persistence.JSONRecord{
+		{Key: []byte("expired"), Type: "string", Value: []byte("1"), TTL: 1000},
+		{Key: []byte("expiring"), Type: "string", Value: []byte("2"), TTL: 120000},
+		{Key: []byte("persistent"), Type: "string", Value: []byte("3")},
+	}
+
+	// The TTLs count from a minute ago, so the first one has passed
+	imported, err := importRecords(store, records, "", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("importRecords() returned error: %v", err)
+	}
+	if imported != 2 {
+		t.Errorf("Expected 2 keys imported, got %d", imported)
+	}
+	if store.Exists("expired") || !store.Exists("expiring") || !store.Exists("persistent") {
+		t.Errorf("Expected only the keys that have not expired, got %d keys", store.Size())
+	}
+}
+
+func TestImportCommand_Exclusive(t *testing.T) {
+	// Other clients must not see the keyspace half replaced
+	if _, ok := any(NewImportCommand()).(exclusiveCommand); !ok {
+		t.Error("Expected IMPORT to run exclusively")
+	}
+}
@@ -1,6 +1,10 @@
 package commands
 
 import (
+	"errors"
+	"strconv"
+	"sync"
+
 	"github.com/tsinivuo/redis-lite/pkg/resp"
 	"github.com/tsinivuo/redis-lite/pkg/storage"
 )
@@ -17,9 +21,24 @@ type Command interface {
 	Validate(args []*resp.Message) error
 }
 
+// exclusiveCommand is implemented by commands that must not run alongside
+// any other command, as a transaction does not
+type exclusiveCommand interface {
+	exclusive()
+}
+
+// QueuedCommand is a command that has been validated and queued inside a MULTI block
+type QueuedCommand struct {
+	Name string
+	Args []*resp.Message
+}
+
 // CommandHandler manages command registration and execution
 type CommandHandler struct {
 	commands map[string]Command
+	// mutex lets regular commands run concurrently while a transaction
+	// holds it exclusively, so EXEC is never interleaved with other clients
+	mutex sync.RWMutex
 }
 
 // NewCommandHandler creates a new command handler
@@ -36,16 +55,65 @@ func (h *CommandHandler) Register(command Command) {
 
 // Execute executes a command by name with the given arguments
 func (h *CommandHandler) Execute(commandName string, args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	if _, ok := h.commands[commandName].(exclusiveCommand); ok {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+	} else {
+		h.mutex.RLock()
+		defer h.mutex.RUnlock()
+	}
+
+	return h.execute(commandName, args, store)
+}
+
+// Check validates a command without executing it, returning an error reply
+// if the command is unknown or its arguments are invalid and nil otherwise
+func (h *CommandHandler) Check(commandName string, args []*resp.Message) *resp.Message {
 	command, exists := h.commands[commandName]
 	if !exists {
-		return resp.NewError("ERR unknown command '" + commandName + "'"), nil
+		return resp.NewError("ERR unknown command '" + commandName + "'")
 	}
 
 	if err := command.Validate(args); err != nil {
-		return resp.NewError("ERR " + err.Error()), nil
+		return resp.NewError("ERR " + err.Error())
 	}
 
-	return command.Execute(args, store)
+	return nil
+}
+
+// ExecuteTransaction executes the queued commands atomically and returns
+// one reply per command. No other command runs until all of them complete.
+// watched maps keys to the versions observed by WATCH; if any of them has
+// changed, nothing is executed and false is returned.
+func (h *CommandHandler) ExecuteTransaction(queue []QueuedCommand, watched map[string]uint64, store storage.Store) ([]*resp.Message, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for key, version := range watched {
+		if store.Version(key) != version {
+			return nil, false
+		}
+	}
+
+	replies := make([]*resp.Message, len(queue))
+	for i, queued := range queue {
+		response, err := h.execute(queued.Name, queued.Args, store)
+		if err != nil {
+			response = resp.NewError("ERR " + err.Error())
+		}
+		replies[i] = response
+	}
+
+	return replies, true
+}
+
+// execute runs a command without taking the handler lock
+func (h *CommandHandler) execute(commandName string, args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	if reply := h.Check(commandName, args); reply != nil {
+		return reply, nil
+	}
+
+	return h.commands[commandName].Execute(args, store)
 }
 
 // GetCommand returns a command by name
@@ -53,3 +121,49 @@ func (h *CommandHandler) GetCommand(name string) (Command, bool) {
 	cmd, exists := h.commands[name]
 	return cmd, exists
 }
+
+// messageString returns the string form of a simple string, bulk string or
+// integer argument
+func messageString(arg *resp.Message) (string, bool) {
+	switch arg.Type {
+	case resp.BulkString:
+		if arg.Value == nil {
+			return "", false
+		}
+		return string(arg.Value.([]byte)), true
+	case resp.SimpleString:
+		return arg.Value.(string), true
+	case resp.Integer:
+		return strconv.FormatInt(arg.Value.(int64), 10), true
+	default:
+		return "", false
+	}
+}
+
+// messageBytes returns the value of a simple string, bulk string or integer
+// argument as bytes. Bulk strings are returned without copying, so the
+// result must not be modified; it may be handed to storage.Store.Set.
+func messageBytes(arg *resp.Message) ([]byte, bool) {
+	switch arg.Type {
+	case resp.BulkString:
+		if arg.Value == nil {
+			return nil, false
+		}
+		return arg.Value.([]byte), true
+	case resp.SimpleString:
+		return []byte(arg.Value.(string)), true
+	case resp.Integer:
+		return strconv.AppendInt(nil, arg.Value.(int64), 10), true
+	default:
+		return nil, false
+	}
+}
+
+// storeError returns the error reply to a write the store refused. Writes
+// refused for lack of memory reply with the OOM error of Redis.
+func storeError(err error) *resp.Message {
+	if errors.Is(err, storage.ErrOutOfMemory) {
+		return resp.NewError(err.Error())
+	}
+	return resp.NewError("ERR " + err.Error())
+}
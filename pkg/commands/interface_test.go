@@ -3,6 +3,7 @@ package commands
 import (
 	"testing"
 
+	"github.com/tsinivuo/redis-lite/pkg/events"
 	"github.com/tsinivuo/redis-lite/pkg/resp"
 	"github.com/tsinivuo/redis-lite/pkg/storage"
 )
@@ -114,3 +115,90 @@ func TestCommandHandler_GetCommand(t *testing.T) {
 		t.Fatal("Expected command to not exist")
 	}
 }
+
+func TestCommandHandler_Check(t *testing.T) {
+	handler := NewCommandHandler()
+	handler.Register(NewGetCommand())
+
+	if reply := handler.Check("GET", []*resp.Message{resp.NewBulkString("key")}); reply != nil {
+		t.Errorf("Expected valid command to pass, got %s", reply)
+	}
+
+	reply := handler.Check("GET", []*resp.Message{})
+	if reply == nil || reply.Type != resp.Error {
+		t.Fatalf("Expected error reply for invalid arguments, got %v", reply)
+	}
+
+	reply = handler.Check("UNKNOWN", []*resp.Message{})
+	if reply == nil || reply.Value.(string) != "ERR unknown command 'UNKNOWN'" {
+		t.Errorf("Expected unknown command error, got %v", reply)
+	}
+}
+
+func TestCommandHandler_ExecuteTransaction(t *testing.T) {
+	handler := NewCommandHandler()
+	handler.Register(NewSetCommand())
+	handler.Register(NewGetCommand())
+	store := storage.NewMemoryStore()
+
+	queue := []QueuedCommand{
+		{Name: "SET", Args: []*resp.Message{resp.NewBulkString("key"), resp.NewBulkString("value")}},
+		{Name: "GET", Args: []*resp.Message{resp.NewBulkString("key")}},
+	}
+
+	replies, ok := handler.ExecuteTransaction(queue, nil, store)
+	if !ok {
+		t.Fatal("Expected transaction without watched keys to execute")
+	}
+
+	if len(replies) != 2 {
+		t.Fatalf("Expected 2 replies, got %d", len(replies))
+	}
+
+	if replies[0].Type != resp.SimpleString || replies[0].Value.(string) != "OK" {
+		t.Errorf("Expected OK from SET, got %s", replies[0])
+	}
+
+	if replies[1].Type != resp.BulkString || string(replies[1].Value.([]byte)) != "value" {
+		t.Errorf("Expected 'value' from GET, got %s", replies[1])
+	}
+}
+
+func TestCommandHandler_ExecuteTransaction_WatchedKeyModified(t *testing.T) {
+	handler := NewCommandHandler()
+	handler.Register(NewSetCommand())
+	store := storage.NewMemoryStore()
+
+	watched := map[string]uint64{"key": store.Watch("key")}
+	store.Set("key", []byte("changed"))
+
+	queue := []QueuedCommand{
+		{Name: "SET", Args: []*resp.Message{resp.NewBulkString("key"), resp.NewBulkString("value")}},
+	}
+
+	replies, ok := handler.ExecuteTransaction(queue, watched, store)
+	if ok || replies != nil {
+		t.Fatalf("Expected transaction to abort, got %v", replies)
+	}
+
+	if value, _ := store.Get("key"); string(value) != "changed" {
+		t.Errorf("Aborted transaction modified the key, got '%s'", value)
+	}
+}
+
+// recordingNotifier records every keyspace event as "event:key"
+type recordingNotifier struct {
+	events []string
+}
+
+func (r *recordingNotifier) Notify(class events.Class, event, key string) {
+	r.events = append(r.events, event+":"+key)
+}
+
+// newNotifyingStore creates a store whose keyspace events are recorded
+func newNotifyingStore() (*storage.MemoryStore, *recordingNotifier) {
+	store := storage.NewMemoryStore()
+	notifier := &recordingNotifier{}
+	store.SetNotifier(notifier)
+	return store, notifier
+}
@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// memoryHelp is the reply of MEMORY HELP
+var memoryHelp = []string{
+	"MEMORY <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"DOCTOR",
+	"    Return memory problems reports.",
+	"STATS",
+	"    Return information about the memory usage of the server.",
+	"USAGE <key> [SAMPLES <count>]",
+	"    Return memory in bytes used by <key> and its value. Nested values are",
+	"    sampled up to <count> times (default: 5, 0 means sample all).",
+	"HELP",
+	"    Print this help.",
+}
+
+// MemorySource reports the memory used by the keyspace
+type MemorySource interface {
+	MemoryStats() storage.MemoryStats
+}
+
+// MemoryCommand implements MEMORY USAGE, STATS and DOCTOR
+type MemoryCommand struct {
+	source MemorySource
+}
+
+// NewMemoryCommand creates a new MEMORY command reporting on source
+func NewMemoryCommand(source MemorySource) *MemoryCommand {
+	return &MemoryCommand{source: source}
+}
+
+// Name returns the command name
+func (c *MemoryCommand) Name() string {
+	return "MEMORY"
+}
+
+// Validate checks if the MEMORY command arguments are valid
+func (c *MemoryCommand) Validate(args []*resp.Message) error {
+	// MEMORY requires a subcommand
+	if len(args) == 0 {
+		return fmt.Errorf("wrong number of arguments for 'memory' command")
+	}
+	return nil
+}
+
+// Execute processes the MEMORY command
+func (c *MemoryCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	values := make([]string, len(args))
+	for i, arg := range args {
+		value, ok := messageString(arg)
+		if !ok {
+			return resp.NewError("ERR invalid argument type for MEMORY"), nil
+		}
+		values[i] = value
+	}
+
+	subcommand, values := values[0], values[1:]
+	switch strings.ToUpper(subcommand) {
+	case "USAGE":
+		if len(values) == 0 {
+			return resp.NewError("ERR wrong number of arguments for 'memory|usage' command"), nil
+		}
+		return memoryUsage(values[0], values[1:], store), nil
+
+	case "STATS":
+		if len(values) != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'memory|stats' command"), nil
+		}
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		return memoryStats(c.source.MemoryStats(), &mem), nil
+
+	case "DOCTOR":
+		if len(values) != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'memory|doctor' command"), nil
+		}
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		return resp.NewVerbatimString("txt", memoryDoctor(c.source.MemoryStats(), &mem)), nil
+
+	case "HELP":
+		if len(values) != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'memory|help' command"), nil
+		}
+		return stringArray(memoryHelp), nil
+
+	default:
+		return resp.NewError(fmt.Sprintf("ERR unknown subcommand '%s'. Try MEMORY HELP.", subcommand)), nil
+	}
+}
+
+// memoryUsage replies with the memory held by a key and its value, or nil
+// if it does not exist. Strings have no nested values to sample, so
+// SAMPLES is only checked.
+func memoryUsage(key string, options []string, store storage.Store) *resp.Message {
+	for i := 0; i < len(options); i += 2 {
+		if !strings.EqualFold(options[i], "SAMPLES") || i+1 == len(options) {
+			return resp.NewError("ERR syntax error")
+		}
+		samples, err := strconv.ParseInt(options[i+1], 10, 64)
+		if err != nil {
+			return resp.NewError("ERR value is not an integer or out of range")
+		}
+		if samples < 0 {
+			return resp.NewError("ERR syntax error")
+		}
+	}
+
+	info, exists := store.Inspect(key)
+	if !exists {
+		return resp.NewNullBulkString()
+	}
+	return resp.NewInteger(info.Size)
+}
+
+// memoryStats builds the reply of MEMORY STATS from the accounting of the
+// keyspace and the statistics of the Go heap. The keyspace figures are
+// estimates: the heap also holds garbage not collected yet, client
+// buffers and the rest of the server.
+func memoryStats(stats storage.MemoryStats, mem *runtime.MemStats) *resp.Message {
+	dataset := stats.Used - stats.Overhead
+	fields := []struct {
+		name  string
+		value *resp.Message
+	}{
+		{"total.allocated", resp.NewInteger(int64(mem.HeapAlloc))},
+		{"keys.count", resp.NewInteger(int64(stats.Keys))},
+		{"keys.expiring", resp.NewInteger(int64(stats.Expiring))},
+		{"keys.bytes-per-key", resp.NewInteger(stats.Used / max(int64(stats.Keys), 1))},
+		{"keyspace.bytes", resp.NewInteger(stats.Used)},
+		{"overhead.keyspace", resp.NewInteger(stats.Overhead)},
+		{"dataset.bytes", resp.NewInteger(dataset)},
+		{"dataset.percentage", resp.NewDouble(percentage(dataset, int64(mem.HeapAlloc)))},
+		{"maxmemory", resp.NewInteger(stats.Limit.MaxMemory)},
+		{"maxmemory.percentage", resp.NewDouble(percentage(stats.Used, stats.Limit.MaxMemory))},
+		{"allocator.allocated", resp.NewInteger(int64(mem.HeapAlloc))},
+		{"allocator.active", resp.NewInteger(int64(mem.HeapInuse))},
+		{"allocator.resident", resp.NewInteger(int64(mem.HeapSys - mem.HeapReleased))},
+		{"allocator-fragmentation.ratio", resp.NewDouble(ratio(mem.HeapInuse, mem.HeapAlloc))},
+		{"allocator-fragmentation.bytes", resp.NewInteger(int64(mem.HeapInuse) - int64(mem.HeapAlloc))},
+		{"gc.count", resp.NewInteger(int64(mem.NumGC))},
+		{"gc.next", resp.NewInteger(int64(mem.NextGC))},
+	}
+
+	elements := make([]*resp.Message, 0, 2*len(fields))
+	for _, field := range fields {
+		elements = append(elements, resp.NewBulkString(field.name), field.value)
+	}
+	return resp.NewMap(elements)
+}
+
+// memoryDoctor reports the memory issues found in the keyspace and the Go
+// heap, with advice on each
+func memoryDoctor(stats storage.MemoryStats, mem *runtime.MemStats) string {
+	if stats.Keys == 0 {
+		return "The keyspace is empty: there is no memory usage to diagnose."
+	}
+
+	var issues []string
+	limit := stats.Limit
+	switch {
+	case limit.MaxMemory == 0:
+		issues = append(issues, "maxmemory is not set, so memory grows without bound as keys are added. "+
+			"Set maxmemory, with a maxmemory-policy that evicts keys if they are a cache.")
+	case limit.Policy == storage.NoEviction && stats.Used*10 >= limit.MaxMemory*9:
+		issues = append(issues, fmt.Sprintf("The keyspace uses %.0f%% of maxmemory under the noeviction policy: "+
+			"writes fail with OOM errors once maxmemory is exceeded.", percentage(stats.Used, limit.MaxMemory)))
+	case limit.Policy.Volatile() && stats.Expiring == 0:
+		issues = append(issues, "maxmemory-policy only evicts keys with an expiry, and no key has one: "+
+			"writes fail with OOM errors once maxmemory is exceeded. Set TTLs, or use an allkeys policy.")
+	}
+
+	if stats.Overhead*2 > stats.Used {
+		issues = append(issues, fmt.Sprintf("%.0f%% of the keyspace memory is per-key overhead, as keys and values "+
+			"average %d bytes. Fewer, larger values would use less memory.",
+			percentage(stats.Overhead, stats.Used), (stats.Used-stats.Overhead)/int64(stats.Keys)))
+	}
+
+	// Small heaps are dominated by the server itself
+	const minHeap = 16 << 20
+	if mem.HeapAlloc >= minHeap && uint64(stats.Used)*2 < mem.HeapAlloc {
+		issues = append(issues, fmt.Sprintf("The Go heap holds %s, of which the keyspace accounts for %s. "+
+			"The rest goes to client buffers, persistence and garbage not collected yet.",
+			humanBytes(int64(mem.HeapAlloc)), humanBytes(stats.Used)))
+	}
+	if resident := mem.HeapSys - mem.HeapReleased; resident >= minHeap && resident*2 > mem.HeapInuse*3 {
+		issues = append(issues, fmt.Sprintf("The Go heap reserves %s but uses %s. The runtime returns the "+
+			"difference to the system over time, and sooner with GOMEMLIMIT set.",
+			humanBytes(int64(resident)), humanBytes(int64(mem.HeapInuse))))
+	}
+
+	if len(issues) == 0 {
+		return "No memory issues found."
+	}
+	var report strings.Builder
+	if len(issues) == 1 {
+		report.WriteString("1 memory issue found:\n")
+	} else {
+		fmt.Fprintf(&report, "%d memory issues found:\n", len(issues))
+	}
+	for _, issue := range issues {
+		report.WriteString("\n* " + issue + "\n")
+	}
+	return report.String()
+}
+
+// percentage returns part as a percentage of total, 0 if total is 0
+func percentage(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// ratio returns a / b, 0 if b is 0
+func ratio(a, b uint64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// humanBytes formats a number of bytes as Redis does in INFO, such as
+// 1.50M
+func humanBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2fK", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func TestMemoryCommand_Validate(t *testing.T) {
+	cmd := NewMemoryCommand(storage.NewMemoryStore())
+
+	if cmd.Name() != "MEMORY" {
+		t.Errorf("Expected command name 'MEMORY', got '%s'", cmd.Name())
+	}
+	if err := cmd.Validate(stringArgs()); err == nil {
+		t.Error("Expected an error without a subcommand")
+	}
+	if err := cmd.Validate(stringArgs("STATS")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestMemoryCommand_Usage(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Set("key", make([]byte, 1000))
+	cmd := NewMemoryCommand(store)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected *resp.Message
+	}{
+		{"usage", []string{"USAGE", "key"}, resp.NewInteger(store.UsedMemory())},
+		{"samples", []string{"usage", "key", "SAMPLES", "0"}, resp.NewInteger(store.UsedMemory())},
+		{"missing key", []string{"USAGE", "missing"}, resp.NewNullBulkString()},
+		{"no key", []string{"USAGE"}, resp.NewError("ERR wrong number of arguments for 'memory|usage' command")},
+		{"invalid samples", []string{"USAGE", "key", "SAMPLES", "all"}, resp.NewError("ERR value is not an integer or out of range")},
+		{"negative samples", []string{"USAGE", "key", "SAMPLES", "-1"}, resp.NewError("ERR syntax error")},
+		{"unknown option", []string{"USAGE", "key", "COUNT", "1"}, resp.NewError("ERR syntax error")},
+		{"unknown subcommand", []string{"bogus"}, resp.NewError("ERR unknown subcommand 'bogus'. Try MEMORY HELP.")},
+		{"help with arguments", []string{"HELP", "extra"}, resp.NewError("ERR wrong number of arguments for 'memory|help' command")},
+		{"help", []string{"help"}, stringArray(memoryHelp)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, _ := cmd.Execute(stringArgs(tt.args...), store)
+			if response.String() != tt.expected.String() {
+				t.Errorf("Expected %s, got %s", tt.expected, response)
+			}
+		})
+	}
+
+	// The value takes a 1024-byte allocation
+	if usage := store.UsedMemory(); usage < 1024 || usage > 1024+256 {
+		t.Errorf("Expected about 1024 bytes, got %d", usage)
+	}
+}
+
+func TestMemoryCommand_Stats(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("2"))
+
+	response, _ := NewMemoryCommand(store).Execute(stringArgs("STATS"), store)
+	if response.Type != resp.Map {
+		t.Fatalf("Expected a map, got %s", response)
+	}
+	fields := make(map[string]*resp.Message)
+	elements := response.Value.([]*resp.Message)
+	for i := 0; i < len(elements); i += 2 {
+		name, _ := elements[i].AsString()
+		fields[name] = elements[i+1]
+	}
+
+	if keys, _ := fields["keys.count"].AsInteger(); keys != 2 {
+		t.Errorf("Expected 2 keys, got %d", keys)
+	}
+	if used, _ := fields["keyspace.bytes"].AsInteger(); used != store.UsedMemory() {
+		t.Errorf("Expected %d bytes, got %d", store.UsedMemory(), used)
+	}
+	if allocated, _ := fields["total.allocated"].AsInteger(); allocated <= 0 {
+		t.Errorf("Expected the heap size, got %d", allocated)
+	}
+}
+
+func TestMemoryDoctor(t *testing.T) {
+	small := &runtime.MemStats{HeapAlloc: 1 << 20, HeapInuse: 1 << 20, HeapSys: 2 << 20}
+	limited := func(policy storage.EvictionPolicy) storage.MemoryLimit {
+		return storage.MemoryLimit{MaxMemory: 1000, Policy: policy, Samples: 5}
+	}
+
+	tests := []struct {
+		name     string
+		stats    storage.MemoryStats
+		mem      *runtime.MemStats
+		expected []string
+	}{
+		{"empty", storage.MemoryStats{}, small, []string{"The keyspace is empty"}},
+		{"healthy", storage.MemoryStats{Keys: 1, Used: 500, Overhead: 100, Limit: limited(storage.AllKeysLRU)}, small,
+			[]string{"No memory issues found."}},
+		{"no maxmemory", storage.MemoryStats{Keys: 1, Used: 500, Overhead: 100}, small,
+			[]string{"1 memory issue found:", "maxmemory is not set"}},
+		{"nearly full", storage.MemoryStats{Keys: 1, Used: 950, Overhead: 100, Limit: limited(storage.NoEviction)}, small,
+			[]string{"uses 95% of maxmemory"}},
+		{"no expiring keys", storage.MemoryStats{Keys: 1, Used: 500, Overhead: 100, Limit: limited(storage.VolatileLRU)}, small,
+			[]string{"no key has one"}},
+		{"small values", storage.MemoryStats{Keys: 10, Used: 500, Overhead: 400, Limit: limited(storage.AllKeysLRU)}, small,
+			[]string{"80% of the keyspace memory is per-key overhead", "average 10 bytes"}},
+		{"large heap", storage.MemoryStats{Keys: 1, Used: 500, Overhead: 100, Limit: limited(storage.AllKeysLRU)},
+			&runtime.MemStats{HeapAlloc: 100 << 20, HeapInuse: 100 << 20, HeapSys: 400 << 20},
+			[]string{"2 memory issues found", "holds 100.00M", "reserves 400.00M but uses 100.00M"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := memoryDoctor(tt.stats, tt.mem)
+			for _, expected := range tt.expected {
+				if !strings.Contains(report, expected) {
+					t.Errorf("Expected %q in the report, got:\n%s", expected, report)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// MigrateCommand implements the MIGRATE command
+type MigrateCommand struct{}
+
+// NewMigrateCommand creates a new MIGRATE command
+func NewMigrateCommand() *MigrateCommand {
+	return &MigrateCommand{}
+}
+
+// Name returns the command name
+func (c *MigrateCommand) Name() string {
+	return "MIGRATE"
+}
+
+// exclusive makes MIGRATE run alone, so that no client changes the keys
+// between their transfer and their deletion
+func (c *MigrateCommand) exclusive() {}
+
+// migrateOptions holds the parsed arguments of MIGRATE
+type migrateOptions struct {
+	address string
+	db      int64
+	timeout time.Duration
+	keys    []string
+	copy    bool
+	replace bool
+	// auth holds the arguments of the AUTH sent first, if any
+	auth []string
+}
+
+// Validate checks if the MIGRATE command arguments are valid
+func (c *MigrateCommand) Validate(args []*resp.Message) error {
+	if len(args) < 5 {
+		return fmt.Errorf("wrong number of arguments for 'migrate' command")
+	}
+
+	_, err := parseMigrateOptions(args)
+	return err
+}
+
+// parseMigrateOptions parses "host port key|"" destination-db timeout
+// [COPY] [REPLACE] [AUTH password | AUTH2 username password]
+// [KEYS key [key ...]]"
+func parseMigrateOptions(args []*resp.Message) (migrateOptions, error) {
+	var options migrateOptions
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		var ok bool
+		if strs[i], ok = messageString(arg); !ok {
+			return options, fmt.Errorf("syntax error")
+		}
+	}
+
+	port, err := strconv.Atoi(strs[1])
+	if err != nil || port < 1 || port > 65535 {
+		return options, fmt.Errorf("value is not an integer or out of range")
+	}
+	options.address = net.JoinHostPort(strs[0], strconv.Itoa(port))
+	if options.db, err = strconv.ParseInt(strs[3], 10, 64); err != nil || options.db < 0 {
+		return options, fmt.Errorf("value is not an integer or out of range")
+	}
+	// A timeout past the range of a duration would wrap to a negative one
+	timeout, err := strconv.ParseInt(strs[4], 10, 64)
+	if err != nil || timeout > math.MaxInt64/int64(time.Millisecond) {
+		return options, fmt.Errorf("value is not an integer or out of range")
+	}
+	// Like Redis, a timeout that is not positive means one second
+	if timeout <= 0 {
+		timeout = 1000
+	}
+	options.timeout = time.Duration(timeout) * time.Millisecond
+
+	for i := 5; i < len(strs); i++ {
+		switch strings.ToUpper(strs[i]) {
+		case "COPY":
+			options.copy = true
+		case "REPLACE":
+			options.replace = true
+		case "AUTH":
+			if i+1 >= len(strs) {
+				return options, fmt.Errorf("syntax error")
+			}
+			options.auth = strs[i+1 : i+2]
+			i++
+		case "AUTH2":
+			if i+2 >= len(strs) {
+				return options, fmt.Errorf("syntax error")
+			}
+			options.auth = strs[i+1 : i+3]
+			i += 2
+		case "KEYS":
+			if strs[2] != "" {
+				return options, fmt.Errorf("When using MIGRATE KEYS option, the key argument must be set to the empty string")
+			}
+			options.keys = strs[i+1:]
+			i = len(strs)
+		default:
+			return options, fmt.Errorf("syntax error")
+		}
+	}
+
+	if options.keys == nil {
+		options.keys = strs[2:3]
+	}
+	if len(options.keys) == 0 {
+		return options, fmt.Errorf("syntax error")
+	}
+	return options, nil
+}
+
+// Execute moves keys to another instance, redis-lite or Redis, by sending
+// them as RESTORE commands in a single round trip. The keys restored are
+// then deleted here, unless COPY is given. Replies NOKEY if none of the
+// keys exist.
+func (c *MigrateCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	options, err := parseMigrateOptions(args)
+	if err != nil {
+		return resp.NewError("ERR " + err.Error()), nil
+	}
+
+	now := time.Now()
+	var keys []string
+	var commands [][]string
+	for _, key := range options.keys {
+		value, expiresAt, exists := store.GetWithExpiry(key)
+		if !exists {
+			continue
+		}
+		payload, err := persistence.EncodeDump(&persistence.Entry{Type: persistence.TypeString, String: value}, true)
+		if err != nil {
+			return resp.NewError("ERR " + err.Error()), nil
+		}
+
+		ttl := "0"
+		if !expiresAt.IsZero() {
+			ttl = strconv.FormatInt(max(expiresAt.Sub(now).Milliseconds(), 1), 10)
+		}
+		command := []string{"RESTORE", key, ttl, string(payload)}
+		if options.replace {
+			command = append(command, "REPLACE")
+		}
+		keys = append(keys, key)
+		commands = append(commands, command)
+	}
+	if len(keys) == 0 {
+		return resp.NewSimpleString("NOKEY"), nil
+	}
+
+	// The AUTH and SELECT replies are checked along with the RESTORE ones
+	var setup [][]string
+	if options.auth != nil {
+		setup = append(setup, append([]string{"AUTH"}, options.auth...))
+	}
+	if options.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.FormatInt(options.db, 10)})
+	}
+
+	replies, err := sendCommands(options.address, options.timeout, append(setup, commands...))
+	if err != nil {
+		return resp.NewError("IOERR " + err.Error()), nil
+	}
+
+	// Nothing is deleted if AUTH or SELECT failed, whatever the target did
+	// with the keys then
+	for _, reply := range replies[:len(setup)] {
+		if reply.Type == resp.Error {
+			return resp.NewError("ERR Target instance replied with error: " + reply.Value.(string)), nil
+		}
+	}
+	var failure string
+	for i, reply := range replies[len(setup):] {
+		if reply.Type == resp.Error {
+			if failure == "" {
+				failure = reply.Value.(string)
+			}
+			continue
+		}
+		// Keys the target accepted are gone from here, even if some
+		// others were refused
+		if !options.copy && store.Delete(keys[i]) {
+			store.Notify(events.Generic, "del", keys[i])
+		}
+	}
+	if failure != "" {
+		return resp.NewError("ERR Target instance replied with error: " + failure), nil
+	}
+	return resp.NewSimpleString("OK"), nil
+}
+
+// sendCommands connects to address and sends commands in a pipeline,
+// returning their replies. Every step must complete within timeout.
+func sendCommands(address string, timeout time.Duration, commands [][]string) ([]*resp.Message, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error or timeout connecting to the client")
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	serializer := resp.NewSerializer(conn)
+	for _, command := range commands {
+		elements := make([]*resp.Message, len(command))
+		for i, arg := range command {
+			elements[i] = resp.NewBulkString(arg)
+		}
+		if err := serializer.Serialize(resp.NewArray(elements)); err != nil {
+			return nil, fmt.Errorf("error or timeout writing to target instance")
+		}
+	}
+	if err := serializer.Flush(); err != nil {
+		return nil, fmt.Errorf("error or timeout writing to target instance")
+	}
+
+	parser := resp.NewParser(conn)
+	replies := make([]*resp.Message, len(commands))
+	for i := range commands {
+		conn.SetDeadline(time.Now().Add(timeout))
+		if replies[i], err = parser.Parse(); err != nil {
+			return nil, fmt.Errorf("error or timeout reading to target instance")
+		}
+	}
+	return replies, nil
+}
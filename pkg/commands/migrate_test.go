@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// serveTarget serves RESTORE on a random local port, as another instance
+// would, and returns its host, port and store. AUTH succeeds for the
+// password "secret" only.
+func serveTarget(t *testing.T) (string, string, storage.Store) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	handler := NewCommandHandler()
+	handler.Register(NewRestoreCommand())
+	store := storage.NewMemoryStore()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				parser := resp.NewParser(conn)
+				serializer := resp.NewSerializer(conn)
+				for {
+					command, err := parser.ParseCommand()
+					if err != nil {
+						return
+					}
+					args := command.Value.([]*resp.Message)
+					name, _ := messageString(args[0])
+					var reply *resp.Message
+					if name == "AUTH" {
+						reply = resp.NewSimpleString("OK")
+						if password, _ := messageString(args[len(args)-1]); password != "secret" {
+							reply = resp.NewError("WRONGPASS invalid username-password pair")
+						}
+					} else {
+						reply, _ = handler.Execute(name, args[1:], store)
+					}
+					serializer.Serialize(reply)
+					serializer.Flush()
+				}
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return host, port, store
+}
+
+func TestMigrateCommand_Validate(t *testing.T) {
+	cmd := NewMigrateCommand()
+
+	if cmd.Name() != "MIGRATE" {
+		t.Errorf("Expected command name 'MIGRATE', got '%s'", cmd.Name())
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"single key", []string{"localhost", "6380", "key", "0", "1000"}, ""},
+		{"all options", []string{"localhost", "6380", "", "1", "0", "COPY", "replace", "AUTH2", "user", "pw", "KEYS", "a", "b"}, ""},
+		{"too few args", []string{"localhost", "6380", "key", "0"}, "wrong number of arguments for 'migrate' command"},
+		{"invalid port", []string{"localhost", "port", "key", "0", "1000"}, "value is not an integer or out of range"},
+		{"negative db", []string{"localhost", "6380", "key", "-1", "1000"}, "value is not an integer or out of range"},
+		{"invalid timeout", []string{"localhost", "6380", "key", "0", "1s"}, "value is not an integer or out of range"},
+		{"timeout overflow", []string{"localhost", "6380", "key", "0", "9223372036854775807"}, "value is not an integer or out of range"},
+		{"largest timeout", []string{"localhost", "6380", "key", "0", "9223372036854"}, ""},
+		{"keys with key", []string{"localhost", "6380", "key", "0", "1000", "KEYS", "a"}, "When using MIGRATE KEYS option, the key argument must be set to the empty string"},
+		{"no keys", []string{"localhost", "6380", "", "0", "1000", "KEYS"}, "syntax error"},
+		{"missing password", []string{"localhost", "6380", "key", "0", "1000", "AUTH"}, "syntax error"},
+		{"unknown option", []string{"localhost", "6380", "key", "0", "1000", "NX"}, "syntax error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmd.Validate(stringArgs(tt.args...))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMigrateCommand_Execute(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []string
+		expected string
+		// moved are the keys found on the target, and kept those left
+		// on the source
+		moved []string
+		kept  []string
+	}{
+		{"single key", []string{"a", "0", "1000"}, "OK", []string{"a"}, []string{"b", "taken"}},
+		{"copy", []string{"a", "0", "1000", "COPY"}, "OK", []string{"a"}, []string{"a", "b", "taken"}},
+		{"keys", []string{"", "0", "1000", "KEYS", "a", "missing", "b"}, "OK", []string{"a", "b"}, []string{"taken"}},
+		{"missing key", []string{"missing", "0", "1000"}, "NOKEY", nil, []string{"a", "b", "taken"}},
+		{"existing key", []string{"", "0", "1000", "KEYS", "a", "taken"}, "ERR Target instance replied with error: BUSYKEY Target key name already exists.", []string{"a"}, []string{"b", "taken"}},
+		{"replace", []string{"taken", "0", "1000", "REPLACE", "AUTH", "secret"}, "OK", []string{"taken"}, []string{"a", "b"}},
+		{"wrong password", []string{"a", "0", "1000", "AUTH2", "user", "wrong"}, "ERR Target instance replied with error: WRONGPASS invalid username-password pair", nil, []string{"a", "b", "taken"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, port, target := serveTarget(t)
+			target.Set("taken", []byte("target"))
+			store := storage.NewMemoryStore()
+			store.SetWithExpiry("a", []byte("1"), time.Now().Add(time.Hour))
+			store.Set("b", []byte("2"))
+			store.Set("taken", []byte("source"))
+
+			response, _ := NewMigrateCommand().Execute(stringArgs(append([]string{host, port}, tt.options...)...), store)
+			if response.Value.(string) != tt.expected {
+				t.Errorf("Expected %q, got %s", tt.expected, response)
+			}
+
+			for _, key := range tt.moved {
+				value, expiresAt, _ := target.GetWithExpiry(key)
+				if expected, _, _ := store.GetWithExpiry(key); string(value) == "target" || (expected != nil && string(value) != string(expected)) {
+					t.Errorf("Expected key %s to be migrated, got %q", key, value)
+				}
+				if key == "a" && time.Until(expiresAt) < 59*time.Minute {
+					t.Errorf("Expected key %s to keep its TTL, got %v", key, expiresAt)
+				}
+			}
+			if store.Size() != len(tt.kept) {
+				t.Errorf("Expected %d keys left, got %d", len(tt.kept), store.Size())
+			}
+			for _, key := range tt.kept {
+				if !store.Exists(key) {
+					t.Errorf("Expected key %s to be kept", key)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateCommand_Unreachable(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	store := storage.NewMemoryStore()
+	store.Set("key", []byte("value"))
+	response, _ := NewMigrateCommand().Execute(stringArgs("127.0.0.1", strconv.Itoa(port), "key", "0", "100"), store)
+	if response.Type != resp.Error || response.Value.(string) != "IOERR error or timeout connecting to the client" {
+		t.Errorf("Expected an IOERR, got %s", response)
+	}
+	if !store.Exists("key") {
+		t.Error("Expected the key to be kept")
+	}
+}
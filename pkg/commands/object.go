@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// objectHelp is the reply of OBJECT HELP
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"FREQ <key>",
+	"    Return the access frequency index of the <key>. The returned integer is",
+	"    proportional to the logarithm of the recent access frequency of the key.",
+	"IDLETIME <key>",
+	"    Return the idle time of the <key>, that is the approximated number of",
+	"    seconds elapsed since the last access to the key.",
+	"REFCOUNT <key>",
+	"    Return the number of references of the value associated with the specified",
+	"    <key>.",
+	"HELP",
+	"    Print this help.",
+}
+
+// embstrSizeLimit is the length up to which Redis embeds a string in its
+// object header, and reports it as embstr
+const embstrSizeLimit = 44
+
+// ObjectCommand implements OBJECT ENCODING, FREQ, IDLETIME and REFCOUNT
+type ObjectCommand struct{}
+
+// NewObjectCommand creates a new OBJECT command
+func NewObjectCommand() *ObjectCommand {
+	return &ObjectCommand{}
+}
+
+// Name returns the command name
+func (c *ObjectCommand) Name() string {
+	return "OBJECT"
+}
+
+// Validate checks if the OBJECT command arguments are valid
+func (c *ObjectCommand) Validate(args []*resp.Message) error {
+	// OBJECT requires a subcommand
+	if len(args) == 0 {
+		return fmt.Errorf("wrong number of arguments for 'object' command")
+	}
+	return nil
+}
+
+// Execute processes the OBJECT command. Looking a key up with OBJECT does
+// not count as an access to it. Both the idle time and the frequency are
+// tracked whatever the eviction policy, unlike in Redis, which only tracks
+// the one its policy uses.
+func (c *ObjectCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	name, ok := messageString(args[0])
+	if !ok {
+		return resp.NewError("ERR invalid subcommand type"), nil
+	}
+
+	subcommand := strings.ToUpper(name)
+	if subcommand == "HELP" {
+		if len(args) != 1 {
+			return resp.NewError("ERR wrong number of arguments for 'object|help' command"), nil
+		}
+		return stringArray(objectHelp), nil
+	}
+	switch subcommand {
+	case "ENCODING", "FREQ", "IDLETIME", "REFCOUNT":
+	default:
+		return resp.NewError(fmt.Sprintf("ERR unknown subcommand '%s'. Try OBJECT HELP.", name)), nil
+	}
+	if len(args) != 2 {
+		return resp.NewError(fmt.Sprintf("ERR wrong number of arguments for 'object|%s' command", strings.ToLower(subcommand))), nil
+	}
+
+	key, ok := messageString(args[1])
+	if !ok {
+		return resp.NewError("ERR invalid key type"), nil
+	}
+	info, exists := store.Inspect(key)
+	if !exists {
+		return resp.NewNullBulkString(), nil
+	}
+
+	switch subcommand {
+	case "ENCODING":
+		return resp.NewBulkString(stringEncoding(info.Value)), nil
+	case "FREQ":
+		return resp.NewInteger(int64(info.Freq)), nil
+	case "IDLETIME":
+		return resp.NewInteger(int64(info.Idle.Seconds())), nil
+	default:
+		// Values are never shared between keys
+		return resp.NewInteger(1), nil
+	}
+}
+
+// stringEncoding returns the encoding Redis would use for a string value:
+// int for the canonical form of a 64-bit integer, embstr for short strings
+// and raw for the others
+func stringEncoding(value []byte) string {
+	if len(value) <= 20 {
+		if n, err := strconv.ParseInt(string(value), 10, 64); err == nil && strconv.FormatInt(n, 10) == string(value) {
+			return "int"
+		}
+	}
+	if len(value) <= embstrSizeLimit {
+		return "embstr"
+	}
+	return "raw"
+}
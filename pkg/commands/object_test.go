@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func TestObjectCommand_Validate(t *testing.T) {
+	cmd := NewObjectCommand()
+
+	if cmd.Name() != "OBJECT" {
+		t.Errorf("Expected command name 'OBJECT', got '%s'", cmd.Name())
+	}
+	if err := cmd.Validate(stringArgs()); err == nil {
+		t.Error("Expected an error without a subcommand")
+	}
+	if err := cmd.Validate(stringArgs("ENCODING", "key")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestObjectCommand_Execute(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Set("int", []byte("-12345"))
+	store.Set("padded", []byte("012"))
+	store.Set("short", []byte("hello"))
+	store.Set("long", []byte(strings.Repeat("x", 45)))
+	store.SetAccess("short", 90*time.Second, -1)
+	store.SetAccess("long", -1, 42)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"int encoding", []string{"ENCODING", "int"}, "int"},
+		{"non canonical int", []string{"encoding", "padded"}, "embstr"},
+		{"embstr encoding", []string{"ENCODING", "short"}, "embstr"},
+		{"raw encoding", []string{"ENCODING", "long"}, "raw"},
+		{"missing key", []string{"ENCODING", "missing"}, "(nil)"},
+		{"idletime", []string{"IDLETIME", "short"}, "90"},
+		{"freq", []string{"FREQ", "long"}, "42"},
+		{"refcount", []string{"REFCOUNT", "int"}, "1"},
+		{"missing argument", []string{"FREQ"}, "ERR wrong number of arguments for 'object|freq' command"},
+		{"unknown subcommand", []string{"bogus", "int"}, "ERR unknown subcommand 'bogus'. Try OBJECT HELP."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, _ := NewObjectCommand().Execute(stringArgs(tt.args...), store)
+			var got string
+			switch response.Type {
+			case resp.Integer:
+				got = strconv.FormatInt(response.Value.(int64), 10)
+			case resp.BulkString:
+				if response.IsNull() {
+					got = "(nil)"
+				} else {
+					got = string(response.Value.([]byte))
+				}
+			default:
+				got, _ = response.AsString()
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %s", tt.expected, response)
+			}
+		})
+	}
+
+	// Looking a key up is not an access
+	if info, _ := store.Inspect("short"); info.Idle < 90*time.Second {
+		t.Errorf("Expected OBJECT to keep the idle time, got %v", info.Idle)
+	}
+}
+
+func TestObjectCommand_Help(t *testing.T) {
+	response, _ := NewObjectCommand().Execute(stringArgs("HELP"), storage.NewMemoryStore())
+	if response.Type != resp.Array || len(response.Value.([]*resp.Message)) != len(objectHelp) {
+		t.Errorf("Expected the help lines, got %s", response)
+	}
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/tsinivuo/redis-lite/pkg/resp"
@@ -76,7 +77,7 @@ func TestPingCommand_Execute(t *testing.T) {
 			name:         "bulk string argument echoed back",
 			args:         []*resp.Message{resp.NewBulkString("hello")},
 			expectedType: resp.BulkString,
-			expectedVal:  "hello",
+			expectedVal:  []byte("hello"),
 		},
 		{
 			name:         "simple string argument echoed back",
@@ -103,7 +104,7 @@ func TestPingCommand_Execute(t *testing.T) {
 				t.Errorf("Expected response type %s, got %s", tc.expectedType, response.Type)
 			}
 
-			if response.Value != tc.expectedVal {
+			if !reflect.DeepEqual(response.Value, tc.expectedVal) {
 				t.Errorf("Expected response value %v, got %v", tc.expectedVal, response.Value)
 			}
 		})
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// PublishCommand implements the PUBLISH command
+type PublishCommand struct {
+	broker *pubsub.Broker
+}
+
+// NewPublishCommand creates a new PUBLISH command publishing on the given broker
+func NewPublishCommand(broker *pubsub.Broker) *PublishCommand {
+	return &PublishCommand{broker: broker}
+}
+
+// Name returns the command name
+func (c *PublishCommand) Name() string {
+	return "PUBLISH"
+}
+
+// Validate checks if the PUBLISH command arguments are valid
+func (c *PublishCommand) Validate(args []*resp.Message) error {
+	// PUBLISH requires exactly 2 arguments: channel and message
+	if len(args) != 2 {
+		return fmt.Errorf("wrong number of arguments for 'publish' command")
+	}
+	return nil
+}
+
+// Execute processes the PUBLISH command
+func (c *PublishCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	channel, ok := messageString(args[0])
+	if !ok {
+		return resp.NewError("ERR invalid channel type"), nil
+	}
+
+	payload, ok := messageString(args[1])
+	if !ok {
+		return resp.NewError("ERR invalid message type"), nil
+	}
+
+	// Reply with the number of subscribers that received the message
+	receivers := c.broker.Publish(channel, payload)
+	return resp.NewInteger(int64(receivers)), nil
+}
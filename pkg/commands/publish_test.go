@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// recordingSubscriber counts delivered messages
+type recordingSubscriber struct {
+	delivered int
+}
+
+func (r *recordingSubscriber) Deliver(message *resp.Message) {
+	r.delivered++
+}
+
+func TestPublishCommand_Name(t *testing.T) {
+	cmd := NewPublishCommand(pubsub.NewBroker())
+	if cmd.Name() != "PUBLISH" {
+		t.Errorf("Expected command name 'PUBLISH', got '%s'", cmd.Name())
+	}
+}
+
+func TestPublishCommand_Validate(t *testing.T) {
+	cmd := NewPublishCommand(pubsub.NewBroker())
+
+	if err := cmd.Validate([]*resp.Message{resp.NewBulkString("channel"), resp.NewBulkString("message")}); err != nil {
+		t.Errorf("Expected valid arguments, got %v", err)
+	}
+
+	if err := cmd.Validate([]*resp.Message{resp.NewBulkString("channel")}); err == nil {
+		t.Error("Expected error for missing message")
+	}
+}
+
+func TestPublishCommand_Execute(t *testing.T) {
+	broker := pubsub.NewBroker()
+	cmd := NewPublishCommand(broker)
+	store := storage.NewMemoryStore()
+
+	subscriber := &recordingSubscriber{}
+	broker.Subscribe(subscriber, "news")
+
+	response, err := cmd.Execute([]*resp.Message{resp.NewBulkString("news"), resp.NewBulkString("hello")}, store)
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+
+	if response.Type != resp.Integer || response.Value.(int64) != 1 {
+		t.Errorf("Expected integer 1, got %s", response)
+	}
+
+	if subscriber.delivered != 1 {
+		t.Errorf("Expected 1 delivered message, got %d", subscriber.delivered)
+	}
+
+	response, _ = cmd.Execute([]*resp.Message{resp.NewBulkString("empty"), resp.NewBulkString("hello")}, store)
+	if response.Value.(int64) != 0 {
+		t.Errorf("Expected integer 0 for channel without subscribers, got %s", response)
+	}
+}
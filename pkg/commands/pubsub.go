@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// PubSubCommand implements the PUBSUB introspection command
+type PubSubCommand struct {
+	broker *pubsub.Broker
+}
+
+// NewPubSubCommand creates a new PUBSUB command inspecting the given broker
+func NewPubSubCommand(broker *pubsub.Broker) *PubSubCommand {
+	return &PubSubCommand{broker: broker}
+}
+
+// Name returns the command name
+func (c *PubSubCommand) Name() string {
+	return "PUBSUB"
+}
+
+// Validate checks if the PUBSUB command arguments are valid
+func (c *PubSubCommand) Validate(args []*resp.Message) error {
+	// PUBSUB requires a subcommand
+	if len(args) == 0 {
+		return fmt.Errorf("wrong number of arguments for 'pubsub' command")
+	}
+	return nil
+}
+
+// Execute processes the PUBSUB command
+func (c *PubSubCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	subcommand, ok := messageString(args[0])
+	if !ok {
+		return resp.NewError("ERR invalid subcommand type"), nil
+	}
+
+	names := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		name, ok := messageString(arg)
+		if !ok {
+			return resp.NewError("ERR invalid argument type for PUBSUB"), nil
+		}
+		names = append(names, name)
+	}
+
+	switch strings.ToUpper(subcommand) {
+	case "CHANNELS":
+		if len(names) > 1 {
+			return resp.NewError("ERR wrong number of arguments for 'pubsub|channels' command"), nil
+		}
+		pattern := ""
+		if len(names) == 1 {
+			pattern = names[0]
+		}
+		return stringArray(c.broker.Channels(pattern)), nil
+
+	case "NUMSUB":
+		return countMap(names, c.broker.NumSub(names...)), nil
+
+	case "NUMPAT":
+		if len(names) != 0 {
+			return resp.NewError("ERR wrong number of arguments for 'pubsub|numpat' command"), nil
+		}
+		return resp.NewInteger(int64(c.broker.NumPat())), nil
+
+	case "SHARDCHANNELS":
+		if len(names) > 1 {
+			return resp.NewError("ERR wrong number of arguments for 'pubsub|shardchannels' command"), nil
+		}
+		pattern := ""
+		if len(names) == 1 {
+			pattern = names[0]
+		}
+		return stringArray(c.broker.ShardChannels(pattern)), nil
+
+	case "SHARDNUMSUB":
+		return countMap(names, c.broker.ShardNumSub(names...)), nil
+
+	default:
+		return resp.NewError(fmt.Sprintf("ERR unknown subcommand '%s'. Try PUBSUB HELP.", subcommand)), nil
+	}
+}
+
+// stringArray converts strings into an array of bulk strings
+func stringArray(values []string) *resp.Message {
+	elements := make([]*resp.Message, len(values))
+	for i, value := range values {
+		elements[i] = resp.NewBulkString(value)
+	}
+	return resp.NewArray(elements)
+}
+
+// countArray builds the flat name, count, name, count... reply of NUMSUB
+func countMap(names []string, counts []int) *resp.Message {
+	elements := make([]*resp.Message, 0, 2*len(names))
+	for i, name := range names {
+		elements = append(elements, resp.NewBulkString(name), resp.NewInteger(int64(counts[i])))
+	}
+	return resp.NewMap(elements)
+}
@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// stringArgs converts strings into bulk string arguments
+func stringArgs(args ...string) []*resp.Message {
+	messages := make([]*resp.Message, len(args))
+	for i, arg := range args {
+		messages[i] = resp.NewBulkString(arg)
+	}
+	return messages
+}
+
+func TestPubSubCommand_Name(t *testing.T) {
+	cmd := NewPubSubCommand(pubsub.NewBroker())
+	if cmd.Name() != "PUBSUB" {
+		t.Errorf("Expected command name 'PUBSUB', got '%s'", cmd.Name())
+	}
+}
+
+func TestPubSubCommand_Validate(t *testing.T) {
+	cmd := NewPubSubCommand(pubsub.NewBroker())
+
+	if err := cmd.Validate([]*resp.Message{}); err == nil {
+		t.Error("Expected error for missing subcommand")
+	}
+
+	if err := cmd.Validate(stringArgs("NUMPAT")); err != nil {
+		t.Errorf("Expected valid arguments, got %v", err)
+	}
+}
+
+func TestPubSubCommand_Execute(t *testing.T) {
+	broker := pubsub.NewBroker()
+	cmd := NewPubSubCommand(broker)
+	store := storage.NewMemoryStore()
+
+	first := &recordingSubscriber{}
+	second := &recordingSubscriber{}
+	broker.Subscribe(first, "news")
+	broker.Subscribe(second, "news")
+	broker.Subscribe(first, "weather")
+	broker.PSubscribe(first, "n*")
+
+	response, _ := cmd.Execute(stringArgs("CHANNELS"), store)
+	channels, err := response.AsArray()
+	if err != nil || len(channels) != 2 {
+		t.Fatalf("Expected 2 channels, got %s", response)
+	}
+
+	response, _ = cmd.Execute(stringArgs("channels", "w*"), store)
+	channels, _ = response.AsArray()
+	if len(channels) != 1 || string(channels[0].Value.([]byte)) != "weather" {
+		t.Errorf("Expected [weather], got %s", response)
+	}
+
+	response, _ = cmd.Execute(stringArgs("NUMSUB", "news", "missing"), store)
+	counts, _ := response.AsArray()
+	if len(counts) != 4 || counts[1].Value.(int64) != 2 || counts[3].Value.(int64) != 0 {
+		t.Errorf("Unexpected NUMSUB reply %v", counts)
+	}
+
+	response, _ = cmd.Execute(stringArgs("NUMPAT"), store)
+	if response.Type != resp.Integer || response.Value.(int64) != 1 {
+		t.Errorf("Expected NUMPAT 1, got %s", response)
+	}
+
+	response, _ = cmd.Execute(stringArgs("BOGUS"), store)
+	if response.Type != resp.Error {
+		t.Errorf("Expected error for unknown subcommand, got %s", response)
+	}
+}
+
+func TestPubSubCommand_ShardSubcommands(t *testing.T) {
+	broker := pubsub.NewBroker()
+	cmd := NewPubSubCommand(broker)
+	store := storage.NewMemoryStore()
+
+	subscriber := &recordingSubscriber{}
+	broker.SSubscribe(subscriber, "orders")
+	broker.Subscribe(subscriber, "classic")
+
+	response, _ := cmd.Execute(stringArgs("SHARDCHANNELS"), store)
+	channels, err := response.AsArray()
+	if err != nil || len(channels) != 1 || string(channels[0].Value.([]byte)) != "orders" {
+		t.Errorf("Expected [orders], got %s", response)
+	}
+
+	response, _ = cmd.Execute(stringArgs("SHARDNUMSUB", "orders", "classic"), store)
+	counts, _ := response.AsArray()
+	if len(counts) != 4 || counts[1].Value.(int64) != 1 || counts[3].Value.(int64) != 0 {
+		t.Errorf("Unexpected SHARDNUMSUB reply %v", counts)
+	}
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// RestoreCommand implements the RESTORE command
+type RestoreCommand struct{}
+
+// NewRestoreCommand creates a new RESTORE command
+func NewRestoreCommand() *RestoreCommand {
+	return &RestoreCommand{}
+}
+
+// Name returns the command name
+func (c *RestoreCommand) Name() string {
+	return "RESTORE"
+}
+
+// exclusive makes RESTORE run alone, so that no client creates the key
+// between the check for an existing key and the restore
+func (c *RestoreCommand) exclusive() {}
+
+// restoreOptions holds the parsed arguments of RESTORE
+type restoreOptions struct {
+	key     string
+	ttl     int64
+	payload []byte
+	replace bool
+	// absTTL makes ttl a Unix time in milliseconds
+	absTTL bool
+	// idle is the IDLETIME in seconds and freq the FREQ, -1 if not given
+	idle int64
+	freq int
+}
+
+// Validate checks if the RESTORE command arguments are valid
+func (c *RestoreCommand) Validate(args []*resp.Message) error {
+	if len(args) < 3 {
+		return fmt.Errorf("wrong number of arguments for 'restore' command")
+	}
+
+	_, err := parseRestoreOptions(args)
+	return err
+}
+
+// parseRestoreOptions parses "key ttl serialized-value [REPLACE] [ABSTTL]
+// [IDLETIME seconds] [FREQ frequency]"
+func parseRestoreOptions(args []*resp.Message) (restoreOptions, error) {
+	options := restoreOptions{idle: -1, freq: -1}
+
+	var ok bool
+	if options.key, ok = messageString(args[0]); !ok {
+		return options, fmt.Errorf("invalid key type")
+	}
+	ttl, ok := messageString(args[1])
+	if !ok {
+		return options, fmt.Errorf("value is not an integer or out of range")
+	}
+	value, err := strconv.ParseInt(ttl, 10, 64)
+	if err != nil {
+		return options, fmt.Errorf("value is not an integer or out of range")
+	}
+	if value < 0 {
+		return options, fmt.Errorf("Invalid TTL value, must be >= 0")
+	}
+	options.ttl = value
+	if options.payload, ok = messageBytes(args[2]); !ok {
+		return options, fmt.Errorf("DUMP payload version or checksum are wrong")
+	}
+
+	for i := 3; i < len(args); i++ {
+		option, ok := messageString(args[i])
+		if !ok {
+			return options, fmt.Errorf("syntax error")
+		}
+
+		switch option = strings.ToUpper(option); option {
+		case "REPLACE":
+			options.replace = true
+		case "ABSTTL":
+			options.absTTL = true
+		case "IDLETIME", "FREQ":
+			if i+1 == len(args) {
+				return options, fmt.Errorf("syntax error")
+			}
+			i++
+			argument, _ := messageString(args[i])
+			value, err := strconv.ParseInt(argument, 10, 64)
+			if err != nil {
+				return options, fmt.Errorf("value is not an integer or out of range")
+			}
+			if option == "IDLETIME" {
+				if value < 0 {
+					return options, fmt.Errorf("Invalid IDLETIME value, must be >= 0")
+				}
+				options.idle = value
+			} else {
+				if value < 0 || value > 255 {
+					return options, fmt.Errorf("Invalid FREQ value, must be >= 0 and <= 255")
+				}
+				options.freq = int(value)
+			}
+		default:
+			return options, fmt.Errorf("syntax error")
+		}
+	}
+
+	// An entry has either an idle time or a frequency, depending on
+	// whether the eviction policy is LRU or LFU
+	if options.idle >= 0 && options.freq >= 0 {
+		return options, fmt.Errorf("syntax error")
+	}
+
+	// A relative TTL past the range of a duration would wrap to the past
+	if !options.absTTL && options.ttl > math.MaxInt64/int64(time.Millisecond) {
+		return options, fmt.Errorf("invalid expire time in 'restore' command")
+	}
+	return options, nil
+}
+
+// Execute creates a key from a DUMP payload, expiring after ttl
+// milliseconds, or at ttl with ABSTTL, unless ttl is 0. An existing key is
+// only replaced with REPLACE. A key whose ABSTTL has passed is not
+// created, but still replaces the existing one.
+func (c *RestoreCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	options, err := parseRestoreOptions(args)
+	if err != nil {
+		return resp.NewError("ERR " + err.Error()), nil
+	}
+
+	if !options.replace && store.Exists(options.key) {
+		return resp.NewError("BUSYKEY Target key name already exists."), nil
+	}
+
+	entry, err := persistence.DecodeDump(options.payload)
+	if err != nil {
+		return resp.NewError("ERR " + err.Error()), nil
+	}
+	if entry.Type != persistence.TypeString {
+		return resp.NewError("ERR Bad data format: " + entry.Type.String() + " values are not supported"), nil
+	}
+
+	now := time.Now()
+	var expiresAt time.Time
+	switch {
+	case options.ttl > 0 && options.absTTL:
+		expiresAt = time.UnixMilli(options.ttl)
+	case options.ttl > 0:
+		expiresAt = now.Add(time.Duration(options.ttl) * time.Millisecond)
+	}
+
+	if !expiresAt.IsZero() && !now.Before(expiresAt) {
+		if store.Delete(options.key) {
+			store.Notify(events.Generic, "del", options.key)
+		}
+		return resp.NewSimpleString("OK"), nil
+	}
+
+	if err := store.SetWithExpiry(options.key, entry.String, expiresAt); err != nil {
+		return storeError(err), nil
+	}
+	if options.idle >= 0 || options.freq >= 0 {
+		idle := time.Duration(min(options.idle, math.MaxInt64/int64(time.Second))) * time.Second
+		store.SetAccess(options.key, idle, options.freq)
+	}
+	store.Notify(events.Generic, "restore", options.key)
+	return resp.NewSimpleString("OK"), nil
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// dumpOf returns the DUMP payload of a string
+func dumpOf(t *testing.T, value string) string {
+	t.Helper()
+	payload, err := persistence.EncodeDump(&persistence.Entry{Type: persistence.TypeString, String: []byte(value)}, true)
+	if err != nil {
+		t.Fatalf("EncodeDump() returned error: %v", err)
+	}
+	return string(payload)
+}
+
+func TestRestoreCommand_Validate(t *testing.T) {
+	cmd := NewRestoreCommand()
+	payload := dumpOf(t, "value")
+
+	if cmd.Name() != "RESTORE" {
+		t.Errorf("Expected command name 'RESTORE', got '%s'", cmd.Name())
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no ttl", []string{"key", "0", payload}, ""},
+		{"all options", []string{"key", "100", payload, "replace", "ABSTTL", "IDLETIME", "10"}, ""},
+		{"freq", []string{"key", "0", payload, "FREQ", "255"}, ""},
+		{"missing payload", []string{"key", "0"}, "wrong number of arguments for 'restore' command"},
+		{"invalid ttl", []string{"key", "soon", payload}, "value is not an integer or out of range"},
+		{"negative ttl", []string{"key", "-1", payload}, "Invalid TTL value, must be >= 0"},
+		{"ttl overflow", []string{"key", "9223372036854775807", payload}, "invalid expire time in 'restore' command"},
+		{"largest absolute ttl", []string{"key", "9223372036854775807", payload, "ABSTTL"}, ""},
+		{"negative idletime", []string{"key", "0", payload, "IDLETIME", "-1"}, "Invalid IDLETIME value, must be >= 0"},
+		{"freq too high", []string{"key", "0", payload, "FREQ", "256"}, "Invalid FREQ value, must be >= 0 and <= 255"},
+		{"idletime and freq", []string{"key", "0", payload, "IDLETIME", "1", "FREQ", "1"}, "syntax error"},
+		{"missing idletime", []string{"key", "0", payload, "IDLETIME"}, "syntax error"},
+		{"unknown option", []string{"key", "0", payload, "NX"}, "syntax error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmd.Validate(stringArgs(tt.args...))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRestoreCommand_Execute(t *testing.T) {
+	payload := dumpOf(t, "restored")
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).UnixMilli(), 10)
+	future := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+
+	tests := []struct {
+		name      string
+		args      []string
+		expected  string
+		value     string
+		expiresAt time.Time
+	}{
+		{"existing key", []string{"existing", "0", payload}, "BUSYKEY Target key name already exists.", "old", time.Time{}},
+		{"replace", []string{"existing", "0", payload, "REPLACE"}, "OK", "restored", time.Time{}},
+		{"new key", []string{"new", "0", payload}, "OK", "restored", time.Time{}},
+		{"absolute ttl", []string{"new", strconv.FormatInt(future.UnixMilli(), 10), payload, "ABSTTL"}, "OK", "restored", future},
+		{"expired", []string{"existing", past, payload, "ABSTTL", "REPLACE"}, "OK", "", time.Time{}},
+		{"ttl overflow", []string{"existing", "9223372036854775807", payload, "REPLACE"}, "ERR invalid expire time in 'restore' command", "old", time.Time{}},
+		{"bad payload", []string{"new", "0", payload[:len(payload)-1] + "x"}, "ERR DUMP payload version or checksum are wrong", "", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			store.Set("existing", []byte("old"))
+
+			response, _ := NewRestoreCommand().Execute(stringArgs(tt.args...), store)
+			if response.Value.(string) != tt.expected {
+				t.Errorf("Expected %q, got %s", tt.expected, response)
+			}
+
+			// An empty value means the key must not exist
+			value, expiresAt, exists := store.GetWithExpiry(tt.args[0])
+			if exists != (tt.value != "") || string(value) != tt.value {
+				t.Errorf("Expected value %q, got %q (exists %v)", tt.value, value, exists)
+			}
+			if !expiresAt.Equal(tt.expiresAt) {
+				t.Errorf("Expected expiry %v, got %v", tt.expiresAt, expiresAt)
+			}
+		})
+	}
+}
+
+func TestRestoreCommand_Exclusive(t *testing.T) {
+	// The check for an existing key and the restore must not interleave
+	// with other clients
+	if _, ok := any(NewRestoreCommand()).(exclusiveCommand); !ok {
+		t.Error("Expected RESTORE to run exclusively")
+	}
+}
+
+func TestRestoreCommand_RelativeTTL(t *testing.T) {
+	store := storage.NewMemoryStore()
+	before := time.Now()
+
+	response, _ := NewRestoreCommand().Execute(stringArgs("key", "5000", dumpOf(t, "v")), store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+	_, expiresAt, _ := store.GetWithExpiry("key")
+	if expiresAt.Before(before.Add(5*time.Second)) || expiresAt.After(time.Now().Add(5*time.Second)) {
+		t.Errorf("Expected the key to expire in 5s, got %v", expiresAt.Sub(before))
+	}
+}
+
+func TestRestoreCommand_Access(t *testing.T) {
+	store := storage.NewMemoryStore()
+	payload := dumpOf(t, "v")
+
+	NewRestoreCommand().Execute(stringArgs("idle", "0", payload, "IDLETIME", "3600"), store)
+	if info, _ := store.Inspect("idle"); info.Idle < time.Hour || info.Idle > time.Hour+time.Second {
+		t.Errorf("Expected an idle time of 1h, got %v", info.Idle)
+	}
+
+	NewRestoreCommand().Execute(stringArgs("freq", "0", payload, "FREQ", "200"), store)
+	if info, _ := store.Inspect("freq"); info.Freq != 200 {
+		t.Errorf("Expected frequency 200, got %d", info.Freq)
+	}
+}
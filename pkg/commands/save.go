@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// SaveCommand implements the SAVE command
+type SaveCommand struct {
+	snapshotter *persistence.Snapshotter
+}
+
+// NewSaveCommand creates a new SAVE command saving through snapshotter
+func NewSaveCommand(snapshotter *persistence.Snapshotter) *SaveCommand {
+	return &SaveCommand{snapshotter: snapshotter}
+}
+
+// Name returns the command name
+func (c *SaveCommand) Name() string {
+	return "SAVE"
+}
+
+// Validate checks if the SAVE command arguments are valid
+func (c *SaveCommand) Validate(args []*resp.Message) error {
+	// SAVE takes no arguments
+	if len(args) != 0 {
+		return fmt.Errorf("wrong number of arguments for 'save' command")
+	}
+	return nil
+}
+
+// Execute saves a snapshot and replies once it is on disk
+func (c *SaveCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	err := c.snapshotter.Save()
+	if errors.Is(err, persistence.ErrSaveInProgress) {
+		return resp.NewError("ERR Background save already in progress"), nil
+	}
+	if err != nil {
+		return resp.NewError("ERR Failed saving the DB: " + err.Error()), nil
+	}
+	return resp.NewSimpleString("OK"), nil
+}
+
+// BgSaveCommand implements the BGSAVE command
+type BgSaveCommand struct {
+	snapshotter *persistence.Snapshotter
+}
+
+// NewBgSaveCommand creates a new BGSAVE command saving through snapshotter
+func NewBgSaveCommand(snapshotter *persistence.Snapshotter) *BgSaveCommand {
+	return &BgSaveCommand{snapshotter: snapshotter}
+}
+
+// Name returns the command name
+func (c *BgSaveCommand) Name() string {
+	return "BGSAVE"
+}
+
+// Validate checks if the BGSAVE command arguments are valid
+func (c *BgSaveCommand) Validate(args []*resp.Message) error {
+	// BGSAVE takes an optional SCHEDULE argument
+	if len(args) > 1 {
+		return fmt.Errorf("wrong number of arguments for 'bgsave' command")
+	}
+	return nil
+}
+
+// Execute starts a background save. With SCHEDULE, a save requested while
+// another runs is started once it ends instead of failing.
+func (c *BgSaveCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	if len(args) == 1 {
+		option, ok := messageString(args[0])
+		if !ok || !strings.EqualFold(option, "SCHEDULE") {
+			return resp.NewError("ERR syntax error"), nil
+		}
+		if c.snapshotter.ScheduleBackgroundSave() {
+			return resp.NewSimpleString("Background saving scheduled"), nil
+		}
+		return resp.NewSimpleString("Background saving started"), nil
+	}
+
+	if err := c.snapshotter.BackgroundSave(); err != nil {
+		return resp.NewError("ERR Background save already in progress"), nil
+	}
+	return resp.NewSimpleString("Background saving started"), nil
+}
+
+// LastSaveCommand implements the LASTSAVE command
+type LastSaveCommand struct {
+	snapshotter *persistence.Snapshotter
+}
+
+// NewLastSaveCommand creates a new LASTSAVE command
+func NewLastSaveCommand(snapshotter *persistence.Snapshotter) *LastSaveCommand {
+	return &LastSaveCommand{snapshotter: snapshotter}
+}
+
+// Name returns the command name
+func (c *LastSaveCommand) Name() string {
+	return "LASTSAVE"
+}
+
+// Validate checks if the LASTSAVE command arguments are valid
+func (c *LastSaveCommand) Validate(args []*resp.Message) error {
+	// LASTSAVE takes no arguments
+	if len(args) != 0 {
+		return fmt.Errorf("wrong number of arguments for 'lastsave' command")
+	}
+	return nil
+}
+
+// Execute returns the Unix time of the last successful save
+func (c *LastSaveCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	return resp.NewInteger(c.snapshotter.LastSave().Unix()), nil
+}
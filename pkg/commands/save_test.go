@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// newSnapshotter returns a snapshotter saving store to a temporary directory
+func newSnapshotter(t *testing.T, store *storage.MemoryStore) *persistence.Snapshotter {
+	t.Helper()
+	return persistence.NewSnapshotter(store, filepath.Join(t.TempDir(), "dump.rdb"), persistence.RDBOptions{})
+}
+
+func TestSaveCommands_Name(t *testing.T) {
+	snapshotter := newSnapshotter(t, storage.NewMemoryStore())
+
+	tests := []struct {
+		command Command
+		name    string
+	}{
+		{NewSaveCommand(snapshotter), "SAVE"},
+		{NewBgSaveCommand(snapshotter), "BGSAVE"},
+		{NewLastSaveCommand(snapshotter), "LASTSAVE"},
+	}
+
+	for _, tt := range tests {
+		if tt.command.Name() != tt.name {
+			t.Errorf("Expected command name '%s', got '%s'", tt.name, tt.command.Name())
+		}
+	}
+}
+
+func TestSaveCommands_Validate(t *testing.T) {
+	snapshotter := newSnapshotter(t, storage.NewMemoryStore())
+
+	tests := []struct {
+		name    string
+		command Command
+		args    []*resp.Message
+		wantErr bool
+	}{
+		{"SAVE", NewSaveCommand(snapshotter), nil, false},
+		{"SAVE with argument", NewSaveCommand(snapshotter), stringArgs("now"), true},
+		{"BGSAVE", NewBgSaveCommand(snapshotter), nil, false},
+		{"BGSAVE SCHEDULE", NewBgSaveCommand(snapshotter), stringArgs("SCHEDULE"), false},
+		{"BGSAVE with two arguments", NewBgSaveCommand(snapshotter), stringArgs("SCHEDULE", "now"), true},
+		{"LASTSAVE", NewLastSaveCommand(snapshotter), nil, false},
+		{"LASTSAVE with argument", NewLastSaveCommand(snapshotter), stringArgs("now"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.command.Validate(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSaveCommand_Execute(t *testing.T) {
+	store := storage.NewMemoryStore()
+	store.Set("key", []byte("value"))
+	snapshotter := newSnapshotter(t, store)
+
+	response, err := NewSaveCommand(snapshotter).Execute(nil, store)
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if response.Type != resp.SimpleString || response.Value.(string) != "OK" {
+		t.Errorf("Expected OK, got %s", response)
+	}
+
+	loaded := storage.NewMemoryStore()
+	if _, err := persistence.LoadSnapshot(snapshotter.Path(), loaded); err != nil {
+		t.Fatalf("LoadSnapshot() returned error: %v", err)
+	}
+	if value, _ := loaded.Get("key"); string(value) != "value" {
+		t.Errorf("Expected 'value', got %q", value)
+	}
+}
+
+func TestSaveCommand_ExecuteFailure(t *testing.T) {
+	store := storage.NewMemoryStore()
+	snapshotter := persistence.NewSnapshotter(store, filepath.Join(t.TempDir(), "missing", "dump.rdb"), persistence.RDBOptions{})
+
+	response, _ := NewSaveCommand(snapshotter).Execute(nil, store)
+	if response.Type != resp.Error {
+		t.Errorf("Expected an error, got %s", response)
+	}
+}
+
+func TestBgSaveCommand_Execute(t *testing.T) {
+	store := storage.NewMemoryStore()
+	snapshotter := newSnapshotter(t, store)
+	cmd := NewBgSaveCommand(snapshotter)
+
+	response, _ := cmd.Execute(nil, store)
+	if response.Type != resp.SimpleString || response.Value.(string) != "Background saving started" {
+		t.Errorf("Expected 'Background saving started', got %s", response)
+	}
+	snapshotter.Wait()
+
+	response, _ = cmd.Execute(stringArgs("schedule"), store)
+	if response.Type != resp.SimpleString {
+		t.Errorf("Expected a simple string, got %s", response)
+	}
+	snapshotter.Wait()
+
+	response, _ = cmd.Execute(stringArgs("later"), store)
+	if response.Type != resp.Error || response.Value.(string) != "ERR syntax error" {
+		t.Errorf("Expected syntax error, got %s", response)
+	}
+}
+
+func TestLastSaveCommand_Execute(t *testing.T) {
+	store := storage.NewMemoryStore()
+	snapshotter := newSnapshotter(t, store)
+
+	response, err := NewLastSaveCommand(snapshotter).Execute(nil, store)
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+	if response.Type != resp.Integer {
+		t.Fatalf("Expected an integer, got %s", response)
+	}
+	if lastSave := response.Value.(int64); time.Now().Unix()-lastSave > 1 {
+		t.Errorf("Expected the creation time, got %d", lastSave)
+	}
+}
@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/glob"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// DefaultScanCount is the number of keys SCAN examines without COUNT
+const DefaultScanCount = 10
+
+// ScanCommand implements the SCAN command
+type ScanCommand struct{}
+
+// NewScanCommand creates a new SCAN command
+func NewScanCommand() *ScanCommand {
+	return &ScanCommand{}
+}
+
+// Name returns the command name
+func (c *ScanCommand) Name() string {
+	return "SCAN"
+}
+
+// scanOptions holds the parsed arguments of SCAN
+type scanOptions struct {
+	cursor  uint64
+	pattern string
+	count   int
+	// keyType is the TYPE filter, empty if not given
+	keyType string
+}
+
+// Validate checks if the SCAN command arguments are valid
+func (c *ScanCommand) Validate(args []*resp.Message) error {
+	if len(args) == 0 {
+		return fmt.Errorf("wrong number of arguments for 'scan' command")
+	}
+
+	_, err := parseScanOptions(args)
+	return err
+}
+
+// parseScanOptions parses "cursor [MATCH pattern] [COUNT count] [TYPE type]"
+func parseScanOptions(args []*resp.Message) (scanOptions, error) {
+	options := scanOptions{count: DefaultScanCount}
+
+	cursor, ok := messageString(args[0])
+	if !ok {
+		return options, fmt.Errorf("invalid cursor")
+	}
+	value, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil {
+		return options, fmt.Errorf("invalid cursor")
+	}
+	options.cursor = value
+
+	for i := 1; i < len(args); i += 2 {
+		option, ok := messageString(args[i])
+		if !ok || i+1 == len(args) {
+			return options, fmt.Errorf("syntax error")
+		}
+		argument, ok := messageString(args[i+1])
+		if !ok {
+			return options, fmt.Errorf("syntax error")
+		}
+
+		switch strings.ToUpper(option) {
+		case "MATCH":
+			options.pattern = argument
+		case "COUNT":
+			count, err := strconv.Atoi(argument)
+			if err != nil {
+				return options, fmt.Errorf("value is not an integer or out of range")
+			}
+			if count < 1 {
+				return options, fmt.Errorf("syntax error")
+			}
+			options.count = count
+		case "TYPE":
+			options.keyType = strings.ToLower(argument)
+		default:
+			return options, fmt.Errorf("syntax error")
+		}
+	}
+
+	return options, nil
+}
+
+// Execute processes the SCAN command
+func (c *ScanCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	options, err := parseScanOptions(args)
+	if err != nil {
+		return resp.NewError("ERR " + err.Error()), nil
+	}
+
+	keys, next := store.Scan(options.cursor, options.count)
+
+	// As in Redis, COUNT bounds the keys examined and the filters are
+	// applied afterwards, so a batch may come back empty. Every key holds
+	// a string.
+	elements := make([]*resp.Message, 0, len(keys))
+	for _, key := range keys {
+		if options.keyType != "" && options.keyType != "string" {
+			continue
+		}
+		if options.pattern != "" && !glob.Match(options.pattern, key) {
+			continue
+		}
+		elements = append(elements, resp.NewBulkString(key))
+	}
+
+	return resp.NewArray([]*resp.Message{
+		resp.NewBulkString(strconv.FormatUint(next, 10)),
+		resp.NewArray(elements),
+	}), nil
+}
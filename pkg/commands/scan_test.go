@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func TestScanCommand_Name(t *testing.T) {
+	cmd := NewScanCommand()
+	if cmd.Name() != "SCAN" {
+		t.Errorf("Expected command name 'SCAN', got '%s'", cmd.Name())
+	}
+}
+
+func TestScanCommand_Validate(t *testing.T) {
+	cmd := NewScanCommand()
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"cursor only", []string{"0"}, ""},
+		{"all options", []string{"0", "MATCH", "user:*", "COUNT", "100", "TYPE", "string"}, ""},
+		{"no args", []string{}, "wrong number of arguments for 'scan' command"},
+		{"invalid cursor", []string{"abc"}, "invalid cursor"},
+		{"negative cursor", []string{"-1"}, "invalid cursor"},
+		{"option without value", []string{"0", "MATCH"}, "syntax error"},
+		{"unknown option", []string{"0", "LIMIT", "10"}, "syntax error"},
+		{"invalid count", []string{"0", "COUNT", "many"}, "value is not an integer or out of range"},
+		{"zero count", []string{"0", "COUNT", "0"}, "syntax error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := make([]*resp.Message, len(tt.args))
+			for i, arg := range tt.args {
+				args[i] = resp.NewBulkString(arg)
+			}
+
+			err := cmd.Validate(args)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// scanAll runs SCAN until the cursor returns to 0 and returns the sorted keys
+func scanAll(t *testing.T, store storage.Store, options ...string) []string {
+	t.Helper()
+
+	cmd := NewScanCommand()
+	var keys []string
+	cursor := "0"
+	for {
+		args := []*resp.Message{resp.NewBulkString(cursor)}
+		for _, option := range options {
+			args = append(args, resp.NewBulkString(option))
+		}
+
+		reply, _ := cmd.Execute(args, store)
+		elements, err := reply.AsArray()
+		if err != nil || len(elements) != 2 {
+			t.Fatalf("Expected a two element array, got %s", reply)
+		}
+
+		batch, _ := elements[1].AsArray()
+		for _, key := range batch {
+			value, _ := key.AsString()
+			keys = append(keys, value)
+		}
+
+		if cursor, _ = elements[0].AsString(); cursor == "0" {
+			break
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func TestScanCommand_Execute(t *testing.T) {
+	store := storage.NewMemoryStore()
+	for _, key := range []string{"user:1", "user:2", "user:3", "session:1", "other"} {
+		store.Set(key, []byte("value"))
+	}
+
+	tests := []struct {
+		name     string
+		options  []string
+		expected []string
+	}{
+		{"all keys", nil, []string{"other", "session:1", "user:1", "user:2", "user:3"}},
+		{"small count", []string{"COUNT", "1"}, []string{"other", "session:1", "user:1", "user:2", "user:3"}},
+		{"match", []string{"MATCH", "user:*", "COUNT", "2"}, []string{"user:1", "user:2", "user:3"}},
+		{"string type", []string{"TYPE", "string"}, []string{"other", "session:1", "user:1", "user:2", "user:3"}},
+		{"other type", []string{"TYPE", "list"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanAll(t, store, tt.options...); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
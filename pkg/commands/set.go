@@ -2,7 +2,12 @@ package commands
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/tsinivuo/redis-lite/pkg/events"
 	"github.com/tsinivuo/redis-lite/pkg/resp"
 	"github.com/tsinivuo/redis-lite/pkg/storage"
 )
@@ -22,11 +27,71 @@ func (c *SetCommand) Name() string {
 
 // Validate checks if the SET command arguments are valid
 func (c *SetCommand) Validate(args []*resp.Message) error {
-	// SET requires exactly 2 arguments: key and value
-	if len(args) != 2 {
+	// SET requires a key and a value, optionally followed by an expiry option
+	if len(args) < 2 {
 		return fmt.Errorf("wrong number of arguments for 'set' command")
 	}
-	return nil
+
+	_, err := parseSetExpiry(args[2:], time.Now())
+	return err
+}
+
+// parseSetExpiry parses the optional EX, PX, EXAT or PXAT argument of SET and
+// returns the absolute expiry time, or the zero time if none was given
+func parseSetExpiry(options []*resp.Message, now time.Time) (time.Time, error) {
+	if len(options) == 0 {
+		return time.Time{}, nil
+	}
+
+	if len(options) != 2 {
+		return time.Time{}, fmt.Errorf("syntax error")
+	}
+
+	option, ok := messageString(options[0])
+	if !ok {
+		return time.Time{}, fmt.Errorf("syntax error")
+	}
+
+	amountStr, ok := messageString(options[1])
+	if !ok {
+		return time.Time{}, fmt.Errorf("value is not an integer or out of range")
+	}
+
+	amount, err := strconv.ParseInt(amountStr, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("value is not an integer or out of range")
+	}
+
+	if amount <= 0 {
+		return time.Time{}, fmt.Errorf("invalid expire time in 'set' command")
+	}
+
+	var expiresAt time.Time
+	switch strings.ToUpper(option) {
+	case "EX":
+		expiresAt, ok = expiryAfter(now, amount, time.Second)
+	case "PX":
+		expiresAt, ok = expiryAfter(now, amount, time.Millisecond)
+	case "EXAT":
+		expiresAt, ok = time.Unix(amount, 0), amount <= math.MaxInt64/1000
+	case "PXAT":
+		expiresAt = time.UnixMilli(amount)
+	default:
+		return time.Time{}, fmt.Errorf("syntax error")
+	}
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid expire time in 'set' command")
+	}
+	return expiresAt, nil
+}
+
+// expiryAfter returns the time amount units after now. It reports false if
+// the duration overflows, which would otherwise wrap to a time in the past.
+func expiryAfter(now time.Time, amount int64, unit time.Duration) (time.Time, bool) {
+	if amount > math.MaxInt64/int64(unit) {
+		return time.Time{}, false
+	}
+	return now.Add(time.Duration(amount) * unit), true
 }
 
 // Execute processes the SET command
@@ -42,35 +107,44 @@ func (c *SetCommand) Execute(args []*resp.Message, store storage.Store) (*resp.M
 		if keyArg.Value == nil {
 			return resp.NewError("ERR key cannot be null"), nil
 		}
-		key = keyArg.Value.(string)
+		key = string(keyArg.Value.([]byte))
 	case resp.SimpleString:
 		key = keyArg.Value.(string)
 	default:
 		return resp.NewError("ERR invalid key type"), nil
 	}
 
-	// Convert value to string
-	var value string
+	// Extract the value. Bulk strings are stored without copying: the
+	// store takes ownership of the slice the parser allocated.
+	var value []byte
 	switch valueArg.Type {
 	case resp.BulkString:
 		if valueArg.Value == nil {
-			value = ""
+			value = []byte{}
 		} else {
-			value = valueArg.Value.(string)
+			value = valueArg.Value.([]byte)
 		}
-	case resp.SimpleString:
-		value = valueArg.Value.(string)
-	case resp.Integer:
-		value = fmt.Sprintf("%d", valueArg.Value.(int64))
+	case resp.SimpleString, resp.Integer:
+		value, _ = messageBytes(valueArg)
 	default:
 		return resp.NewError("ERR invalid value type"), nil
 	}
 
-	// Store the key-value pair
-	if err := store.Set(key, value); err != nil {
+	expiresAt, err := parseSetExpiry(args[2:], time.Now())
+	if err != nil {
 		return resp.NewError("ERR " + err.Error()), nil
 	}
 
+	// Store the key-value pair
+	if err := store.SetWithExpiry(key, value, expiresAt); err != nil {
+		return storeError(err), nil
+	}
+
+	store.Notify(events.String, "set", key)
+	if !expiresAt.IsZero() {
+		store.Notify(events.Generic, "expire", key)
+	}
+
 	// Return OK response
 	return resp.NewSimpleString("OK"), nil
 }
@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/tsinivuo/redis-lite/pkg/resp"
 	"github.com/tsinivuo/redis-lite/pkg/storage"
@@ -132,7 +134,7 @@ func TestSetCommand_Execute(t *testing.T) {
 					return
 				}
 
-				if response.Value != tt.wantResponse.Value {
+				if !reflect.DeepEqual(response.Value, tt.wantResponse.Value) {
 					t.Errorf("Execute() response value = %v, want %v", response.Value, tt.wantResponse.Value)
 				}
 			}
@@ -161,7 +163,7 @@ func TestSetCommand_Integration(t *testing.T) {
 	if !exists {
 		t.Error("Key was not stored in the storage")
 	}
-	if storedValue != "testvalue" {
+	if string(storedValue) != "testvalue" {
 		t.Errorf("Expected stored value 'testvalue', got '%s'", storedValue)
 	}
 }
@@ -191,7 +193,99 @@ func TestSetCommand_OverwriteValue(t *testing.T) {
 	if !exists {
 		t.Error("Key was not found in storage")
 	}
-	if storedValue != "value2" {
+	if string(storedValue) != "value2" {
 		t.Errorf("Expected stored value 'value2', got '%s'", storedValue)
 	}
 }
+
+func TestSetCommand_ValidateExpiry(t *testing.T) {
+	cmd := NewSetCommand()
+
+	tests := []struct {
+		name    string
+		options []string
+		wantErr bool
+	}{
+		{name: "EX", options: []string{"EX", "10"}},
+		{name: "PX lowercase", options: []string{"px", "100"}},
+		{name: "EXAT", options: []string{"EXAT", "4102444800"}},
+		{name: "PXAT", options: []string{"PXAT", "4102444800000"}},
+		{name: "missing amount", options: []string{"EX"}, wantErr: true},
+		{name: "non-integer amount", options: []string{"EX", "ten"}, wantErr: true},
+		{name: "zero amount", options: []string{"PX", "0"}, wantErr: true},
+		{name: "negative amount", options: []string{"EX", "-1"}, wantErr: true},
+		{name: "EX overflow", options: []string{"EX", "9223372036854775807"}, wantErr: true},
+		{name: "PX overflow", options: []string{"PX", "9223372036854775807"}, wantErr: true},
+		{name: "EXAT overflow", options: []string{"EXAT", "9223372036854775807"}, wantErr: true},
+		{name: "largest EX", options: []string{"EX", "9223372036"}},
+		{name: "unknown option", options: []string{"KX", "10"}, wantErr: true},
+		{name: "too many options", options: []string{"EX", "10", "PX"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []*resp.Message{resp.NewBulkString("key"), resp.NewBulkString("value")}
+			for _, option := range tt.options {
+				args = append(args, resp.NewBulkString(option))
+			}
+
+			err := cmd.Validate(args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSetCommand_ExecuteWithExpiry(t *testing.T) {
+	cmd := NewSetCommand()
+	store := storage.NewMemoryStore()
+
+	args := []*resp.Message{
+		resp.NewBulkString("key"),
+		resp.NewBulkString("value"),
+		resp.NewBulkString("PX"),
+		resp.NewBulkString("20"),
+	}
+
+	response, err := cmd.Execute(args, store)
+	if err != nil || response.Type != resp.SimpleString || response.Value != "OK" {
+		t.Fatalf("Expected OK response, got %v (err=%v)", response, err)
+	}
+
+	if !store.Exists("key") {
+		t.Fatal("Key should exist before expiry")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if store.Exists("key") {
+		t.Error("Key should not exist after expiry")
+	}
+}
+
+func TestSetCommand_KeyspaceEvents(t *testing.T) {
+	cmd := NewSetCommand()
+	store, notifier := newNotifyingStore()
+
+	cmd.Execute(stringArgs("key", "value"), store)
+	cmd.Execute(stringArgs("key", "value", "EX", "10"), store)
+
+	want := []string{"set:key", "set:key", "expire:key"}
+	if !reflect.DeepEqual(notifier.events, want) {
+		t.Errorf("Expected events %v, got %v", want, notifier.events)
+	}
+}
+
+func TestSetCommand_OutOfMemory(t *testing.T) {
+	cmd := NewSetCommand()
+	store := storage.NewMemoryStore()
+	store.Set("key", []byte("value"))
+	store.SetMemoryLimit(storage.MemoryLimit{MaxMemory: 1, Policy: storage.NoEviction})
+
+	response, _ := cmd.Execute(stringArgs("other", "value"), store)
+	expected := "OOM command not allowed when used memory > 'maxmemory'."
+	if response.Type != resp.Error || response.Value.(string) != expected {
+		t.Errorf("Expected %q, got %s", expected, response)
+	}
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// SPublishCommand implements the SPUBLISH command
+type SPublishCommand struct {
+	broker *pubsub.Broker
+}
+
+// NewSPublishCommand creates a new SPUBLISH command publishing on the given broker
+func NewSPublishCommand(broker *pubsub.Broker) *SPublishCommand {
+	return &SPublishCommand{broker: broker}
+}
+
+// Name returns the command name
+func (c *SPublishCommand) Name() string {
+	return "SPUBLISH"
+}
+
+// Validate checks if the SPUBLISH command arguments are valid
+func (c *SPublishCommand) Validate(args []*resp.Message) error {
+	// SPUBLISH requires exactly 2 arguments: shard channel and message
+	if len(args) != 2 {
+		return fmt.Errorf("wrong number of arguments for 'spublish' command")
+	}
+	return nil
+}
+
+// Execute processes the SPUBLISH command
+func (c *SPublishCommand) Execute(args []*resp.Message, store storage.Store) (*resp.Message, error) {
+	channel, ok := messageString(args[0])
+	if !ok {
+		return resp.NewError("ERR invalid channel type"), nil
+	}
+
+	payload, ok := messageString(args[1])
+	if !ok {
+		return resp.NewError("ERR invalid message type"), nil
+	}
+
+	// Reply with the number of shard subscribers that received the message
+	receivers := c.broker.SPublish(channel, payload)
+	return resp.NewInteger(int64(receivers)), nil
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func TestSPublishCommand_Name(t *testing.T) {
+	cmd := NewSPublishCommand(pubsub.NewBroker())
+	if cmd.Name() != "SPUBLISH" {
+		t.Errorf("Expected command name 'SPUBLISH', got '%s'", cmd.Name())
+	}
+}
+
+func TestSPublishCommand_Validate(t *testing.T) {
+	cmd := NewSPublishCommand(pubsub.NewBroker())
+
+	if err := cmd.Validate(stringArgs("channel", "message")); err != nil {
+		t.Errorf("Expected valid arguments, got %v", err)
+	}
+
+	if err := cmd.Validate(stringArgs("channel")); err == nil {
+		t.Error("Expected error for missing message")
+	}
+}
+
+func TestSPublishCommand_Execute(t *testing.T) {
+	broker := pubsub.NewBroker()
+	cmd := NewSPublishCommand(broker)
+	store := storage.NewMemoryStore()
+
+	shardSubscriber := &recordingSubscriber{}
+	classicSubscriber := &recordingSubscriber{}
+	broker.SSubscribe(shardSubscriber, "orders")
+	broker.Subscribe(classicSubscriber, "orders")
+
+	response, err := cmd.Execute(stringArgs("orders", "created"), store)
+	if err != nil {
+		t.Fatalf("Execute() returned error: %v", err)
+	}
+
+	if response.Type != resp.Integer || response.Value.(int64) != 1 {
+		t.Errorf("Expected integer 1, got %s", response)
+	}
+
+	if shardSubscriber.delivered != 1 || classicSubscriber.delivered != 0 {
+		t.Errorf("Expected only the shard subscriber to receive the message, got %d and %d",
+			shardSubscriber.delivered, classicSubscriber.delivered)
+	}
+}
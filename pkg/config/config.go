@@ -0,0 +1,115 @@
+// Package config holds the server configuration. It is read from a
+// redis.conf-style file, one directive per line, and from command-line
+// overrides such as --port 7000.
+package config
+
+import (
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// appendfsync policies
+const (
+	FsyncAlways   = "always"
+	FsyncEverySec = "everysec"
+	FsyncNo       = "no"
+)
+
+// maxmemory-policy values
+const (
+	PolicyNoEviction     = "noeviction"
+	PolicyAllKeysLRU     = "allkeys-lru"
+	PolicyVolatileLRU    = "volatile-lru"
+	PolicyAllKeysLFU     = "allkeys-lfu"
+	PolicyVolatileLFU    = "volatile-lfu"
+	PolicyAllKeysRandom  = "allkeys-random"
+	PolicyVolatileRandom = "volatile-random"
+	PolicyVolatileTTL    = "volatile-ttl"
+)
+
+// SavePoint triggers a snapshot once Changes writes happened within
+// Seconds, as a "save <seconds> <changes>" directive does
+type SavePoint struct {
+	Seconds int
+	Changes int
+}
+
+// Config holds the server settings
+type Config struct {
+	// File is the configuration file the settings were read from, empty if
+	// there is none
+	File string
+
+	// Network
+	Bind string
+	Port int
+	// Timeout closes clients idle for this long, 0 to never close them
+	Timeout time.Duration
+	// TCPKeepAlive is the TCP keepalive period, 0 to disable keepalives
+	TCPKeepAlive time.Duration
+	MaxClients   int
+
+	// Databases is the number of databases. Only database 0 is served;
+	// the setting is kept for compatibility with existing files.
+	Databases   int
+	RequirePass string
+
+	// Persistence
+	Dir            string
+	DBFilename     string
+	Save           []SavePoint
+	RDBCompression bool
+	RDBChecksum    bool
+	AppendOnly     bool
+	AppendFilename string
+	// AppendDirname is the directory in Dir holding the append-only files
+	AppendDirname    string
+	AppendFsync      string
+	AOFLoadTruncated bool
+	// AOFUseRDBPreamble writes the base file of the append-only file as
+	// an RDB snapshot
+	AOFUseRDBPreamble bool
+	// AutoAOFRewritePercentage is the growth since the last rewrite that
+	// triggers a rewrite, 0 to disable automatic rewrites, once the files
+	// are larger than AutoAOFRewriteMinSize
+	AutoAOFRewritePercentage int
+	AutoAOFRewriteMinSize    int64
+
+	// Memory
+	MaxMemory        int64
+	MaxMemoryPolicy  string
+	MaxMemorySamples int
+
+	// Protocol and notifications
+	ProtoMaxBulkLen      int64
+	NotifyKeyspaceEvents string
+}
+
+// Default returns the default configuration, matching the Redis defaults
+// where the setting exists in Redis
+func Default() *Config {
+	return &Config{
+		Bind:             "127.0.0.1",
+		Port:             6379,
+		TCPKeepAlive:     300 * time.Second,
+		MaxClients:       10000,
+		Databases:        16,
+		Dir:              ".",
+		DBFilename:       "dump.rdb",
+		Save:             []SavePoint{{3600, 1}, {300, 100}, {60, 10000}},
+		RDBCompression:   true,
+		RDBChecksum:      true,
+		AppendFilename:   "appendonly.aof",
+		AppendDirname:    "appendonlydir",
+		AppendFsync:      FsyncEverySec,
+		AOFLoadTruncated: true,
+
+		AOFUseRDBPreamble:        true,
+		AutoAOFRewritePercentage: 100,
+		AutoAOFRewriteMinSize:    64 * 1024 * 1024,
+		MaxMemoryPolicy:          PolicyNoEviction,
+		MaxMemorySamples:         5,
+		ProtoMaxBulkLen:          resp.DefaultMaxBulkLength,
+	}
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tsinivuo/redis-lite/pkg/glob"
+)
+
+// ErrUnknownParameter is returned when setting a parameter that does not
+// exist
+var ErrUnknownParameter = errors.New("unknown parameter")
+
+// ErrImmutable is returned when setting a parameter that can only be set at
+// startup
+var ErrImmutable = errors.New("can't set immutable config")
+
+// ParameterError reports the parameter a change failed on
+type ParameterError struct {
+	Name string
+	Err  error
+}
+
+func (e *ParameterError) Error() string {
+	return fmt.Sprintf("'%s': %v", e.Name, e.Err)
+}
+
+func (e *ParameterError) Unwrap() error {
+	return e.Err
+}
+
+// Setting is a parameter name and its value
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// Hook applies a configuration change to a subsystem. It receives the new
+// configuration and may reject it by returning an error.
+type Hook func(c *Config) error
+
+// Manager holds the configuration of a running server. It lets parameters
+// be read and changed concurrently, and calls the hooks registered for a
+// parameter when its value changes.
+type Manager struct {
+	mutex  sync.RWMutex
+	config *Config
+	hooks  []watcher
+}
+
+// watcher is a hook and the parameters it watches
+type watcher struct {
+	names []string
+	hook  Hook
+}
+
+// NewManager creates a manager for a configuration, which it takes
+// ownership of
+func NewManager(c *Config) *Manager {
+	return &Manager{config: c}
+}
+
+// Config returns a copy of the current configuration
+func (m *Manager) Config() *Config {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.config.clone()
+}
+
+// OnChange registers a hook called when any of the named parameters
+// changes. A hook watching several of the parameters changed by one Set is
+// called once.
+func (m *Manager) OnChange(hook Hook, names ...string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, name := range names {
+		if lookup(name) == nil {
+			panic("config: hook registered for unknown parameter " + name)
+		}
+	}
+	m.hooks = append(m.hooks, watcher{names: names, hook: hook})
+}
+
+// Get returns the parameters whose names match any of the glob patterns,
+// in the order of a configuration file
+func (m *Manager) Get(patterns ...string) []Setting {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var settings []Setting
+	for _, p := range parameters {
+		for _, pattern := range patterns {
+			if glob.Match(strings.ToLower(pattern), p.name) {
+				settings = append(settings, Setting{Name: p.name, Value: p.get(m.config)})
+				break
+			}
+		}
+	}
+	return settings
+}
+
+// Set changes parameters atomically: if any value is invalid, or a hook
+// rejects the change, the configuration is left as it was and the hooks
+// are called again to restore it. Errors are ParameterErrors.
+func (m *Manager) Set(settings ...Setting) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	updated := m.config.clone()
+	var changed []string
+
+	for _, setting := range settings {
+		name := strings.ToLower(setting.Name)
+		p := lookup(name)
+		switch {
+		case p == nil:
+			return &ParameterError{Name: setting.Name, Err: ErrUnknownParameter}
+		case p.immutable:
+			return &ParameterError{Name: name, Err: ErrImmutable}
+		case slices.Contains(changed, name):
+			return &ParameterError{Name: name, Err: errors.New("duplicate parameter")}
+		}
+
+		if err := p.set(updated, setting.Value); err != nil {
+			return &ParameterError{Name: name, Err: err}
+		}
+		changed = append(changed, name)
+	}
+
+	previous := m.config
+	m.config = updated
+
+	if name, err := m.runHooks(changed); err != nil {
+		m.config = previous
+		m.runHooks(changed)
+		return &ParameterError{Name: name, Err: err}
+	}
+	return nil
+}
+
+// runHooks calls the hooks watching any of the changed parameters, in the
+// order they were registered, and returns the parameter whose hook failed
+func (m *Manager) runHooks(changed []string) (string, error) {
+	for _, w := range m.hooks {
+		for _, name := range changed {
+			if !slices.Contains(w.names, name) {
+				continue
+			}
+			if err := w.hook(m.config.clone()); err != nil {
+				return name, err
+			}
+			break
+		}
+	}
+	return "", nil
+}
+
+// Rewrite updates the configuration file with the current values, keeping
+// its comments and the order of its directives
+func (m *Manager) Rewrite() error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.config.File == "" {
+		return errors.New("the server is running without a config file")
+	}
+	return rewriteFile(m.config.File, m.config)
+}
+
+// clone returns a deep copy of the configuration
+func (c *Config) clone() *Config {
+	copied := *c
+	copied.Save = slices.Clone(c.Save)
+	return &copied
+}
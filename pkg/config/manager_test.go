@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestManager_Get(t *testing.T) {
+	m := NewManager(Default())
+
+	tests := []struct {
+		patterns []string
+		expected []string
+	}{
+		{[]string{"port"}, []string{"port"}},
+		{[]string{"PORT"}, []string{"port"}},
+		{[]string{"maxmemory*"}, []string{"maxmemory", "maxmemory-policy", "maxmemory-samples"}},
+		{[]string{"dbfilename", "bind"}, []string{"bind", "dbfilename"}},
+		{[]string{"*max*", "maxclients"}, []string{"maxclients", "maxmemory", "maxmemory-policy", "maxmemory-samples", "proto-max-bulk-len"}},
+		{[]string{"nosuch*"}, nil},
+	}
+
+	for _, tt := range tests {
+		var names []string
+		for _, setting := range m.Get(tt.patterns...) {
+			names = append(names, setting.Name)
+		}
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("Expected %v for %v, got %v", tt.expected, tt.patterns, names)
+		}
+	}
+
+	if settings := m.Get("port"); settings[0].Value != "6379" {
+		t.Errorf("Expected port 6379, got %q", settings[0].Value)
+	}
+}
+
+func TestManager_Set(t *testing.T) {
+	m := NewManager(Default())
+
+	if err := m.Set(Setting{"maxmemory", "100mb"}, Setting{"TIMEOUT", "30"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c := m.Config(); c.MaxMemory != 100*1024*1024 || c.Timeout.Seconds() != 30 {
+		t.Errorf("Expected the new values, got %d and %v", c.MaxMemory, c.Timeout)
+	}
+
+	tests := []struct {
+		name     string
+		settings []Setting
+		param    string
+		err      error
+	}{
+		{"unknown", []Setting{{"nosuch", "1"}}, "nosuch", ErrUnknownParameter},
+		{"immutable", []Setting{{"port", "7000"}}, "port", ErrImmutable},
+		{"invalid", []Setting{{"maxmemory", "lots"}}, "maxmemory", nil},
+		{"duplicate", []Setting{{"timeout", "1"}, {"Timeout", "2"}}, "timeout", nil},
+		{"all or none", []Setting{{"maxmemory", "1mb"}, {"maxclients", "0"}}, "maxclients", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Set(tt.settings...)
+
+			var paramErr *ParameterError
+			if !errors.As(err, &paramErr) {
+				t.Fatalf("Expected a ParameterError, got %v", err)
+			}
+			if paramErr.Name != tt.param {
+				t.Errorf("Expected error on %s, got %s", tt.param, paramErr.Name)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v, got %v", tt.err, err)
+			}
+
+			if c := m.Config(); c.MaxMemory != 100*1024*1024 || c.Timeout.Seconds() != 30 {
+				t.Errorf("Expected the configuration to be unchanged, got %d and %v", c.MaxMemory, c.Timeout)
+			}
+		})
+	}
+}
+
+func TestManager_Hooks(t *testing.T) {
+	m := NewManager(Default())
+
+	var memoryCalls, clientCalls int
+	var seen int64
+	m.OnChange(func(c *Config) error {
+		memoryCalls++
+		seen = c.MaxMemory
+		return nil
+	}, "maxmemory", "maxmemory-policy")
+	m.OnChange(func(c *Config) error {
+		clientCalls++
+		return nil
+	}, "maxclients")
+
+	if err := m.Set(Setting{"maxmemory", "1kb"}, Setting{"maxmemory-policy", "allkeys-lru"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if memoryCalls != 1 {
+		t.Errorf("Expected the memory hook to run once, got %d", memoryCalls)
+	}
+	if seen != 1024 {
+		t.Errorf("Expected the hook to see the new value, got %d", seen)
+	}
+	if clientCalls != 0 {
+		t.Errorf("Expected the client hook not to run, got %d", clientCalls)
+	}
+}
+
+func TestManager_HookRejects(t *testing.T) {
+	m := NewManager(Default())
+
+	var applied []int64
+	m.OnChange(func(c *Config) error {
+		applied = append(applied, c.MaxMemory)
+		return nil
+	}, "maxmemory")
+	m.OnChange(func(c *Config) error {
+		if c.MaxMemoryPolicy == PolicyAllKeysRandom {
+			return errors.New("policy not supported")
+		}
+		return nil
+	}, "maxmemory-policy")
+
+	err := m.Set(Setting{"maxmemory", "1mb"}, Setting{"maxmemory-policy", "allkeys-random"})
+	var paramErr *ParameterError
+	if !errors.As(err, &paramErr) || paramErr.Name != "maxmemory-policy" {
+		t.Fatalf("Expected the hook error on maxmemory-policy, got %v", err)
+	}
+
+	if c := m.Config(); c.MaxMemory != 0 || c.MaxMemoryPolicy != PolicyNoEviction {
+		t.Errorf("Expected the configuration to be restored, got %d %s", c.MaxMemory, c.MaxMemoryPolicy)
+	}
+	// The hooks ran with the new values, then again with the restored ones
+	if !reflect.DeepEqual(applied, []int64{1024 * 1024, 0}) {
+		t.Errorf("Expected the hook to apply and restore, got %v", applied)
+	}
+}
+
+func TestManager_ConfigIsACopy(t *testing.T) {
+	m := NewManager(Default())
+
+	c := m.Config()
+	c.Port = 1
+	c.Save[0].Seconds = 1
+
+	if c := m.Config(); c.Port != 6379 || c.Save[0].Seconds != 3600 {
+		t.Errorf("Expected the configuration to be unchanged, got %+v", c)
+	}
+}
+
+func TestManager_RewriteWithoutFile(t *testing.T) {
+	if err := NewManager(Default()).Rewrite(); err == nil {
+		t.Error("Expected error without a config file")
+	}
+}
@@ -0,0 +1,330 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
+)
+
+// parameter is a setting that can be read from a configuration file. Its
+// value is exchanged as a string, in the form a directive takes it.
+type parameter struct {
+	name string
+	// multiArg parameters, such as save, take several arguments in a
+	// directive, joined with spaces into a single value
+	multiArg bool
+	// cumulative parameters add the values of successive directives to
+	// each other rather than replacing them
+	cumulative bool
+	// immutable parameters can only be set at startup
+	immutable bool
+	set       func(c *Config, value string) error
+	get       func(c *Config) string
+}
+
+// parameters lists the supported parameters in the order of a redis.conf
+var parameters = []*parameter{
+	immutable(bindParameter()),
+	immutable(intParameter("port", func(c *Config) *int { return &c.Port }, 0, 65535)),
+	secondsParameter("timeout", func(c *Config) *time.Duration { return &c.Timeout }),
+	secondsParameter("tcp-keepalive", func(c *Config) *time.Duration { return &c.TCPKeepAlive }),
+	intParameter("maxclients", func(c *Config) *int { return &c.MaxClients }, 1, math.MaxInt32),
+	immutable(intParameter("databases", func(c *Config) *int { return &c.Databases }, 1, math.MaxInt32)),
+	stringParameter("requirepass", func(c *Config) *string { return &c.RequirePass }, nil),
+
+	stringParameter("dir", func(c *Config) *string { return &c.Dir }, validateDir),
+	stringParameter("dbfilename", func(c *Config) *string { return &c.DBFilename }, validateFilename),
+	saveParameter(),
+	boolParameter("rdbcompression", func(c *Config) *bool { return &c.RDBCompression }),
+	boolParameter("rdbchecksum", func(c *Config) *bool { return &c.RDBChecksum }),
+	boolParameter("appendonly", func(c *Config) *bool { return &c.AppendOnly }),
+	immutable(stringParameter("appendfilename", func(c *Config) *string { return &c.AppendFilename }, validateFilename)),
+	immutable(stringParameter("appenddirname", func(c *Config) *string { return &c.AppendDirname }, validateFilename)),
+	enumParameter("appendfsync", func(c *Config) *string { return &c.AppendFsync },
+		FsyncAlways, FsyncEverySec, FsyncNo),
+	intParameter("auto-aof-rewrite-percentage", func(c *Config) *int { return &c.AutoAOFRewritePercentage }, 0, math.MaxInt32),
+	memoryParameter("auto-aof-rewrite-min-size", func(c *Config) *int64 { return &c.AutoAOFRewriteMinSize }, 0),
+	boolParameter("aof-load-truncated", func(c *Config) *bool { return &c.AOFLoadTruncated }),
+	boolParameter("aof-use-rdb-preamble", func(c *Config) *bool { return &c.AOFUseRDBPreamble }),
+
+	memoryParameter("maxmemory", func(c *Config) *int64 { return &c.MaxMemory }, 0),
+	enumParameter("maxmemory-policy", func(c *Config) *string { return &c.MaxMemoryPolicy },
+		PolicyNoEviction, PolicyAllKeysLRU, PolicyVolatileLRU, PolicyAllKeysLFU,
+		PolicyVolatileLFU, PolicyAllKeysRandom, PolicyVolatileRandom, PolicyVolatileTTL),
+	intParameter("maxmemory-samples", func(c *Config) *int { return &c.MaxMemorySamples }, 1, 64),
+
+	memoryParameter("proto-max-bulk-len", func(c *Config) *int64 { return &c.ProtoMaxBulkLen }, 1024*1024),
+	stringParameter("notify-keyspace-events", func(c *Config) *string { return &c.NotifyKeyspaceEvents },
+		validateKeyspaceEvents),
+}
+
+// lookup returns the parameter with the given name, ignoring case, or nil
+func lookup(name string) *parameter {
+	name = strings.ToLower(name)
+	for _, p := range parameters {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// immutable marks a parameter as settable at startup only
+func immutable(p *parameter) *parameter {
+	p.immutable = true
+	return p
+}
+
+// intParameter is an integer within [min, max]
+func intParameter(name string, field func(*Config) *int, min, max int) *parameter {
+	return &parameter{
+		name: name,
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an integer")
+			}
+			if n < min || n > max {
+				return fmt.Errorf("argument must be between %d and %d inclusive", min, max)
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c *Config) string { return strconv.Itoa(*field(c)) },
+	}
+}
+
+// secondsParameter is a non-negative duration given in seconds
+func secondsParameter(name string, field func(*Config) *time.Duration) *parameter {
+	return &parameter{
+		name: name,
+		set: func(c *Config, value string) error {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.New("argument couldn't be parsed into an integer")
+			}
+			if n < 0 || n > math.MaxInt64/int64(time.Second) {
+				return errors.New("argument must be a non-negative number of seconds")
+			}
+			*field(c) = time.Duration(n) * time.Second
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatInt(int64(*field(c)/time.Second), 10) },
+	}
+}
+
+// memoryParameter is a number of bytes of at least min, with an optional
+// unit such as 100mb
+func memoryParameter(name string, field func(*Config) *int64, min int64) *parameter {
+	return &parameter{
+		name: name,
+		set: func(c *Config, value string) error {
+			n, err := ParseMemory(value)
+			if err != nil {
+				return err
+			}
+			if n < min {
+				return fmt.Errorf("argument must be at least %d bytes", min)
+			}
+			*field(c) = n
+			return nil
+		},
+		get: func(c *Config) string { return strconv.FormatInt(*field(c), 10) },
+	}
+}
+
+// boolParameter is a yes or no flag
+func boolParameter(name string, field func(*Config) *bool) *parameter {
+	return &parameter{
+		name: name,
+		set: func(c *Config, value string) error {
+			switch strings.ToLower(value) {
+			case "yes":
+				*field(c) = true
+			case "no":
+				*field(c) = false
+			default:
+				return errors.New("argument must be 'yes' or 'no'")
+			}
+			return nil
+		},
+		get: func(c *Config) string {
+			if *field(c) {
+				return "yes"
+			}
+			return "no"
+		},
+	}
+}
+
+// enumParameter is one of a fixed set of values, ignoring case
+func enumParameter(name string, field func(*Config) *string, values ...string) *parameter {
+	return &parameter{
+		name: name,
+		set: func(c *Config, value string) error {
+			value = strings.ToLower(value)
+			if !slices.Contains(values, value) {
+				return fmt.Errorf("argument(s) must be one of the following: %s", strings.Join(values, ", "))
+			}
+			*field(c) = value
+			return nil
+		},
+		get: func(c *Config) string { return *field(c) },
+	}
+}
+
+// stringParameter is a string, checked by validate if not nil
+func stringParameter(name string, field func(*Config) *string, validate func(string) error) *parameter {
+	return &parameter{
+		name: name,
+		set: func(c *Config, value string) error {
+			if validate != nil {
+				if err := validate(value); err != nil {
+					return err
+				}
+			}
+			*field(c) = value
+			return nil
+		},
+		get: func(c *Config) string { return *field(c) },
+	}
+}
+
+// bindParameter holds the listening address. It takes a list of
+// addresses, as in Redis, but the server has a single listener, so only
+// one is accepted.
+func bindParameter() *parameter {
+	return &parameter{
+		name:     "bind",
+		multiArg: true,
+		set: func(c *Config, value string) error {
+			switch len(strings.Fields(value)) {
+			case 0:
+				return errors.New("bind requires an address")
+			case 1:
+				c.Bind = strings.TrimSpace(value)
+				return nil
+			default:
+				return errors.New("only one bind address is supported")
+			}
+		},
+		get: func(c *Config) string { return c.Bind },
+	}
+}
+
+// saveParameter holds the snapshot save points as "<seconds> <changes>"
+// pairs. An empty value disables snapshots.
+func saveParameter() *parameter {
+	return &parameter{
+		name:       "save",
+		multiArg:   true,
+		cumulative: true,
+		set: func(c *Config, value string) error {
+			points, err := parseSavePoints(value)
+			if err != nil {
+				return err
+			}
+			c.Save = points
+			return nil
+		},
+		get: func(c *Config) string {
+			fields := make([]string, 0, 2*len(c.Save))
+			for _, point := range c.Save {
+				fields = append(fields, strconv.Itoa(point.Seconds), strconv.Itoa(point.Changes))
+			}
+			return strings.Join(fields, " ")
+		},
+	}
+}
+
+// parseSavePoints parses "<seconds> <changes>" pairs
+func parseSavePoints(value string) ([]SavePoint, error) {
+	fields := strings.Fields(value)
+	if len(fields)%2 != 0 {
+		return nil, errors.New("invalid save parameters, expected pairs of seconds and changes")
+	}
+
+	points := make([]SavePoint, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err := strconv.Atoi(fields[i])
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("invalid save seconds '%s'", fields[i])
+		}
+		changes, err := strconv.Atoi(fields[i+1])
+		if err != nil || changes < 0 {
+			return nil, fmt.Errorf("invalid save changes '%s'", fields[i+1])
+		}
+		points = append(points, SavePoint{Seconds: seconds, Changes: changes})
+	}
+	return points, nil
+}
+
+// memoryUnits are the multipliers of the memory units, as in redis.conf:
+// k is 1000 bytes and kb is 1024 bytes
+var memoryUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1000,
+	"kb": 1024,
+	"m":  1000 * 1000,
+	"mb": 1024 * 1024,
+	"g":  1000 * 1000 * 1000,
+	"gb": 1024 * 1024 * 1024,
+}
+
+// ParseMemory parses a number of bytes with an optional unit, such as
+// "100mb" or "1gb". Units are case-insensitive.
+func ParseMemory(value string) (int64, error) {
+	lower := strings.ToLower(value)
+	digits := strings.TrimRight(lower, "bkmg")
+
+	multiplier, ok := memoryUnits[lower[len(digits):]]
+	if !ok || digits == "" {
+		return 0, fmt.Errorf("argument must be a memory value, got '%s'", value)
+	}
+
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("argument must be a memory value, got '%s'", value)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("memory value '%s' is too large", value)
+	}
+	return n * multiplier, nil
+}
+
+// validateDir checks that the working directory for persistence files
+// exists
+func validateDir(value string) error {
+	info, err := os.Stat(value)
+	if err != nil {
+		return fmt.Errorf("can't use dir '%s': %v", value, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("can't use dir '%s': not a directory", value)
+	}
+	return nil
+}
+
+// validateFilename checks that a file name has no directory part, since
+// persistence files are kept in dir
+func validateFilename(value string) error {
+	if value == "" || value == "." || value == ".." || filepath.Base(value) != value {
+		return fmt.Errorf("'%s' must be a file name, not a path", value)
+	}
+	return nil
+}
+
+// validateKeyspaceEvents checks the notify-keyspace-events flags
+func validateKeyspaceEvents(value string) error {
+	_, err := events.ParseFlags(value)
+	return err
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+		err      bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"10b", 10, false},
+		{"1k", 1000, false},
+		{"1kb", 1024, false},
+		{"100mb", 100 * 1024 * 1024, false},
+		{"100MB", 100 * 1024 * 1024, false},
+		{"2m", 2000000, false},
+		{"1gb", 1 << 30, false},
+		{"3g", 3000000000, false},
+		{"", 0, true},
+		{"mb", 0, true},
+		{"-1", 0, true},
+		{"1tb", 0, true},
+		{"1.5gb", 0, true},
+		{"99999999999999gb", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseMemory(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("Expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParameters_SetGet(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		err      bool
+	}{
+		{"port", "7000", "7000", false},
+		{"port", "65536", "", true},
+		{"port", "x", "", true},
+		{"timeout", "30", "30", false},
+		{"timeout", "-1", "", true},
+		{"maxclients", "0", "", true},
+		{"bind", "0.0.0.0", "0.0.0.0", false},
+		{"bind", "127.0.0.1 ::1", "", true},
+		{"dir", dir, dir, false},
+		{"dir", filepath.Join(dir, "missing"), "", true},
+		{"dbfilename", "data.rdb", "data.rdb", false},
+		{"dbfilename", "../data.rdb", "", true},
+		{"save", "900 1 300 10", "900 1 300 10", false},
+		{"save", "", "", false},
+		{"save", "900", "", true},
+		{"save", "0 1", "", true},
+		{"appendonly", "YES", "yes", false},
+		{"appendonly", "maybe", "", true},
+		{"appendfsync", "Always", "always", false},
+		{"appendfsync", "sometimes", "", true},
+		{"maxmemory", "100mb", "104857600", false},
+		{"maxmemory-policy", "allkeys-lru", "allkeys-lru", false},
+		{"maxmemory-policy", "lru", "", true},
+		{"maxmemory-samples", "65", "", true},
+		{"proto-max-bulk-len", "1kb", "", true},
+		{"notify-keyspace-events", "KEA", "KEA", false},
+		{"notify-keyspace-events", "Q", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.value, func(t *testing.T) {
+			c := Default()
+			p := lookup(tt.name)
+			if p == nil {
+				t.Fatalf("Expected parameter %s", tt.name)
+			}
+
+			err := p.set(c, tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("Expected error, got value %q", p.get(c))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := p.get(c); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParameters_Fields(t *testing.T) {
+	c := Default()
+	values := map[string]string{
+		"timeout":          "30",
+		"tcp-keepalive":    "0",
+		"maxmemory":        "1gb",
+		"save":             "60 5",
+		"requirepass":      "secret",
+		"rdbcompression":   "no",
+		"maxmemory-policy": "volatile-ttl",
+	}
+	for name, value := range values {
+		if err := lookup(name).set(c, value); err != nil {
+			t.Fatalf("Failed to set %s: %v", name, err)
+		}
+	}
+
+	if c.Timeout != 30*time.Second {
+		t.Errorf("Expected timeout 30s, got %v", c.Timeout)
+	}
+	if c.TCPKeepAlive != 0 {
+		t.Errorf("Expected keepalive disabled, got %v", c.TCPKeepAlive)
+	}
+	if c.MaxMemory != 1<<30 {
+		t.Errorf("Expected maxmemory 1gb, got %d", c.MaxMemory)
+	}
+	if len(c.Save) != 1 || c.Save[0] != (SavePoint{Seconds: 60, Changes: 5}) {
+		t.Errorf("Expected save point 60 5, got %v", c.Save)
+	}
+	if c.RequirePass != "secret" || c.RDBCompression || c.MaxMemoryPolicy != PolicyVolatileTTL {
+		t.Errorf("Unexpected configuration %+v", c)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if p := lookup("MaxMemory"); p == nil || p.name != "maxmemory" {
+		t.Errorf("Expected case-insensitive lookup, got %v", p)
+	}
+	if p := lookup("nosuchparameter"); p != nil {
+		t.Errorf("Expected no parameter, got %s", p.name)
+	}
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// CommandLine is the source of the directives given as command-line
+// overrides
+const CommandLine = "command line"
+
+// LineError reports an invalid directive and where it was found
+type LineError struct {
+	// Source is the file name, or CommandLine
+	Source string
+	// Line is the line number in the file, or the position of the option
+	// among the command-line arguments
+	Line int
+	// Directive is the directive as written
+	Directive string
+	Err       error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("%s:%d: '%s': %v", e.Source, e.Line, e.Directive, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// directive is a parameter name followed by its arguments
+type directive struct {
+	line int
+	text string
+	args []string
+}
+
+// Load returns the default configuration updated with the file at path,
+// unless path is empty, and then with the command-line overrides, such as
+// "--port", "7000". Every invalid directive is reported, as LineErrors
+// joined together.
+func Load(path string, overrides []string) (*Config, error) {
+	c := Default()
+
+	var errs []error
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open config file: %w", err)
+		}
+		defer file.Close()
+
+		c.File = path
+		if err := c.Read(file, path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := c.Override(overrides); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SplitCommandLine splits the server arguments into the configuration file
+// path, which comes first if given, and the overrides that follow
+func SplitCommandLine(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+// Read applies the directives of a configuration file. source names the
+// file in errors. Blank lines and lines starting with '#' are ignored, and
+// arguments may be quoted as in inline commands.
+func (c *Config) Read(r io.Reader, source string) error {
+	var directives []directive
+	var errs []error
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		args, err := resp.SplitArgs(text)
+		if err != nil {
+			errs = append(errs, &LineError{Source: source, Line: line, Directive: text, Err: err})
+			continue
+		}
+		directives = append(directives, directive{line: line, text: text, args: args})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+
+	errs = append(errs, c.apply(source, directives)...)
+	return errors.Join(errs...)
+}
+
+// Override applies command-line overrides: each option, such as --port,
+// names a parameter and the arguments up to the next option are its value
+func (c *Config) Override(args []string) error {
+	var directives []directive
+	var errs []error
+
+	for i := 0; i < len(args); i++ {
+		name, ok := strings.CutPrefix(args[i], "--")
+		if !ok || name == "" {
+			errs = append(errs, &LineError{
+				Source:    CommandLine,
+				Line:      i + 1,
+				Directive: args[i],
+				Err:       errors.New("expected an option such as --port"),
+			})
+			continue
+		}
+
+		d := directive{line: i + 1, args: []string{name}}
+		for i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			i++
+			d.args = append(d.args, args[i])
+		}
+		d.text = strings.Join(args[d.line-1:i+1], " ")
+		directives = append(directives, d)
+	}
+
+	errs = append(errs, c.apply(CommandLine, directives)...)
+	return errors.Join(errs...)
+}
+
+// apply sets the parameters named by the directives. The first directive
+// of a cumulative parameter, such as save, replaces its value, and the
+// following ones from the same source add to it.
+func (c *Config) apply(source string, directives []directive) []error {
+	var errs []error
+	seen := make(map[string]bool)
+
+	for _, d := range directives {
+		fail := func(err error) {
+			errs = append(errs, &LineError{Source: source, Line: d.line, Directive: d.text, Err: err})
+		}
+
+		p := lookup(d.args[0])
+		if p == nil {
+			fail(fmt.Errorf("unknown directive '%s'", d.args[0]))
+			continue
+		}
+
+		if len(d.args) < 2 || (!p.multiArg && len(d.args) > 2) {
+			fail(errors.New("wrong number of arguments"))
+			continue
+		}
+
+		value := strings.Join(d.args[1:], " ")
+		if p.cumulative && seen[p.name] {
+			value = strings.TrimSpace(p.get(c) + " " + value)
+		}
+
+		if err := p.set(c, value); err != nil {
+			fail(err)
+			continue
+		}
+		seen[p.name] = true
+	}
+	return errs
+}
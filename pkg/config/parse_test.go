@@ -0,0 +1,201 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_Read(t *testing.T) {
+	file := `# Network
+bind 0.0.0.0
+port 7000
+
+  timeout 60
+requirepass "pass word"
+
+save 900 1
+save 300 10
+maxmemory 100mb
+maxmemory-policy allkeys-lru
+`
+
+	c := Default()
+	if err := c.Read(strings.NewReader(file), "redis.conf"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if c.Bind != "0.0.0.0" || c.Port != 7000 {
+		t.Errorf("Expected 0.0.0.0:7000, got %s:%d", c.Bind, c.Port)
+	}
+	if c.Timeout != time.Minute {
+		t.Errorf("Expected timeout 1m, got %v", c.Timeout)
+	}
+	if c.RequirePass != "pass word" {
+		t.Errorf("Expected quoted password, got %q", c.RequirePass)
+	}
+	expectedSave := []SavePoint{{900, 1}, {300, 10}}
+	if !reflect.DeepEqual(c.Save, expectedSave) {
+		t.Errorf("Expected save points %v, got %v", expectedSave, c.Save)
+	}
+	if c.MaxMemory != 100*1024*1024 || c.MaxMemoryPolicy != PolicyAllKeysLRU {
+		t.Errorf("Expected 100mb allkeys-lru, got %d %s", c.MaxMemory, c.MaxMemoryPolicy)
+	}
+}
+
+func TestConfig_ReadErrors(t *testing.T) {
+	file := `port 7000
+port abc
+unknown yes
+timeout
+save 60
+bind "unbalanced
+maxclients 10 20
+`
+
+	c := Default()
+	err := c.Read(strings.NewReader(file), "redis.conf")
+	if err == nil {
+		t.Fatal("Expected errors")
+	}
+
+	expected := []struct {
+		line      int
+		directive string
+	}{
+		{6, `bind "unbalanced`},
+		{2, "port abc"},
+		{3, "unknown yes"},
+		{4, "timeout"},
+		{5, "save 60"},
+		{7, "maxclients 10 20"},
+	}
+
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d errors, got %q", len(expected), lines)
+	}
+	for i, e := range expected {
+		prefix := fmt.Sprintf("redis.conf:%d: '%s'", e.line, e.directive)
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("Expected error starting with %q, got %q", prefix, lines[i])
+		}
+	}
+
+	var lineErr *LineError
+	if !errors.As(err, &lineErr) || lineErr.Source != "redis.conf" {
+		t.Errorf("Expected a LineError, got %v", err)
+	}
+
+	// Valid directives still apply
+	if c.Port != 7000 {
+		t.Errorf("Expected port 7000, got %d", c.Port)
+	}
+}
+
+func TestConfig_Override(t *testing.T) {
+	c := Default()
+	err := c.Override([]string{"--port", "7000", "--maxmemory", "100mb", "--save", "900", "1", "300", "10", "--appendonly", "yes"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if c.Port != 7000 || c.MaxMemory != 100*1024*1024 || !c.AppendOnly {
+		t.Errorf("Unexpected configuration %+v", c)
+	}
+	expectedSave := []SavePoint{{900, 1}, {300, 10}}
+	if !reflect.DeepEqual(c.Save, expectedSave) {
+		t.Errorf("Expected save points %v, got %v", expectedSave, c.Save)
+	}
+}
+
+func TestConfig_OverrideErrors(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"7000"}, "command line:1: '7000': expected an option"},
+		{[]string{"--port"}, "command line:1: '--port': wrong number of arguments"},
+		{[]string{"--port", "1", "--nosuch", "x"}, "command line:3: '--nosuch x': unknown directive"},
+		{[]string{"--maxclients", "1", "2"}, "command line:1: '--maxclients 1 2': wrong number of arguments"},
+	}
+
+	for _, tt := range tests {
+		err := Default().Override(tt.args)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+			t.Errorf("Expected error %q for %v, got %v", tt.expected, tt.args, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis-lite.conf")
+	if err := os.WriteFile(path, []byte("port 7000\nsave 900 1\nmaxmemory 1mb\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	c, err := Load(path, []string{"--port", "7001", "--save", ""})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if c.File != path {
+		t.Errorf("Expected file %s, got %s", path, c.File)
+	}
+	if c.Port != 7001 {
+		t.Errorf("Expected the override to win, got port %d", c.Port)
+	}
+	if len(c.Save) != 0 {
+		t.Errorf("Expected snapshots disabled, got %v", c.Save)
+	}
+	if c.MaxMemory != 1024*1024 {
+		t.Errorf("Expected maxmemory from the file, got %d", c.MaxMemory)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.conf"), nil); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+
+	c, err = Load("", nil)
+	if err != nil || !reflect.DeepEqual(c, Default()) {
+		t.Errorf("Expected the defaults without a file, got %+v, %v", c, err)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		args      []string
+		path      string
+		overrides []string
+	}{
+		{nil, "", nil},
+		{[]string{"redis.conf"}, "redis.conf", []string{}},
+		{[]string{"redis.conf", "--port", "1"}, "redis.conf", []string{"--port", "1"}},
+		{[]string{"--port", "1"}, "", []string{"--port", "1"}},
+	}
+
+	for _, tt := range tests {
+		path, overrides := SplitCommandLine(tt.args)
+		if path != tt.path || len(overrides) != len(tt.overrides) {
+			t.Errorf("Expected %q %q for %v, got %q %q", tt.path, tt.overrides, tt.args, path, overrides)
+		}
+	}
+}
+
+func TestLoad_SampleFile(t *testing.T) {
+	c, err := Load(filepath.Join("..", "..", "redis-lite.conf"), nil)
+	if err != nil {
+		t.Fatalf("Expected the sample file to load, got %v", err)
+	}
+
+	defaults := Default()
+	defaults.File = c.File
+	if !reflect.DeepEqual(c, defaults) {
+		t.Errorf("Expected the sample file to hold the defaults, got %+v", c)
+	}
+}
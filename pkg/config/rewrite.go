@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// rewriteMarker precedes the directives CONFIG REWRITE appends to a file
+const rewriteMarker = "# Generated by CONFIG REWRITE"
+
+// rewriteFile replaces a configuration file with a copy updated with the
+// current values. The copy is written to a temporary file, synced and
+// renamed over the original, so the file is never left half-written.
+func rewriteFile(path string, c *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var lines []string
+	if len(content) > 0 {
+		lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+	updated := strings.Join(rewriteLines(lines, c), "\n") + "\n"
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary config file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.WriteString(updated); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to sync config file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(temp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to set config file mode: %w", err)
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace config file: %w", err)
+	}
+	return nil
+}
+
+// rewriteLines updates the lines of a configuration file. The first
+// directive of each parameter is replaced with its current value and the
+// repeated ones are dropped. Comments, blank lines and unknown directives
+// are kept in place. Parameters that are not in the file but differ from
+// their default are appended after rewriteMarker.
+func rewriteLines(lines []string, c *Config) []string {
+	written := make(map[string]bool)
+	hasMarker := false
+	out := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		text := strings.TrimSpace(line)
+		if text == rewriteMarker {
+			hasMarker = true
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			out = append(out, line)
+			continue
+		}
+
+		args, err := resp.SplitArgs(text)
+		var p *parameter
+		if err == nil && len(args) > 0 {
+			p = lookup(args[0])
+		}
+		if p == nil {
+			out = append(out, line)
+			continue
+		}
+
+		if !written[p.name] {
+			written[p.name] = true
+			out = append(out, formatDirective(p, c))
+		}
+	}
+
+	defaults := Default()
+	var appended []string
+	for _, p := range parameters {
+		if !written[p.name] && p.get(c) != p.get(defaults) {
+			appended = append(appended, formatDirective(p, c))
+		}
+	}
+
+	if len(appended) > 0 && !hasMarker {
+		out = append(out, rewriteMarker)
+	}
+	return append(out, appended...)
+}
+
+// formatDirective renders a parameter as a configuration directive
+func formatDirective(p *parameter, c *Config) string {
+	value := p.get(c)
+	// The value of a multi-argument parameter is its arguments joined by
+	// spaces, which read back the same without quotes
+	if p.multiArg && value != "" {
+		return p.name + " " + value
+	}
+	return p.name + " " + quoteValue(value)
+}
+
+// quoteValue quotes a value if it would not read back as a single argument
+// otherwise, with the escapes understood by the directive parser
+func quoteValue(value string) string {
+	plain := value != ""
+	for i := 0; i < len(value) && plain; i++ {
+		b := value[i]
+		plain = b > ' ' && b < 0x7f && b != '"' && b != '\'' && b != '\\'
+	}
+	if plain {
+		return value
+	}
+
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch b := value[i]; b {
+		case '\\', '"':
+			out.WriteByte('\\')
+			out.WriteByte(b)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if b < ' ' || b >= 0x7f {
+				fmt.Fprintf(&out, `\x%02x`, b)
+			} else {
+				out.WriteByte(b)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
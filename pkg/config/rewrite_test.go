@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+func TestRewriteLines(t *testing.T) {
+	lines := []string{
+		"# Network",
+		"port 6379",
+		"",
+		"   # indented comment",
+		"timeout 0",
+		"save 900 1",
+		"save 300 10",
+		"include other.conf",
+		"timeout 5",
+	}
+
+	c := Default()
+	c.Timeout = 60e9
+	c.Save = []SavePoint{{60, 1}}
+	c.MaxMemory = 1024
+	c.RequirePass = "pass word"
+
+	expected := []string{
+		"# Network",
+		"port 6379",
+		"",
+		"   # indented comment",
+		"timeout 60",
+		"save 60 1",
+		"include other.conf",
+		rewriteMarker,
+		`requirepass "pass word"`,
+		"maxmemory 1024",
+	}
+
+	got := rewriteLines(lines, c)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	// A second rewrite keeps the marker and the generated lines in place
+	c.Port = 7000
+	again := rewriteLines(got, c)
+	expected[1] = "port 7000"
+	if !reflect.DeepEqual(again, expected) {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(again, "\n"))
+	}
+}
+
+func TestRewriteLines_EmptySave(t *testing.T) {
+	c := Default()
+	c.Save = nil
+
+	got := rewriteLines([]string{"save 900 1"}, c)
+	if !reflect.DeepEqual(got, []string{`save ""`}) {
+		t.Errorf("Expected save \"\", got %q", got)
+	}
+}
+
+func TestQuoteValue(t *testing.T) {
+	for _, value := range []string{"plain", "", "two words", `quo"te`, "it's", `back\slash`, "new\nline", "\x00\xff"} {
+		quoted := quoteValue(value)
+		args, err := resp.SplitArgs("requirepass " + quoted)
+		if err != nil || len(args) != 2 || args[1] != value {
+			t.Errorf("Expected %q to read back, got %q from %s (%v)", value, args, quoted, err)
+		}
+	}
+
+	if quoteValue("plain") != "plain" {
+		t.Errorf("Expected plain values unquoted, got %s", quoteValue("plain"))
+	}
+}
+
+func TestManager_Rewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis-lite.conf")
+	original := "# Settings\nport 7000\n\n# Memory\nmaxmemory 1mb\n"
+	if err := os.WriteFile(path, []byte(original), 0640); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	c, err := Load(path, nil)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	m := NewManager(c)
+	if err := m.Set(Setting{"maxmemory", "2mb"}, Setting{"appendonly", "yes"}); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	if err := m.Rewrite(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	expected := "# Settings\nport 7000\n\n# Memory\nmaxmemory 2097152\n" + rewriteMarker + "\nappendonly yes\n"
+	if string(content) != expected {
+		t.Errorf("Expected %q, got %q", expected, content)
+	}
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %o", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary file left, got %d entries", len(entries))
+	}
+
+	// The rewritten file loads back to the same configuration
+	reloaded, err := Load(path, nil)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded, m.Config()) {
+		t.Errorf("Expected %+v, got %+v", m.Config(), reloaded)
+	}
+}
@@ -0,0 +1,114 @@
+package events
+
+import "fmt"
+
+// Class is a bit set of keyspace event classes, as selected by the
+// notify-keyspace-events flags
+type Class int
+
+const (
+	// Keyspace selects notifications on __keyspace@<db>__:<key> channels (K)
+	Keyspace Class = 1 << iota
+	// Keyevent selects notifications on __keyevent@<db>__:<event> channels (E)
+	Keyevent
+	// Generic covers type-independent commands like DEL, EXPIRE and RENAME (g)
+	Generic
+	// String covers string commands (e.g. SET) ($)
+	String
+	// List covers list commands (l)
+	List
+	// Set covers set commands (s)
+	Set
+	// Hash covers hash commands (h)
+	Hash
+	// SortedSet covers sorted set commands (z)
+	SortedSet
+	// Expired covers keys removed because their TTL elapsed (x)
+	Expired
+	// Evicted covers keys removed to honour maxmemory (e)
+	Evicted
+	// Stream covers stream commands (t)
+	Stream
+	// KeyMiss covers reads of keys that do not exist (m)
+	KeyMiss
+)
+
+// All is the set of classes selected by the 'A' flag. Like in Redis it
+// excludes key-miss events, which must be enabled explicitly.
+const All = Generic | String | List | Set | Hash | SortedSet | Expired | Evicted | Stream
+
+// flagClasses maps each flag character to the classes it enables, in the
+// order used when formatting flags
+var flagClasses = []struct {
+	flag  byte
+	class Class
+}{
+	{'g', Generic},
+	{'$', String},
+	{'l', List},
+	{'s', Set},
+	{'h', Hash},
+	{'z', SortedSet},
+	{'x', Expired},
+	{'e', Evicted},
+	{'t', Stream},
+	{'K', Keyspace},
+	{'E', Keyevent},
+	{'m', KeyMiss},
+}
+
+// Notifier receives keyspace events emitted by commands and the storage layer
+type Notifier interface {
+	// Notify reports that event happened to key. class is the single
+	// class the event belongs to.
+	Notify(class Class, event, key string)
+}
+
+// ParseFlags parses a notify-keyspace-events flags string such as "KEA"
+func ParseFlags(flags string) (Class, error) {
+	var classes Class
+
+	for i := 0; i < len(flags); i++ {
+		if flags[i] == 'A' {
+			classes |= All
+			continue
+		}
+
+		found := false
+		for _, fc := range flagClasses {
+			if fc.flag == flags[i] {
+				classes |= fc.class
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("invalid event class character '%c'", flags[i])
+		}
+	}
+
+	return classes, nil
+}
+
+// FormatFlags formats classes as a flags string, using 'A' when every class
+// it covers is enabled
+func FormatFlags(classes Class) string {
+	var flags []byte
+
+	all := classes&All == All
+	if all {
+		flags = append(flags, 'A')
+	}
+
+	for _, fc := range flagClasses {
+		if all && All&fc.class != 0 {
+			continue
+		}
+		if classes&fc.class != 0 {
+			flags = append(flags, fc.flag)
+		}
+	}
+
+	return string(flags)
+}
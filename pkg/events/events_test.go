@@ -0,0 +1,64 @@
+package events
+
+import "testing"
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		flags   string
+		want    Class
+		wantErr bool
+	}{
+		{flags: "", want: 0},
+		{flags: "KEA", want: Keyspace | Keyevent | All},
+		{flags: "Ex", want: Keyevent | Expired},
+		{flags: "K$", want: Keyspace | String},
+		{flags: "Em", want: Keyevent | KeyMiss},
+		{flags: "glshzxet", want: All &^ String},
+		{flags: "KQ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flags, func(t *testing.T) {
+			got, err := ParseFlags(tt.flags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFlags(%q) error = %v, wantErr %v", tt.flags, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFlags(%q) = %b, want %b", tt.flags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFlags(t *testing.T) {
+	tests := []struct {
+		classes Class
+		want    string
+	}{
+		{classes: 0, want: ""},
+		{classes: Keyspace | Keyevent | All, want: "AKE"},
+		{classes: Keyevent | Expired, want: "xE"},
+		{classes: Keyspace | String | Generic, want: "g$K"},
+		{classes: All | KeyMiss | Keyevent, want: "AEm"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatFlags(tt.classes); got != tt.want {
+			t.Errorf("FormatFlags(%b) = %q, want %q", tt.classes, got, tt.want)
+		}
+	}
+}
+
+func TestFlagsRoundTrip(t *testing.T) {
+	for _, flags := range []string{"KEA", "Ex", "g$lK", "AEm"} {
+		classes, err := ParseFlags(flags)
+		if err != nil {
+			t.Fatalf("ParseFlags(%q) returned error: %v", flags, err)
+		}
+
+		reparsed, err := ParseFlags(FormatFlags(classes))
+		if err != nil || reparsed != classes {
+			t.Errorf("Round trip of %q gave %q", flags, FormatFlags(classes))
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package glob
+
+// Match reports whether str matches the Redis glob-style pattern.
+//
+// Supported syntax:
+//   - '*' matches any sequence of characters, including the empty sequence
+//   - '?' matches exactly one character
+//   - '[abc]', '[a-z]' and '[^a]' match character classes
+//   - '\' escapes the following character
+//
+// Unlike path.Match, '/' has no special meaning and malformed patterns
+// never return an error; they are matched as literally as possible.
+func Match(pattern, str string) bool {
+	p, s := 0, 0
+
+	for p < len(pattern) {
+		switch pattern[p] {
+		case '*':
+			// Collapse consecutive stars
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for i := s; i <= len(str); i++ {
+				if Match(pattern[p+1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if s >= len(str) {
+				return false
+			}
+			s++
+
+		case '[':
+			if s >= len(str) {
+				return false
+			}
+			matched, next := matchClass(pattern, p+1, str[s])
+			if !matched {
+				return false
+			}
+			p = next
+			s++
+			continue
+
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+			fallthrough
+
+		default:
+			if s >= len(str) || pattern[p] != str[s] {
+				return false
+			}
+			s++
+		}
+		p++
+	}
+
+	return s == len(str)
+}
+
+// matchClass matches c against the character class starting at pattern[p],
+// just after the opening '['. It returns whether c matched and the index
+// just past the closing ']'.
+func matchClass(pattern string, p int, c byte) (bool, int) {
+	negate := false
+	if p < len(pattern) && pattern[p] == '^' {
+		negate = true
+		p++
+	}
+
+	matched := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			if pattern[p] == c {
+				matched = true
+			}
+		case p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']':
+			start, end := pattern[p], pattern[p+2]
+			if start > end {
+				start, end = end, start
+			}
+			if c >= start && c <= end {
+				matched = true
+			}
+			p += 2
+		default:
+			if pattern[p] == c {
+				matched = true
+			}
+		}
+		p++
+	}
+
+	// Skip the closing bracket if present
+	if p < len(pattern) {
+		p++
+	}
+
+	return matched != negate, p
+}
@@ -0,0 +1,40 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		str     string
+		want    bool
+	}{
+		{pattern: "*", str: "", want: true},
+		{pattern: "*", str: "anything", want: true},
+		{pattern: "news.*", str: "news.sports", want: true},
+		{pattern: "news.*", str: "weather", want: false},
+		{pattern: "h?llo", str: "hello", want: true},
+		{pattern: "h?llo", str: "hllo", want: false},
+		{pattern: "h*llo", str: "heeeello", want: true},
+		{pattern: "h[ae]llo", str: "hallo", want: true},
+		{pattern: "h[ae]llo", str: "hillo", want: false},
+		{pattern: "h[^e]llo", str: "hallo", want: true},
+		{pattern: "h[^e]llo", str: "hello", want: false},
+		{pattern: "h[a-b]llo", str: "hbllo", want: true},
+		{pattern: "h[a-b]llo", str: "hcllo", want: false},
+		{pattern: `h\*llo`, str: "h*llo", want: true},
+		{pattern: `h\*llo`, str: "hello", want: false},
+		{pattern: "a/*", str: "a/b/c", want: true},
+		{pattern: "**b", str: "aab", want: true},
+		{pattern: "exact", str: "exact", want: true},
+		{pattern: "exact", str: "exactly", want: false},
+		{pattern: "__keyspace@0__:*", str: "__keyspace@0__:foo", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.str, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.str); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.str, got, tt.want)
+			}
+		})
+	}
+}
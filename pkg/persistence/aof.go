@@ -0,0 +1,613 @@
+package persistence
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// maxSpareBuffer is the largest write buffer an AOF keeps for reuse, so
+// that a burst of writes does not pin its memory
+const maxSpareBuffer = 1024 * 1024
+
+var (
+	// ErrRewriteInProgress is returned when a rewrite is requested while
+	// another is running
+	ErrRewriteInProgress = errors.New("background append only file rewriting already in progress")
+	// ErrAOFClosed is returned when a rewrite is requested after Close
+	ErrAOFClosed = errors.New("append only file closed")
+)
+
+// AOFOptions describes how an append-only file is written
+type AOFOptions struct {
+	// Fsync is when the file is synced: always, everysec or no
+	Fsync string
+	// RDBPreamble writes base files as RDB snapshots rather than as
+	// commands (aof-use-rdb-preamble)
+	RDBPreamble bool
+	// RDB is how base files are written in RDB form
+	RDB RDBOptions
+}
+
+// AOF appends every change made to a store to a multi-part append-only
+// file, in the form of the commands that replay it: SET, with any expiry
+// as an absolute PXAT so that replaying does not extend TTLs, DEL and
+// FLUSHALL.
+//
+// As in Redis 7, the file is made of a base file, holding a snapshot of
+// the keyspace, and incremental files holding the changes made since, all
+// listed by a manifest in one directory. A rewrite compacts them: changes
+// go to a new incremental file while a new base file is written, then the
+// manifest switches to both and the files they replace are deleted.
+//
+// Changes are buffered as the store makes them and written by Flush, which
+// connections call before sending their replies: with appendfsync always,
+// a change is on disk before it is acknowledged, and clients replying at
+// the same time share a single fsync. With everysec, Sync is called every
+// second; with no, the operating system decides when data reaches the disk.
+type AOF struct {
+	store    *storage.MemoryStore
+	dir      string
+	filename string
+
+	// mutex guards the buffer of changes not yet written, and the options
+	mutex   sync.Mutex
+	buffer  []byte
+	options AOFOptions
+
+	// writeMutex serializes writes to the files, and guards the fields
+	// below
+	writeMutex sync.Mutex
+	// file is the incremental file changes are appended to. It is nil
+	// while the first base file is written, changes being buffered
+	// meanwhile.
+	file     *os.File
+	manifest *AOFManifest
+	// baseSize and incrSize are the sizes of the base file and of the
+	// incremental files
+	baseSize int64
+	incrSize int64
+	// unsynced is set when data was written but not synced yet
+	unsynced bool
+	// spare is the previous buffer, reused for the next changes
+	spare []byte
+
+	// rewriteMutex guards the state of rewrites
+	rewriteMutex sync.Mutex
+	closed       bool
+	// rewriting is set while a rewrite runs, and done is closed when it ends
+	rewriting bool
+	done      chan struct{}
+	// rewriteBaseSize is the size of the files after the last rewrite, or
+	// when they were opened, from which automatic rewrites measure growth
+	rewriteBaseSize int64
+	// lastAttempt and lastErr describe the last rewrite
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// OpenAOF opens the multi-part append-only file named after filename in
+// dir, usually just loaded by LoadAOF, and logs the changes of store to
+// it from then on
+func OpenAOF(dir, filename string, store *storage.MemoryStore, options AOFOptions) (*AOF, error) {
+	manifest, err := LoadAOFManifest(dir, filename)
+	if err != nil {
+		return nil, err
+	}
+	aof := &AOF{store: store, dir: dir, filename: filename, options: options, manifest: manifest}
+
+	// Files left over by an interrupted rewrite are deleted
+	if len(manifest.History) > 0 {
+		history := manifest.History
+		manifest.History = nil
+		if err := saveAOFManifest(dir, filename, manifest); err != nil {
+			return nil, err
+		}
+		aof.remove(history)
+	}
+
+	for _, file := range manifest.Files() {
+		info, err := os.Stat(filepath.Join(dir, file.Name))
+		if err != nil {
+			return nil, err
+		}
+		if file.Type == AOFBase {
+			aof.baseSize = info.Size()
+		} else {
+			aof.incrSize += info.Size()
+		}
+	}
+	aof.rewriteBaseSize = aof.baseSize + aof.incrSize
+
+	// Changes are appended to the last incremental file, or to a new one
+	// if there is none, such as after an upgrade from a single file
+	if n := len(manifest.Incr); n > 0 {
+		aof.file, err = os.OpenFile(filepath.Join(dir, manifest.Incr[n-1].Name), os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+	} else if _, _, _, err := aof.rotate(true); err != nil {
+		return nil, err
+	}
+
+	store.SetChangeLog(aof)
+	return aof, nil
+}
+
+// CreateAOF creates a multi-part append-only file named after filename in
+// dir, holding the current keyspace of store, and logs the changes of
+// store to it from then on. The files of a previous append-only file in
+// dir are replaced. Clients are served while the keyspace is written;
+// their changes are appended after it.
+func CreateAOF(dir, filename string, store *storage.MemoryStore, options AOFOptions) (*AOF, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// The files of a previous append-only file keep their numbers, so that
+	// the new files do not overwrite them before the new manifest is saved
+	manifest, err := LoadAOFManifest(dir, filename)
+	if err != nil {
+		manifest = &AOFManifest{}
+	}
+	aof := &AOF{store: store, dir: dir, filename: filename, options: options, manifest: manifest}
+
+	// A key changed while the keyspace is written may be both written and
+	// logged, which replays to the same result
+	store.SetChangeLog(aof)
+	if err := aof.rewrite(false); err != nil {
+		store.SetChangeLog(nil)
+		aof.Close()
+		return nil, err
+	}
+	return aof, nil
+}
+
+// UpgradeAOF turns the single append-only file at path, as written before
+// multi-part files, into the base file of a multi-part append-only file
+// named after filename in dir, as Redis 7 does on startup
+func UpgradeAOF(path, dir, filename string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	manifest := &AOFManifest{}
+	base := manifest.nextBase(filename, false)
+	if err := os.Rename(path, filepath.Join(dir, base.Name)); err != nil {
+		return err
+	}
+	manifest.Base = &base
+	return saveAOFManifest(dir, filename, manifest)
+}
+
+// WriteAOFSnapshot writes the keyspace of source to w as SET commands
+func WriteAOFSnapshot(w io.Writer, source Source) error {
+	var buffer []byte
+	return source.Snapshot(func(records []storage.Record) error {
+		buffer = buffer[:0]
+		for _, record := range records {
+			buffer = appendSet(buffer, record.Key, record.Value, record.ExpiresAt)
+		}
+		_, err := w.Write(buffer)
+		return err
+	})
+}
+
+// SetOptions changes how the files are written. Changes to the form of
+// base files apply from the next rewrite.
+func (a *AOF) SetOptions(options AOFOptions) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.options = options
+}
+
+// Options returns how the files are written
+func (a *AOF) Options() AOFOptions {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.options
+}
+
+// Dir returns the directory holding the files
+func (a *AOF) Dir() string {
+	return a.dir
+}
+
+// Manifest returns a copy of the manifest listing the files
+func (a *AOF) Manifest() *AOFManifest {
+	a.writeMutex.Lock()
+	defer a.writeMutex.Unlock()
+
+	return a.manifest.clone()
+}
+
+// LogSet logs a SET, with the expiry as an absolute PXAT
+func (a *AOF) LogSet(key string, value []byte, expiresAt time.Time) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.buffer = appendSet(a.buffer, key, value, expiresAt)
+}
+
+// LogDelete logs a DEL
+func (a *AOF) LogDelete(key string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.buffer = appendCommand(a.buffer, "DEL", key)
+}
+
+// LogClear logs a FLUSHALL
+func (a *AOF) LogClear() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.buffer = appendCommand(a.buffer, "FLUSHALL")
+}
+
+// Flush writes the buffered changes to the file, and syncs it if the
+// policy is always. When it returns, the changes buffered before the call
+// are written, and synced under always, even if another goroutine wrote
+// them.
+func (a *AOF) Flush() error {
+	return a.write(a.Options().Fsync == config.FsyncAlways)
+}
+
+// Sync writes the buffered changes to the file and syncs it
+func (a *AOF) Sync() error {
+	return a.write(true)
+}
+
+// Size returns the size of the base and incremental files, including the
+// changes written but not yet synced
+func (a *AOF) Size() int64 {
+	a.writeMutex.Lock()
+	defer a.writeMutex.Unlock()
+
+	return a.baseSize + a.incrSize
+}
+
+// Close waits for a running rewrite, writes and syncs the buffered
+// changes, then closes the file. The caller removes it from the store's
+// change log first.
+func (a *AOF) Close() error {
+	a.rewriteMutex.Lock()
+	a.closed = true
+	a.rewriteMutex.Unlock()
+	a.Wait()
+
+	err := a.Sync()
+
+	a.writeMutex.Lock()
+	defer a.writeMutex.Unlock()
+
+	if a.file == nil {
+		return err
+	}
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	a.file = nil
+	return err
+}
+
+// write writes the buffered changes to the file, then syncs it if sync is
+// set
+func (a *AOF) write(sync bool) error {
+	a.writeMutex.Lock()
+	defer a.writeMutex.Unlock()
+
+	return a.writeLocked(sync)
+}
+
+// writeLocked writes the buffered changes to the file, then syncs it if
+// sync is set. Changes that could not be written are kept for the next
+// attempt. Must be called with writeMutex held.
+func (a *AOF) writeLocked(sync bool) error {
+	if a.file == nil {
+		return nil
+	}
+
+	// The store keeps logging to the spare buffer while this one is written
+	a.mutex.Lock()
+	data := a.buffer
+	a.buffer = a.spare
+	a.spare = nil
+	a.mutex.Unlock()
+
+	if len(data) > 0 {
+		n, err := a.file.Write(data)
+		a.incrSize += int64(n)
+		if n > 0 {
+			a.unsynced = true
+		}
+		if err != nil {
+			a.mutex.Lock()
+			a.buffer = append(data[n:], a.buffer...)
+			a.mutex.Unlock()
+			return err
+		}
+	}
+	if cap(data) <= maxSpareBuffer {
+		a.spare = data[:0]
+	}
+
+	if sync && a.unsynced {
+		if err := a.file.Sync(); err != nil {
+			return err
+		}
+		a.unsynced = false
+	}
+	return nil
+}
+
+// Rewrite rewrites the files and waits for the rewrite to end
+func (a *AOF) Rewrite() error {
+	if err := a.beginRewrite(); err != nil {
+		return err
+	}
+	return a.endRewrite(time.Now(), a.rewrite(true))
+}
+
+// BackgroundRewrite starts rewriting the files and returns without waiting
+// for it. Changes keep being logged during the rewrite.
+func (a *AOF) BackgroundRewrite() error {
+	if err := a.beginRewrite(); err != nil {
+		return err
+	}
+
+	go func() {
+		start := time.Now()
+		err := a.endRewrite(start, a.rewrite(true))
+		if err != nil {
+			log.Printf("Background AOF rewrite error: %v", err)
+		} else {
+			log.Printf("Background AOF rewrite terminated with success in %v", time.Since(start).Round(time.Millisecond))
+		}
+	}()
+	return nil
+}
+
+// CheckRewrite starts a background rewrite if the files have grown by
+// percentage since the last rewrite and are larger than minSize, as
+// auto-aof-rewrite-percentage and auto-aof-rewrite-min-size do. A failed
+// rewrite is retried after RetryDelay. It reports whether a rewrite was
+// started.
+func (a *AOF) CheckRewrite(percentage int, minSize int64, now time.Time) bool {
+	if percentage <= 0 {
+		return false
+	}
+	size := a.Size()
+
+	a.rewriteMutex.Lock()
+	base := max(a.rewriteBaseSize, 1)
+	growth := size*100/base - 100
+	due := size > minSize && growth >= int64(percentage) && !a.rewriting &&
+		(a.lastErr == nil || now.Sub(a.lastAttempt) >= RetryDelay)
+	a.rewriteMutex.Unlock()
+
+	if !due {
+		return false
+	}
+	log.Printf("Starting automatic rewriting of AOF on %d%% growth", growth)
+	return a.BackgroundRewrite() == nil
+}
+
+// Rewriting reports whether a rewrite is running
+func (a *AOF) Rewriting() bool {
+	a.rewriteMutex.Lock()
+	defer a.rewriteMutex.Unlock()
+
+	return a.rewriting
+}
+
+// LastError returns the error of the last rewrite, nil if it succeeded
+func (a *AOF) LastError() error {
+	a.rewriteMutex.Lock()
+	defer a.rewriteMutex.Unlock()
+
+	return a.lastErr
+}
+
+// Wait waits for the running rewrite, if any, to end
+func (a *AOF) Wait() {
+	a.rewriteMutex.Lock()
+	done := a.done
+	rewriting := a.rewriting
+	a.rewriteMutex.Unlock()
+
+	if rewriting {
+		<-done
+	}
+}
+
+// beginRewrite marks a rewrite as running
+func (a *AOF) beginRewrite() error {
+	a.rewriteMutex.Lock()
+	defer a.rewriteMutex.Unlock()
+
+	switch {
+	case a.closed:
+		return ErrAOFClosed
+	case a.rewriting:
+		return ErrRewriteInProgress
+	}
+	a.rewriting = true
+	a.done = make(chan struct{})
+	return nil
+}
+
+// endRewrite ends the rewrite started by beginRewrite and returns its error
+func (a *AOF) endRewrite(start time.Time, err error) error {
+	a.rewriteMutex.Lock()
+	defer a.rewriteMutex.Unlock()
+
+	a.rewriting = false
+	a.lastAttempt = start
+	a.lastErr = err
+	close(a.done)
+	return err
+}
+
+// rewrite writes a new base file from the keyspace and replaces the files
+// it covers. If persist is set, the switch to a new incremental file is
+// saved to the manifest before the base file is written, so that the
+// files stay loadable if the rewrite fails; otherwise the existing
+// manifest describes other data and is only replaced at the end.
+func (a *AOF) rewrite(persist bool) error {
+	// New changes go to a new incremental file, replayed after the new
+	// base file. The snapshot starts after the switch, so the base file
+	// covers every change written to the previous files.
+	incr, base, rotated, err := a.rotate(persist)
+	if err != nil {
+		return err
+	}
+
+	options := a.Options()
+	path := filepath.Join(a.dir, base.Name)
+	err = writeFileAtomic(path, func(w *bufio.Writer) error {
+		if options.RDBPreamble {
+			_, err := WriteSnapshot(w, a.store, options.RDB, time.Now())
+			return err
+		}
+		return WriteAOFSnapshot(w, a.store)
+	})
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	a.writeMutex.Lock()
+	previous := a.manifest
+	manifest := &AOFManifest{Base: &base}
+	for i, file := range previous.Incr {
+		if file.Seq >= incr.Seq {
+			manifest.Incr = previous.Incr[i:]
+			break
+		}
+	}
+	if err := saveAOFManifest(a.dir, a.filename, manifest); err != nil {
+		a.writeMutex.Unlock()
+		return err
+	}
+	a.manifest = manifest
+	a.baseSize = info.Size()
+	a.incrSize -= rotated
+	size := a.baseSize + a.incrSize
+	a.writeMutex.Unlock()
+
+	// The files replaced are no longer needed
+	var replaced []AOFFile
+	for _, file := range append(previous.Files(), previous.History...) {
+		if file.Name != base.Name && (file.Type != AOFIncr || file.Seq < incr.Seq) {
+			replaced = append(replaced, file)
+		}
+	}
+	a.remove(replaced)
+
+	a.rewriteMutex.Lock()
+	a.rewriteBaseSize = size
+	a.rewriteMutex.Unlock()
+	return nil
+}
+
+// rotate writes the buffered changes to the current incremental file and
+// opens a new one that changes are appended to from then on. It returns
+// the new incremental file, the base file a rewrite should write next,
+// and the size of the incremental files before the new one. If persist is
+// set, the new incremental file is added to the manifest on disk.
+func (a *AOF) rotate(persist bool) (AOFFile, AOFFile, int64, error) {
+	a.writeMutex.Lock()
+	defer a.writeMutex.Unlock()
+
+	if err := a.writeLocked(true); err != nil {
+		return AOFFile{}, AOFFile{}, 0, err
+	}
+
+	incr := a.manifest.nextIncr(a.filename)
+	path := filepath.Join(a.dir, incr.Name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return AOFFile{}, AOFFile{}, 0, err
+	}
+
+	manifest := a.manifest.clone()
+	manifest.Incr = append(manifest.Incr, incr)
+	if persist {
+		if err := saveAOFManifest(a.dir, a.filename, manifest); err != nil {
+			file.Close()
+			os.Remove(path)
+			return AOFFile{}, AOFFile{}, 0, err
+		}
+	}
+
+	if a.file != nil {
+		a.file.Close()
+	}
+	a.file = file
+	a.unsynced = false
+	a.manifest = manifest
+	base := manifest.nextBase(a.filename, a.Options().RDBPreamble)
+	return incr, base, a.incrSize, nil
+}
+
+// remove deletes files from the directory, logging failures
+func (a *AOF) remove(files []AOFFile) {
+	for _, file := range files {
+		err := os.Remove(filepath.Join(a.dir, file.Name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error removing %s: %v", file.Name, err)
+		}
+	}
+}
+
+// appendSet appends a SET command for a key to buf, with an absolute PXAT
+// expiry unless expiresAt is zero
+func appendSet(buf []byte, key string, value []byte, expiresAt time.Time) []byte {
+	if expiresAt.IsZero() {
+		buf = append(buf, "*3\r\n$3\r\nSET\r\n"...)
+	} else {
+		buf = append(buf, "*5\r\n$3\r\nSET\r\n"...)
+	}
+	buf = appendBulk(buf, key)
+	buf = appendBulk(buf, value)
+	if !expiresAt.IsZero() {
+		buf = append(buf, "$4\r\nPXAT\r\n"...)
+		buf = appendBulk(buf, strconv.FormatInt(expiresAt.UnixMilli(), 10))
+	}
+	return buf
+}
+
+// appendCommand appends a command in RESP form to buf
+func appendCommand(buf []byte, args ...string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, "\r\n"...)
+	for _, arg := range args {
+		buf = appendBulk(buf, arg)
+	}
+	return buf
+}
+
+// appendBulk appends a bulk string to buf
+func appendBulk[T string | []byte](buf []byte, value T) []byte {
+	buf = append(buf, '$')
+	buf = strconv.AppendInt(buf, int64(len(value)), 10)
+	buf = append(buf, "\r\n"...)
+	buf = append(buf, value...)
+	return append(buf, "\r\n"...)
+}
@@ -0,0 +1,260 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// AOFFileType is the type of a file listed in an AOF manifest
+type AOFFileType byte
+
+// AOF file types, as written in a manifest
+const (
+	// AOFBase is a snapshot of the keyspace, in RDB or AOF form
+	AOFBase AOFFileType = 'b'
+	// AOFHistory is a file replaced by a rewrite, waiting to be deleted
+	AOFHistory AOFFileType = 'h'
+	// AOFIncr holds the changes made after the base file
+	AOFIncr AOFFileType = 'i'
+)
+
+// AOFFile is a file of a multi-part append-only file
+type AOFFile struct {
+	Name string
+	Seq  int64
+	Type AOFFileType
+}
+
+// AOFManifest lists the files making up a multi-part append-only file, as
+// Redis 7 does: a base file holding a snapshot of the keyspace, then the
+// incremental files holding the changes made since, in order
+type AOFManifest struct {
+	Base *AOFFile
+	Incr []AOFFile
+	// History lists the files replaced by a rewrite that may not be
+	// deleted yet. They are not loaded.
+	History []AOFFile
+}
+
+// ErrInvalidManifest is returned when reading a malformed AOF manifest
+var ErrInvalidManifest = errors.New("invalid AOF manifest file format")
+
+// ManifestName returns the name of the manifest of the append-only files
+// named after filename (appendfilename)
+func ManifestName(filename string) string {
+	return filename + ".manifest"
+}
+
+// ReadAOFManifest reads an AOF manifest: one line per file, such as
+// "file appendonly.aof.1.base.rdb seq 1 type b", and comment lines
+// starting with #
+func ReadAOFManifest(r io.Reader) (*AOFManifest, error) {
+	manifest := &AOFManifest{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+
+		file, err := parseManifestLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidManifest, line, err)
+		}
+		switch file.Type {
+		case AOFBase:
+			if manifest.Base != nil {
+				return nil, fmt.Errorf("%w: line %d: more than one base file", ErrInvalidManifest, line)
+			}
+			manifest.Base = &file
+		case AOFIncr:
+			if n := len(manifest.Incr); n > 0 && file.Seq <= manifest.Incr[n-1].Seq {
+				return nil, fmt.Errorf("%w: line %d: incremental files out of order", ErrInvalidManifest, line)
+			}
+			manifest.Incr = append(manifest.Incr, file)
+		case AOFHistory:
+			manifest.History = append(manifest.History, file)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if manifest.Base == nil && len(manifest.Incr) == 0 {
+		return nil, fmt.Errorf("%w: no base or incremental file", ErrInvalidManifest)
+	}
+	return manifest, nil
+}
+
+// parseManifestLine parses a manifest line, made of key-value pairs in any
+// order, where the file name may be quoted
+func parseManifestLine(line string) (AOFFile, error) {
+	args, err := resp.SplitArgs(line)
+	if err != nil {
+		return AOFFile{}, err
+	}
+	if len(args)%2 != 0 {
+		return AOFFile{}, errors.New("expected key-value pairs")
+	}
+
+	var file AOFFile
+	var hasSeq bool
+	for i := 0; i < len(args); i += 2 {
+		key, value := args[i], args[i+1]
+		switch key {
+		case "file":
+			if value == "" || strings.ContainsAny(value, `/\`) {
+				return AOFFile{}, fmt.Errorf("invalid file name %q", value)
+			}
+			file.Name = value
+		case "seq":
+			seq, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seq < 0 {
+				return AOFFile{}, fmt.Errorf("invalid sequence %q", value)
+			}
+			file.Seq = seq
+			hasSeq = true
+		case "type":
+			if len(value) != 1 || !strings.Contains("bhi", value) {
+				return AOFFile{}, fmt.Errorf("invalid file type %q", value)
+			}
+			file.Type = AOFFileType(value[0])
+		}
+		// Unknown keys are ignored, so that newer manifests can be read
+	}
+	if file.Name == "" || !hasSeq || file.Type == 0 {
+		return AOFFile{}, errors.New("missing file, seq or type")
+	}
+	return file, nil
+}
+
+// Bytes returns the manifest in the form ReadAOFManifest reads: the base
+// file, the history files and the incremental files
+func (m *AOFManifest) Bytes() []byte {
+	var buf bytes.Buffer
+	write := func(file AOFFile) {
+		fmt.Fprintf(&buf, "file %s seq %d type %c\n", quoteManifestName(file.Name), file.Seq, file.Type)
+	}
+	if m.Base != nil {
+		write(*m.Base)
+	}
+	for _, file := range m.History {
+		write(file)
+	}
+	for _, file := range m.Incr {
+		write(file)
+	}
+	return buf.Bytes()
+}
+
+// quoteManifestName quotes a file name containing spaces, quotes or
+// unprintable characters, the way SplitArgs unquotes it
+func quoteManifestName(name string) string {
+	plain := true
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c <= ' ' || c >= 0x7f || c == '"' || c == '\'' || c == '\\' {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return name
+	}
+
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == '\t':
+			buf.WriteString(`\t`)
+		case c < ' ' || c >= 0x7f:
+			fmt.Fprintf(&buf, `\x%02x`, c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// Files returns the files to load, in order: the base file, if any, then
+// the incremental files
+func (m *AOFManifest) Files() []AOFFile {
+	var files []AOFFile
+	if m.Base != nil {
+		files = append(files, *m.Base)
+	}
+	return append(files, m.Incr...)
+}
+
+// nextBase returns the next base file, in RDB form or in AOF form
+func (m *AOFManifest) nextBase(filename string, rdb bool) AOFFile {
+	seq := int64(1)
+	if m.Base != nil {
+		seq = m.Base.Seq + 1
+	}
+	extension := "aof"
+	if rdb {
+		extension = "rdb"
+	}
+	return AOFFile{Name: fmt.Sprintf("%s.%d.base.%s", filename, seq, extension), Seq: seq, Type: AOFBase}
+}
+
+// nextIncr returns the next incremental file
+func (m *AOFManifest) nextIncr(filename string) AOFFile {
+	seq := int64(1)
+	if n := len(m.Incr); n > 0 {
+		seq = m.Incr[n-1].Seq + 1
+	}
+	return AOFFile{Name: fmt.Sprintf("%s.%d.incr.aof", filename, seq), Seq: seq, Type: AOFIncr}
+}
+
+// clone returns a copy of the manifest
+func (m *AOFManifest) clone() *AOFManifest {
+	clone := &AOFManifest{
+		Incr:    append([]AOFFile(nil), m.Incr...),
+		History: append([]AOFFile(nil), m.History...),
+	}
+	if m.Base != nil {
+		base := *m.Base
+		clone.Base = &base
+	}
+	return clone
+}
+
+// LoadAOFManifest reads the manifest of the append-only files named after
+// filename in dir
+func LoadAOFManifest(dir, filename string) (*AOFManifest, error) {
+	file, err := os.Open(filepath.Join(dir, ManifestName(filename)))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadAOFManifest(file)
+}
+
+// saveAOFManifest atomically replaces the manifest in dir
+func saveAOFManifest(dir, filename string, manifest *AOFManifest) error {
+	return writeFileAtomic(filepath.Join(dir, ManifestName(filename)), func(w *bufio.Writer) error {
+		_, err := w.Write(manifest.Bytes())
+		return err
+	})
+}
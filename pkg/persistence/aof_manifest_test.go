@@ -0,0 +1,84 @@
+package persistence
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReadAOFManifest(t *testing.T) {
+	data := "# written by Redis 7\n" +
+		"file appendonly.aof.2.base.rdb seq 2 type b\n" +
+		"file appendonly.aof.1.base.rdb seq 1 type h\n" +
+		"seq 3 type i file appendonly.aof.3.incr.aof\n" +
+		"file \"append only.aof.4.incr.aof\" seq 4 type i extra ignored\n"
+
+	manifest, err := ReadAOFManifest(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadAOFManifest() returned error: %v", err)
+	}
+	if manifest.Base == nil || manifest.Base.Name != "appendonly.aof.2.base.rdb" || manifest.Base.Seq != 2 {
+		t.Errorf("Expected base file appendonly.aof.2.base.rdb, got %+v", manifest.Base)
+	}
+	if len(manifest.History) != 1 || manifest.History[0].Seq != 1 {
+		t.Errorf("Expected one history file, got %+v", manifest.History)
+	}
+	if len(manifest.Incr) != 2 || manifest.Incr[1].Name != "append only.aof.4.incr.aof" {
+		t.Errorf("Expected two incremental files, got %+v", manifest.Incr)
+	}
+
+	// Written back, the manifest reads the same
+	again, err := ReadAOFManifest(strings.NewReader(string(manifest.Bytes())))
+	if err != nil {
+		t.Fatalf("ReadAOFManifest() returned error on %q: %v", manifest.Bytes(), err)
+	}
+	if string(again.Bytes()) != string(manifest.Bytes()) {
+		t.Errorf("Expected %q, got %q", manifest.Bytes(), again.Bytes())
+	}
+}
+
+func TestReadAOFManifest_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"only history", "file a seq 1 type h\n"},
+		{"missing type", "file a seq 1\n"},
+		{"odd arguments", "file a seq 1 type\n"},
+		{"bad seq", "file a seq one type b\n"},
+		{"bad type", "file a seq 1 type x\n"},
+		{"path", "file ../a seq 1 type b\n"},
+		{"two bases", "file a seq 1 type b\nfile b seq 2 type b\n"},
+		{"incr out of order", "file a seq 2 type i\nfile b seq 1 type i\n"},
+		{"unbalanced quotes", "file \"a seq 1 type b\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadAOFManifest(strings.NewReader(tt.data))
+			if !errors.Is(err, ErrInvalidManifest) {
+				t.Errorf("Expected ErrInvalidManifest, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAOFManifest_NextFiles(t *testing.T) {
+	manifest := &AOFManifest{}
+	if base := manifest.nextBase("appendonly.aof", true); base.Name != "appendonly.aof.1.base.rdb" || base.Seq != 1 {
+		t.Errorf("Expected appendonly.aof.1.base.rdb, got %+v", base)
+	}
+	if incr := manifest.nextIncr("appendonly.aof"); incr.Name != "appendonly.aof.1.incr.aof" || incr.Type != AOFIncr {
+		t.Errorf("Expected appendonly.aof.1.incr.aof, got %+v", incr)
+	}
+
+	manifest.Base = &AOFFile{Name: "appendonly.aof.3.base.rdb", Seq: 3, Type: AOFBase}
+	manifest.Incr = []AOFFile{{Name: "appendonly.aof.7.incr.aof", Seq: 7, Type: AOFIncr}}
+	if base := manifest.nextBase("appendonly.aof", false); base.Name != "appendonly.aof.4.base.aof" {
+		t.Errorf("Expected appendonly.aof.4.base.aof, got %+v", base)
+	}
+	if incr := manifest.nextIncr("appendonly.aof"); incr.Name != "appendonly.aof.8.incr.aof" {
+		t.Errorf("Expected appendonly.aof.8.incr.aof, got %+v", incr)
+	}
+}
@@ -0,0 +1,312 @@
+package persistence
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// aofLimits are the parser limits for reading append-only files, which
+// hold the commands the server accepted whatever proto-max-bulk-len was.
+// The lengths are read incrementally, so a corrupt one ends the file early
+// rather than allocating memory it never fills.
+var aofLimits = resp.Limits{
+	MaxBulkLength:      math.MaxInt,
+	MaxMultiBulkLength: math.MaxInt,
+	MaxDepth:           1,
+	MaxInlineSize:      resp.DefaultMaxInlineSize,
+	Incremental:        true,
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// ReadAOF reads the commands of an append-only file from r and calls apply
+// with the arguments of each. The commands of a MULTI/EXEC transaction are
+// applied once its EXEC is read, and MULTI and EXEC themselves are not
+// passed to apply.
+//
+// It returns the offset just past the last command applied. Invalid data,
+// or an error from apply, is reported as a *CorruptError. A file that ends
+// in the middle of a command or of a transaction is reported as a
+// *CorruptError wrapping io.ErrUnexpectedEOF: truncating the file at the
+// returned offset makes it valid again.
+func ReadAOF(r io.Reader, apply func(args [][]byte) error) (int64, error) {
+	counter := &countingReader{reader: r}
+	parser := resp.NewParser(counter)
+	parser.SetLimits(aofLimits)
+	offset := func() int64 {
+		return counter.count - int64(parser.Buffered())
+	}
+
+	var valid int64
+	var transaction [][][]byte
+	multiOffset := int64(-1)
+	for {
+		start := offset()
+		message, err := parser.Parse()
+		if err != nil {
+			if offset() == start && errors.Is(err, io.EOF) {
+				break
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return valid, &CorruptError{Offset: start, Err: io.ErrUnexpectedEOF}
+			}
+			return valid, &CorruptError{Offset: start, Err: err}
+		}
+
+		args, err := aofCommand(message)
+		if err != nil {
+			return valid, &CorruptError{Offset: start, Err: err}
+		}
+
+		switch name := strings.ToUpper(string(args[0])); {
+		case name == "MULTI":
+			if multiOffset >= 0 {
+				return valid, &CorruptError{Offset: start, Err: errors.New("MULTI calls can not be nested")}
+			}
+			multiOffset = start
+		case name == "EXEC":
+			if multiOffset < 0 {
+				return valid, &CorruptError{Offset: start, Err: errors.New("EXEC without MULTI")}
+			}
+			for _, command := range transaction {
+				if err := apply(command); err != nil {
+					return valid, &CorruptError{Offset: multiOffset, Err: err}
+				}
+			}
+			transaction = transaction[:0]
+			multiOffset = -1
+			valid = offset()
+		case multiOffset >= 0:
+			transaction = append(transaction, args)
+		default:
+			if err := apply(args); err != nil {
+				return valid, &CorruptError{Offset: start, Err: err}
+			}
+			valid = offset()
+		}
+	}
+
+	if multiOffset >= 0 {
+		return valid, &CorruptError{Offset: multiOffset, Err: fmt.Errorf("MULTI without EXEC: %w", io.ErrUnexpectedEOF)}
+	}
+	return valid, nil
+}
+
+// aofCommand returns the arguments of a command read from an append-only
+// file, which must be a non-empty array of bulk strings
+func aofCommand(message *resp.Message) ([][]byte, error) {
+	elements, ok := message.Value.([]*resp.Message)
+	if message.Type != resp.Array || !ok || len(elements) == 0 {
+		return nil, errors.New("expected a command")
+	}
+
+	args := make([][]byte, len(elements))
+	for i, element := range elements {
+		arg, ok := element.Value.([]byte)
+		if element.Type != resp.BulkString || !ok {
+			return nil, errors.New("expected a bulk string argument")
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// AOFStats describes an append-only file loaded by LoadAOF
+type AOFStats struct {
+	// Files is the number of files loaded
+	Files    int
+	Commands int
+	// RDB counts the keys of the base file, if in RDB form
+	RDB LoadStats
+	// Truncated is set if an incomplete command at the end of the last
+	// file was removed
+	Truncated bool
+	// Size is the size of the files once loaded
+	Size int64
+}
+
+// LoadAOF replays the multi-part append-only file named after filename in
+// dir into store: the base file, in RDB or AOF form, then the incremental
+// files. If the last file ends with an incomplete command and truncate is
+// set, as with aof-load-truncated, it is truncated to its last complete
+// command; otherwise, and for the other files, the error is returned.
+func LoadAOF(dir, filename string, store storage.Store, truncate bool) (AOFStats, error) {
+	stats := AOFStats{RDB: LoadStats{Unsupported: make(map[Type]int)}}
+	manifest, err := LoadAOFManifest(dir, filename)
+	if err != nil {
+		return stats, err
+	}
+
+	now := time.Now()
+	files := manifest.Files()
+	for i, file := range files {
+		last := i == len(files)-1
+		if err := loadAOFFile(filepath.Join(dir, file.Name), store, truncate && last, now, &stats); err != nil {
+			return stats, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		stats.Files++
+	}
+	return stats, nil
+}
+
+// loadAOFFile replays an append-only file into store, adding to stats
+func loadAOFFile(path string, store storage.Store, truncate bool, now time.Time, stats *AOFStats) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, valid, err := readAOFFile(file, loadEntry(store, now, &stats.RDB), func(args [][]byte) error {
+		stats.Commands++
+		return applyAOFCommand(store, args, now)
+	})
+	stats.Size += valid
+	if err == nil || !truncate || !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+
+	if err := os.Truncate(path, valid); err != nil {
+		return fmt.Errorf("truncating the append-only file: %w", err)
+	}
+	stats.Truncated = true
+	return nil
+}
+
+// readAOFFile reads an append-only file from r, which may start with an
+// RDB snapshot, as base files and the files of Redis's aof-use-rdb-preamble
+// do. The keys of the snapshot are passed to visit, then the commands
+// following it to apply, as with ReadAOF.
+//
+// It returns the offset the commands start at, -1 if the snapshot could
+// not be read, and the offset just past the last command applied. Offsets
+// in errors are from the start of the file.
+func readAOFFile(r io.Reader, visit func(*Entry) error, apply func(args [][]byte) error) (int64, int64, error) {
+	// The RDB reader reads through the same buffer, which holds the
+	// commands following the snapshot once it returns
+	counter := &countingReader{reader: r}
+	reader := bufio.NewReaderSize(counter, 64*1024)
+	var start int64
+	if header, _ := reader.Peek(len(rdbMagic)); string(header) == rdbMagic {
+		if _, err := ReadRDB(reader, visit); err != nil {
+			return -1, 0, err
+		}
+		start = counter.count - int64(reader.Buffered())
+	}
+
+	valid, err := ReadAOF(reader, apply)
+	var corrupt *CorruptError
+	if errors.As(err, &corrupt) {
+		corrupt.Offset += start
+	}
+	return start, valid + start, err
+}
+
+// applyAOFCommand applies a command read from an append-only file to
+// store. Only the commands the AOF writes are accepted, along with the
+// other forms of SET expiry and SELECT 0, which Redis also writes.
+func applyAOFCommand(store storage.Store, args [][]byte, now time.Time) error {
+	if err := checkAOFCommand(args); err != nil {
+		return err
+	}
+
+	switch strings.ToUpper(string(args[0])) {
+	case "SET":
+		expiresAt, _ := aofExpiry(args[3:])
+		key := string(args[1])
+		// A key that has expired since it was set is not loaded
+		if !expiresAt.IsZero() && !now.Before(expiresAt) {
+			store.Delete(key)
+			return nil
+		}
+		return store.SetWithExpiry(key, args[2], expiresAt)
+	case "DEL":
+		for _, key := range args[1:] {
+			store.Delete(string(key))
+		}
+		return nil
+	case "FLUSHALL", "FLUSHDB":
+		store.Clear()
+	}
+	return nil
+}
+
+// checkAOFCommand checks that a command read from an append-only file can
+// be applied by applyAOFCommand
+func checkAOFCommand(args [][]byte) error {
+	switch strings.ToUpper(string(args[0])) {
+	case "SET":
+		if len(args) < 3 {
+			return errors.New("wrong number of arguments for 'set' command")
+		}
+		_, err := aofExpiry(args[3:])
+		return err
+	case "DEL", "FLUSHALL", "FLUSHDB":
+		return nil
+	case "SELECT":
+		if len(args) != 2 || string(args[1]) != "0" {
+			return errors.New("only database 0 is supported")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown command '%s'", args[0])
+	}
+}
+
+// aofExpiry parses the expiry options of a SET command, returning the zero
+// time if there are none
+func aofExpiry(options [][]byte) (time.Time, error) {
+	if len(options) == 0 {
+		return time.Time{}, nil
+	}
+	if len(options) != 2 {
+		return time.Time{}, errors.New("syntax error in 'set' command")
+	}
+
+	value, err := strconv.ParseInt(string(options[1]), 10, 64)
+	if err != nil || value <= 0 {
+		return time.Time{}, errors.New("invalid expire time in 'set' command")
+	}
+	unit := time.Second
+	switch strings.ToUpper(string(options[0])) {
+	case "PXAT":
+		return time.UnixMilli(value), nil
+	case "EXAT":
+		if value > math.MaxInt64/1000 {
+			return time.Time{}, errors.New("invalid expire time in 'set' command")
+		}
+		return time.Unix(value, 0), nil
+	case "PX":
+		unit = time.Millisecond
+	case "EX":
+	default:
+		return time.Time{}, errors.New("syntax error in 'set' command")
+	}
+
+	// A duration that overflows would wrap to a time in the past
+	if value > math.MaxInt64/int64(unit) {
+		return time.Time{}, errors.New("invalid expire time in 'set' command")
+	}
+	return time.Now().Add(time.Duration(value) * unit), nil
+}
@@ -0,0 +1,227 @@
+package persistence
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// readCommands reads an append-only file and returns its commands, with
+// the arguments of each joined by spaces
+func readCommands(data []byte) ([]string, int64, error) {
+	var commands []string
+	offset, err := ReadAOF(bytes.NewReader(data), func(args [][]byte) error {
+		commands = append(commands, string(bytes.Join(args, []byte(" "))))
+		return nil
+	})
+	return commands, offset, err
+}
+
+// writeAOF writes a multi-part append-only file named appendonly.aof to a
+// temporary directory, with base as its base file and incr as its
+// incremental files, and returns the directory
+func writeAOF(t *testing.T, base []byte, incr ...[]byte) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	manifest := &AOFManifest{Base: &AOFFile{Name: "appendonly.aof.1.base.aof", Seq: 1, Type: AOFBase}}
+	os.WriteFile(filepath.Join(dir, manifest.Base.Name), base, 0644)
+	for i, data := range incr {
+		file := AOFFile{Name: fmt.Sprintf("appendonly.aof.%d.incr.aof", i+1), Seq: int64(i + 1), Type: AOFIncr}
+		os.WriteFile(filepath.Join(dir, file.Name), data, 0644)
+		manifest.Incr = append(manifest.Incr, file)
+	}
+	if err := saveAOFManifest(dir, "appendonly.aof", manifest); err != nil {
+		t.Fatalf("saveAOFManifest() returned error: %v", err)
+	}
+	return dir
+}
+
+func TestReadAOF(t *testing.T) {
+	set := "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"
+	multi := "*1\r\n$5\r\nMULTI\r\n"
+	exec := "*1\r\n$4\r\nEXEC\r\n"
+	del := "*2\r\n$3\r\nDEL\r\n$1\r\nk\r\n"
+
+	tests := []struct {
+		name      string
+		data      string
+		commands  int
+		offset    int
+		truncated bool
+		corrupt   int
+	}{
+		{"empty", "", 0, 0, false, -1},
+		{"commands", set + del, 2, len(set + del), false, -1},
+		{"transaction", set + multi + del + set + exec, 3, len(set + multi + del + set + exec), false, -1},
+		{"truncated bulk", set + del[:len(del)-3], 1, len(set), true, len(set)},
+		{"truncated length", set + "*2\r\n$3", 1, len(set), true, len(set)},
+		{"huge bulk length", set + "*1\r\n$999999999999999999\r\n" + set, 1, len(set), true, len(set)},
+		{"bulk length past the end", set + "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$80000000000\r\nv\r\n", 1, len(set), true, len(set)},
+		{"huge multibulk length", set + "*999999999999999999\r\n$3\r\nSET\r\n", 1, len(set), true, len(set)},
+		{"invalid bulk length", set + "*1\r\n$9999999999999999999\r\n", 1, len(set), false, len(set)},
+		{"unterminated transaction", set + multi + del, 1, len(set), true, len(set)},
+		{"garbage", set + "garbage\r\n", 1, len(set), false, len(set)},
+		{"not a command", set + ":1\r\n", 1, len(set), false, len(set)},
+		{"exec without multi", exec, 0, 0, false, 0},
+		{"nested multi", multi + multi, 0, 0, false, len(multi)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, offset, err := readCommands([]byte(tt.data))
+			if len(commands) != tt.commands {
+				t.Errorf("Expected %d commands, got %q", tt.commands, commands)
+			}
+			if offset != int64(tt.offset) {
+				t.Errorf("Expected offset %d, got %d", tt.offset, offset)
+			}
+
+			var corrupt *CorruptError
+			switch {
+			case tt.corrupt < 0 && err != nil:
+				t.Errorf("Expected no error, got %v", err)
+			case tt.corrupt >= 0 && !errors.As(err, &corrupt):
+				t.Errorf("Expected a CorruptError, got %v", err)
+			case tt.corrupt >= 0 && corrupt.Offset != int64(tt.corrupt):
+				t.Errorf("Expected the error at offset %d, got %d", tt.corrupt, corrupt.Offset)
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) != tt.truncated {
+				t.Errorf("Expected truncated %v, got %v", tt.truncated, err)
+			}
+		})
+	}
+}
+
+func TestLoadAOF_Commands(t *testing.T) {
+	future := strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10)
+
+	var base, incr []byte
+	base = appendCommand(base, "SET", "a", "1")
+	base = appendCommand(base, "SET", "b", "2", "PXAT", future)
+	base = appendCommand(base, "SET", "expired", "3", "PXAT", past)
+	incr = appendCommand(incr, "SET", "c", "4", "EX", "100")
+	incr = appendCommand(incr, "SELECT", "0")
+	incr = appendCommand(incr, "SET", "d", "5")
+	incr = appendCommand(incr, "DEL", "d", "missing")
+
+	store := storage.NewMemoryStore()
+	stats, err := LoadAOF(writeAOF(t, base, incr), "appendonly.aof", store, false)
+	if err != nil {
+		t.Fatalf("LoadAOF() returned error: %v", err)
+	}
+	if stats.Files != 2 || stats.Commands != 7 || stats.Size != int64(len(base)+len(incr)) {
+		t.Errorf("Expected 2 files, 7 commands and size %d, got %+v", len(base)+len(incr), stats)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if !store.Exists(key) {
+			t.Errorf("Expected key %s to be loaded", key)
+		}
+	}
+	for _, key := range []string{"expired", "d"} {
+		if store.Exists(key) {
+			t.Errorf("Expected key %s not to be loaded", key)
+		}
+	}
+}
+
+func TestLoadAOF_RDBBase(t *testing.T) {
+	base := encode(t, newStore("a", "1", "b", "2"), RDBOptions{Checksum: true})
+	// A file written with Redis's aof-use-rdb-preamble continues with
+	// commands after the snapshot
+	base = appendCommand(base, "DEL", "b")
+
+	store := storage.NewMemoryStore()
+	stats, err := LoadAOF(writeAOF(t, base, appendCommand(nil, "SET", "c", "3")), "appendonly.aof", store, false)
+	if err != nil {
+		t.Fatalf("LoadAOF() returned error: %v", err)
+	}
+	if stats.RDB.Loaded != 2 || stats.Commands != 2 {
+		t.Errorf("Expected 2 keys from the snapshot and 2 commands, got %+v", stats)
+	}
+	if store.Size() != 2 || !store.Exists("a") || !store.Exists("c") {
+		t.Errorf("Expected keys a and c, got %d keys", store.Size())
+	}
+}
+
+func TestLoadAOF_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown command", []string{"INCR", "a"}},
+		{"other database", []string{"SELECT", "1"}},
+		{"missing value", []string{"SET", "a"}},
+		{"bad expiry", []string{"SET", "a", "1", "PXAT", "soon"}},
+		{"bad option", []string{"SET", "a", "1", "NX"}},
+		{"EX overflow", []string{"SET", "a", "1", "EX", "9223372036854775807"}},
+		{"PX overflow", []string{"SET", "a", "1", "PX", "9223372036854775807"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeAOF(t, nil, appendCommand(nil, tt.args...))
+			if _, err := LoadAOF(dir, "appendonly.aof", storage.NewMemoryStore(), true); err == nil {
+				t.Errorf("Expected an error loading %q", tt.args)
+			}
+		})
+	}
+}
+
+func TestLoadAOF_MissingFile(t *testing.T) {
+	dir := writeAOF(t, nil, nil)
+	os.Remove(filepath.Join(dir, "appendonly.aof.1.incr.aof"))
+
+	if _, err := LoadAOF(dir, "appendonly.aof", storage.NewMemoryStore(), true); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file error, got %v", err)
+	}
+}
+
+func TestLoadAOF_Truncated(t *testing.T) {
+	complete := appendCommand(nil, "SET", "a", "1")
+	data := append(bytes.Clone(complete), "*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$1"...)
+
+	for _, truncate := range []bool{false, true} {
+		dir := writeAOF(t, nil, data)
+		store := storage.NewMemoryStore()
+		stats, err := LoadAOF(dir, "appendonly.aof", store, truncate)
+		if !truncate {
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("Expected a truncation error, got %v", err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("LoadAOF() returned error: %v", err)
+		}
+		if !stats.Truncated || stats.Commands != 1 || store.Size() != 1 {
+			t.Errorf("Expected the first command to be loaded and the file truncated, got %+v", stats)
+		}
+		if written, _ := os.ReadFile(filepath.Join(dir, "appendonly.aof.1.incr.aof")); !bytes.Equal(written, complete) {
+			t.Errorf("Expected the file to be truncated to %q, got %q", complete, written)
+		}
+	}
+
+	// A length past the end of the file is reported rather than allocated
+	huge := append(bytes.Clone(complete), "*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$80000000000\r\n1\r\n"...)
+	var corrupt *CorruptError
+	if _, err := LoadAOF(writeAOF(t, nil, huge), "appendonly.aof", storage.NewMemoryStore(), false); !errors.As(err, &corrupt) || corrupt.Offset != int64(len(complete)) {
+		t.Errorf("Expected a CorruptError at offset %d, got %v", len(complete), err)
+	}
+
+	// Only the last file may be truncated
+	dir := writeAOF(t, nil, data, complete)
+	if _, err := LoadAOF(dir, "appendonly.aof", storage.NewMemoryStore(), true); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected a truncation error in a file other than the last, got %v", err)
+	}
+}
@@ -0,0 +1,353 @@
+package persistence
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// createAOF creates an append-only file logging the changes of store in a
+// temporary directory
+func createAOF(t *testing.T, store *storage.MemoryStore, options AOFOptions) *AOF {
+	t.Helper()
+
+	aof, err := CreateAOF(t.TempDir(), "appendonly.aof", store, options)
+	if err != nil {
+		t.Fatalf("CreateAOF() returned error: %v", err)
+	}
+	t.Cleanup(func() {
+		store.SetChangeLog(nil)
+		aof.Close()
+	})
+	return aof
+}
+
+// incrCommands returns the commands of the last incremental file
+func incrCommands(t *testing.T, aof *AOF) []string {
+	t.Helper()
+
+	manifest := aof.Manifest()
+	data, err := os.ReadFile(filepath.Join(aof.Dir(), manifest.Incr[len(manifest.Incr)-1].Name))
+	if err != nil {
+		t.Fatalf("Failed to read the incremental file: %v", err)
+	}
+	commands, _, err := readCommands(data)
+	if err != nil {
+		t.Fatalf("ReadAOF() returned error: %v", err)
+	}
+	return commands
+}
+
+// reload loads the append-only file of aof into a new store
+func reload(t *testing.T, aof *AOF) *storage.MemoryStore {
+	t.Helper()
+
+	store := storage.NewMemoryStore()
+	if _, err := LoadAOF(aof.Dir(), "appendonly.aof", store, false); err != nil {
+		t.Fatalf("LoadAOF() returned error: %v", err)
+	}
+	return store
+}
+
+// dirFiles returns the names of the files in dir
+func dirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestAOF_LogsChanges(t *testing.T) {
+	store := storage.NewMemoryStore()
+	aof := createAOF(t, store, AOFOptions{Fsync: config.FsyncAlways})
+
+	expiresAt := time.Now().Add(time.Hour)
+	store.Set("a", []byte("1"))
+	store.SetWithExpiry("b", []byte("2"), expiresAt)
+	store.Delete("a")
+	store.Clear()
+
+	if err := aof.Flush(); err != nil {
+		t.Fatalf("Flush() returned error: %v", err)
+	}
+
+	commands := incrCommands(t, aof)
+	expected := []string{"SET a 1", "SET b 2 PXAT " + strconv.FormatInt(expiresAt.UnixMilli(), 10), "DEL a", "FLUSHALL"}
+	if !slices.Equal(commands, expected) {
+		t.Errorf("Expected %q, got %q", expected, commands)
+	}
+
+	var size int64
+	for _, file := range aof.Manifest().Files() {
+		info, _ := os.Stat(filepath.Join(aof.Dir(), file.Name))
+		size += info.Size()
+	}
+	if aof.Size() != size {
+		t.Errorf("Expected size %d, got %d", size, aof.Size())
+	}
+}
+
+func TestAOF_FlushWaitsForPolicy(t *testing.T) {
+	aof := createAOF(t, storage.NewMemoryStore(), AOFOptions{Fsync: config.FsyncNo})
+
+	aof.LogSet("key", []byte("value"), time.Time{})
+	if commands := incrCommands(t, aof); len(commands) != 0 {
+		t.Fatalf("Expected changes to stay buffered until flushed, got %q", commands)
+	}
+
+	for _, policy := range []string{config.FsyncNo, config.FsyncEverySec, config.FsyncAlways} {
+		aof.SetOptions(AOFOptions{Fsync: policy})
+		aof.LogDelete("key")
+		if err := aof.Flush(); err != nil {
+			t.Fatalf("Flush() returned error under %s: %v", policy, err)
+		}
+	}
+
+	if commands := incrCommands(t, aof); len(commands) != 4 {
+		t.Errorf("Expected 4 commands, got %q", commands)
+	}
+}
+
+func TestCreateAOF(t *testing.T) {
+	for _, preamble := range []bool{false, true} {
+		store := newStore("a", "1", "b", "2")
+		expiresAt := time.Now().Add(time.Hour)
+		store.SetWithExpiry("ttl", []byte("x"), expiresAt)
+
+		aof := createAOF(t, store, AOFOptions{Fsync: config.FsyncEverySec, RDBPreamble: preamble})
+		store.Set("c", []byte("3"))
+		aof.Flush()
+
+		manifest := aof.Manifest()
+		if manifest.Base == nil || strings.HasSuffix(manifest.Base.Name, ".rdb") != preamble || len(manifest.Incr) != 1 {
+			t.Errorf("Expected a base file, in RDB form %v, and an incremental file, got %s", preamble, manifest.Bytes())
+		}
+
+		loaded := reload(t, aof)
+		if loaded.Size() != 4 {
+			t.Errorf("Expected 4 keys, got %d", loaded.Size())
+		}
+		if value, _ := loaded.Get("c"); string(value) != "3" {
+			t.Errorf("Expected the change made after creation, got %q", value)
+		}
+
+		var ttl time.Time
+		loaded.Snapshot(func(records []storage.Record) error {
+			for _, record := range records {
+				if record.Key == "ttl" {
+					ttl = record.ExpiresAt
+				}
+			}
+			return nil
+		})
+		if ttl.UnixMilli() != expiresAt.UnixMilli() {
+			t.Errorf("Expected the expiry to be kept as %v, got %v", expiresAt, ttl)
+		}
+	}
+}
+
+func TestCreateAOF_ReplacesPrevious(t *testing.T) {
+	dir := writeAOF(t, appendCommand(nil, "SET", "stale", "1"), appendCommand(nil, "SET", "stale", "2"))
+
+	store := newStore("fresh", "1")
+	aof, err := CreateAOF(dir, "appendonly.aof", store, AOFOptions{Fsync: config.FsyncNo})
+	if err != nil {
+		t.Fatalf("CreateAOF() returned error: %v", err)
+	}
+	store.SetChangeLog(nil)
+	aof.Close()
+
+	expected := []string{"appendonly.aof.2.base.aof", "appendonly.aof.2.incr.aof", "appendonly.aof.manifest"}
+	if files := dirFiles(t, dir); !slices.Equal(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+	if loaded := reload(t, aof); loaded.Size() != 1 || !loaded.Exists("fresh") {
+		t.Errorf("Expected only the current keyspace, got %d keys", loaded.Size())
+	}
+}
+
+func TestOpenAOF(t *testing.T) {
+	dir := writeAOF(t, appendCommand(nil, "SET", "a", "1"), appendCommand(nil, "SET", "b", "2"))
+	store := storage.NewMemoryStore()
+	if _, err := LoadAOF(dir, "appendonly.aof", store, false); err != nil {
+		t.Fatalf("LoadAOF() returned error: %v", err)
+	}
+
+	aof, err := OpenAOF(dir, "appendonly.aof", store, AOFOptions{Fsync: config.FsyncAlways})
+	if err != nil {
+		t.Fatalf("OpenAOF() returned error: %v", err)
+	}
+	store.Set("c", []byte("3"))
+	store.SetChangeLog(nil)
+	aof.Close()
+
+	// Changes are appended to the last incremental file
+	if commands := incrCommands(t, aof); !slices.Equal(commands, []string{"SET b 2", "SET c 3"}) {
+		t.Errorf("Expected the change to be appended, got %q", commands)
+	}
+	if loaded := reload(t, aof); loaded.Size() != 3 {
+		t.Errorf("Expected 3 keys, got %d", loaded.Size())
+	}
+}
+
+func TestUpgradeAOF(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "appendonly.aof")
+	os.WriteFile(path, appendCommand(nil, "SET", "a", "1"), 0644)
+
+	appendDir := filepath.Join(dir, "appendonlydir")
+	if err := UpgradeAOF(path, appendDir, "appendonly.aof"); err != nil {
+		t.Fatalf("UpgradeAOF() returned error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the single file to be moved, got %v", err)
+	}
+
+	store := storage.NewMemoryStore()
+	if _, err := LoadAOF(appendDir, "appendonly.aof", store, false); err != nil {
+		t.Fatalf("LoadAOF() returned error: %v", err)
+	}
+	aof, err := OpenAOF(appendDir, "appendonly.aof", store, AOFOptions{Fsync: config.FsyncNo})
+	if err != nil {
+		t.Fatalf("OpenAOF() returned error: %v", err)
+	}
+	store.Set("b", []byte("2"))
+	store.SetChangeLog(nil)
+	aof.Close()
+
+	// The single file becomes the base file, followed by a new incremental file
+	manifest := aof.Manifest()
+	if manifest.Base.Name != "appendonly.aof.1.base.aof" || len(manifest.Incr) != 1 {
+		t.Errorf("Expected the single file as base and one incremental file, got %s", manifest.Bytes())
+	}
+	if loaded := reload(t, aof); loaded.Size() != 2 {
+		t.Errorf("Expected 2 keys, got %d", loaded.Size())
+	}
+}
+
+func TestAOF_Rewrite(t *testing.T) {
+	store := storage.NewMemoryStore()
+	aof := createAOF(t, store, AOFOptions{Fsync: config.FsyncNo, RDBPreamble: true})
+	for i := 0; i < 100; i++ {
+		store.Set("key", []byte(strconv.Itoa(i)))
+	}
+	aof.Flush()
+	before := aof.Size()
+
+	if err := aof.Rewrite(); err != nil {
+		t.Fatalf("Rewrite() returned error: %v", err)
+	}
+	store.Set("after", []byte("1"))
+	aof.Flush()
+
+	if aof.Size() >= before {
+		t.Errorf("Expected the rewrite to shrink the files from %d bytes, got %d", before, aof.Size())
+	}
+	manifest := aof.Manifest()
+	if manifest.Base.Seq != 2 || len(manifest.Incr) != 1 || manifest.Incr[0].Seq != 2 {
+		t.Errorf("Expected the second base and incremental files, got %s", manifest.Bytes())
+	}
+	expected := []string{"appendonly.aof.2.base.rdb", "appendonly.aof.2.incr.aof", "appendonly.aof.manifest"}
+	if files := dirFiles(t, aof.Dir()); !slices.Equal(files, expected) {
+		t.Errorf("Expected the replaced files to be deleted, got %v", files)
+	}
+
+	loaded := reload(t, aof)
+	if value, _ := loaded.Get("key"); string(value) != "99" || !loaded.Exists("after") {
+		t.Errorf("Expected the latest values, got %q", value)
+	}
+}
+
+func TestAOF_BackgroundRewriteDuringWrites(t *testing.T) {
+	store := storage.NewMemoryStore()
+	for i := 0; i < 5000; i++ {
+		store.Set("key"+strconv.Itoa(i), []byte("initial"))
+	}
+	aof := createAOF(t, store, AOFOptions{Fsync: config.FsyncNo})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5000; i++ {
+			key := "key" + strconv.Itoa(i)
+			if i%3 == 0 {
+				store.Delete(key)
+			} else {
+				store.Set(key, []byte("changed"))
+			}
+			aof.Flush()
+		}
+	}()
+
+	if err := aof.BackgroundRewrite(); err != nil {
+		t.Fatalf("BackgroundRewrite() returned error: %v", err)
+	}
+	if err := aof.BackgroundRewrite(); err != ErrRewriteInProgress && aof.Rewriting() {
+		t.Errorf("Expected ErrRewriteInProgress, got %v", err)
+	}
+	wg.Wait()
+	aof.Wait()
+	if err := aof.LastError(); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	aof.Flush()
+
+	loaded := reload(t, aof)
+	if loaded.Size() != store.Size() {
+		t.Fatalf("Expected %d keys, got %d", store.Size(), loaded.Size())
+	}
+	for i := 0; i < 5000; i++ {
+		key := "key" + strconv.Itoa(i)
+		want, _ := store.Get(key)
+		got, _ := loaded.Get(key)
+		if string(got) != string(want) {
+			t.Fatalf("Expected %s to be %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestAOF_CheckRewrite(t *testing.T) {
+	store := storage.NewMemoryStore()
+	aof := createAOF(t, store, AOFOptions{Fsync: config.FsyncNo})
+	now := time.Now()
+
+	// The files are smaller than the minimum size
+	store.Set("key", []byte("value"))
+	aof.Flush()
+	if aof.CheckRewrite(100, 1024, now) {
+		t.Error("Expected no rewrite below the minimum size")
+	}
+
+	for i := 0; i < 100; i++ {
+		store.Set("key", []byte("value"))
+	}
+	aof.Flush()
+	if aof.CheckRewrite(0, 0, now) {
+		t.Error("Expected no rewrite with a percentage of 0")
+	}
+	if !aof.CheckRewrite(100, 1024, now) {
+		t.Fatal("Expected a rewrite once the files have grown")
+	}
+	aof.Wait()
+
+	// Growth is measured from the size after the rewrite
+	if aof.CheckRewrite(100, 0, now) {
+		t.Error("Expected no rewrite right after a rewrite")
+	}
+}
@@ -0,0 +1,67 @@
+package persistence
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// AOFCheck is the result of checking an append-only file with CheckAOF
+type AOFCheck struct {
+	Size int64
+	// RDB is set if the file starts with an RDB snapshot, of RDBKeys keys,
+	// which the commands follow from offset Start. Start is -1 if the
+	// snapshot is corrupt.
+	RDB      bool
+	RDBKeys  int
+	Start    int64
+	Commands int
+	// Valid is the offset just past the last valid command
+	Valid int64
+	// Err is the first invalid data found, a *CorruptError unless the
+	// snapshot header is invalid, or nil if the file is valid
+	Err error
+}
+
+// Truncated reports whether the file ends in the middle of a command or
+// of a MULTI/EXEC transaction, as after a crash or a full disk
+func (c AOFCheck) Truncated() bool {
+	return errors.Is(c.Err, io.ErrUnexpectedEOF)
+}
+
+// Fixable reports whether truncating the file at Valid makes it valid:
+// whether the invalid data is in the commands rather than in the snapshot
+func (c AOFCheck) Fixable() bool {
+	return c.Err != nil && c.Start >= 0
+}
+
+// CheckAOF reads the append-only file at path, in RDB or AOF form, and
+// checks that it could be loaded. Only errors opening the file are
+// returned; invalid data is reported in the AOFCheck.
+func CheckAOF(path string) (AOFCheck, error) {
+	var check AOFCheck
+	file, err := os.Open(path)
+	if err != nil {
+		return check, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return check, err
+	}
+	check.Size = info.Size()
+
+	check.Start, check.Valid, check.Err = readAOFFile(file, func(*Entry) error {
+		check.RDBKeys++
+		return nil
+	}, func(args [][]byte) error {
+		if err := checkAOFCommand(args); err != nil {
+			return err
+		}
+		check.Commands++
+		return nil
+	})
+	check.RDB = check.RDB || check.Start != 0
+	return check, nil
+}
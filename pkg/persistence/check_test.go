@@ -0,0 +1,122 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckAOF(t *testing.T) {
+	set := appendCommand(nil, "SET", "a", "1")
+	transaction := appendCommand(appendCommand(appendCommand(nil, "MULTI"), "DEL", "a"), "EXEC")
+	rdb := encode(t, newStore("a", "1", "b", "2"), RDBOptions{Checksum: true})
+
+	tests := []struct {
+		name      string
+		data      []byte
+		rdbKeys   int
+		start     int64
+		commands  int
+		valid     int
+		truncated bool
+		fixable   bool
+	}{
+		{"empty", nil, 0, 0, 0, 0, false, false},
+		{"commands", join(set, transaction), 0, 0, 2, len(set) + len(transaction), false, false},
+		{"rdb preamble", join(rdb, set), 2, int64(len(rdb)), 1, len(rdb) + len(set), false, false},
+		{"truncated", join(set, transaction[:len(transaction)-2]), 0, 0, 1, len(set), true, true},
+		{"truncated after rdb", join(rdb, set, set[:5]), 2, int64(len(rdb)), 1, len(rdb) + len(set), true, true},
+		{"unknown command", join(set, appendCommand(nil, "INCR", "a"), set), 0, 0, 1, len(set), false, true},
+		{"truncated rdb", rdb[:len(rdb)-4], 2, -1, 0, 0, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "appendonly.aof")
+			os.WriteFile(path, tt.data, 0644)
+
+			check, err := CheckAOF(path)
+			if err != nil {
+				t.Fatalf("CheckAOF() returned error: %v", err)
+			}
+			if check.Size != int64(len(tt.data)) {
+				t.Errorf("Expected size %d, got %d", len(tt.data), check.Size)
+			}
+			if check.RDBKeys != tt.rdbKeys || check.Start != tt.start || check.Commands != tt.commands {
+				t.Errorf("Expected %d keys, commands from %d and %d commands, got %+v", tt.rdbKeys, tt.start, tt.commands, check)
+			}
+			if check.Valid != int64(tt.valid) {
+				t.Errorf("Expected valid up to %d, got %d", tt.valid, check.Valid)
+			}
+			if check.Truncated() != tt.truncated || check.Fixable() != tt.fixable {
+				t.Errorf("Expected truncated %v and fixable %v, got %v", tt.truncated, tt.fixable, check.Err)
+			}
+		})
+	}
+}
+
+func TestCheckAOF_Offset(t *testing.T) {
+	set := appendCommand(nil, "SET", "a", "1")
+	rdb := encode(t, newStore("a", "1"), RDBOptions{Checksum: true})
+	path := filepath.Join(t.TempDir(), "appendonly.aof")
+	os.WriteFile(path, join(rdb, set, []byte("garbage\r\n")), 0644)
+
+	check, err := CheckAOF(path)
+	if err != nil {
+		t.Fatalf("CheckAOF() returned error: %v", err)
+	}
+	// The offset is from the start of the file, not of the commands
+	var corrupt *CorruptError
+	if !errors.As(check.Err, &corrupt) || corrupt.Offset != int64(len(rdb)+len(set)) {
+		t.Errorf("Expected an error at offset %d, got %v", len(rdb)+len(set), check.Err)
+	}
+}
+
+func TestCheckAOF_OversizedLength(t *testing.T) {
+	set := appendCommand(nil, "SET", "a", "1")
+	rdb := encode(t, newStore("a", "1"), RDBOptions{Checksum: true})
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"bulk length", "*1\r\n$999999999999999999\r\n"},
+		{"bulk length past the end", "*3\r\n$3\r\nSET\r\n$1\r\nb\r\n$80000000000\r\n1\r\n"},
+		{"multibulk length", "*999999999999999999\r\n$3\r\nSET\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "appendonly.aof")
+			os.WriteFile(path, join(rdb, set, []byte(tt.data)), 0644)
+
+			check, err := CheckAOF(path)
+			if err != nil {
+				t.Fatalf("CheckAOF() returned error: %v", err)
+			}
+			var corrupt *CorruptError
+			if !errors.As(check.Err, &corrupt) || corrupt.Offset != int64(len(rdb)+len(set)) {
+				t.Errorf("Expected an error at offset %d, got %v", len(rdb)+len(set), check.Err)
+			}
+			if check.Commands != 1 || check.Valid != int64(len(rdb)+len(set)) {
+				t.Errorf("Expected 1 command valid up to %d, got %+v", len(rdb)+len(set), check)
+			}
+		})
+	}
+}
+
+func TestCheckAOF_MissingFile(t *testing.T) {
+	if _, err := CheckAOF(filepath.Join(t.TempDir(), "missing.aof")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file error, got %v", err)
+	}
+}
+
+// join concatenates the parts of a file
+func join(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
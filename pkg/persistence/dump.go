@@ -0,0 +1,67 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// A DUMP payload, as exchanged by DUMP, RESTORE and MIGRATE, is a value
+// encoded as in an RDB file, its type then its contents, followed by the
+// RDB version as 2 little-endian bytes and a CRC-64 of everything before
+// it. It is the format of Redis, so keys move between redis-lite and
+// Redis in both directions.
+const dumpFooterSize = 2 + 8
+
+var (
+	// ErrDumpPayload is returned for a payload of a newer RDB version, or
+	// with a wrong checksum
+	ErrDumpPayload = errors.New("DUMP payload version or checksum are wrong")
+	// ErrBadDumpData is returned for a payload whose value cannot be read
+	ErrBadDumpData = errors.New("Bad data format")
+)
+
+// EncodeDump encodes the value of entry as a DUMP payload, compressing
+// long strings if compression is set. Its key, expiry and access metadata
+// are not part of the payload.
+func EncodeDump(entry *Entry, compression bool) ([]byte, error) {
+	var buf bytes.Buffer
+	w := NewRDBWriter(&buf, RDBOptions{Compression: compression})
+	if err := w.writeValue(entry, nil); err != nil {
+		return nil, err
+	}
+	if err := w.out.Flush(); err != nil {
+		return nil, err
+	}
+
+	payload := binary.LittleEndian.AppendUint16(buf.Bytes(), RDBVersion)
+	var crc crc64
+	crc.Write(payload)
+	return binary.LittleEndian.AppendUint64(payload, crc.Sum64()), nil
+}
+
+// DecodeDump decodes a DUMP payload into an entry holding its value, with
+// no expiry or access metadata. The payload must be of an RDB version that
+// can be read and end with a valid checksum.
+func DecodeDump(payload []byte) (*Entry, error) {
+	if len(payload) < dumpFooterSize+1 {
+		return nil, ErrDumpPayload
+	}
+	footer := payload[len(payload)-dumpFooterSize:]
+	version := binary.LittleEndian.Uint16(footer)
+	var crc crc64
+	crc.Write(payload[:len(payload)-8])
+	if version > MaxRDBVersion || binary.LittleEndian.Uint64(footer[2:]) != crc.Sum64() {
+		return nil, ErrDumpPayload
+	}
+
+	data := payload[:len(payload)-dumpFooterSize]
+	in := &rdbReader{reader: bufio.NewReader(bytes.NewReader(data))}
+	valueType, _ := in.ReadByte()
+	entry := &Entry{Idle: -1, Freq: -1}
+	if !rdbValueTypes[valueType] || in.readValue(valueType, entry) != nil || in.offset != int64(len(data)) {
+		return nil, ErrBadDumpData
+	}
+	return entry, nil
+}
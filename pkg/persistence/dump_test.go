@@ -0,0 +1,90 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDump(t *testing.T) {
+	// DUMP of the string "10" by Redis 5.0 to 6.2, from the Redis
+	// documentation
+	expected := []byte("\x00\xc0\n\t\x00\xbem\x06\x89Z(\x00\n")
+
+	payload, err := EncodeDump(&Entry{Type: TypeString, String: []byte("10")}, true)
+	if err != nil {
+		t.Fatalf("EncodeDump() returned error: %v", err)
+	}
+	if !bytes.Equal(payload, expected) {
+		t.Errorf("Expected %q, got %q", expected, payload)
+	}
+}
+
+func TestDecodeDump(t *testing.T) {
+	// DUMP of the string "10" by Redis 7.0, of RDB version 10
+	entry, err := DecodeDump([]byte("\x00\xc0\n\n\x00n\x9fWE\x0e\xaec\xbb"))
+	if err != nil {
+		t.Fatalf("DecodeDump() returned error: %v", err)
+	}
+	if entry.Type != TypeString || string(entry.String) != "10" {
+		t.Errorf("Expected the string '10', got %+v", entry)
+	}
+
+	// Every type written reads back
+	entries := []*Entry{
+		{Type: TypeString, String: []byte(strings.Repeat("compressible ", 10))},
+		{Type: TypeList, List: [][]byte{[]byte("a"), []byte("1")}},
+		{Type: TypeSet, List: [][]byte{[]byte("x")}},
+		{Type: TypeZSet, ZSet: []ZSetMember{{Member: []byte("m"), Score: 1.5}}},
+		{Type: TypeHash, Hash: []HashField{{Field: []byte("f"), Value: []byte("v")}}},
+	}
+	for _, expected := range entries {
+		payload, err := EncodeDump(expected, true)
+		if err != nil {
+			t.Fatalf("EncodeDump() returned error: %v", err)
+		}
+		expected.Idle, expected.Freq = -1, -1
+		if entry, err := DecodeDump(payload); err != nil || !reflect.DeepEqual(entry, expected) {
+			t.Errorf("Expected %+v, got %+v (%v)", expected, entry, err)
+		}
+	}
+}
+
+func TestDecodeDump_Invalid(t *testing.T) {
+	payload, _ := EncodeDump(&Entry{Type: TypeString, String: []byte("value")}, false)
+	newer := bytes.Clone(payload)
+	newer[len(newer)-10] = MaxRDBVersion + 1
+	corrupt := bytes.Clone(payload)
+	corrupt[2] ^= 1
+
+	// A value followed by extra bytes, with a valid checksum
+	extra, _ := EncodeDump(&Entry{Type: TypeString, String: []byte("value")}, false)
+	body := append(bytes.Clone(extra[:len(extra)-dumpFooterSize]), 0)
+	var crc crc64
+	withFooter := append(body, extra[len(extra)-dumpFooterSize:len(extra)-8]...)
+	crc.Write(withFooter)
+	extra = binary.LittleEndian.AppendUint64(withFooter, crc.Sum64())
+
+	tests := []struct {
+		name    string
+		payload []byte
+		err     error
+	}{
+		{"empty", nil, ErrDumpPayload},
+		{"short", payload[:5], ErrDumpPayload},
+		{"newer version", newer, ErrDumpPayload},
+		{"wrong checksum", corrupt, ErrDumpPayload},
+		{"trailing data", extra, ErrBadDumpData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeDump(tt.payload); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
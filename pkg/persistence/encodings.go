@@ -0,0 +1,268 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// The compact encodings below are stored in RDB files as single strings.
+// Each decoder returns the elements in order, integers formatted in
+// decimal as Redis returns them.
+
+// errEncoding reports a compact encoding that cannot be decoded
+var errEncoding = errors.New("invalid encoding")
+
+// decodeZiplist decodes a ziplist: a header of the total size (32 bits),
+// the offset of the last entry (32 bits) and the entry count (16 bits),
+// then entries made of the previous entry's length, an encoding and the
+// data, and a 0xFF terminator
+func decodeZiplist(data []byte) ([][]byte, error) {
+	if len(data) < 11 {
+		return nil, fmt.Errorf("ziplist: %w", errEncoding)
+	}
+
+	var elements [][]byte
+	i := 10
+	for {
+		if i >= len(data) {
+			return nil, fmt.Errorf("ziplist: %w", errEncoding)
+		}
+		if data[i] == 0xFF {
+			return elements, nil
+		}
+
+		// Skip the previous entry's length
+		if data[i] == 0xFE {
+			i += 5
+		} else {
+			i++
+		}
+		if i >= len(data) {
+			return nil, fmt.Errorf("ziplist: %w", errEncoding)
+		}
+
+		encoding := data[i]
+		var length, header int
+		switch {
+		case encoding>>6 == 0:
+			length, header = int(encoding&0x3f), 1
+		case encoding>>6 == 1:
+			if i+1 >= len(data) {
+				return nil, fmt.Errorf("ziplist: %w", errEncoding)
+			}
+			length, header = int(encoding&0x3f)<<8|int(data[i+1]), 2
+		case encoding == 0x80:
+			if i+5 > len(data) {
+				return nil, fmt.Errorf("ziplist: %w", errEncoding)
+			}
+			length, header = int(binary.BigEndian.Uint32(data[i+1:])), 5
+		default:
+			value, size, err := ziplistInt(data[i+1:], encoding)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, []byte(strconv.FormatInt(value, 10)))
+			i += 1 + size
+			continue
+		}
+
+		start := i + header
+		if length < 0 || start+length > len(data) {
+			return nil, fmt.Errorf("ziplist: %w", errEncoding)
+		}
+		elements = append(elements, data[start:start+length])
+		i = start + length
+	}
+}
+
+// ziplistInt decodes a ziplist integer and returns it with the number of
+// bytes it takes after the encoding byte
+func ziplistInt(data []byte, encoding byte) (int64, int, error) {
+	sizes := map[byte]int{0xC0: 2, 0xD0: 4, 0xE0: 8, 0xF0: 3, 0xFE: 1}
+	if encoding >= 0xF1 && encoding <= 0xFD {
+		// Immediate values 0 to 12
+		return int64(encoding&0x0f) - 1, 0, nil
+	}
+
+	size, ok := sizes[encoding]
+	if !ok || size > len(data) {
+		return 0, 0, fmt.Errorf("ziplist: %w", errEncoding)
+	}
+	return littleEndianInt(data[:size]), size, nil
+}
+
+// littleEndianInt decodes a signed little-endian integer of 1 to 8 bytes
+func littleEndianInt(data []byte) int64 {
+	var value uint64
+	for i := len(data) - 1; i >= 0; i-- {
+		value = value<<8 | uint64(data[i])
+	}
+	shift := 64 - 8*len(data)
+	return int64(value<<shift) >> shift
+}
+
+// decodeListpack decodes a listpack: a header of the total size (32 bits)
+// and the element count (16 bits), then entries made of an encoding, the
+// data and the entry's length backwards, and a 0xFF terminator
+func decodeListpack(data []byte) ([][]byte, error) {
+	if len(data) < 7 {
+		return nil, fmt.Errorf("listpack: %w", errEncoding)
+	}
+
+	var elements [][]byte
+	i := 6
+	for {
+		if i >= len(data) {
+			return nil, fmt.Errorf("listpack: %w", errEncoding)
+		}
+		encoding := data[i]
+		if encoding == 0xFF {
+			return elements, nil
+		}
+
+		var element []byte
+		var size int
+		switch {
+		case encoding&0x80 == 0:
+			// 7-bit unsigned integer
+			element, size = []byte(strconv.Itoa(int(encoding))), 1
+		case encoding&0xC0 == 0x80:
+			// String of up to 63 bytes
+			length := int(encoding & 0x3f)
+			if i+1+length > len(data) {
+				return nil, fmt.Errorf("listpack: %w", errEncoding)
+			}
+			element, size = data[i+1:i+1+length], 1+length
+		case encoding&0xE0 == 0xC0:
+			// 13-bit signed integer
+			if i+1 >= len(data) {
+				return nil, fmt.Errorf("listpack: %w", errEncoding)
+			}
+			value := int64(encoding&0x1f)<<8 | int64(data[i+1])
+			if value >= 1<<12 {
+				value -= 1 << 13
+			}
+			element, size = []byte(strconv.FormatInt(value, 10)), 2
+		case encoding&0xF0 == 0xE0:
+			// String of up to 4095 bytes
+			if i+1 >= len(data) {
+				return nil, fmt.Errorf("listpack: %w", errEncoding)
+			}
+			length := int(encoding&0x0f)<<8 | int(data[i+1])
+			if i+2+length > len(data) {
+				return nil, fmt.Errorf("listpack: %w", errEncoding)
+			}
+			element, size = data[i+2:i+2+length], 2+length
+		case encoding == 0xF0:
+			// String with a 32-bit length
+			if i+5 > len(data) {
+				return nil, fmt.Errorf("listpack: %w", errEncoding)
+			}
+			length := int(binary.LittleEndian.Uint32(data[i+1:]))
+			if length < 0 || i+5+length > len(data) {
+				return nil, fmt.Errorf("listpack: %w", errEncoding)
+			}
+			element, size = data[i+5:i+5+length], 5+length
+		case encoding >= 0xF1 && encoding <= 0xF4:
+			// 16, 24, 32 and 64-bit signed integers
+			bytes := []int{2, 3, 4, 8}[encoding-0xF1]
+			if i+1+bytes > len(data) {
+				return nil, fmt.Errorf("listpack: %w", errEncoding)
+			}
+			value := littleEndianInt(data[i+1 : i+1+bytes])
+			element, size = []byte(strconv.FormatInt(value, 10)), 1+bytes
+		default:
+			return nil, fmt.Errorf("listpack: %w", errEncoding)
+		}
+
+		elements = append(elements, element)
+		i += size + listpackBacklenSize(size)
+	}
+}
+
+// listpackBacklenSize returns the number of bytes taken by the backwards
+// length of an entry of the given size
+func listpackBacklenSize(size int) int {
+	switch {
+	case size <= 127:
+		return 1
+	case size < 16383:
+		return 2
+	case size < 2097151:
+		return 3
+	case size < 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// decodeIntset decodes an intset: the integer size (32 bits), the count
+// (32 bits) and the sorted integers
+func decodeIntset(data []byte) ([][]byte, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("intset: %w", errEncoding)
+	}
+
+	size := int(binary.LittleEndian.Uint32(data))
+	count := int(binary.LittleEndian.Uint32(data[4:]))
+	if (size != 2 && size != 4 && size != 8) || count < 0 || len(data) != 8+size*count {
+		return nil, fmt.Errorf("intset: %w", errEncoding)
+	}
+
+	elements := make([][]byte, count)
+	for i := range elements {
+		value := littleEndianInt(data[8+i*size : 8+(i+1)*size])
+		elements[i] = []byte(strconv.FormatInt(value, 10))
+	}
+	return elements, nil
+}
+
+// decodeZipmap decodes a zipmap, the hash encoding of Redis before 2.6: a
+// count byte, then fields and values preceded by their length, values also
+// followed by unused bytes, and a 0xFF terminator
+func decodeZipmap(data []byte) ([][]byte, error) {
+	var elements [][]byte
+	i := 1
+	readLength := func() (int, bool) {
+		if i >= len(data) {
+			return 0, false
+		}
+		if data[i] < 254 {
+			i++
+			return int(data[i-1]), true
+		}
+		if data[i] == 254 && i+5 <= len(data) {
+			i += 5
+			return int(binary.LittleEndian.Uint32(data[i-4:])), true
+		}
+		return 0, false
+	}
+
+	for {
+		if i >= len(data) {
+			return nil, fmt.Errorf("zipmap: %w", errEncoding)
+		}
+		if data[i] == 0xFF {
+			return elements, nil
+		}
+
+		length, ok := readLength()
+		if !ok || i+length > len(data) {
+			return nil, fmt.Errorf("zipmap: %w", errEncoding)
+		}
+		elements = append(elements, data[i:i+length])
+		i += length
+
+		length, ok = readLength()
+		if !ok || i+1+length > len(data) {
+			return nil, fmt.Errorf("zipmap: %w", errEncoding)
+		}
+		free := int(data[i])
+		i++
+		elements = append(elements, data[i:i+length])
+		i += length + free
+	}
+}
@@ -0,0 +1,192 @@
+package persistence
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// listpack builds a listpack of strings and ints in their smallest
+// encodings
+func listpack(elements ...any) []byte {
+	var body []byte
+	for _, element := range elements {
+		var entry []byte
+		switch v := element.(type) {
+		case string:
+			switch {
+			case len(v) < 64:
+				entry = append([]byte{0x80 | byte(len(v))}, v...)
+			case len(v) < 4096:
+				entry = append([]byte{0xE0 | byte(len(v)>>8), byte(len(v))}, v...)
+			default:
+				entry = binary.LittleEndian.AppendUint32([]byte{0xF0}, uint32(len(v)))
+				entry = append(entry, v...)
+			}
+		case int:
+			switch {
+			case v >= 0 && v < 128:
+				entry = []byte{byte(v)}
+			case v >= -4096 && v < 4096:
+				u := uint16(v) & 0x1fff
+				entry = []byte{0xC0 | byte(u>>8), byte(u)}
+			case v >= -1<<15 && v < 1<<15:
+				entry = binary.LittleEndian.AppendUint16([]byte{0xF1}, uint16(v))
+			case v >= -1<<23 && v < 1<<23:
+				entry = []byte{0xF2, byte(v), byte(v >> 8), byte(v >> 16)}
+			case v >= -1<<31 && v < 1<<31:
+				entry = binary.LittleEndian.AppendUint32([]byte{0xF3}, uint32(v))
+			default:
+				entry = binary.LittleEndian.AppendUint64([]byte{0xF4}, uint64(v))
+			}
+		}
+
+		body = append(body, entry...)
+		if size := len(entry); size <= 127 {
+			body = append(body, byte(size))
+		} else {
+			body = append(body, byte(size>>7), byte(size&127)|128)
+		}
+	}
+
+	header := binary.LittleEndian.AppendUint32(nil, uint32(6+len(body)+1))
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(elements)))
+	return append(append(header, body...), 0xFF)
+}
+
+// ziplist builds a ziplist of strings and ints in their smallest encodings
+func ziplist(elements ...any) []byte {
+	var body []byte
+	previous := 0
+	for _, element := range elements {
+		var entry []byte
+		if previous < 254 {
+			entry = []byte{byte(previous)}
+		} else {
+			entry = binary.LittleEndian.AppendUint32([]byte{0xFE}, uint32(previous))
+		}
+
+		switch v := element.(type) {
+		case string:
+			switch {
+			case len(v) < 64:
+				entry = append(entry, byte(len(v)))
+			case len(v) < 16384:
+				entry = append(entry, 0x40|byte(len(v)>>8), byte(len(v)))
+			default:
+				entry = binary.BigEndian.AppendUint32(append(entry, 0x80), uint32(len(v)))
+			}
+			entry = append(entry, v...)
+		case int:
+			switch {
+			case v >= 0 && v <= 12:
+				entry = append(entry, 0xF1+byte(v))
+			case v >= -128 && v < 128:
+				entry = append(entry, 0xFE, byte(v))
+			case v >= -1<<15 && v < 1<<15:
+				entry = binary.LittleEndian.AppendUint16(append(entry, 0xC0), uint16(v))
+			case v >= -1<<23 && v < 1<<23:
+				entry = append(entry, 0xF0, byte(v), byte(v>>8), byte(v>>16))
+			case v >= -1<<31 && v < 1<<31:
+				entry = binary.LittleEndian.AppendUint32(append(entry, 0xD0), uint32(v))
+			default:
+				entry = binary.LittleEndian.AppendUint64(append(entry, 0xE0), uint64(v))
+			}
+		}
+
+		body = append(body, entry...)
+		previous = len(entry)
+	}
+
+	header := binary.LittleEndian.AppendUint32(nil, uint32(10+len(body)+1))
+	header = binary.LittleEndian.AppendUint32(header, 0)
+	header = binary.LittleEndian.AppendUint16(header, uint16(len(elements)))
+	return append(append(header, body...), 0xFF)
+}
+
+// strs converts elements to strings for comparison
+func strs(elements [][]byte) []string {
+	result := make([]string, len(elements))
+	for i, element := range elements {
+		result[i] = string(element)
+	}
+	return result
+}
+
+func TestDecodeListpack(t *testing.T) {
+	long := strings.Repeat("l", 300)
+	huge := strings.Repeat("h", 5000)
+	data := listpack("a", 0, 127, -1, 4095, -4096, 30000, -8000000, 2000000000, 1<<40, long, huge, "")
+
+	elements, err := decodeListpack(data)
+	if err != nil {
+		t.Fatalf("decodeListpack() returned error: %v", err)
+	}
+
+	expected := []string{"a", "0", "127", "-1", "4095", "-4096", "30000", "-8000000", "2000000000", "1099511627776", long, huge, ""}
+	if !reflect.DeepEqual(strs(elements), expected) {
+		t.Errorf("Expected %v, got %v", expected, strs(elements))
+	}
+
+	if _, err := decodeListpack(data[:len(data)-1]); err == nil {
+		t.Error("Expected an error for a listpack without terminator")
+	}
+}
+
+func TestDecodeZiplist(t *testing.T) {
+	long := strings.Repeat("l", 300)
+	huge := strings.Repeat("h", 17000)
+	data := ziplist("a", 0, 12, 13, -100, 30000, -8000000, 2000000000, -(1 << 40), long, huge, "")
+
+	elements, err := decodeZiplist(data)
+	if err != nil {
+		t.Fatalf("decodeZiplist() returned error: %v", err)
+	}
+
+	expected := []string{"a", "0", "12", "13", "-100", "30000", "-8000000", "2000000000", "-1099511627776", long, huge, ""}
+	if !reflect.DeepEqual(strs(elements), expected) {
+		t.Errorf("Expected %v, got %v", expected, strs(elements))
+	}
+
+	if _, err := decodeZiplist(data[:20]); err == nil {
+		t.Error("Expected an error for a truncated ziplist")
+	}
+}
+
+func TestDecodeIntset(t *testing.T) {
+	data := binary.LittleEndian.AppendUint32(nil, 2)
+	data = binary.LittleEndian.AppendUint32(data, 3)
+	for _, v := range []int16{-5, 1, 300} {
+		data = binary.LittleEndian.AppendUint16(data, uint16(v))
+	}
+
+	elements, err := decodeIntset(data)
+	if err != nil {
+		t.Fatalf("decodeIntset() returned error: %v", err)
+	}
+	if expected := []string{"-5", "1", "300"}; !reflect.DeepEqual(strs(elements), expected) {
+		t.Errorf("Expected %v, got %v", expected, strs(elements))
+	}
+
+	if _, err := decodeIntset(data[:len(data)-1]); err == nil {
+		t.Error("Expected an error for a truncated intset")
+	}
+}
+
+func TestDecodeZipmap(t *testing.T) {
+	// Two pairs, the second value followed by 2 free bytes
+	data := []byte{2, 3, 'f', 'o', 'o', 3, 0, 'b', 'a', 'r', 1, 'k', 1, 2, 'v', 0, 0, 0xFF}
+
+	elements, err := decodeZipmap(data)
+	if err != nil {
+		t.Fatalf("decodeZipmap() returned error: %v", err)
+	}
+	if expected := []string{"foo", "bar", "k", "v"}; !reflect.DeepEqual(strs(elements), expected) {
+		t.Errorf("Expected %v, got %v", expected, strs(elements))
+	}
+
+	if _, err := decodeZipmap(data[:8]); err == nil {
+		t.Error("Expected an error for a truncated zipmap")
+	}
+}
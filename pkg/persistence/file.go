@@ -0,0 +1,51 @@
+// Package persistence saves the keyspace to disk and loads it back.
+package persistence
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes a file through write, so that the file is either
+// left untouched or entirely replaced even if the process crashes: the
+// data goes to a temporary file in the same directory, which is synced to
+// disk and renamed over the file.
+func writeFileAtomic(path string, write func(*bufio.Writer) error) error {
+	dir := filepath.Dir(path)
+	temp, err := os.CreateTemp(dir, "temp-*-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	writer := bufio.NewWriterSize(temp, 64*1024)
+	if err := write(writer); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("renaming %s: %w", temp.Name(), err)
+	}
+
+	// The rename itself is only durable once the directory is synced. Not
+	// every platform can sync a directory, so failures are ignored.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tsinivuo/redis-lite/pkg/glob"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// JSONRecord is a key as exported to JSON lines, one object per key:
+//
+//	{"key":"greeting","type":"string","value":"hello","ttl":60000}
+//
+// TTL is the time to live in milliseconds, omitted for keys that do not
+// expire. It is relative to the time of the export, so that an exported
+// file can be imported later, as test fixtures are.
+type JSONRecord struct {
+	Key   JSONBytes `json:"key"`
+	Type  string    `json:"type"`
+	Value JSONBytes `json:"value"`
+	TTL   int64     `json:"ttl,omitempty"`
+}
+
+// JSONBytes is a binary-safe string in JSON: it is written as a JSON string
+// when it is valid UTF-8, and as {"base64":"..."} otherwise
+type JSONBytes []byte
+
+// MarshalJSON writes b as a string, or in base64 if it is not valid UTF-8
+func (b JSONBytes) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON reads a string, or an object holding a base64 string.
+// null leaves b unchanged.
+func (b *JSONBytes) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = JSONBytes(s)
+		return nil
+	}
+
+	var encoded struct {
+		Base64 *string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil || encoded.Base64 == nil {
+		return errors.New(`expected a string or {"base64": string}`)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(*encoded.Base64)
+	if err != nil {
+		return fmt.Errorf("invalid base64: %w", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// ExpiresAt returns the expiry of a record imported at now, or the zero
+// time if it does not expire
+func (r *JSONRecord) ExpiresAt(now time.Time) time.Time {
+	if r.TTL == 0 {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(r.TTL) * time.Millisecond)
+}
+
+// WriteJSON writes the keys of source matching pattern, or every key if
+// pattern is empty, to w as JSON lines, and returns the number of keys
+// written. Keys are sorted, so that exporting the same keyspace twice
+// gives the same file, and fixtures checked into version control diff
+// cleanly.
+func WriteJSON(w io.Writer, source Source, pattern string, now time.Time) (int, error) {
+	var records []JSONRecord
+	err := source.Snapshot(func(batch []storage.Record) error {
+		for _, record := range batch {
+			if pattern != "" && !glob.Match(pattern, record.Key) {
+				continue
+			}
+			out := JSONRecord{Key: JSONBytes(record.Key), Type: "string", Value: record.Value}
+			if !record.ExpiresAt.IsZero() {
+				// A key about to expire keeps the shortest TTL
+				out.TTL = max(record.ExpiresAt.Sub(now).Milliseconds(), 1)
+			}
+			records = append(records, out)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	slices.SortFunc(records, func(a, b JSONRecord) int {
+		return bytes.Compare(a.Key, b.Key)
+	})
+
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return 0, err
+		}
+	}
+	return len(records), out.Flush()
+}
+
+// ReadJSON reads keys exported as JSON lines by WriteJSON. Empty lines are
+// skipped. The first invalid line is reported with its number, and no
+// records are returned then, so that an import is all or nothing.
+func ReadJSON(r io.Reader) ([]JSONRecord, error) {
+	var records []JSONRecord
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			record, parseErr := parseJSONRecord(data)
+			if parseErr != nil {
+				return nil, fmt.Errorf("line %d: %w", line, parseErr)
+			}
+			records = append(records, record)
+		}
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseJSONRecord parses a line of JSON lines
+func parseJSONRecord(data []byte) (JSONRecord, error) {
+	var record JSONRecord
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&record); err != nil {
+		return record, err
+	}
+	if decoder.More() {
+		return record, errors.New("expected one object per line")
+	}
+
+	switch {
+	case record.Key == nil:
+		return record, errors.New("missing key")
+	case record.Value == nil:
+		return record, errors.New("missing value")
+	case record.Type == "":
+		return record, errors.New("missing type")
+	case record.Type != "string":
+		return record, fmt.Errorf("unsupported type '%s', only strings are supported", record.Type)
+	case record.TTL < 0 || record.TTL > math.MaxInt64/int64(time.Millisecond):
+		return record, errors.New("invalid ttl")
+	}
+	return record, nil
+}
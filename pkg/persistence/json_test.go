@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJSON(t *testing.T) {
+	now := time.Now()
+	store := newStore("b", "2", "a", "1", "other", "3")
+	store.SetWithExpiry("expiring", []byte("4"), now.Add(90*time.Second))
+	store.Set("binary", []byte{0xff, 0})
+
+	var buf bytes.Buffer
+	count, err := WriteJSON(&buf, store, "", now)
+	if err != nil {
+		t.Fatalf("WriteJSON() returned error: %v", err)
+	}
+	expected := `{"key":"a","type":"string","value":"1"}
+{"key":"b","type":"string","value":"2"}
+{"key":"binary","type":"string","value":{"base64":"/wA="}}
+{"key":"expiring","type":"string","value":"4","ttl":90000}
+{"key":"other","type":"string","value":"3"}
+`
+	if count != 5 || buf.String() != expected {
+		t.Errorf("Expected 5 keys:\n%s\ngot %d:\n%s", expected, count, buf.String())
+	}
+
+	buf.Reset()
+	if count, _ := WriteJSON(&buf, store, "[ab]", now); count != 2 || strings.Count(buf.String(), "\n") != 2 {
+		t.Errorf("Expected 2 keys matching the pattern, got %d: %q", count, buf.String())
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	data := `{"key":"a","type":"string","value":"1"}
+
+{"key":{"base64":"/wA="},"type":"string","value":"","ttl":1500}
+{"key":"c","type":"string","value":"<&>"}`
+
+	records, err := ReadJSON(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadJSON() returned error: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	if string(records[1].Key) != "\xff\x00" || records[1].Value == nil || records[1].TTL != 1500 {
+		t.Errorf("Expected a binary key with an empty value and a TTL, got %+v", records[1])
+	}
+	now := time.Now()
+	if !records[0].ExpiresAt(now).IsZero() || !records[1].ExpiresAt(now).Equal(now.Add(1500*time.Millisecond)) {
+		t.Errorf("Expected expiries relative to the import, got %v and %v", records[0].ExpiresAt(now), records[1].ExpiresAt(now))
+	}
+
+	// Exported keys read back the same
+	var buf bytes.Buffer
+	store := newStore()
+	for _, record := range records {
+		store.SetWithExpiry(string(record.Key), record.Value, record.ExpiresAt(now))
+	}
+	WriteJSON(&buf, store, "", now)
+	again, err := ReadJSON(&buf)
+	if err != nil || len(again) != 3 || string(again[1].Value) != "<&>" || again[2].TTL != 1500 {
+		t.Errorf("Expected the records to read back, got %+v (%v)", again, err)
+	}
+}
+
+func TestReadJSON_Invalid(t *testing.T) {
+	valid := `{"key":"a","type":"string","value":"1"}` + "\n"
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"not json", "key=a", "line 1: "},
+		{"missing key", `{"type":"string","value":"1"}`, "line 1: missing key"},
+		{"missing value", `{"key":"a","type":"string"}`, "line 1: missing value"},
+		{"null value", `{"key":"a","type":"string","value":null}`, "line 1: missing value"},
+		{"missing type", `{"key":"a","value":"1"}`, "line 1: missing type"},
+		{"other type", valid + `{"key":"a","type":"list","value":"1"}`, "line 2: unsupported type 'list', only strings are supported"},
+		{"negative ttl", `{"key":"a","type":"string","value":"1","ttl":-1}`, "line 1: invalid ttl"},
+		{"ttl overflow", `{"key":"a","type":"string","value":"1","ttl":9223372036854775807}`, "line 1: invalid ttl"},
+		{"unknown field", `{"key":"a","type":"string","value":"1","db":1}`, "line 1: "},
+		{"bad base64", `{"key":"a","type":"string","value":{"base64":"!"}}`, "line 1: "},
+		{"two objects", valid[:len(valid)-1] + valid, "line 1: expected one object per line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := ReadJSON(strings.NewReader(tt.data))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+			if records != nil {
+				t.Errorf("Expected no records, got %d", len(records))
+			}
+		})
+	}
+}
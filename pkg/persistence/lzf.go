@@ -0,0 +1,122 @@
+package persistence
+
+import "errors"
+
+// LZF, as implemented by liblzf and used by Redis, encodes data as literal
+// runs and back references. A control byte below 32 starts a run of
+// control+1 literal bytes. Otherwise its top 3 bits are the reference
+// length minus 2 (7 meaning that a byte with the rest follows) and its low
+// 5 bits the high bits of the offset, whose low 8 bits come next.
+const (
+	lzfMaxLiteral = 32
+	lzfMaxOffset  = 1 << 13
+	lzfMaxMatch   = 264
+	lzfHashBits   = 14
+)
+
+// errLZF reports compressed data that cannot be decompressed
+var errLZF = errors.New("invalid LZF data")
+
+// lzfDecompress decompresses data into a buffer of the given length, which
+// must be exactly the decompressed length
+func lzfDecompress(data []byte, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+
+	for i := 0; i < len(data); {
+		control := int(data[i])
+		i++
+
+		if control < lzfMaxLiteral {
+			run := control + 1
+			if i+run > len(data) || len(out)+run > length {
+				return nil, errLZF
+			}
+			out = append(out, data[i:i+run]...)
+			i += run
+			continue
+		}
+
+		size := control >> 5
+		if size == 7 {
+			if i >= len(data) {
+				return nil, errLZF
+			}
+			size += int(data[i])
+			i++
+		}
+		if i >= len(data) {
+			return nil, errLZF
+		}
+		ref := len(out) - (control&0x1f)<<8 - int(data[i]) - 1
+		i++
+		size += 2
+
+		if ref < 0 || len(out)+size > length {
+			return nil, errLZF
+		}
+		// References may overlap the bytes they produce
+		for j := range size {
+			out = append(out, out[ref+j])
+		}
+	}
+
+	if len(out) != length {
+		return nil, errLZF
+	}
+	return out, nil
+}
+
+// lzfCompress compresses data, returning nil if the result would not be
+// smaller than max bytes
+func lzfCompress(data []byte, max int) []byte {
+	out := make([]byte, 0, max)
+	var table [1 << lzfHashBits]int
+
+	literal := 0
+	flush := func(end int) {
+		for literal < end {
+			run := min(end-literal, lzfMaxLiteral)
+			out = append(out, byte(run-1))
+			out = append(out, data[literal:literal+run]...)
+			literal += run
+		}
+	}
+
+	for i := 0; i+2 < len(data); {
+		hash := (uint32(data[i])<<16 | uint32(data[i+1])<<8 | uint32(data[i+2])) * 2654435761 >> (32 - lzfHashBits)
+		ref := table[hash] - 1
+		table[hash] = i + 1
+
+		offset := i - ref - 1
+		if ref < 0 || offset >= lzfMaxOffset ||
+			data[ref] != data[i] || data[ref+1] != data[i+1] || data[ref+2] != data[i+2] {
+			i++
+			continue
+		}
+
+		size := 3
+		for limit := min(len(data)-i, lzfMaxMatch); size < limit && data[ref+size] == data[i+size]; size++ {
+		}
+
+		flush(i)
+		encoded := size - 2
+		if encoded < 7 {
+			out = append(out, byte(encoded<<5|offset>>8))
+		} else {
+			out = append(out, byte(7<<5|offset>>8), byte(encoded-7))
+		}
+		out = append(out, byte(offset))
+
+		i += size
+		literal = i
+		if len(out) >= max {
+			return nil
+		}
+	}
+	flush(len(data))
+
+	if len(out) >= max {
+		return nil
+	}
+	return out
+}
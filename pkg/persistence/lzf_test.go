@@ -0,0 +1,76 @@
+package persistence
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestLZFDecompress(t *testing.T) {
+	// A literal "a" and a back reference repeating it 9 times
+	data := []byte{0x00, 'a', 0xE0, 0x00, 0x00}
+
+	out, err := lzfDecompress(data, 10)
+	if err != nil {
+		t.Fatalf("lzfDecompress() returned error: %v", err)
+	}
+	if string(out) != "aaaaaaaaaa" {
+		t.Errorf("Expected 'aaaaaaaaaa', got %q", out)
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		length int
+	}{
+		{"wrong length", data, 11},
+		{"truncated literal", []byte{0x05, 'a'}, 6},
+		{"reference before start", []byte{0x20, 0x05}, 3},
+		{"missing offset", []byte{0x00, 'a', 0x20}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := lzfDecompress(tt.data, tt.length); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestLZFRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	noise := make([]byte, 4096)
+	random.Read(noise)
+
+	tests := []struct {
+		name         string
+		data         []byte
+		compressible bool
+	}{
+		{"repeated byte", bytes.Repeat([]byte("x"), 1000), true},
+		{"repeated text", bytes.Repeat([]byte("hello world, "), 500), true},
+		{"long match", append(bytes.Repeat([]byte("0123456789"), 100), noise[:100]...), true},
+		{"random", noise, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := lzfCompress(tt.data, len(tt.data)-4)
+			if compressed == nil {
+				if tt.compressible {
+					t.Fatal("Expected the data to compress")
+				}
+				return
+			}
+
+			out, err := lzfDecompress(compressed, len(tt.data))
+			if err != nil {
+				t.Fatalf("lzfDecompress() returned error: %v", err)
+			}
+			if !bytes.Equal(out, tt.data) {
+				t.Error("Expected the decompressed data to match")
+			}
+		})
+	}
+}
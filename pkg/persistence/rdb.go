@@ -0,0 +1,206 @@
+package persistence
+
+import (
+	"strconv"
+	"time"
+)
+
+// An RDB file is made of the "REDIS" magic and a 4-digit version, followed
+// by opcodes: AUX fields, SELECTDB and RESIZEDB, and keys, each optionally
+// preceded by its expiry, idle time or frequency, then a value type and
+// the encoded value. The file ends with EOF and a CRC-64 of everything
+// before it, 0 if checksums are disabled.
+const (
+	rdbMagic = "REDIS"
+	// RDBVersion is the version of the RDB files written. The encodings
+	// written are understood by Redis 5.0 and later.
+	RDBVersion = 9
+	// MaxRDBVersion is the newest RDB version that can be read (Redis 7.4)
+	MaxRDBVersion = 12
+)
+
+// Opcodes
+const (
+	rdbOpSlotInfo     byte = 0xF4
+	rdbOpFunction2    byte = 0xF5
+	rdbOpFunctionPre  byte = 0xF6
+	rdbOpModuleAux    byte = 0xF7
+	rdbOpIdle         byte = 0xF8
+	rdbOpFreq         byte = 0xF9
+	rdbOpAux          byte = 0xFA
+	rdbOpResizeDB     byte = 0xFB
+	rdbOpExpireTimeMs byte = 0xFC
+	rdbOpExpireTime   byte = 0xFD
+	rdbOpSelectDB     byte = 0xFE
+	rdbOpEOF          byte = 0xFF
+)
+
+// Value types, as encoded in the file
+const (
+	rdbTypeString              byte = 0
+	rdbTypeList                byte = 1
+	rdbTypeSet                 byte = 2
+	rdbTypeZSet                byte = 3
+	rdbTypeHash                byte = 4
+	rdbTypeZSet2               byte = 5
+	rdbTypeModulePreGA         byte = 6
+	rdbTypeModule2             byte = 7
+	rdbTypeHashZipmap          byte = 9
+	rdbTypeListZiplist         byte = 10
+	rdbTypeSetIntset           byte = 11
+	rdbTypeZSetZiplist         byte = 12
+	rdbTypeHashZiplist         byte = 13
+	rdbTypeListQuicklist       byte = 14
+	rdbTypeStreamListpacks     byte = 15
+	rdbTypeHashListpack        byte = 16
+	rdbTypeZSetListpack        byte = 17
+	rdbTypeListQuicklist2      byte = 18
+	rdbTypeStreamListpacks2    byte = 19
+	rdbTypeSetListpack         byte = 20
+	rdbTypeStreamListpacks3    byte = 21
+	rdbTypeHashMetadataPreGA   byte = 22
+	rdbTypeHashListpackExPreGA byte = 23
+	rdbTypeHashMetadata        byte = 24
+	rdbTypeHashListpackEx      byte = 25
+)
+
+// Length encodings. The two high bits of the first byte select a 6-bit or
+// 14-bit length, a 32-bit or 64-bit big-endian length in the following
+// bytes, or a special string encoding in the low 6 bits.
+const (
+	rdb6BitLen  = 0
+	rdb14BitLen = 1
+	rdb32BitLen = 0x80
+	rdb64BitLen = 0x81
+	rdbEncoded  = 3
+
+	rdbEncInt8  = 0
+	rdbEncInt16 = 1
+	rdbEncInt32 = 2
+	rdbEncLZF   = 3
+)
+
+// Module value opcodes, used to skip module values
+const (
+	rdbModuleOpEOF    = 0
+	rdbModuleOpSInt   = 1
+	rdbModuleOpUInt   = 2
+	rdbModuleOpFloat  = 3
+	rdbModuleOpDouble = 4
+	rdbModuleOpString = 5
+)
+
+// Type is the type of a value stored in an RDB file
+type Type byte
+
+// Value types. Streams and module values are recognised but their contents
+// are not decoded.
+const (
+	TypeString Type = iota
+	TypeList
+	TypeSet
+	TypeZSet
+	TypeHash
+	TypeStream
+	TypeModule
+)
+
+// String returns the type name, as reported by the TYPE command
+func (t Type) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeZSet:
+		return "zset"
+	case TypeHash:
+		return "hash"
+	case TypeStream:
+		return "stream"
+	case TypeModule:
+		return "module"
+	default:
+		return "unknown(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// Entry is a key read from or written to an RDB file. Only the field
+// matching Type is set.
+type Entry struct {
+	DB        int
+	Key       string
+	Type      Type
+	ExpiresAt time.Time
+	// Idle is the LRU idle time in seconds and Freq the LFU counter, -1
+	// when not saved
+	Idle int64
+	Freq int
+
+	String []byte
+	// List holds the elements of lists and sets
+	List [][]byte
+	Hash []HashField
+	ZSet []ZSetMember
+}
+
+// HashField is a field of a hash
+type HashField struct {
+	Field []byte
+	Value []byte
+	// ExpiresAt is the field's expiry (Redis 7.4), zero if it has none
+	ExpiresAt time.Time
+}
+
+// ZSetMember is a member of a sorted set
+type ZSetMember struct {
+	Member []byte
+	Score  float64
+}
+
+// AuxField is an AUX field of an RDB file, such as redis-ver or ctime
+type AuxField struct {
+	Key   string
+	Value string
+}
+
+// crc64Table is the table of the CRC-64 used by Redis: the Jones
+// polynomial, reflected, with no initial or final XOR. Go's hash/crc64
+// inverts the CRC before and after, so it cannot be used directly.
+var crc64Table = func() *[256]uint64 {
+	const poly = 0x95ac9329ac4bc9b5
+	var table [256]uint64
+	for i := range table {
+		crc := uint64(i)
+		for range 8 {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ poly
+			} else {
+				crc >>= 1
+			}
+		}
+		table[i] = crc
+	}
+	return &table
+}()
+
+// crc64 computes the Redis CRC-64 of the data written to it
+type crc64 struct {
+	sum uint64
+}
+
+func (c *crc64) Write(p []byte) (int, error) {
+	sum := c.sum
+	for _, b := range p {
+		sum = crc64Table[byte(sum)^b] ^ sum>>8
+	}
+	c.sum = sum
+	return len(p), nil
+}
+
+// Sum64 returns the CRC of the data written so far
+func (c *crc64) Sum64() uint64 {
+	return c.sum
+}
@@ -0,0 +1,755 @@
+package persistence
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ErrNotRDB is returned when reading a file that is not an RDB file
+var ErrNotRDB = errors.New("not an RDB file")
+
+// CorruptError reports invalid data found while reading a file, with the
+// offset of the first byte that could not be read
+type CorruptError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("corrupt data at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *CorruptError) Unwrap() error {
+	return e.Err
+}
+
+// RDBInfo describes an RDB file read by ReadRDB
+type RDBInfo struct {
+	Version int
+	Aux     []AuxField
+	// Checksum is the CRC-64 ending the file, 0 if it was written without
+	Checksum uint64
+	Keys     int
+}
+
+// ReadRDB reads an RDB file from r, calling visit for each key, and
+// verifies its checksum. visit may keep the entries it is given.
+// Invalid data is reported as a *CorruptError; visit may have been called
+// for the keys before it.
+func ReadRDB(r io.Reader, visit func(*Entry) error) (RDBInfo, error) {
+	in := &rdbReader{reader: bufio.NewReaderSize(r, 64*1024)}
+	var info RDBInfo
+
+	header := make([]byte, len(rdbMagic)+4)
+	if _, err := io.ReadFull(in, header); err != nil || string(header[:len(rdbMagic)]) != rdbMagic {
+		return info, ErrNotRDB
+	}
+	version, err := strconv.Atoi(string(header[len(rdbMagic):]))
+	if err != nil {
+		return info, ErrNotRDB
+	}
+	if version < 1 || version > MaxRDBVersion {
+		return info, &CorruptError{Offset: int64(len(rdbMagic)), Err: fmt.Errorf("can't handle RDB format version %d", version)}
+	}
+	info.Version = version
+
+	db := 0
+	entry := &Entry{Idle: -1, Freq: -1}
+	for {
+		offset := in.offset
+		op, err := in.ReadByte()
+		if err != nil {
+			return info, in.corrupt(offset, err)
+		}
+
+		switch op {
+		case rdbOpExpireTimeMs:
+			var ms int64
+			ms, err = in.readInt64()
+			entry.ExpiresAt = time.UnixMilli(ms)
+		case rdbOpExpireTime:
+			var buf [4]byte
+			_, err = io.ReadFull(in, buf[:])
+			entry.ExpiresAt = time.Unix(int64(int32(binary.LittleEndian.Uint32(buf[:]))), 0)
+		case rdbOpIdle:
+			var idle uint64
+			idle, err = in.readLength()
+			entry.Idle = int64(min(idle, math.MaxInt64))
+		case rdbOpFreq:
+			var freq byte
+			freq, err = in.ReadByte()
+			entry.Freq = int(freq)
+		case rdbOpAux:
+			var key, value []byte
+			if key, err = in.readString(); err == nil {
+				value, err = in.readString()
+			}
+			info.Aux = append(info.Aux, AuxField{Key: string(key), Value: string(value)})
+		case rdbOpResizeDB:
+			if _, err = in.readLength(); err == nil {
+				_, err = in.readLength()
+			}
+		case rdbOpSelectDB:
+			var n uint64
+			n, err = in.readLength()
+			db = int(min(n, math.MaxInt32))
+		case rdbOpSlotInfo:
+			for i := 0; i < 3 && err == nil; i++ {
+				_, err = in.readLength()
+			}
+		case rdbOpModuleAux:
+			err = in.skipModuleAux()
+		case rdbOpFunction2:
+			_, err = in.readString()
+		case rdbOpFunctionPre:
+			err = errors.New("functions saved by Redis 7.0 release candidates are not supported")
+		case rdbOpEOF:
+			if version >= 5 {
+				info.Checksum, err = in.readChecksum()
+			}
+			if err != nil {
+				return info, in.corrupt(in.offset, err)
+			}
+			return info, nil
+		default:
+			if !rdbValueTypes[op] {
+				return info, &CorruptError{Offset: offset, Err: fmt.Errorf("unsupported value type %d", op)}
+			}
+
+			var key []byte
+			if key, err = in.readString(); err == nil {
+				entry.DB = db
+				entry.Key = string(key)
+				err = in.readValue(op, entry)
+			}
+			if err != nil {
+				return info, in.corrupt(offset, err)
+			}
+
+			if err := visit(entry); err != nil {
+				return info, err
+			}
+			info.Keys++
+			entry = &Entry{Idle: -1, Freq: -1}
+			continue
+		}
+
+		if err != nil {
+			return info, in.corrupt(offset, err)
+		}
+	}
+}
+
+// rdbValueTypes are the value types that can be read
+var rdbValueTypes = map[byte]bool{
+	rdbTypeString: true, rdbTypeList: true, rdbTypeSet: true, rdbTypeZSet: true,
+	rdbTypeHash: true, rdbTypeZSet2: true, rdbTypeModule2: true, rdbTypeHashZipmap: true,
+	rdbTypeListZiplist: true, rdbTypeSetIntset: true, rdbTypeZSetZiplist: true,
+	rdbTypeHashZiplist: true, rdbTypeListQuicklist: true, rdbTypeStreamListpacks: true,
+	rdbTypeHashListpack: true, rdbTypeZSetListpack: true, rdbTypeListQuicklist2: true,
+	rdbTypeStreamListpacks2: true, rdbTypeSetListpack: true, rdbTypeStreamListpacks3: true,
+	rdbTypeHashMetadata: true, rdbTypeHashListpackEx: true,
+}
+
+// rdbReader reads an RDB file while computing its checksum and tracking
+// the offset reached
+type rdbReader struct {
+	reader *bufio.Reader
+	crc    crc64
+	offset int64
+}
+
+func (r *rdbReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.crc.Write(p[:n])
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *rdbReader) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.crc.Write([]byte{b})
+		r.offset++
+	}
+	return b, err
+}
+
+// corrupt wraps an error in a *CorruptError. Premature ends of file are
+// reported at the offset reached, other errors at the offset of the
+// record that failed.
+func (r *rdbReader) corrupt(offset int64, err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &CorruptError{Offset: r.offset, Err: io.ErrUnexpectedEOF}
+	}
+	return &CorruptError{Offset: offset, Err: err}
+}
+
+// readChecksum reads the checksum ending the file and verifies it, unless
+// it is 0, meaning that the file was written without checksum
+func (r *rdbReader) readChecksum() (uint64, error) {
+	expected := r.crc.Sum64()
+	var buf [8]byte
+	if _, err := io.ReadFull(r.reader, buf[:]); err != nil {
+		return 0, err
+	}
+
+	checksum := binary.LittleEndian.Uint64(buf[:])
+	if checksum != 0 && checksum != expected {
+		return checksum, errors.New("wrong RDB checksum")
+	}
+	return checksum, nil
+}
+
+func (r *rdbReader) readInt64() (int64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(buf[:])), nil
+}
+
+// readEncodedLength reads a length, or reports that a special string
+// encoding was found instead and returns it
+func (r *rdbReader) readEncodedLength() (uint64, bool, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch b >> 6 {
+	case rdb6BitLen:
+		return uint64(b & 0x3f), false, nil
+	case rdb14BitLen:
+		next, err := r.ReadByte()
+		return uint64(b&0x3f)<<8 | uint64(next), false, err
+	case rdbEncoded:
+		return uint64(b & 0x3f), true, nil
+	}
+
+	switch b {
+	case rdb32BitLen:
+		var buf [4]byte
+		_, err := io.ReadFull(r, buf[:])
+		return uint64(binary.BigEndian.Uint32(buf[:])), false, err
+	case rdb64BitLen:
+		var buf [8]byte
+		_, err := io.ReadFull(r, buf[:])
+		return binary.BigEndian.Uint64(buf[:]), false, err
+	}
+	return 0, false, fmt.Errorf("unknown length encoding 0x%02x", b)
+}
+
+// readLength reads a length that must not be a special encoding
+func (r *rdbReader) readLength() (uint64, error) {
+	length, encoded, err := r.readEncodedLength()
+	if err == nil && encoded {
+		err = fmt.Errorf("unexpected string encoding %d", length)
+	}
+	return length, err
+}
+
+// readString reads a string, which may be stored as an integer or
+// LZF-compressed
+func (r *rdbReader) readString() ([]byte, error) {
+	length, encoded, err := r.readEncodedLength()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		return r.readBytes(length)
+	}
+
+	switch length {
+	case rdbEncInt8, rdbEncInt16, rdbEncInt32:
+		buf := make([]byte, 1<<length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(littleEndianInt(buf), 10)), nil
+
+	case rdbEncLZF:
+		compressed, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		// A back reference expands at most 3 bytes into lzfMaxMatch, so a
+		// larger size can only come from corruption
+		if size > compressed*lzfMaxMatch {
+			return nil, errLZF
+		}
+		data, err := r.readBytes(compressed)
+		if err != nil {
+			return nil, err
+		}
+		return lzfDecompress(data, int(size))
+	}
+	return nil, fmt.Errorf("unknown string encoding %d", length)
+}
+
+// readBytes reads length bytes. Long strings are read as they arrive
+// rather than allocated upfront, so that a corrupt length fails at the end
+// of the file instead of allocating a huge buffer.
+func (r *rdbReader) readBytes(length uint64) ([]byte, error) {
+	if length <= 64*1024 {
+		data := make([]byte, length)
+		_, err := io.ReadFull(r, data)
+		return data, err
+	}
+
+	var data bytes.Buffer
+	n, err := io.CopyN(&data, r, int64(min(length, math.MaxInt64)))
+	if err == nil && uint64(n) != length {
+		err = io.ErrUnexpectedEOF
+	}
+	return data.Bytes(), err
+}
+
+// readStrings reads count strings. The slice grows as strings are read,
+// so that a corrupt count does not allocate a huge slice.
+func (r *rdbReader) readStrings(count uint64) ([][]byte, error) {
+	elements := make([][]byte, 0, min(count, 1024))
+	for range count {
+		element, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
+
+// readDouble reads a score as saved by RDB versions before 8: a length
+// byte, with 253 to 255 standing for NaN, +inf and -inf, then the number
+// in decimal
+func (r *rdbReader) readDouble() (float64, error) {
+	length, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch length {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(buf), 64)
+}
+
+// readBinaryDouble reads a score as an IEEE 754 little-endian double
+func (r *rdbReader) readBinaryDouble() (float64, error) {
+	n, err := r.readInt64()
+	return math.Float64frombits(uint64(n)), err
+}
+
+// readEncoded reads a string holding a compact encoding and decodes it
+func (r *rdbReader) readEncoded(decode func([]byte) ([][]byte, error)) ([][]byte, error) {
+	data, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// readValue reads a value of the given type into entry
+func (r *rdbReader) readValue(valueType byte, entry *Entry) error {
+	var err error
+	switch valueType {
+	case rdbTypeString:
+		entry.Type = TypeString
+		entry.String, err = r.readString()
+
+	case rdbTypeList, rdbTypeSet:
+		entry.Type = TypeList
+		if valueType == rdbTypeSet {
+			entry.Type = TypeSet
+		}
+		var count uint64
+		if count, err = r.readLength(); err == nil {
+			entry.List, err = r.readStrings(count)
+		}
+
+	case rdbTypeListZiplist:
+		entry.Type = TypeList
+		entry.List, err = r.readEncoded(decodeZiplist)
+
+	case rdbTypeListQuicklist, rdbTypeListQuicklist2:
+		entry.Type = TypeList
+		entry.List, err = r.readQuicklist(valueType == rdbTypeListQuicklist2)
+
+	case rdbTypeSetIntset:
+		entry.Type = TypeSet
+		entry.List, err = r.readEncoded(decodeIntset)
+
+	case rdbTypeSetListpack:
+		entry.Type = TypeSet
+		entry.List, err = r.readEncoded(decodeListpack)
+
+	case rdbTypeZSet, rdbTypeZSet2:
+		entry.Type = TypeZSet
+		entry.ZSet, err = r.readZSet(valueType == rdbTypeZSet2)
+
+	case rdbTypeZSetZiplist, rdbTypeZSetListpack:
+		entry.Type = TypeZSet
+		decode := decodeZiplist
+		if valueType == rdbTypeZSetListpack {
+			decode = decodeListpack
+		}
+		var elements [][]byte
+		if elements, err = r.readEncoded(decode); err == nil {
+			entry.ZSet, err = zsetMembers(elements)
+		}
+
+	case rdbTypeHash, rdbTypeHashMetadata:
+		entry.Type = TypeHash
+		entry.Hash, err = r.readHash(valueType == rdbTypeHashMetadata)
+
+	case rdbTypeHashZipmap, rdbTypeHashZiplist, rdbTypeHashListpack:
+		entry.Type = TypeHash
+		decode := map[byte]func([]byte) ([][]byte, error){
+			rdbTypeHashZipmap:   decodeZipmap,
+			rdbTypeHashZiplist:  decodeZiplist,
+			rdbTypeHashListpack: decodeListpack,
+		}[valueType]
+		var elements [][]byte
+		if elements, err = r.readEncoded(decode); err == nil {
+			entry.Hash, err = hashFields(elements, false, 0)
+		}
+
+	case rdbTypeHashListpackEx:
+		entry.Type = TypeHash
+		var minExpire int64
+		var elements [][]byte
+		if minExpire, err = r.readInt64(); err == nil {
+			if elements, err = r.readEncoded(decodeListpack); err == nil {
+				entry.Hash, err = hashFields(elements, true, minExpire)
+			}
+		}
+
+	case rdbTypeStreamListpacks, rdbTypeStreamListpacks2, rdbTypeStreamListpacks3:
+		entry.Type = TypeStream
+		err = r.skipStream(valueType)
+
+	case rdbTypeModule2:
+		entry.Type = TypeModule
+		if _, err = r.readLength(); err == nil {
+			err = r.skipModuleValue()
+		}
+
+	default:
+		err = fmt.Errorf("unsupported value type %d", valueType)
+	}
+	return err
+}
+
+// readQuicklist reads a quicklist: nodes holding ziplists, or for version
+// 2, listpacks or single plain elements
+func (r *rdbReader) readQuicklist(version2 bool) ([][]byte, error) {
+	nodes, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	var elements [][]byte
+	for range nodes {
+		container := uint64(2)
+		if version2 {
+			if container, err = r.readLength(); err != nil {
+				return nil, err
+			}
+		}
+
+		data, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case container == 1:
+			elements = append(elements, data)
+		case container != 2:
+			return nil, fmt.Errorf("unknown quicklist container %d", container)
+		default:
+			decode := decodeZiplist
+			if version2 {
+				decode = decodeListpack
+			}
+			node, err := decode(data)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, node...)
+		}
+	}
+	return elements, nil
+}
+
+// readZSet reads the members and scores of a sorted set
+func (r *rdbReader) readZSet(binaryScores bool) ([]ZSetMember, error) {
+	count, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]ZSetMember, 0, min(count, 1024))
+	for range count {
+		member, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+
+		var score float64
+		if binaryScores {
+			score, err = r.readBinaryDouble()
+		} else {
+			score, err = r.readDouble()
+		}
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, ZSetMember{Member: member, Score: score})
+	}
+	return members, nil
+}
+
+// readHash reads the fields of a hash. With metadata (Redis 7.4 field
+// expiry), the fields are preceded by the earliest expiry and each by its
+// expiry relative to it, plus one, or 0 if it has none.
+func (r *rdbReader) readHash(metadata bool) ([]HashField, error) {
+	var minExpire int64
+	var err error
+	if metadata {
+		if minExpire, err = r.readInt64(); err != nil {
+			return nil, err
+		}
+	}
+
+	count, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]HashField, 0, min(count, 1024))
+	for range count {
+		var field HashField
+		if metadata {
+			ttl, err := r.readLength()
+			if err != nil {
+				return nil, err
+			}
+			if ttl != 0 {
+				field.ExpiresAt = time.UnixMilli(minExpire + int64(ttl) - 1)
+			}
+		}
+
+		if field.Field, err = r.readString(); err != nil {
+			return nil, err
+		}
+		if field.Value, err = r.readString(); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// zsetMembers pairs the members and scores of a compact sorted set
+func zsetMembers(elements [][]byte) ([]ZSetMember, error) {
+	if len(elements)%2 != 0 {
+		return nil, errors.New("sorted set with an odd number of elements")
+	}
+
+	members := make([]ZSetMember, 0, len(elements)/2)
+	for i := 0; i < len(elements); i += 2 {
+		score, err := strconv.ParseFloat(string(elements[i+1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid score %q", elements[i+1])
+		}
+		members = append(members, ZSetMember{Member: elements[i], Score: score})
+	}
+	return members, nil
+}
+
+// hashFields pairs the fields and values of a compact hash. With expiry,
+// each pair is followed by the field's expiry in Unix milliseconds, 0 if
+// it has none.
+func hashFields(elements [][]byte, withExpiry bool, minExpire int64) ([]HashField, error) {
+	width := 2
+	if withExpiry {
+		width = 3
+	}
+	if len(elements)%width != 0 {
+		return nil, errors.New("hash with a missing value")
+	}
+
+	fields := make([]HashField, 0, len(elements)/width)
+	for i := 0; i < len(elements); i += width {
+		field := HashField{Field: elements[i], Value: elements[i+1]}
+		if withExpiry {
+			ms, err := strconv.ParseInt(string(elements[i+2]), 10, 64)
+			if err != nil || (ms != 0 && ms < minExpire) {
+				return nil, fmt.Errorf("invalid field expiry %q", elements[i+2])
+			}
+			if ms != 0 {
+				field.ExpiresAt = time.UnixMilli(ms)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// skip reads and discards n bytes
+func (r *rdbReader) skip(n int64) error {
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
+
+// skipLengths reads and discards count lengths
+func (r *rdbReader) skipLengths(count int) error {
+	for range count {
+		if _, err := r.readLength(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipStream reads and discards a stream: its listpacks, metadata,
+// consumer groups and their pending entries. Version 2 adds the first ID,
+// the maximal deleted ID and the entries added to the metadata and the
+// entries read to groups, and version 3 the consumers' active time.
+func (r *rdbReader) skipStream(valueType byte) error {
+	nodes, err := r.readLength()
+	if err != nil {
+		return err
+	}
+	for range 2 * nodes {
+		if _, err := r.readString(); err != nil {
+			return err
+		}
+	}
+
+	// Length and last ID, then the version 2 metadata
+	metadata := 3
+	if valueType >= rdbTypeStreamListpacks2 {
+		metadata += 5
+	}
+	if err := r.skipLengths(metadata); err != nil {
+		return err
+	}
+
+	groups, err := r.readLength()
+	if err != nil {
+		return err
+	}
+	for range groups {
+		if _, err := r.readString(); err != nil {
+			return err
+		}
+		fields := 2
+		if valueType >= rdbTypeStreamListpacks2 {
+			fields++
+		}
+		if err := r.skipLengths(fields); err != nil {
+			return err
+		}
+
+		// Pending entries: ID, delivery time and delivery count
+		pending, err := r.readLength()
+		if err != nil {
+			return err
+		}
+		for range pending {
+			if err := r.skip(16 + 8); err != nil {
+				return err
+			}
+			if _, err := r.readLength(); err != nil {
+				return err
+			}
+		}
+
+		consumers, err := r.readLength()
+		if err != nil {
+			return err
+		}
+		for range consumers {
+			if _, err := r.readString(); err != nil {
+				return err
+			}
+			times := int64(8)
+			if valueType >= rdbTypeStreamListpacks3 {
+				times += 8
+			}
+			if err := r.skip(times); err != nil {
+				return err
+			}
+
+			// The consumer's pending IDs
+			pending, err := r.readLength()
+			if err != nil {
+				return err
+			}
+			if err := r.skip(16 * int64(min(pending, math.MaxInt64/16))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skipModuleAux reads and discards module AUX data: the module ID, when
+// it was saved, and the data
+func (r *rdbReader) skipModuleAux() error {
+	if err := r.skipLengths(3); err != nil {
+		return err
+	}
+	return r.skipModuleValue()
+}
+
+// skipModuleValue reads and discards a module value saved with typed
+// opcodes, up to its EOF opcode
+func (r *rdbReader) skipModuleValue() error {
+	for {
+		opcode, err := r.readLength()
+		if err != nil {
+			return err
+		}
+
+		switch opcode {
+		case rdbModuleOpEOF:
+			return nil
+		case rdbModuleOpSInt, rdbModuleOpUInt:
+			_, err = r.readLength()
+		case rdbModuleOpFloat:
+			err = r.skip(4)
+		case rdbModuleOpDouble:
+			err = r.skip(8)
+		case rdbModuleOpString:
+			_, err = r.readString()
+		default:
+			err = fmt.Errorf("unknown module opcode %d", opcode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package persistence
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// rawRDB builds an RDB file of the given version, with build writing the
+// opcodes between the header and the end of the file
+func rawRDB(version int, build func(w *RDBWriter)) []byte {
+	var buf bytes.Buffer
+	w := NewRDBWriter(&buf, RDBOptions{Checksum: true})
+	fmt.Fprintf(w.out, "%s%04d", rdbMagic, version)
+	build(w)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestCRC64(t *testing.T) {
+	// The check value of the Redis CRC-64
+	var crc crc64
+	crc.Write([]byte("123456789"))
+	if crc.Sum64() != 0xe9c6d914c4b8d9ca {
+		t.Errorf("Expected 0xe9c6d914c4b8d9ca, got %#x", crc.Sum64())
+	}
+}
+
+func TestRDB_RoundTrip(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	written := []*Entry{
+		{Key: "string", Type: TypeString, String: []byte("value"), Idle: -1, Freq: -1},
+		{Key: "list", Type: TypeList, List: [][]byte{[]byte("a"), []byte("12"), []byte("")}, Idle: 30, Freq: -1},
+		{Key: "set", Type: TypeSet, List: [][]byte{[]byte("x"), []byte("y")}, Idle: -1, Freq: 5},
+		{Key: "zset", Type: TypeZSet, ZSet: []ZSetMember{
+			{Member: []byte("one"), Score: 1.5},
+			{Member: []byte("low"), Score: math.Inf(-1)},
+		}, Idle: -1, Freq: -1},
+		{Key: "hash", Type: TypeHash, Hash: []HashField{
+			{Field: []byte("f"), Value: []byte("v")},
+		}, ExpiresAt: expiresAt, Idle: -1, Freq: -1},
+	}
+
+	var buf bytes.Buffer
+	w := NewRDBWriter(&buf, RDBOptions{Compression: true, Checksum: true})
+	w.WriteHeader(AuxField{Key: "redis-ver", Value: "7.2.0"})
+	w.SelectDB(2)
+	for _, entry := range written {
+		if err := w.WriteEntry(entry); err != nil {
+			t.Fatalf("WriteEntry() returned error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	var read []*Entry
+	info, err := ReadRDB(&buf, func(entry *Entry) error {
+		read = append(read, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadRDB() returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(info.Aux, []AuxField{{Key: "redis-ver", Value: "7.2.0"}}) {
+		t.Errorf("Expected redis-ver AUX field, got %v", info.Aux)
+	}
+	for i, entry := range written {
+		entry.DB = 2
+		if !reflect.DeepEqual(read[i], entry) {
+			t.Errorf("Expected %+v, got %+v", entry, read[i])
+		}
+	}
+}
+
+func TestRDBWriter_Unsupported(t *testing.T) {
+	tests := []*Entry{
+		{Key: "stream", Type: TypeStream},
+		{Key: "hash", Type: TypeHash, Hash: []HashField{{Field: []byte("f"), ExpiresAt: time.Now()}}},
+	}
+
+	for _, entry := range tests {
+		w := NewRDBWriter(&bytes.Buffer{}, RDBOptions{})
+		if err := w.WriteEntry(entry); err == nil {
+			t.Errorf("Expected an error writing %s", entry.Key)
+		}
+		if err := w.Close(); err == nil {
+			t.Errorf("Expected Close to return the error writing %s", entry.Key)
+		}
+	}
+}
+
+func TestRDBWriter_IntegerStrings(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []byte
+	}{
+		{"12", []byte{0xC0, 12}},
+		{"-300", []byte{0xC1, 0xD4, 0xFE}},
+		{"100000", []byte{0xC2, 0xA0, 0x86, 0x01, 0x00}},
+		{"007", []byte{3, '0', '0', '7'}},
+		{"12345678901", append([]byte{11}, "12345678901"...)},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := NewRDBWriter(&buf, RDBOptions{})
+		w.writeString([]byte(tt.value))
+		w.out.Flush()
+		if !bytes.Equal(buf.Bytes(), tt.expected) {
+			t.Errorf("Expected %q encoded as %x, got %x", tt.value, tt.expected, buf.Bytes())
+		}
+	}
+}
+
+func TestReadRDB_CompactEncodings(t *testing.T) {
+	intset := binary.LittleEndian.AppendUint32(nil, 2)
+	intset = binary.LittleEndian.AppendUint32(intset, 2)
+	intset = binary.LittleEndian.AppendUint16(intset, 7)
+	intset = binary.LittleEndian.AppendUint16(intset, 9)
+	minExpire := time.Now().Add(time.Hour).UnixMilli()
+
+	data := rawRDB(12, func(w *RDBWriter) {
+		key := func(valueType byte, name string) {
+			w.out.WriteByte(valueType)
+			w.writeString([]byte(name))
+		}
+
+		key(rdbTypeListZiplist, "ziplist")
+		w.writeString(ziplist("a", 1))
+
+		key(rdbTypeListQuicklist, "quicklist")
+		w.writeLength(2)
+		w.writeString(ziplist("a"))
+		w.writeString(ziplist("b", "c"))
+
+		key(rdbTypeListQuicklist2, "quicklist2")
+		w.writeLength(2)
+		w.writeLength(2)
+		w.writeString(listpack("a", -5))
+		w.writeLength(1)
+		w.writeString([]byte("plain"))
+
+		key(rdbTypeSetIntset, "intset")
+		w.writeString(intset)
+
+		key(rdbTypeSetListpack, "setlistpack")
+		w.writeString(listpack("m", "n"))
+
+		key(rdbTypeZSetZiplist, "zsetziplist")
+		w.writeString(ziplist("m", 1, "n", "2.5"))
+
+		key(rdbTypeZSetListpack, "zsetlistpack")
+		w.writeString(listpack("m", "inf"))
+
+		key(rdbTypeZSet, "zsetv1")
+		w.writeLength(2)
+		w.writeString([]byte("m"))
+		w.out.Write([]byte{3, '1', '.', '5'})
+		w.writeString([]byte("n"))
+		w.out.WriteByte(254)
+
+		key(rdbTypeHashZiplist, "hashziplist")
+		w.writeString(ziplist("f", "v"))
+
+		key(rdbTypeHashListpack, "hashlistpack")
+		w.writeString(listpack("f", 1))
+
+		key(rdbTypeHashZipmap, "zipmap")
+		w.writeString([]byte{1, 1, 'f', 1, 0, 'v', 0xFF})
+
+		key(rdbTypeHashMetadata, "hashmetadata")
+		w.writeInt64(minExpire)
+		w.writeLength(2)
+		w.writeLength(0)
+		w.writeString([]byte("f"))
+		w.writeString([]byte("v"))
+		w.writeLength(11)
+		w.writeString([]byte("g"))
+		w.writeString([]byte("w"))
+
+		key(rdbTypeHashListpackEx, "hashlistpackex")
+		w.writeInt64(minExpire)
+		w.writeString(listpack("f", "v", 0, "g", "w", int(minExpire)))
+
+		w.out.WriteByte(rdbOpExpireTime)
+		w.out.Write(binary.LittleEndian.AppendUint32(nil, 2000000000))
+		key(rdbTypeString, "seconds")
+		w.writeString([]byte("s"))
+	})
+
+	entries, info := decode(t, data)
+	if info.Keys != 14 {
+		t.Errorf("Expected 14 keys, got %d", info.Keys)
+	}
+
+	lists := map[string][]string{
+		"ziplist":     {"a", "1"},
+		"quicklist":   {"a", "b", "c"},
+		"quicklist2":  {"a", "-5", "plain"},
+		"intset":      {"7", "9"},
+		"setlistpack": {"m", "n"},
+	}
+	for key, expected := range lists {
+		if got := strs(entries[key].List); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, got)
+		}
+	}
+
+	zsets := map[string][]ZSetMember{
+		"zsetziplist":  {{Member: []byte("m"), Score: 1}, {Member: []byte("n"), Score: 2.5}},
+		"zsetlistpack": {{Member: []byte("m"), Score: math.Inf(1)}},
+		"zsetv1":       {{Member: []byte("m"), Score: 1.5}, {Member: []byte("n"), Score: math.Inf(1)}},
+	}
+	for key, expected := range zsets {
+		if got := entries[key].ZSet; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, got)
+		}
+	}
+
+	for _, key := range []string{"hashziplist", "hashlistpack", "zipmap"} {
+		hash := entries[key].Hash
+		if len(hash) != 1 || string(hash[0].Field) != "f" {
+			t.Errorf("Expected %s to have field f, got %v", key, hash)
+		}
+	}
+
+	for _, key := range []string{"hashmetadata", "hashlistpackex"} {
+		hash := entries[key].Hash
+		if len(hash) != 2 || !hash[0].ExpiresAt.IsZero() {
+			t.Fatalf("Expected %s to have 2 fields, the first without expiry, got %v", key, hash)
+		}
+	}
+	if got := entries["hashmetadata"].Hash[1].ExpiresAt.UnixMilli(); got != minExpire+10 {
+		t.Errorf("Expected field expiry %d, got %d", minExpire+10, got)
+	}
+	if got := entries["hashlistpackex"].Hash[1].ExpiresAt.UnixMilli(); got != minExpire {
+		t.Errorf("Expected field expiry %d, got %d", minExpire, got)
+	}
+
+	if got := entries["seconds"].ExpiresAt; !got.Equal(time.Unix(2000000000, 0)) {
+		t.Errorf("Expected expiry in seconds, got %v", got)
+	}
+}
+
+func TestReadRDB_SkipsStreamsAndModules(t *testing.T) {
+	data := rawRDB(11, func(w *RDBWriter) {
+		w.out.WriteByte(rdbOpFunction2)
+		w.writeString([]byte("#!lua name=lib"))
+
+		w.out.WriteByte(rdbOpModuleAux)
+		w.writeLength(12345)
+		w.writeLength(rdbModuleOpUInt)
+		w.writeLength(2)
+		w.writeLength(rdbModuleOpString)
+		w.writeString([]byte("aux"))
+		w.writeLength(rdbModuleOpEOF)
+
+		// A stream with one listpack node and one consumer group with a
+		// pending entry, owned by one consumer
+		w.out.WriteByte(rdbTypeStreamListpacks3)
+		w.writeString([]byte("stream"))
+		w.writeLength(1)
+		w.writeString(make([]byte, 16))
+		w.writeString(listpack("f", "v"))
+		for range 8 {
+			w.writeLength(1)
+		}
+		w.writeLength(1)
+		w.writeString([]byte("group"))
+		w.writeLength(1)
+		w.writeLength(0)
+		w.writeLength(1)
+		w.writeLength(1)
+		w.out.Write(make([]byte, 16+8))
+		w.writeLength(1)
+		w.writeLength(1)
+		w.writeString([]byte("consumer"))
+		w.out.Write(make([]byte, 8+8))
+		w.writeLength(1)
+		w.out.Write(make([]byte, 16))
+
+		w.out.WriteByte(rdbTypeModule2)
+		w.writeString([]byte("module"))
+		w.writeLength(12345)
+		w.writeLength(rdbModuleOpDouble)
+		w.writeInt64(0)
+		w.writeLength(rdbModuleOpFloat)
+		w.out.Write(make([]byte, 4))
+		w.writeLength(rdbModuleOpSInt)
+		w.writeLength(7)
+		w.writeLength(rdbModuleOpEOF)
+
+		w.out.WriteByte(rdbTypeString)
+		w.writeString([]byte("after"))
+		w.writeString([]byte("value"))
+	})
+
+	entries, _ := decode(t, data)
+	if entries["stream"].Type != TypeStream || entries["module"].Type != TypeModule {
+		t.Errorf("Expected a stream and a module value, got %v and %v", entries["stream"].Type, entries["module"].Type)
+	}
+	if string(entries["after"].String) != "value" {
+		t.Errorf("Expected the key after them to be read, got %q", entries["after"].String)
+	}
+}
+
+func TestReadRDB_Checksums(t *testing.T) {
+	data := encode(t, newStore("a", "1"), RDBOptions{})
+
+	// A file written without checksum ends with 0, which is not verified
+	if !bytes.HasSuffix(data, make([]byte, 8)) {
+		t.Errorf("Expected a zero checksum, got %x", data[len(data)-8:])
+	}
+	if _, info := decode(t, data); info.Checksum != 0 {
+		t.Errorf("Expected checksum 0, got %x", info.Checksum)
+	}
+
+	// Before version 5, files have no checksum
+	old := append([]byte("REDIS0004"), rdbTypeString, 1, 'k', 1, 'v', rdbOpEOF)
+	entries, _ := decode(t, old)
+	if string(entries["k"].String) != "v" {
+		t.Errorf("Expected k to be v, got %q", entries["k"].String)
+	}
+}
+
+func TestReadRDB_LZF(t *testing.T) {
+	value := bytes.Repeat([]byte("compressible "), 100)
+	data := rawRDB(9, func(w *RDBWriter) {
+		w.options.Compression = true
+		w.out.WriteByte(rdbTypeString)
+		w.writeString([]byte("key"))
+		w.writeString(value)
+	})
+
+	if !bytes.Contains(data, []byte{rdbEncoded<<6 | rdbEncLZF}) {
+		t.Error("Expected the value to be compressed")
+	}
+	entries, _ := decode(t, data)
+	if !bytes.Equal(entries["key"].String, value) {
+		t.Error("Expected the decompressed value to match")
+	}
+}
@@ -0,0 +1,239 @@
+package persistence
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// minCompressedLength is the length from which strings are compressed, as
+// in Redis: shorter ones rarely get smaller
+const minCompressedLength = 21
+
+// RDBOptions controls how RDB files are written
+type RDBOptions struct {
+	// Compression LZF-compresses long strings (rdbcompression)
+	Compression bool
+	// Checksum ends the file with a CRC-64 rather than 0 (rdbchecksum)
+	Checksum bool
+}
+
+// RDBWriter writes an RDB file: WriteHeader, then SelectDB and WriteEntry
+// for each database, then Close. The first error is returned by every
+// later call.
+type RDBWriter struct {
+	w       io.Writer
+	out     *bufio.Writer
+	crc     crc64
+	options RDBOptions
+	err     error
+}
+
+// NewRDBWriter creates a writer of an RDB file to w
+func NewRDBWriter(w io.Writer, options RDBOptions) *RDBWriter {
+	writer := &RDBWriter{w: w, options: options}
+	writer.out = bufio.NewWriterSize(io.MultiWriter(w, &writer.crc), 64*1024)
+	return writer
+}
+
+// WriteHeader writes the magic, the version and the given AUX fields
+func (w *RDBWriter) WriteHeader(aux ...AuxField) error {
+	fmt.Fprintf(w.out, "%s%04d", rdbMagic, RDBVersion)
+	for _, field := range aux {
+		w.out.WriteByte(rdbOpAux)
+		w.writeString([]byte(field.Key))
+		w.writeString([]byte(field.Value))
+	}
+	return w.err
+}
+
+// SelectDB starts the keys of a database
+func (w *RDBWriter) SelectDB(db int) error {
+	w.out.WriteByte(rdbOpSelectDB)
+	w.writeLength(uint64(db))
+	return w.err
+}
+
+// WriteEntry writes a key with its expiry and value. Lists and sets are
+// written as plain lists, sorted sets with binary scores and hashes as
+// plain hashes, which every Redis since 5.0 loads. Streams, module values
+// and hash field expiry cannot be written.
+func (w *RDBWriter) WriteEntry(entry *Entry) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if !entry.ExpiresAt.IsZero() {
+		w.out.WriteByte(rdbOpExpireTimeMs)
+		w.writeInt64(entry.ExpiresAt.UnixMilli())
+	}
+	if entry.Idle >= 0 {
+		w.out.WriteByte(rdbOpIdle)
+		w.writeLength(uint64(entry.Idle))
+	}
+	if entry.Freq >= 0 {
+		w.out.WriteByte(rdbOpFreq)
+		w.out.WriteByte(byte(min(entry.Freq, 255)))
+	}
+
+	if err := w.writeValue(entry, []byte(entry.Key)); err != nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// writeValue writes the value type, then key if not nil, then the value
+func (w *RDBWriter) writeValue(entry *Entry, key []byte) error {
+	valueType, err := rdbValueType(entry)
+	if err != nil {
+		return err
+	}
+
+	w.out.WriteByte(valueType)
+	if key != nil {
+		w.writeString(key)
+	}
+
+	switch entry.Type {
+	case TypeString:
+		w.writeString(entry.String)
+	case TypeList, TypeSet:
+		w.writeLength(uint64(len(entry.List)))
+		for _, element := range entry.List {
+			w.writeString(element)
+		}
+	case TypeZSet:
+		w.writeLength(uint64(len(entry.ZSet)))
+		for _, member := range entry.ZSet {
+			w.writeString(member.Member)
+			w.writeInt64(int64(math.Float64bits(member.Score)))
+		}
+	case TypeHash:
+		w.writeLength(uint64(len(entry.Hash)))
+		for _, field := range entry.Hash {
+			w.writeString(field.Field)
+			w.writeString(field.Value)
+		}
+	}
+	return nil
+}
+
+// rdbValueType returns the type written for an entry
+func rdbValueType(entry *Entry) (byte, error) {
+	switch entry.Type {
+	case TypeString:
+		return rdbTypeString, nil
+	case TypeList:
+		return rdbTypeList, nil
+	case TypeSet:
+		return rdbTypeSet, nil
+	case TypeZSet:
+		return rdbTypeZSet2, nil
+	case TypeHash:
+		for _, field := range entry.Hash {
+			if !field.ExpiresAt.IsZero() {
+				return 0, errors.New("hash field expiry cannot be written")
+			}
+		}
+		return rdbTypeHash, nil
+	}
+	return 0, fmt.Errorf("%s values cannot be written", entry.Type)
+}
+
+// Close writes the end of the file and its checksum, and flushes it
+func (w *RDBWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	w.out.WriteByte(rdbOpEOF)
+	if err := w.out.Flush(); err != nil {
+		w.err = err
+		return err
+	}
+
+	var checksum [8]byte
+	if w.options.Checksum {
+		binary.LittleEndian.PutUint64(checksum[:], w.crc.Sum64())
+	}
+	_, w.err = w.w.Write(checksum[:])
+	return w.err
+}
+
+func (w *RDBWriter) writeInt64(n int64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(n))
+	w.out.Write(buf[:])
+}
+
+// writeLength writes a length in the shortest encoding
+func (w *RDBWriter) writeLength(n uint64) {
+	switch {
+	case n < 1<<6:
+		w.out.WriteByte(byte(n))
+	case n < 1<<14:
+		w.out.WriteByte(byte(n>>8) | rdb14BitLen<<6)
+		w.out.WriteByte(byte(n))
+	case n <= math.MaxUint32:
+		var buf [5]byte
+		buf[0] = rdb32BitLen
+		binary.BigEndian.PutUint32(buf[1:], uint32(n))
+		w.out.Write(buf[:])
+	default:
+		var buf [9]byte
+		buf[0] = rdb64BitLen
+		binary.BigEndian.PutUint64(buf[1:], n)
+		w.out.Write(buf[:])
+	}
+}
+
+// writeString writes a string as an integer if it is one, compressed if
+// enabled and worth it, or as is
+func (w *RDBWriter) writeString(s []byte) {
+	if value, ok := integerEncodable(s); ok {
+		switch {
+		case value >= math.MinInt8 && value <= math.MaxInt8:
+			w.out.Write([]byte{rdbEncoded<<6 | rdbEncInt8, byte(value)})
+		case value >= math.MinInt16 && value <= math.MaxInt16:
+			w.out.Write([]byte{rdbEncoded<<6 | rdbEncInt16, byte(value), byte(value >> 8)})
+		default:
+			var buf [5]byte
+			buf[0] = rdbEncoded<<6 | rdbEncInt32
+			binary.LittleEndian.PutUint32(buf[1:], uint32(int32(value)))
+			w.out.Write(buf[:])
+		}
+		return
+	}
+
+	// Like Redis, compression must save at least 4 bytes
+	if w.options.Compression && len(s) >= minCompressedLength {
+		if compressed := lzfCompress(s, len(s)-4); compressed != nil {
+			w.out.WriteByte(rdbEncoded<<6 | rdbEncLZF)
+			w.writeLength(uint64(len(compressed)))
+			w.writeLength(uint64(len(s)))
+			w.out.Write(compressed)
+			return
+		}
+	}
+
+	w.writeLength(uint64(len(s)))
+	w.out.Write(s)
+}
+
+// integerEncodable reports whether a string is the canonical decimal form
+// of a 32-bit integer, which can be stored as an integer and read back
+// unchanged
+func integerEncodable(s []byte) (int64, bool) {
+	if len(s) == 0 || len(s) > 11 {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(string(s), 10, 32)
+	if err != nil || strconv.FormatInt(value, 10) != string(s) {
+		return 0, false
+	}
+	return value, true
+}
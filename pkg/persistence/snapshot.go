@@ -0,0 +1,123 @@
+package persistence
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// Source is a keyspace that can be saved
+type Source interface {
+	Snapshot(visit func([]storage.Record) error) error
+}
+
+// WriteSnapshot writes an RDB snapshot of source to w and returns the
+// number of keys written
+func WriteSnapshot(w io.Writer, source Source, options RDBOptions, now time.Time) (int, error) {
+	writer := NewRDBWriter(w, options)
+	writer.WriteHeader(
+		AuxField{Key: "redis-bits", Value: strconv.Itoa(strconv.IntSize)},
+		AuxField{Key: "ctime", Value: strconv.FormatInt(now.Unix(), 10)},
+	)
+	writer.SelectDB(0)
+
+	count := 0
+	entry := &Entry{Type: TypeString, Idle: -1, Freq: -1}
+	err := source.Snapshot(func(records []storage.Record) error {
+		for _, record := range records {
+			entry.Key = record.Key
+			entry.String = record.Value
+			entry.ExpiresAt = record.ExpiresAt
+			if err := writer.WriteEntry(entry); err != nil {
+				return err
+			}
+		}
+		count += len(records)
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, writer.Close()
+}
+
+// SaveSnapshot atomically replaces the file at path with an RDB snapshot
+// of source and returns the number of keys saved
+func SaveSnapshot(path string, source Source, options RDBOptions) (int, error) {
+	count := 0
+	err := writeFileAtomic(path, func(w *bufio.Writer) error {
+		var err error
+		count, err = WriteSnapshot(w, source, options, time.Now())
+		return err
+	})
+	return count, err
+}
+
+// LoadStats counts the keys read from a snapshot
+type LoadStats struct {
+	Loaded int
+	// Expired counts the keys that expired since the snapshot was taken
+	Expired int
+	// Unsupported counts by type the keys that the store cannot hold,
+	// since it only holds strings
+	Unsupported map[Type]int
+	// OtherDB counts the keys of databases other than 0, the only one
+	// served
+	OtherDB int
+}
+
+// Skipped describes the keys that could not be loaded, such as "2 list,
+// 1 hash, 3 in other databases", or returns "" if none were skipped
+func (s LoadStats) Skipped() string {
+	var parts []string
+	for valueType, count := range s.Unsupported {
+		parts = append(parts, fmt.Sprintf("%d %s", count, valueType))
+	}
+	sort.Strings(parts)
+	if s.OtherDB > 0 {
+		parts = append(parts, fmt.Sprintf("%d in other databases", s.OtherDB))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// LoadSnapshot loads the RDB snapshot at path into store, skipping the keys
+// that have expired since it was taken and those the store cannot hold.
+// The whole file is read, so a corrupt snapshot may leave some of its keys
+// in the store.
+func LoadSnapshot(path string, store storage.Store) (LoadStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return LoadStats{Unsupported: make(map[Type]int)}, err
+	}
+	defer file.Close()
+
+	stats := LoadStats{Unsupported: make(map[Type]int)}
+	_, err = ReadRDB(file, loadEntry(store, time.Now(), &stats))
+	return stats, err
+}
+
+// loadEntry returns a visit function for ReadRDB that loads the keys into
+// store as of now, counting them in stats
+func loadEntry(store storage.Store, now time.Time, stats *LoadStats) func(*Entry) error {
+	return func(entry *Entry) error {
+		switch {
+		case entry.DB != 0:
+			stats.OtherDB++
+		case entry.Type != TypeString:
+			stats.Unsupported[entry.Type]++
+		case !entry.ExpiresAt.IsZero() && !now.Before(entry.ExpiresAt):
+			stats.Expired++
+		default:
+			stats.Loaded++
+			return store.SetWithExpiry(entry.Key, entry.String, entry.ExpiresAt)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,260 @@
+package persistence
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// newStore returns a store holding the given keys without expiry
+func newStore(pairs ...string) *storage.MemoryStore {
+	store := storage.NewMemoryStore()
+	for i := 0; i+1 < len(pairs); i += 2 {
+		store.Set(pairs[i], []byte(pairs[i+1]))
+	}
+	return store
+}
+
+// encode returns a snapshot of store
+func encode(t *testing.T, store *storage.MemoryStore, options RDBOptions) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if _, err := WriteSnapshot(&buf, store, options, time.Now()); err != nil {
+		t.Fatalf("WriteSnapshot() returned error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// decode reads an RDB file and returns its entries by key
+func decode(t *testing.T, data []byte) (map[string]*Entry, RDBInfo) {
+	t.Helper()
+
+	entries := make(map[string]*Entry)
+	info, err := ReadRDB(bytes.NewReader(data), func(entry *Entry) error {
+		entries[entry.Key] = entry
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadRDB() returned error: %v", err)
+	}
+	return entries, info
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	store := newStore("a", "1", "empty", "", "binary", "\x00\xff\r\n", "number", "-123456", "big", "12345678901")
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	store.SetWithExpiry("ttl", []byte("x"), expiresAt)
+	store.Set("large", bytes.Repeat([]byte("abcdefgh"), 10*1024))
+
+	for _, options := range []RDBOptions{{}, {Compression: true, Checksum: true}} {
+		data := encode(t, store, options)
+		entries, info := decode(t, data)
+
+		if info.Keys != 7 || info.Version != RDBVersion {
+			t.Errorf("Expected 7 keys and version %d, got %+v", RDBVersion, info)
+		}
+		if (info.Checksum != 0) != options.Checksum {
+			t.Errorf("Expected checksum %v, got %x", options.Checksum, info.Checksum)
+		}
+
+		for _, key := range []string{"a", "empty", "binary", "number", "big", "large"} {
+			value, _ := store.Get(key)
+			if entries[key].Type != TypeString || !bytes.Equal(entries[key].String, value) {
+				t.Errorf("Expected %q to be %q, got %q", key, value, entries[key].String)
+			}
+			if !entries[key].ExpiresAt.IsZero() {
+				t.Errorf("Expected %q without expiry, got %v", key, entries[key].ExpiresAt)
+			}
+		}
+		if !entries["ttl"].ExpiresAt.Equal(expiresAt) {
+			t.Errorf("Expected expiry %v, got %v", expiresAt, entries["ttl"].ExpiresAt)
+		}
+
+		// The large value compresses well
+		if options.Compression && len(data) > 10*1024 {
+			t.Errorf("Expected a compressed snapshot, got %d bytes", len(data))
+		}
+	}
+}
+
+func TestSnapshot_Corrupt(t *testing.T) {
+	data := encode(t, newStore("key", "value", "other", "value"), RDBOptions{Checksum: true})
+	end := len(data) - 9
+
+	tests := []struct {
+		name   string
+		data   func() []byte
+		offset int64
+	}{
+		{
+			name: "flipped value byte",
+			data: func() []byte {
+				corrupt := bytes.Clone(data)
+				corrupt[end-1] ^= 0xFF
+				return corrupt
+			},
+			offset: int64(end + 1),
+		},
+		{
+			name:   "truncated",
+			data:   func() []byte { return data[:end-2] },
+			offset: int64(end - 2),
+		},
+		{
+			name:   "missing checksum",
+			data:   func() []byte { return data[:len(data)-3] },
+			offset: int64(len(data) - 8),
+		},
+		{
+			name: "unknown value type",
+			data: func() []byte {
+				corrupt := bytes.Clone(data)
+				corrupt[end] = 0x08
+				return corrupt
+			},
+			offset: int64(end),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadRDB(bytes.NewReader(tt.data()), func(*Entry) error { return nil })
+
+			var corrupt *CorruptError
+			if !errors.As(err, &corrupt) {
+				t.Fatalf("Expected a CorruptError, got %v", err)
+			}
+			if corrupt.Offset != tt.offset {
+				t.Errorf("Expected offset %d, got %d (%v)", tt.offset, corrupt.Offset, err)
+			}
+		})
+	}
+}
+
+func TestSnapshot_NotRDB(t *testing.T) {
+	_, err := ReadRDB(bytes.NewReader([]byte("RLSNAP\x01")), func(*Entry) error { return nil })
+	if !errors.Is(err, ErrNotRDB) {
+		t.Errorf("Expected ErrNotRDB, got %v", err)
+	}
+
+	_, err = ReadRDB(bytes.NewReader([]byte("REDIS0099\xff")), func(*Entry) error { return nil })
+	var corrupt *CorruptError
+	if !errors.As(err, &corrupt) {
+		t.Errorf("Expected a CorruptError for an unknown version, got %v", err)
+	}
+}
+
+func TestSaveAndLoadSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	store := newStore("a", "1", "b", "2")
+	store.SetWithExpiry("expiring", []byte("x"), time.Now().Add(50*time.Millisecond))
+
+	count, err := SaveSnapshot(path, store, RDBOptions{Compression: true, Checksum: true})
+	if err != nil {
+		t.Fatalf("SaveSnapshot() returned error: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 keys saved, got %d", count)
+	}
+
+	// Keys expired by the time the snapshot is loaded are skipped
+	time.Sleep(60 * time.Millisecond)
+	loaded := storage.NewMemoryStore()
+	stats, err := LoadSnapshot(path, loaded)
+	if err != nil {
+		t.Fatalf("LoadSnapshot() returned error: %v", err)
+	}
+	if stats.Loaded != 2 || stats.Expired != 1 || loaded.Size() != 2 {
+		t.Errorf("Expected 2 keys loaded and 1 expired, got %+v", stats)
+	}
+	if value, _ := loaded.Get("b"); string(value) != "2" {
+		t.Errorf("Expected '2', got %q", value)
+	}
+}
+
+func TestLoadSnapshot_SkipsUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewRDBWriter(&buf, RDBOptions{Checksum: true})
+	writer.WriteHeader()
+	writer.SelectDB(0)
+	writer.WriteEntry(&Entry{Key: "string", Type: TypeString, String: []byte("v"), Idle: -1, Freq: -1})
+	writer.WriteEntry(&Entry{Key: "list", Type: TypeList, List: [][]byte{[]byte("a")}, Idle: -1, Freq: -1})
+	writer.WriteEntry(&Entry{Key: "hash", Type: TypeHash, Hash: []HashField{{Field: []byte("f"), Value: []byte("v")}}, Idle: -1, Freq: -1})
+	writer.SelectDB(3)
+	writer.WriteEntry(&Entry{Key: "elsewhere", Type: TypeString, String: []byte("v"), Idle: -1, Freq: -1})
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() returned error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	os.WriteFile(path, buf.Bytes(), 0644)
+
+	store := storage.NewMemoryStore()
+	stats, err := LoadSnapshot(path, store)
+	if err != nil {
+		t.Fatalf("LoadSnapshot() returned error: %v", err)
+	}
+	if stats.Loaded != 1 || store.Size() != 1 {
+		t.Errorf("Expected 1 key loaded, got %+v", stats)
+	}
+	if skipped := stats.Skipped(); skipped != "1 hash, 1 list, 1 in other databases" {
+		t.Errorf("Expected '1 hash, 1 list, 1 in other databases', got %q", skipped)
+	}
+}
+
+func TestSaveSnapshot_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dump.rdb")
+	if _, err := SaveSnapshot(path, newStore("a", "1"), RDBOptions{}); err != nil {
+		t.Fatalf("SaveSnapshot() returned error: %v", err)
+	}
+	before, _ := os.ReadFile(path)
+
+	// A failed save leaves the previous snapshot, and no temporary file
+	failure := errors.New("disk full")
+	_, err := SaveSnapshot(path, failingSource{failure}, RDBOptions{})
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected %v, got %v", failure, err)
+	}
+
+	after, _ := os.ReadFile(path)
+	if !bytes.Equal(before, after) {
+		t.Error("Expected the previous snapshot to be kept")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the snapshot in the directory, got %d files", len(entries))
+	}
+}
+
+// failingSource is a source whose snapshots fail
+type failingSource struct {
+	err error
+}
+
+func (s failingSource) Snapshot(visit func([]storage.Record) error) error {
+	return s.err
+}
+
+func TestLoadSnapshot_Missing(t *testing.T) {
+	_, err := LoadSnapshot(filepath.Join(t.TempDir(), "missing.rdb"), storage.NewMemoryStore())
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestReadRDB_VisitError(t *testing.T) {
+	data := encode(t, newStore("a", "1"), RDBOptions{})
+	failure := errors.New("out of memory")
+
+	_, err := ReadRDB(bytes.NewReader(data), func(*Entry) error { return failure })
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected %v, got %v", failure, err)
+	}
+}
@@ -0,0 +1,274 @@
+package persistence
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// RetryDelay is how long save points wait before retrying a failed
+// background save
+const RetryDelay = 5 * time.Second
+
+// ErrSaveInProgress is returned when a save is requested while another is
+// running
+var ErrSaveInProgress = errors.New("background save already in progress")
+
+// Snapshotter saves the store to a snapshot file, in the foreground for
+// SAVE or in the background for BGSAVE and save points, and keeps track of
+// the changes made since the last save
+type Snapshotter struct {
+	store   *storage.MemoryStore
+	mutex   sync.Mutex
+	path    string
+	options RDBOptions
+	// saving is set while a save runs, and done is closed when it ends
+	saving bool
+	done   chan struct{}
+	// scheduled starts a background save once the running one ends
+	scheduled bool
+	// lastSave is the time of the last successful save, and savedChanges
+	// the store's change counter when it started
+	lastSave     time.Time
+	savedChanges uint64
+	// lastAttempt and lastErr describe the last background save
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// NewSnapshotter creates a snapshotter saving store to the RDB file at path
+func NewSnapshotter(store *storage.MemoryStore, path string, options RDBOptions) *Snapshotter {
+	return &Snapshotter{
+		store:        store,
+		path:         path,
+		options:      options,
+		lastSave:     time.Now(),
+		savedChanges: store.Changes(),
+	}
+}
+
+// SetPath changes the file snapshots are saved to
+func (s *Snapshotter) SetPath(path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.path = path
+}
+
+// SetOptions changes how snapshots are written
+func (s *Snapshotter) SetOptions(options RDBOptions) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.options = options
+}
+
+// Path returns the file snapshots are saved to
+func (s *Snapshotter) Path() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.path
+}
+
+// Load loads the snapshot file into the store and counts the keys loaded
+// and skipped. A missing file is not an error: there is nothing to load.
+func (s *Snapshotter) Load() (LoadStats, error) {
+	stats, err := LoadSnapshot(s.Path(), s.store)
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	s.mutex.Lock()
+	s.savedChanges = s.store.Changes()
+	s.mutex.Unlock()
+	return stats, nil
+}
+
+// Save saves a snapshot and waits for it to be written
+func (s *Snapshotter) Save() error {
+	path, options, err := s.begin()
+	if err != nil {
+		return err
+	}
+	err = s.save(path, options)
+
+	s.mutex.Lock()
+	s.startScheduled()
+	s.mutex.Unlock()
+	return err
+}
+
+// BackgroundSave starts saving a snapshot and returns without waiting for
+// it. Clients are served while the snapshot is written.
+func (s *Snapshotter) BackgroundSave() error {
+	path, options, err := s.begin()
+	if err != nil {
+		return err
+	}
+	go s.backgroundSave(path, options)
+	return nil
+}
+
+// ScheduleBackgroundSave starts a background save, or schedules one for
+// when the running save ends. It reports whether the save was scheduled
+// rather than started.
+func (s *Snapshotter) ScheduleBackgroundSave() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Checking for a running save and starting one under the same lock
+	// leaves no save to end in between and miss the schedule
+	if s.saving {
+		s.scheduled = true
+		return true
+	}
+	path, options := s.start()
+	go s.backgroundSave(path, options)
+	return false
+}
+
+// Wait waits for the running save, if any, to end
+func (s *Snapshotter) Wait() {
+	s.mutex.Lock()
+	done := s.done
+	saving := s.saving
+	s.mutex.Unlock()
+
+	if saving {
+		<-done
+	}
+}
+
+// begin marks a save as running and returns the path to save to and how
+func (s *Snapshotter) begin() (string, RDBOptions, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.saving {
+		return "", RDBOptions{}, ErrSaveInProgress
+	}
+	path, options := s.start()
+	return path, options, nil
+}
+
+// start marks a save as running, as begin does. Must be called with the
+// mutex held and no save running.
+func (s *Snapshotter) start() (string, RDBOptions) {
+	s.saving = true
+	s.done = make(chan struct{})
+	return s.path, s.options
+}
+
+// save writes a snapshot to path and ends the save started by begin
+func (s *Snapshotter) save(path string, options RDBOptions) error {
+	changes := s.store.Changes()
+	_, err := SaveSnapshot(path, s.store, options)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err == nil {
+		s.lastSave = time.Now()
+		s.savedChanges = changes
+	}
+	s.saving = false
+	close(s.done)
+	return err
+}
+
+// startScheduled starts the background save scheduled while another save
+// ran, unless a save is running again. Must be called with the mutex held.
+func (s *Snapshotter) startScheduled() {
+	if s.scheduled && !s.saving {
+		s.scheduled = false
+		path, options := s.start()
+		go s.backgroundSave(path, options)
+	}
+}
+
+// backgroundSave runs a background save, then the one scheduled meanwhile
+// if any
+func (s *Snapshotter) backgroundSave(path string, options RDBOptions) {
+	start := time.Now()
+	err := s.save(path, options)
+	if err != nil {
+		log.Printf("Background saving error: %v", err)
+	} else {
+		log.Printf("Background saving terminated with success in %v", time.Since(start).Round(time.Millisecond))
+	}
+
+	s.mutex.Lock()
+	s.lastAttempt = start
+	s.lastErr = err
+	s.startScheduled()
+	s.mutex.Unlock()
+}
+
+// LastSave returns the time of the last successful save, or of the
+// snapshotter's creation if nothing was saved yet
+func (s *Snapshotter) LastSave() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lastSave
+}
+
+// LastError returns the error of the last background save, nil if it
+// succeeded
+func (s *Snapshotter) LastError() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lastErr
+}
+
+// Saving reports whether a save is running
+func (s *Snapshotter) Saving() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.saving
+}
+
+// Dirty returns the number of changes since the last successful save
+func (s *Snapshotter) Dirty() uint64 {
+	changes := s.store.Changes()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return changes - s.savedChanges
+}
+
+// CheckSavePoints starts a background save if one of the save points is
+// reached: at least Changes changes in the Seconds since the last save.
+// After a failed background save, it waits RetryDelay before trying
+// again. It reports whether a save was started.
+func (s *Snapshotter) CheckSavePoints(points []config.SavePoint, now time.Time) bool {
+	dirty := s.Dirty()
+
+	s.mutex.Lock()
+	if s.saving || (s.lastErr != nil && now.Sub(s.lastAttempt) < RetryDelay) {
+		s.mutex.Unlock()
+		return false
+	}
+	elapsed := now.Sub(s.lastSave)
+	s.mutex.Unlock()
+
+	for _, point := range points {
+		if dirty >= uint64(point.Changes) && elapsed >= time.Duration(point.Seconds)*time.Second {
+			log.Printf("%d changes in %d seconds. Saving...", point.Changes, point.Seconds)
+			return s.BackgroundSave() == nil
+		}
+	}
+	return false
+}
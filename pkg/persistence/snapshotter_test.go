@@ -0,0 +1,194 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func TestSnapshotter_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	store := newStore("a", "1")
+	snapshotter := NewSnapshotter(store, path, RDBOptions{})
+
+	if dirty := snapshotter.Dirty(); dirty != 0 {
+		t.Errorf("Expected no changes, got %d", dirty)
+	}
+	store.Set("b", []byte("2"))
+	if dirty := snapshotter.Dirty(); dirty != 1 {
+		t.Errorf("Expected 1 change, got %d", dirty)
+	}
+
+	before := snapshotter.LastSave()
+	time.Sleep(time.Millisecond)
+	if err := snapshotter.Save(); err != nil {
+		t.Fatalf("Save() returned error: %v", err)
+	}
+	if dirty := snapshotter.Dirty(); dirty != 0 {
+		t.Errorf("Expected no changes after saving, got %d", dirty)
+	}
+	if !snapshotter.LastSave().After(before) {
+		t.Error("Expected LASTSAVE to advance")
+	}
+
+	loaded := storage.NewMemoryStore()
+	stats, err := NewSnapshotter(loaded, path, RDBOptions{}).Load()
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if stats.Loaded != 2 {
+		t.Errorf("Expected 2 keys loaded, got %d", stats.Loaded)
+	}
+}
+
+func TestSnapshotter_LoadMissing(t *testing.T) {
+	snapshotter := NewSnapshotter(storage.NewMemoryStore(), filepath.Join(t.TempDir(), "dump.rdb"), RDBOptions{})
+
+	stats, err := snapshotter.Load()
+	if err != nil || stats.Loaded != 0 {
+		t.Errorf("Expected nothing loaded without error, got %d, %v", stats.Loaded, err)
+	}
+}
+
+func TestSnapshotter_BackgroundSave(t *testing.T) {
+	dir := t.TempDir()
+	store := newStore("a", "1")
+	snapshotter := NewSnapshotter(store, filepath.Join(dir, "dump.rdb"), RDBOptions{})
+
+	if err := snapshotter.BackgroundSave(); err != nil {
+		t.Fatalf("BackgroundSave() returned error: %v", err)
+	}
+	snapshotter.Wait()
+
+	if snapshotter.Saving() {
+		t.Error("Expected the save to be over")
+	}
+	if err := snapshotter.LastError(); err != nil {
+		t.Errorf("Expected the save to succeed, got %v", err)
+	}
+
+	loaded := storage.NewMemoryStore()
+	if _, err := LoadSnapshot(filepath.Join(dir, "dump.rdb"), loaded); err != nil {
+		t.Fatalf("LoadSnapshot() returned error: %v", err)
+	}
+	if value, _ := loaded.Get("a"); string(value) != "1" {
+		t.Errorf("Expected '1', got %q", value)
+	}
+}
+
+func TestSnapshotter_SaveInProgress(t *testing.T) {
+	snapshotter := NewSnapshotter(newStore(), filepath.Join(t.TempDir(), "dump.rdb"), RDBOptions{})
+
+	// Pretend a save is running
+	if _, _, err := snapshotter.begin(); err != nil {
+		t.Fatalf("begin() returned error: %v", err)
+	}
+
+	if err := snapshotter.Save(); !errors.Is(err, ErrSaveInProgress) {
+		t.Errorf("Expected ErrSaveInProgress from Save, got %v", err)
+	}
+	if err := snapshotter.BackgroundSave(); !errors.Is(err, ErrSaveInProgress) {
+		t.Errorf("Expected ErrSaveInProgress from BackgroundSave, got %v", err)
+	}
+	if !snapshotter.ScheduleBackgroundSave() {
+		t.Error("Expected the save to be scheduled")
+	}
+
+	// The running save ends and the scheduled one starts
+	snapshotter.backgroundSave(snapshotter.Path(), RDBOptions{})
+	snapshotter.Wait()
+	snapshotter.mutex.Lock()
+	scheduled := snapshotter.scheduled
+	snapshotter.mutex.Unlock()
+	if scheduled {
+		t.Error("Expected the scheduled save to have run")
+	}
+}
+
+func TestSnapshotter_ScheduleBackgroundSave(t *testing.T) {
+	dir := t.TempDir()
+	snapshotter := NewSnapshotter(newStore("a", "1"), filepath.Join(dir, "dump.rdb"), RDBOptions{})
+
+	// Without a running save, the save starts at once
+	if snapshotter.ScheduleBackgroundSave() {
+		t.Error("Expected the save to start rather than be scheduled")
+	}
+	snapshotter.Wait()
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); err != nil {
+		t.Errorf("Expected the save to have run, got %v", err)
+	}
+
+	// A save scheduled during a foreground save runs once it ends
+	path, options, err := snapshotter.begin()
+	if err != nil {
+		t.Fatalf("begin() returned error: %v", err)
+	}
+	if !snapshotter.ScheduleBackgroundSave() {
+		t.Error("Expected the save to be scheduled")
+	}
+	snapshotter.SetPath(filepath.Join(dir, "scheduled.rdb"))
+	if err := snapshotter.save(path, options); err != nil {
+		t.Fatalf("save() returned error: %v", err)
+	}
+	snapshotter.mutex.Lock()
+	snapshotter.startScheduled()
+	snapshotter.mutex.Unlock()
+	snapshotter.Wait()
+	if _, err := os.Stat(filepath.Join(dir, "scheduled.rdb")); err != nil {
+		t.Errorf("Expected the scheduled save to have run, got %v", err)
+	}
+}
+
+func TestSnapshotter_CheckSavePoints(t *testing.T) {
+	store := newStore()
+	snapshotter := NewSnapshotter(store, filepath.Join(t.TempDir(), "dump.rdb"), RDBOptions{})
+	points := []config.SavePoint{{Seconds: 60, Changes: 2}}
+	start := snapshotter.LastSave()
+
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("2"))
+
+	if snapshotter.CheckSavePoints(points, start.Add(30*time.Second)) {
+		t.Error("Expected no save before the interval elapsed")
+	}
+	if snapshotter.CheckSavePoints(nil, start.Add(time.Hour)) {
+		t.Error("Expected no save without save points")
+	}
+	if !snapshotter.CheckSavePoints(points, start.Add(time.Minute)) {
+		t.Fatal("Expected a save once the save point is reached")
+	}
+	snapshotter.Wait()
+
+	if snapshotter.CheckSavePoints(points, time.Now().Add(time.Hour)) {
+		t.Error("Expected no save without changes")
+	}
+}
+
+func TestSnapshotter_CheckSavePointsRetry(t *testing.T) {
+	store := newStore("a", "1")
+	snapshotter := NewSnapshotter(store, filepath.Join(t.TempDir(), "missing", "dump.rdb"), RDBOptions{})
+	points := []config.SavePoint{{Seconds: 0, Changes: 1}}
+	store.Set("b", []byte("2"))
+
+	if !snapshotter.CheckSavePoints(points, time.Now()) {
+		t.Fatal("Expected a save to start")
+	}
+	snapshotter.Wait()
+	if snapshotter.LastError() == nil {
+		t.Fatal("Expected the save to fail")
+	}
+
+	// Failed saves are retried after RetryDelay
+	if snapshotter.CheckSavePoints(points, time.Now()) {
+		t.Error("Expected no retry before RetryDelay")
+	}
+	if !snapshotter.CheckSavePoints(points, time.Now().Add(RetryDelay)) {
+		t.Error("Expected a retry after RetryDelay")
+	}
+	snapshotter.Wait()
+}
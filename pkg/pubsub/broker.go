@@ -0,0 +1,306 @@
+package pubsub
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/tsinivuo/redis-lite/pkg/cluster"
+	"github.com/tsinivuo/redis-lite/pkg/glob"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// Subscriber receives messages published on the channels and patterns it
+// is subscribed to
+type Subscriber interface {
+	// Deliver hands a message to the subscriber. It is called from the
+	// publishing goroutine and must not block.
+	Deliver(message *resp.Message)
+}
+
+// subscriberSet is the set of subscribers of a channel or pattern
+type subscriberSet map[Subscriber]struct{}
+
+// Broker routes published messages to channel, pattern and shard channel
+// subscribers
+type Broker struct {
+	channels map[string]subscriberSet
+	patterns map[string]subscriberSet
+	// shards holds the shard channels indexed by hash slot. Shard channels
+	// have their own namespace and are never matched by patterns.
+	shards map[uint16]map[string]subscriberSet
+	mutex  sync.RWMutex
+}
+
+// NewBroker creates a new pub/sub broker
+func NewBroker() *Broker {
+	return &Broker{
+		channels: make(map[string]subscriberSet),
+		patterns: make(map[string]subscriberSet),
+		shards:   make(map[uint16]map[string]subscriberSet),
+	}
+}
+
+// Subscribe subscribes to a channel and returns false if the subscriber
+// was already subscribed
+func (b *Broker) Subscribe(subscriber Subscriber, channel string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return add(b.channels, channel, subscriber)
+}
+
+// Unsubscribe removes a channel subscription and returns false if the
+// subscriber was not subscribed
+func (b *Broker) Unsubscribe(subscriber Subscriber, channel string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return remove(b.channels, channel, subscriber)
+}
+
+// PSubscribe subscribes to a glob-style pattern and returns false if the
+// subscriber was already subscribed
+func (b *Broker) PSubscribe(subscriber Subscriber, pattern string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return add(b.patterns, pattern, subscriber)
+}
+
+// PUnsubscribe removes a pattern subscription and returns false if the
+// subscriber was not subscribed
+func (b *Broker) PUnsubscribe(subscriber Subscriber, pattern string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return remove(b.patterns, pattern, subscriber)
+}
+
+// SSubscribe subscribes to a shard channel and returns false if the
+// subscriber was already subscribed
+func (b *Broker) SSubscribe(subscriber Subscriber, channel string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	slot := cluster.KeySlot(channel)
+	channels, exists := b.shards[slot]
+	if !exists {
+		channels = make(map[string]subscriberSet)
+		b.shards[slot] = channels
+	}
+
+	return add(channels, channel, subscriber)
+}
+
+// SUnsubscribe removes a shard channel subscription and returns false if the
+// subscriber was not subscribed
+func (b *Broker) SUnsubscribe(subscriber Subscriber, channel string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	slot := cluster.KeySlot(channel)
+	channels, exists := b.shards[slot]
+	if !exists {
+		return false
+	}
+
+	removed := remove(channels, channel, subscriber)
+	if len(channels) == 0 {
+		delete(b.shards, slot)
+	}
+	return removed
+}
+
+// SPublish delivers a message to every subscriber of the shard channel and
+// returns the number of subscribers that received it
+func (b *Broker) SPublish(channel, payload string) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	subscribers := b.shards[cluster.KeySlot(channel)][channel]
+	if len(subscribers) == 0 {
+		return 0
+	}
+
+	message := NewSMessage(channel, payload)
+	for subscriber := range subscribers {
+		subscriber.Deliver(message)
+	}
+	return len(subscribers)
+}
+
+// ShardChannels returns the active shard channels that match the pattern.
+// An empty pattern matches every shard channel.
+func (b *Broker) ShardChannels(pattern string) []string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	var channels []string
+	for _, slotChannels := range b.shards {
+		for channel := range slotChannels {
+			if pattern == "" || glob.Match(pattern, channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	sort.Strings(channels)
+	return channels
+}
+
+// SlotChannels returns the active shard channels that hash to the slot
+func (b *Broker) SlotChannels(slot uint16) []string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	channels := make([]string, 0, len(b.shards[slot]))
+	for channel := range b.shards[slot] {
+		channels = append(channels, channel)
+	}
+
+	sort.Strings(channels)
+	return channels
+}
+
+// ShardNumSub returns the number of subscribers of each shard channel
+func (b *Broker) ShardNumSub(channels ...string) []int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		counts[i] = len(b.shards[cluster.KeySlot(channel)][channel])
+	}
+	return counts
+}
+
+// Publish delivers a message to every subscriber of the channel and of every
+// matching pattern, and returns the number of subscribers that received it
+func (b *Broker) Publish(channel, payload string) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	receivers := 0
+
+	if subscribers, exists := b.channels[channel]; exists {
+		message := NewMessage(channel, payload)
+		for subscriber := range subscribers {
+			subscriber.Deliver(message)
+			receivers++
+		}
+	}
+
+	for pattern, subscribers := range b.patterns {
+		if !glob.Match(pattern, channel) {
+			continue
+		}
+
+		message := NewPMessage(pattern, channel, payload)
+		for subscriber := range subscribers {
+			subscriber.Deliver(message)
+			receivers++
+		}
+	}
+
+	return receivers
+}
+
+// Channels returns the active channels, those with at least one subscriber,
+// that match the pattern. An empty pattern matches every channel.
+func (b *Broker) Channels(pattern string) []string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	channels := make([]string, 0, len(b.channels))
+	for channel := range b.channels {
+		if pattern == "" || glob.Match(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+
+	sort.Strings(channels)
+	return channels
+}
+
+// NumSub returns the number of subscribers of each channel
+func (b *Broker) NumSub(channels ...string) []int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	counts := make([]int, len(channels))
+	for i, channel := range channels {
+		counts[i] = len(b.channels[channel])
+	}
+	return counts
+}
+
+// NumPat returns the number of patterns with at least one subscriber
+func (b *Broker) NumPat() int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return len(b.patterns)
+}
+
+// add adds a subscriber to the set stored under name
+func add(sets map[string]subscriberSet, name string, subscriber Subscriber) bool {
+	subscribers, exists := sets[name]
+	if !exists {
+		subscribers = make(subscriberSet)
+		sets[name] = subscribers
+	}
+
+	if _, subscribed := subscribers[subscriber]; subscribed {
+		return false
+	}
+
+	subscribers[subscriber] = struct{}{}
+	return true
+}
+
+// remove removes a subscriber from the set stored under name, dropping the
+// set once it is empty
+func remove(sets map[string]subscriberSet, name string, subscriber Subscriber) bool {
+	subscribers, exists := sets[name]
+	if !exists {
+		return false
+	}
+
+	if _, subscribed := subscribers[subscriber]; !subscribed {
+		return false
+	}
+
+	delete(subscribers, subscriber)
+	if len(subscribers) == 0 {
+		delete(sets, name)
+	}
+	return true
+}
+
+// NewMessage creates the message pushed to channel subscribers
+func NewMessage(channel, payload string) *resp.Message {
+	return resp.NewPush([]*resp.Message{
+		resp.NewBulkString("message"),
+		resp.NewBulkString(channel),
+		resp.NewBulkString(payload),
+	})
+}
+
+// NewPMessage creates the message pushed to pattern subscribers
+func NewPMessage(pattern, channel, payload string) *resp.Message {
+	return resp.NewPush([]*resp.Message{
+		resp.NewBulkString("pmessage"),
+		resp.NewBulkString(pattern),
+		resp.NewBulkString(channel),
+		resp.NewBulkString(payload),
+	})
+}
+
+// NewSMessage creates the message pushed to shard channel subscribers
+func NewSMessage(channel, payload string) *resp.Message {
+	return resp.NewPush([]*resp.Message{
+		resp.NewBulkString("smessage"),
+		resp.NewBulkString(channel),
+		resp.NewBulkString(payload),
+	})
+}
@@ -0,0 +1,202 @@
+package pubsub
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/cluster"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// mockSubscriber records every delivered message
+type mockSubscriber struct {
+	mutex    sync.Mutex
+	messages []*resp.Message
+}
+
+func (m *mockSubscriber) Deliver(message *resp.Message) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.messages = append(m.messages, message)
+}
+
+// payloads returns the string elements of every delivered message
+func (m *mockSubscriber) payloads() [][]string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := make([][]string, len(m.messages))
+	for i, message := range m.messages {
+		elements, _ := message.AsArray()
+		for _, element := range elements {
+			value, _ := element.AsString()
+			result[i] = append(result[i], value)
+		}
+	}
+	return result
+}
+
+func TestBroker_SubscribePublish(t *testing.T) {
+	broker := NewBroker()
+	subscriber := &mockSubscriber{}
+
+	if !broker.Subscribe(subscriber, "news") {
+		t.Fatal("Expected first subscription to succeed")
+	}
+	if broker.Subscribe(subscriber, "news") {
+		t.Error("Expected duplicate subscription to return false")
+	}
+
+	if receivers := broker.Publish("news", "hello"); receivers != 1 {
+		t.Errorf("Expected 1 receiver, got %d", receivers)
+	}
+	if receivers := broker.Publish("other", "ignored"); receivers != 0 {
+		t.Errorf("Expected 0 receivers, got %d", receivers)
+	}
+
+	want := [][]string{{"message", "news", "hello"}}
+	if got := subscriber.payloads(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestBroker_Unsubscribe(t *testing.T) {
+	broker := NewBroker()
+	subscriber := &mockSubscriber{}
+
+	if broker.Unsubscribe(subscriber, "news") {
+		t.Error("Expected unsubscribing an unknown channel to return false")
+	}
+
+	broker.Subscribe(subscriber, "news")
+	if !broker.Unsubscribe(subscriber, "news") {
+		t.Error("Expected unsubscribe to return true")
+	}
+
+	if receivers := broker.Publish("news", "hello"); receivers != 0 {
+		t.Errorf("Expected 0 receivers after unsubscribe, got %d", receivers)
+	}
+
+	if channels := broker.Channels(""); len(channels) != 0 {
+		t.Errorf("Expected no active channels, got %v", channels)
+	}
+}
+
+func TestBroker_PatternSubscribe(t *testing.T) {
+	broker := NewBroker()
+	channelSubscriber := &mockSubscriber{}
+	patternSubscriber := &mockSubscriber{}
+
+	broker.Subscribe(channelSubscriber, "news.sports")
+	broker.PSubscribe(patternSubscriber, "news.*")
+
+	if receivers := broker.Publish("news.sports", "goal"); receivers != 2 {
+		t.Errorf("Expected 2 receivers, got %d", receivers)
+	}
+
+	want := [][]string{{"pmessage", "news.*", "news.sports", "goal"}}
+	if got := patternSubscriber.payloads(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if broker.NumPat() != 1 {
+		t.Errorf("Expected 1 pattern, got %d", broker.NumPat())
+	}
+
+	broker.PUnsubscribe(patternSubscriber, "news.*")
+	if broker.NumPat() != 0 {
+		t.Errorf("Expected 0 patterns, got %d", broker.NumPat())
+	}
+}
+
+func TestBroker_Introspection(t *testing.T) {
+	broker := NewBroker()
+	first := &mockSubscriber{}
+	second := &mockSubscriber{}
+
+	broker.Subscribe(first, "news")
+	broker.Subscribe(second, "news")
+	broker.Subscribe(first, "weather")
+
+	if got := broker.Channels(""); !reflect.DeepEqual(got, []string{"news", "weather"}) {
+		t.Errorf("Unexpected channels: %v", got)
+	}
+
+	if got := broker.Channels("n*"); !reflect.DeepEqual(got, []string{"news"}) {
+		t.Errorf("Unexpected channels for pattern: %v", got)
+	}
+
+	if got := broker.NumSub("news", "weather", "missing"); !reflect.DeepEqual(got, []int{2, 1, 0}) {
+		t.Errorf("Unexpected subscriber counts: %v", got)
+	}
+}
+
+func TestBroker_ShardChannels(t *testing.T) {
+	broker := NewBroker()
+	shardSubscriber := &mockSubscriber{}
+	classicSubscriber := &mockSubscriber{}
+
+	if !broker.SSubscribe(shardSubscriber, "orders") {
+		t.Fatal("Expected first shard subscription to succeed")
+	}
+	if broker.SSubscribe(shardSubscriber, "orders") {
+		t.Error("Expected duplicate shard subscription to return false")
+	}
+
+	broker.Subscribe(classicSubscriber, "orders")
+	broker.PSubscribe(classicSubscriber, "*")
+
+	// Shard channels and classic channels are separate namespaces
+	if receivers := broker.SPublish("orders", "created"); receivers != 1 {
+		t.Errorf("Expected 1 shard receiver, got %d", receivers)
+	}
+
+	want := [][]string{{"smessage", "orders", "created"}}
+	if got := shardSubscriber.payloads(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if got := classicSubscriber.payloads(); len(got) != 0 {
+		t.Errorf("Classic subscribers must not receive shard messages, got %v", got)
+	}
+
+	broker.Publish("orders", "classic")
+	if got := shardSubscriber.payloads(); len(got) != 1 {
+		t.Errorf("Shard subscribers must not receive classic messages, got %v", got)
+	}
+}
+
+func TestBroker_ShardIntrospection(t *testing.T) {
+	broker := NewBroker()
+	first := &mockSubscriber{}
+	second := &mockSubscriber{}
+
+	broker.SSubscribe(first, "{user1}.orders")
+	broker.SSubscribe(second, "{user1}.orders")
+	broker.SSubscribe(first, "{user1}.payments")
+	broker.SSubscribe(first, "other")
+	broker.Subscribe(first, "classic")
+
+	if got := broker.ShardChannels(""); !reflect.DeepEqual(got, []string{"other", "{user1}.orders", "{user1}.payments"}) {
+		t.Errorf("Unexpected shard channels: %v", got)
+	}
+
+	if got := broker.ShardChannels("{user1}*"); !reflect.DeepEqual(got, []string{"{user1}.orders", "{user1}.payments"}) {
+		t.Errorf("Unexpected shard channels for pattern: %v", got)
+	}
+
+	if got := broker.ShardNumSub("{user1}.orders", "missing"); !reflect.DeepEqual(got, []int{2, 0}) {
+		t.Errorf("Unexpected shard subscriber counts: %v", got)
+	}
+
+	if got := broker.SlotChannels(cluster.KeySlot("user1")); !reflect.DeepEqual(got, []string{"{user1}.orders", "{user1}.payments"}) {
+		t.Errorf("Unexpected channels in slot: %v", got)
+	}
+
+	broker.SUnsubscribe(first, "other")
+	if got := broker.SlotChannels(cluster.KeySlot("other")); len(got) != 0 {
+		t.Errorf("Expected empty slot after unsubscribe, got %v", got)
+	}
+}
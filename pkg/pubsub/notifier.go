@@ -0,0 +1,54 @@
+package pubsub
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
+)
+
+// KeyspaceNotifier publishes keyspace events on a broker according to the
+// notify-keyspace-events flags. Notifications are disabled until flags
+// enabling K or E and at least one event class are set.
+type KeyspaceNotifier struct {
+	broker  *Broker
+	classes atomic.Int64
+}
+
+// NewKeyspaceNotifier creates a notifier publishing on the given broker
+func NewKeyspaceNotifier(broker *Broker) *KeyspaceNotifier {
+	return &KeyspaceNotifier{broker: broker}
+}
+
+// SetFlags sets the notify-keyspace-events flags, such as "KEA" or "Ex"
+func (n *KeyspaceNotifier) SetFlags(flags string) error {
+	classes, err := events.ParseFlags(flags)
+	if err != nil {
+		return err
+	}
+
+	n.classes.Store(int64(classes))
+	return nil
+}
+
+// Flags returns the current notify-keyspace-events flags
+func (n *KeyspaceNotifier) Flags() string {
+	return events.FormatFlags(events.Class(n.classes.Load()))
+}
+
+// Notify publishes an event on the keyspace and keyevent channels of
+// database 0 if its class is enabled
+func (n *KeyspaceNotifier) Notify(class events.Class, event, key string) {
+	classes := events.Class(n.classes.Load())
+	if classes&class == 0 {
+		return
+	}
+
+	if classes&events.Keyspace != 0 {
+		n.broker.Publish(fmt.Sprintf("__keyspace@%d__:%s", 0, key), event)
+	}
+
+	if classes&events.Keyevent != 0 {
+		n.broker.Publish(fmt.Sprintf("__keyevent@%d__:%s", 0, event), key)
+	}
+}
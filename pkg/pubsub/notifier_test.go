@@ -0,0 +1,84 @@
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
+)
+
+func TestKeyspaceNotifier_DisabledByDefault(t *testing.T) {
+	broker := NewBroker()
+	notifier := NewKeyspaceNotifier(broker)
+	subscriber := &mockSubscriber{}
+	broker.PSubscribe(subscriber, "*")
+
+	notifier.Notify(events.String, "set", "key")
+
+	if got := subscriber.payloads(); len(got) != 0 {
+		t.Errorf("Expected no notifications, got %v", got)
+	}
+
+	if notifier.Flags() != "" {
+		t.Errorf("Expected empty flags, got %q", notifier.Flags())
+	}
+}
+
+func TestKeyspaceNotifier_KeyspaceAndKeyevent(t *testing.T) {
+	broker := NewBroker()
+	notifier := NewKeyspaceNotifier(broker)
+	subscriber := &mockSubscriber{}
+	broker.PSubscribe(subscriber, "__key*__:*")
+
+	if err := notifier.SetFlags("KEA"); err != nil {
+		t.Fatalf("SetFlags returned error: %v", err)
+	}
+
+	notifier.Notify(events.String, "set", "mykey")
+
+	want := [][]string{
+		{"pmessage", "__key*__:*", "__keyspace@0__:mykey", "set"},
+		{"pmessage", "__key*__:*", "__keyevent@0__:set", "mykey"},
+	}
+	if got := subscriber.payloads(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestKeyspaceNotifier_ClassFiltering(t *testing.T) {
+	broker := NewBroker()
+	notifier := NewKeyspaceNotifier(broker)
+	subscriber := &mockSubscriber{}
+	broker.Subscribe(subscriber, "__keyevent@0__:expired")
+	broker.Subscribe(subscriber, "__keyevent@0__:set")
+
+	notifier.SetFlags("Ex")
+
+	notifier.Notify(events.String, "set", "ignored")
+	notifier.Notify(events.Expired, "expired", "gone")
+
+	want := [][]string{{"message", "__keyevent@0__:expired", "gone"}}
+	if got := subscriber.payloads(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// Classes without K or E produce nothing
+	notifier.SetFlags("x")
+	notifier.Notify(events.Expired, "expired", "silent")
+	if got := subscriber.payloads(); len(got) != 1 {
+		t.Errorf("Expected no notification without K or E, got %v", got)
+	}
+}
+
+func TestKeyspaceNotifier_InvalidFlags(t *testing.T) {
+	notifier := NewKeyspaceNotifier(NewBroker())
+	notifier.SetFlags("KEA")
+
+	if err := notifier.SetFlags("KEQ"); err == nil {
+		t.Fatal("Expected error for invalid flags")
+	}
+
+	if notifier.Flags() != "AKE" {
+		t.Errorf("Invalid flags must not change the configuration, got %q", notifier.Flags())
+	}
+}
@@ -2,22 +2,189 @@ package resp
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
 	"strings"
 )
 
+// Default parser limits, matching the Redis defaults
+const (
+	// DefaultMaxBulkLength is the default proto-max-bulk-len: 512MB
+	DefaultMaxBulkLength = 512 * 1024 * 1024
+	// DefaultMaxMultiBulkLength is the default maximum number of elements
+	// in an aggregate
+	DefaultMaxMultiBulkLength = 1024 * 1024
+	// DefaultMaxDepth is the default maximum nesting depth of aggregates
+	DefaultMaxDepth = 32
+	// DefaultMaxInlineSize is the default maximum length of an inline
+	// command, and of any other line
+	DefaultMaxInlineSize = 64 * 1024
+)
+
+// Limits bounds the size of the messages a parser accepts, so that a
+// client cannot make the server allocate unbounded memory
+type Limits struct {
+	// MaxBulkLength is the maximum length of a bulk string (proto-max-bulk-len)
+	MaxBulkLength int
+	// MaxMultiBulkLength is the maximum number of elements of an array,
+	// set or push, and of key-value pairs of a map
+	MaxMultiBulkLength int
+	// MaxDepth is the maximum nesting depth of aggregates. A flat array,
+	// such as a command, has depth 1.
+	MaxDepth int
+	// MaxInlineSize is the maximum length of an inline command or line
+	MaxInlineSize int
+	// Incremental reads bulk strings and aggregates as their data arrives
+	// rather than allocating their declared length first. It is meant for
+	// input whose lengths the other limits do not bound, such as
+	// append-only files, and copies long strings as they grow.
+	Incremental bool
+}
+
+// DefaultLimits returns the default parser limits
+func DefaultLimits() Limits {
+	return Limits{
+		MaxBulkLength:      DefaultMaxBulkLength,
+		MaxMultiBulkLength: DefaultMaxMultiBulkLength,
+		MaxDepth:           DefaultMaxDepth,
+		MaxInlineSize:      DefaultMaxInlineSize,
+	}
+}
+
+// ProtocolError reports input that violates the protocol or the parser
+// limits. After a protocol error the stream cannot be resynchronized, so
+// servers reply with the error and close the connection.
+type ProtocolError struct {
+	Reason string
+}
+
+// Error returns the error message, such as "Protocol error: invalid bulk length"
+func (e *ProtocolError) Error() string {
+	return "Protocol error: " + e.Reason
+}
+
+// protocolErrorf creates a ProtocolError with a formatted reason
+func protocolErrorf(format string, args ...interface{}) error {
+	return &ProtocolError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// ErrUnbalancedQuotes is returned by SplitArgs for unterminated quotes
+var ErrUnbalancedQuotes = errors.New("unbalanced quotes in request")
+
 // Parser handles parsing RESP messages from byte streams
 type Parser struct {
 	reader *bufio.Reader
+	limits Limits
+	// depth is the nesting depth of the aggregate being parsed
+	depth int
+	// arena holds the messages of the last command returned by ParseCommand
+	arena arena
+}
+
+// maxArenaArgs is the largest command whose messages are kept in the arena.
+// Larger commands are rare and allocated normally, so that a single huge
+// command does not pin its memory for the lifetime of the connection.
+const maxArenaArgs = 1024
+
+// arena recycles the messages and argument slice of parsed commands, so
+// that parsing a command does not allocate them every time
+type arena struct {
+	messages []Message
+	args     []*Message
+	// used is the number of messages handed out for the last command
+	used int
+}
+
+// alloc returns the command message and its n argument slots, reusing the
+// storage of the previous command
+func (a *arena) alloc(n int) (*Message, []*Message) {
+	// Drop the previous command's values so they can be garbage collected
+	clear(a.messages[:a.used])
+
+	if cap(a.messages) < n+1 {
+		a.messages = make([]Message, n+1)
+		a.args = make([]*Message, n+1)
+	}
+
+	a.used = n + 1
+	args := a.args[:n]
+	for i := range args {
+		args[i] = &a.messages[i]
+	}
+	return &a.messages[n], args
 }
 
-// NewParser creates a new RESP parser with the given reader
+// NewParser creates a new RESP parser with the given reader and the
+// default limits
 func NewParser(reader io.Reader) *Parser {
 	return &Parser{
 		reader: bufio.NewReader(reader),
+		limits: DefaultLimits(),
+	}
+}
+
+// SetLimits sets the limits applied to subsequent messages
+func (p *Parser) SetLimits(limits Limits) {
+	p.limits = limits
+}
+
+// Buffered returns the number of bytes already read from the input but not
+// yet parsed. A non-zero value means a pipelining client has sent more
+// commands, which can be parsed without waiting for the network.
+func (p *Parser) Buffered() int {
+	return p.reader.Buffered()
+}
+
+// CommandBuffered reports whether a complete command is buffered, so that
+// ParseCommand returns it without reading from the input. It errs on the
+// side of false for input it does not recognize, such as malformed or
+// nested commands, which the parser rejects or reads anyway.
+func (p *Parser) CommandBuffered() bool {
+	buffered, _ := p.reader.Peek(p.reader.Buffered())
+
+	for len(buffered) > 0 {
+		line, rest, ok := bytes.Cut(buffered, []byte("\n"))
+		if !ok {
+			return false
+		}
+
+		// Empty inline lines are skipped, like ParseCommand does
+		if buffered[0] != '*' {
+			if len(bytes.TrimSpace(line)) > 0 {
+				return true
+			}
+			buffered = rest
+			continue
+		}
+
+		count, ok := parseLength(bytes.TrimSuffix(line[1:], []byte("\r")))
+		if !ok {
+			return false
+		}
+		for ; count > 0; count-- {
+			if len(rest) == 0 || rest[0] != '$' {
+				return false
+			}
+			line, after, ok := bytes.Cut(rest, []byte("\n"))
+			length, valid := parseLength(bytes.TrimSuffix(line[1:], []byte("\r")))
+			switch {
+			case !ok || !valid:
+				return false
+			case length < 0:
+				// A null bulk string has no data
+				rest = after
+			case len(after) < length+2:
+				return false
+			default:
+				rest = after[length+2:]
+			}
+		}
+		return true
 	}
+	return false
 }
 
 // Parse parses a single RESP message from the input stream
@@ -39,11 +206,297 @@ func (p *Parser) Parse() (*Message, error) {
 		return p.parseBulkString()
 	case '*':
 		return p.parseArray()
+	case '_':
+		return p.parseNull()
+	case ',':
+		return p.parseDouble()
+	case '#':
+		return p.parseBoolean()
+	case '!':
+		return p.parseBlobError()
+	case '=':
+		return p.parseVerbatimString()
+	case '(':
+		return p.parseBigNumber()
+	case '%':
+		return p.parseMap(Map)
+	case '|':
+		return p.parseMap(Attribute)
+	case '~':
+		return p.parseAggregate(Set)
+	case '>':
+		return p.parseAggregate(Push)
+	default:
+		return nil, protocolErrorf("invalid message type: %c", typeByte)
+	}
+}
+
+// ParseCommand parses a single command sent by a client. Commands are
+// normally arrays of bulk strings, but like Redis, any line that does not
+// start with '*' is parsed as an inline command: space-separated arguments,
+// optionally quoted, terminated by LF or CRLF. This lets clients such as
+// telnet send commands by hand. Empty inline lines are skipped.
+//
+// The returned message and its argument messages are recycled by the next
+// call to ParseCommand. Callers that keep arguments longer, such as a
+// transaction queue, must copy them with CopyMessages. The bulk string data
+// is never recycled and may be retained without copying.
+func (p *Parser) ParseCommand() (*Message, error) {
+	for {
+		typeByte, err := p.reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message type: %w", err)
+		}
+
+		if typeByte == '*' {
+			return p.parseCommandArray()
+		}
+
+		if err := p.reader.UnreadByte(); err != nil {
+			return nil, err
+		}
+
+		args, err := p.parseInline()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(args) > 0 {
+			return NewArray(args), nil
+		}
+	}
+}
+
+// parseCommandArray parses a command array, storing the command and its
+// bulk string arguments in the arena
+func (p *Parser) parseCommandArray() (*Message, error) {
+	line, err := p.readLineBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read array length: %w", err)
+	}
+
+	length, ok := parseLength(line)
+	if !ok {
+		return nil, protocolErrorf("invalid array length: %s", line)
+	}
+
+	if length == -1 {
+		return NewNullArray(), nil
+	}
+
+	if length < 0 || length > p.limits.MaxMultiBulkLength {
+		return nil, protocolErrorf("invalid multibulk length")
+	}
+
+	if length > maxArenaArgs {
+		elements, err := p.parseElements(length)
+		if err != nil {
+			return nil, err
+		}
+		return NewArray(elements), nil
+	}
+
+	p.depth++
+	defer func() { p.depth-- }()
+
+	if p.depth > p.limits.MaxDepth {
+		return nil, protocolErrorf("exceeded maximum nesting depth")
+	}
+
+	command, args := p.arena.alloc(length)
+	for i, arg := range args {
+		typeByte, err := p.reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse array element %d: %w", i, err)
+		}
+
+		// Arguments are almost always bulk strings; anything else is
+		// parsed in full and copied into the arena
+		if typeByte != '$' {
+			if err := p.reader.UnreadByte(); err != nil {
+				return nil, err
+			}
+			element, err := p.Parse()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse array element %d: %w", i, err)
+			}
+			*arg = *element
+			continue
+		}
+
+		data, err := p.readBulk()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse array element %d: %w", i, err)
+		}
+
+		arg.Type = BulkString
+		if data != nil {
+			arg.Value = data
+		}
+	}
+
+	command.Type = Array
+	command.Value = args
+	return command, nil
+}
+
+// parseInline reads an inline command line and splits it into arguments
+func (p *Parser) parseInline() ([]*Message, error) {
+	var line []byte
+	for {
+		chunk, err := p.reader.ReadSlice('\n')
+		if len(line)+len(chunk) > p.limits.MaxInlineSize {
+			return nil, protocolErrorf("too big inline request")
+		}
+		line = append(line, chunk...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inline command: %w", err)
+		}
+		break
+	}
+
+	// Accept both LF and CRLF line endings
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+
+	words, err := SplitArgs(string(line))
+	if err != nil {
+		return nil, protocolErrorf("%v", err)
+	}
+
+	args := make([]*Message, len(words))
+	for i, word := range words {
+		args[i] = NewBulkString(word)
+	}
+	return args, nil
+}
+
+// SplitArgs splits an inline command line into arguments the way Redis
+// does. Arguments are separated by whitespace and may be quoted. Double
+// quoted arguments support the escapes \n, \r, \t, \b, \a, \\, \" and
+// \xHH; single quoted arguments only support \'. A closing quote must be
+// followed by whitespace or the end of the line.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+
+	i := 0
+	for {
+		// Skip leading whitespace
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+
+		var current strings.Builder
+		inDoubleQuotes, inSingleQuotes := false, false
+
+		for done := false; !done; {
+			switch {
+			case inDoubleQuotes:
+				if i == len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+				switch {
+				case line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHexDigit(line[i+2]) && isHexDigit(line[i+3]):
+					value, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					current.WriteByte(byte(value))
+					i += 3
+				case line[i] == '\\' && i+1 < len(line):
+					i++
+					current.WriteByte(unescape(line[i]))
+				case line[i] == '"':
+					// The closing quote must be followed by a space or nothing at all
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					current.WriteByte(line[i])
+				}
+			case inSingleQuotes:
+				if i == len(line) {
+					return nil, ErrUnbalancedQuotes
+				}
+				switch {
+				case line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					current.WriteByte('\'')
+				case line[i] == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, ErrUnbalancedQuotes
+					}
+					done = true
+				default:
+					current.WriteByte(line[i])
+				}
+			default:
+				if i == len(line) {
+					done = true
+					continue
+				}
+				switch line[i] {
+				case ' ', '\n', '\r', '\t', '\v', '\f':
+					done = true
+				case '"':
+					inDoubleQuotes = true
+				case '\'':
+					inSingleQuotes = true
+				default:
+					current.WriteByte(line[i])
+				}
+			}
+
+			if i < len(line) {
+				i++
+			}
+		}
+
+		args = append(args, current.String())
+	}
+}
+
+// unescape returns the character denoted by a backslash escape inside
+// double quotes; unknown escapes stand for the character itself
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	default:
+		return c
+	}
+}
+
+// isSpace reports whether c separates inline arguments
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\n', '\r', '\t', '\v', '\f':
+		return true
 	default:
-		return nil, fmt.Errorf("invalid message type: %c", typeByte)
+		return false
 	}
 }
 
+// isHexDigit reports whether c is a hexadecimal digit
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
 // parseSimpleString parses a simple string message (+OK\r\n)
 func (p *Parser) parseSimpleString() (*Message, error) {
 	line, err := p.readLine()
@@ -71,7 +524,7 @@ func (p *Parser) parseInteger() (*Message, error) {
 
 	value, err := strconv.ParseInt(line, 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("invalid integer format: %s", line)
+		return nil, protocolErrorf("invalid integer format: %s", line)
 	}
 
 	return NewInteger(value), nil
@@ -79,28 +532,43 @@ func (p *Parser) parseInteger() (*Message, error) {
 
 // parseBulkString parses a bulk string message ($6\r\nfoobar\r\n or $-1\r\n for null)
 func (p *Parser) parseBulkString() (*Message, error) {
-	line, err := p.readLine()
+	data, err := p.readBulk()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read bulk string length: %w", err)
+		return nil, err
+	}
+
+	// Handle null bulk string
+	if data == nil {
+		return NewNullBulkString(), nil
 	}
 
-	length, err := strconv.Atoi(line)
+	return NewBulkBytes(data), nil
+}
+
+// readBulk reads the length and data of a bulk string whose '$' prefix has
+// been consumed. It returns nil for a null bulk string, and otherwise a new
+// slice that the caller owns.
+func (p *Parser) readBulk() ([]byte, error) {
+	line, err := p.readLineBytes()
 	if err != nil {
-		return nil, fmt.Errorf("invalid bulk string length: %s", line)
+		return nil, fmt.Errorf("failed to read bulk string length: %w", err)
+	}
+
+	length, ok := parseLength(line)
+	if !ok {
+		return nil, protocolErrorf("invalid bulk string length: %s", line)
 	}
 
-	// Handle null bulk string
 	if length == -1 {
-		return NewNullBulkString(), nil
+		return nil, nil
 	}
 
-	if length < 0 {
-		return nil, fmt.Errorf("invalid bulk string length: %d", length)
+	if length < 0 || length > p.limits.MaxBulkLength {
+		return nil, protocolErrorf("invalid bulk length")
 	}
 
-	// Read the string data plus the trailing \r\n
-	data := make([]byte, length)
-	_, err = io.ReadFull(p.reader, data)
+	// Read the string data straight into the slice the message will own
+	data, err := p.readBytes(length)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read bulk string data: %w", err)
 	}
@@ -110,19 +578,19 @@ func (p *Parser) parseBulkString() (*Message, error) {
 		return nil, fmt.Errorf("missing CRLF after bulk string: %w", err)
 	}
 
-	return NewBulkString(string(data)), nil
+	return data, nil
 }
 
 // parseArray parses an array message (*2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n or *-1\r\n for null)
 func (p *Parser) parseArray() (*Message, error) {
-	line, err := p.readLine()
+	line, err := p.readLineBytes()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read array length: %w", err)
 	}
 
-	length, err := strconv.Atoi(line)
-	if err != nil {
-		return nil, fmt.Errorf("invalid array length: %s", line)
+	length, ok := parseLength(line)
+	if !ok {
+		return nil, protocolErrorf("invalid array length: %s", line)
 	}
 
 	// Handle null array
@@ -130,33 +598,296 @@ func (p *Parser) parseArray() (*Message, error) {
 		return NewNullArray(), nil
 	}
 
-	if length < 0 {
-		return nil, fmt.Errorf("invalid array length: %d", length)
+	if length < 0 || length > p.limits.MaxMultiBulkLength {
+		return nil, protocolErrorf("invalid multibulk length")
+	}
+
+	elements, err := p.parseElements(length)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewArray(elements), nil
+}
+
+// parseElements parses the given number of consecutive messages as the
+// elements of an aggregate one level deeper than the current one
+func (p *Parser) parseElements(count int) ([]*Message, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+
+	if p.depth > p.limits.MaxDepth {
+		return nil, protocolErrorf("exceeded maximum nesting depth")
 	}
 
-	// Parse each element in the array
-	elements := make([]*Message, length)
-	for i := 0; i < length; i++ {
+	// With incremental limits, the slice grows as elements arrive, so that
+	// a bogus count does not allocate memory upfront
+	size := count
+	if p.limits.Incremental {
+		size = min(count, maxArenaArgs)
+	}
+	elements := make([]*Message, 0, size)
+	for i := 0; i < count; i++ {
 		element, err := p.Parse()
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse array element %d: %w", i, err)
 		}
-		elements[i] = element
+		elements = append(elements, element)
 	}
+	return elements, nil
+}
 
-	return NewArray(elements), nil
+// parseNull parses a RESP3 null (_\r\n)
+func (p *Parser) parseNull() (*Message, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read null: %w", err)
+	}
+	if line != "" {
+		return nil, protocolErrorf("invalid null: %s", line)
+	}
+	return NewNull(), nil
+}
+
+// parseDouble parses a double (,1.23\r\n, ,inf\r\n or ,nan\r\n)
+func (p *Parser) parseDouble() (*Message, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read double: %w", err)
+	}
+
+	value, err := strconv.ParseFloat(line, 64)
+	if err != nil {
+		return nil, protocolErrorf("invalid double format: %s", line)
+	}
+
+	return NewDouble(value), nil
+}
+
+// parseBoolean parses a boolean (#t\r\n or #f\r\n)
+func (p *Parser) parseBoolean() (*Message, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read boolean: %w", err)
+	}
+
+	switch line {
+	case "t":
+		return NewBoolean(true), nil
+	case "f":
+		return NewBoolean(false), nil
+	default:
+		return nil, protocolErrorf("invalid boolean: %s", line)
+	}
+}
+
+// parseBlobError parses a blob error (!21\r\nSYNTAX invalid syntax\r\n)
+func (p *Parser) parseBlobError() (*Message, error) {
+	data, err := p.readBlob()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob error: %w", err)
+	}
+	return NewBlobError(data), nil
+}
+
+// parseVerbatimString parses a verbatim string (=15\r\ntxt:Some string\r\n)
+func (p *Parser) parseVerbatimString() (*Message, error) {
+	data, err := p.readBlob()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read verbatim string: %w", err)
+	}
+
+	if len(data) < 4 || data[3] != ':' {
+		return nil, protocolErrorf("invalid verbatim string: %q", data)
+	}
+
+	return NewVerbatimString(data[:3], data[4:]), nil
+}
+
+// parseBigNumber parses a big number ((3492890328409238509324850943850943825024385\r\n)
+func (p *Parser) parseBigNumber() (*Message, error) {
+	line, err := p.readLine()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read big number: %w", err)
+	}
+
+	digits := strings.TrimPrefix(line, "-")
+	if digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return nil, protocolErrorf("invalid big number: %s", line)
+	}
+
+	return NewBigNumber(line), nil
+}
+
+// parseMap parses a map (%2\r\n...) or attribute (|1\r\n...) whose
+// length is the number of key-value pairs
+func (p *Parser) parseMap(mapType MessageType) (*Message, error) {
+	length, err := p.readLength()
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s length: %w", mapType, err)
+	}
+
+	if length > p.limits.MaxMultiBulkLength {
+		return nil, protocolErrorf("invalid multibulk length")
+	}
+
+	pairs, err := p.parseElements(2 * length)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{Type: mapType, Value: pairs}, nil
+}
+
+// parseAggregate parses a set (~2\r\n...) or push (>2\r\n...)
+func (p *Parser) parseAggregate(aggregateType MessageType) (*Message, error) {
+	length, err := p.readLength()
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s length: %w", aggregateType, err)
+	}
+
+	if length > p.limits.MaxMultiBulkLength {
+		return nil, protocolErrorf("invalid multibulk length")
+	}
+
+	elements, err := p.parseElements(length)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{Type: aggregateType, Value: elements}, nil
+}
+
+// readLength reads a non-negative aggregate length line
+func (p *Parser) readLength() (int, error) {
+	line, err := p.readLineBytes()
+	if err != nil {
+		return 0, err
+	}
+
+	length, ok := parseLength(line)
+	if !ok || length < 0 {
+		return 0, protocolErrorf("invalid length: %s", line)
+	}
+
+	return length, nil
+}
+
+// maxLengthDigits bounds the digits of a length so parsing cannot overflow
+const maxLengthDigits = 18
+
+// parseLength parses a decimal length, which is -1 for nulls, without
+// allocating
+func parseLength(line []byte) (int, bool) {
+	negative := len(line) > 0 && line[0] == '-'
+	if negative {
+		line = line[1:]
+	}
+
+	if len(line) == 0 || len(line) > maxLengthDigits {
+		return 0, false
+	}
+
+	length := 0
+	for _, c := range line {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		length = length*10 + int(c-'0')
+	}
+
+	if negative {
+		return -length, true
+	}
+	return length, true
+}
+
+// readBlob reads a length-prefixed payload followed by CRLF
+func (p *Parser) readBlob() (string, error) {
+	length, err := p.readLength()
+	if err != nil {
+		return "", err
+	}
+
+	if length > p.limits.MaxBulkLength {
+		return "", protocolErrorf("invalid bulk length")
+	}
+
+	data, err := p.readBytes(length)
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.expectCRLF(); err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// bulkChunkSize is the length up to which a payload is allocated before it
+// is read
+const bulkChunkSize = 64 * 1024
+
+// readBytes reads a payload of length bytes into a buffer of exactly that
+// length. With incremental limits, longer payloads are read in chunks,
+// doubling the buffer as data arrives, so that a length the data never
+// reaches fails at the end of the input instead of allocating a huge
+// buffer first.
+func (p *Parser) readBytes(length int) ([]byte, error) {
+	size := length
+	if p.limits.Incremental {
+		size = min(length, bulkChunkSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(p.reader, data); err != nil {
+		return nil, err
+	}
+
+	for len(data) < length {
+		grown := make([]byte, min(length, 2*len(data)))
+		copy(grown, data)
+		if _, err := io.ReadFull(p.reader, grown[len(data):]); err != nil {
+			return nil, err
+		}
+		data = grown
+	}
+	return data, nil
 }
 
 // readLine reads a line terminated by \r\n and returns the content without the terminator
 func (p *Parser) readLine() (string, error) {
-	line, err := p.reader.ReadString('\n')
+	line, err := p.readLineBytes()
 	if err != nil {
 		return "", err
 	}
+	return string(line), nil
+}
+
+// readLineBytes reads a line terminated by \r\n and returns the content
+// without the terminator. The returned slice is only valid until the next
+// read from the parser.
+func (p *Parser) readLineBytes() ([]byte, error) {
+	line, err := p.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		// The line is longer than the buffer, so collect it in a new slice
+		long := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			line, err = p.reader.ReadSlice('\n')
+			long = append(long, line...)
+			if len(long) > p.limits.MaxInlineSize {
+				return nil, protocolErrorf("too big line")
+			}
+		}
+		line = long
+	}
+	if err != nil {
+		return nil, err
+	}
 
 	// Remove \r\n terminator
-	if len(line) < 2 || line[len(line)-2:] != "\r\n" {
-		return "", fmt.Errorf("line not terminated with CRLF: %q", line)
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, protocolErrorf("line not terminated with CRLF: %q", line)
 	}
 
 	return line[:len(line)-2], nil
@@ -169,7 +900,7 @@ func (p *Parser) expectCRLF() error {
 		return err
 	}
 	if cr != '\r' {
-		return fmt.Errorf("expected \\r, got %c", cr)
+		return protocolErrorf("expected \\r, got %c", cr)
 	}
 
 	lf, err := p.reader.ReadByte()
@@ -177,7 +908,7 @@ func (p *Parser) expectCRLF() error {
 		return err
 	}
 	if lf != '\n' {
-		return fmt.Errorf("expected \\n, got %c", lf)
+		return protocolErrorf("expected \\n, got %c", lf)
 	}
 
 	return nil
@@ -1,6 +1,13 @@
 package resp
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -178,8 +185,8 @@ func TestParseBulkString(t *testing.T) {
 					t.Error("Expected null bulk string")
 				}
 			} else {
-				if msg.Value != test.expected {
-					t.Errorf("Expected value %q, got %v", test.expected, msg.Value)
+				if value, _ := msg.AsString(); value != test.expected {
+					t.Errorf("Expected value %q, got %q", test.expected, value)
 				}
 			}
 		})
@@ -278,8 +285,8 @@ func TestSpecificJiraTestCases(t *testing.T) {
 				if arr[0].Type != BulkString {
 					t.Errorf("Expected first element to be BulkString, got %s", arr[0].Type)
 				}
-				if arr[0].Value != "ping" {
-					t.Errorf("Expected first element value 'ping', got %v", arr[0].Value)
+				if value, _ := arr[0].AsString(); value != "ping" {
+					t.Errorf("Expected first element value 'ping', got %q", value)
 				}
 			},
 		},
@@ -369,6 +376,26 @@ func TestParseInvalidMessages(t *testing.T) {
 			name:  "Line with only LF",
 			input: "+OK\n",
 		},
+		{
+			name:  "Invalid boolean",
+			input: "#x\r\n",
+		},
+		{
+			name:  "Invalid double",
+			input: ",abc\r\n",
+		},
+		{
+			name:  "Invalid big number",
+			input: "(12a\r\n",
+		},
+		{
+			name:  "Verbatim string without format",
+			input: "=2\r\nab\r\n",
+		},
+		{
+			name:  "Incomplete map",
+			input: "%1\r\n+key\r\n",
+		},
 	}
 
 	for _, test := range tests {
@@ -381,6 +408,43 @@ func TestParseInvalidMessages(t *testing.T) {
 	}
 }
 
+func TestParseRESP3(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *Message
+	}{
+		{"Null", "_\r\n", NewNull()},
+		{"Double", ",3.14\r\n", NewDouble(3.14)},
+		{"Negative infinity", ",-inf\r\n", NewDouble(math.Inf(-1))},
+		{"True", "#t\r\n", NewBoolean(true)},
+		{"False", "#f\r\n", NewBoolean(false)},
+		{"Blob error", "!21\r\nSYNTAX invalid syntax\r\n", NewBlobError("SYNTAX invalid syntax")},
+		{"Verbatim string", "=15\r\ntxt:Some string\r\n", NewVerbatimString("txt", "Some string")},
+		{"Big number", "(-3492890328409238509324850943850943825024385\r\n",
+			NewBigNumber("-3492890328409238509324850943850943825024385")},
+		{"Map", "%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n", NewMap([]*Message{
+			NewSimpleString("first"), NewInteger(1), NewSimpleString("second"), NewInteger(2),
+		})},
+		{"Set", "~2\r\n+a\r\n+b\r\n", NewSet([]*Message{NewSimpleString("a"), NewSimpleString("b")})},
+		{"Attribute", "|1\r\n+ttl\r\n:3600\r\n", NewAttribute([]*Message{NewSimpleString("ttl"), NewInteger(3600)})},
+		{"Push", ">2\r\n+message\r\n$5\r\nhello\r\n", NewPush([]*Message{NewSimpleString("message"), NewBulkString("hello")})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, err := ParseString(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(msg, test.expected) {
+				t.Errorf("Expected %s, got %s", test.expected, msg)
+			}
+		})
+	}
+}
+
 func TestComplexNestedArray(t *testing.T) {
 	// Test parsing a complex nested array structure
 	input := "*2\r\n*3\r\n:1\r\n:2\r\n:3\r\n*2\r\n+Hello\r\n-World\r\n"
@@ -425,3 +489,313 @@ func TestComplexNestedArray(t *testing.T) {
 		t.Errorf("Expected first element of second sub-array to be 'Hello', got %v", subArr2[0].Value)
 	}
 }
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"Single word", "PING", []string{"PING"}},
+		{"Multiple spaces", "  SET   key\tvalue ", []string{"SET", "key", "value"}},
+		{"Empty line", "   ", nil},
+		{"Double quotes", `SET key "hello world"`, []string{"SET", "key", "hello world"}},
+		{"Escapes", `ECHO "a\nb\t\"c\"\\"`, []string{"ECHO", "a\nb\t\"c\"\\"}},
+		{"Hex escape", `ECHO "\x41\x7a"`, []string{"ECHO", "Az"}},
+		{"Single quotes", `ECHO 'it\'s "raw" \n'`, []string{"ECHO", `it's "raw" \n`}},
+		{"Empty quoted argument", `SET key ""`, []string{"SET", "key", ""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, err := SplitArgs(test.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(args, test.expected) {
+				t.Errorf("Expected %q, got %q", test.expected, args)
+			}
+		})
+	}
+}
+
+func TestSplitArgsUnbalancedQuotes(t *testing.T) {
+	for _, input := range []string{`ECHO "open`, `ECHO 'open`, `ECHO "closed"trailing`, `ECHO 'closed'x`} {
+		if _, err := SplitArgs(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"RESP array", "*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n", []string{"ECHO", "hi"}},
+		{"Inline with CRLF", "PING\r\n", []string{"PING"}},
+		{"Inline with LF", "SET key value\n", []string{"SET", "key", "value"}},
+		{"Empty lines are skipped", "\r\n\nPING\n", []string{"PING"}},
+		{"Inline with quotes", "SET key \"a b\"\r\n", []string{"SET", "key", "a b"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := NewParser(strings.NewReader(test.input))
+			msg, err := parser.ParseCommand()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			elements, err := msg.AsArray()
+			if err != nil {
+				t.Fatalf("Expected array, got %s", msg)
+			}
+
+			args := make([]string, len(elements))
+			for i, element := range elements {
+				args[i], _ = element.AsString()
+			}
+
+			if !reflect.DeepEqual(args, test.expected) {
+				t.Errorf("Expected %q, got %q", test.expected, args)
+			}
+		})
+	}
+}
+
+func TestParseCommandInlineLimits(t *testing.T) {
+	parser := NewParser(strings.NewReader(strings.Repeat("a", DefaultMaxInlineSize+1) + "\n"))
+	expectProtocolError(t, parser, "too big inline request")
+
+	parser = NewParser(strings.NewReader("PING"))
+	if _, err := parser.ParseCommand(); err == nil {
+		t.Error("Expected error for unterminated inline command")
+	}
+
+	parser = NewParser(strings.NewReader("ECHO \"open\r\n"))
+	expectProtocolError(t, parser, "unbalanced quotes in request")
+}
+
+// expectProtocolError parses a command and checks that it fails with a
+// protocol error with the given reason
+func expectProtocolError(t *testing.T, parser *Parser, reason string) {
+	t.Helper()
+
+	_, err := parser.ParseCommand()
+
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) {
+		t.Fatalf("Expected protocol error, got %v", err)
+	}
+
+	if protocolErr.Reason != reason {
+		t.Errorf("Expected reason %q, got %q", reason, protocolErr.Reason)
+	}
+}
+
+func TestParserLimits(t *testing.T) {
+	limits := Limits{
+		MaxBulkLength:      8,
+		MaxMultiBulkLength: 4,
+		MaxDepth:           2,
+		MaxInlineSize:      16,
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		reason string
+	}{
+		{"Bulk string too long", "*1\r\n$9\r\n", "invalid bulk length"},
+		{"Huge bulk length", "*1\r\n$2000000000\r\n", "invalid bulk length"},
+		{"Too many elements", "*5\r\n", "invalid multibulk length"},
+		{"Map with too many pairs", "*1\r\n%5\r\n", "invalid multibulk length"},
+		{"Nested too deeply", "*1\r\n*1\r\n*1\r\n", "exceeded maximum nesting depth"},
+		{"Inline command too long", strings.Repeat("a", 17) + "\r\n", "too big inline request"},
+		{"Invalid length", "*x\r\n", "invalid array length: x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := NewParser(strings.NewReader(test.input))
+			parser.SetLimits(limits)
+			expectProtocolError(t, parser, test.reason)
+		})
+	}
+
+	// Messages within the limits are still accepted
+	parser := NewParser(strings.NewReader("*2\r\n*1\r\n$8\r\n12345678\r\n:1\r\n"))
+	parser.SetLimits(limits)
+	if _, err := parser.ParseCommand(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestParseLongLineLimit(t *testing.T) {
+	parser := NewParser(strings.NewReader("+" + strings.Repeat("a", DefaultMaxInlineSize+1) + "\r\n"))
+	if _, err := parser.Parse(); err == nil {
+		t.Error("Expected error for line longer than the limit")
+	}
+}
+
+// cyclicReader endlessly repeats its data, so benchmarks can parse the same
+// input many times without allocating a new parser
+type cyclicReader struct {
+	data   []byte
+	offset int
+}
+
+func (r *cyclicReader) Read(b []byte) (int, error) {
+	n := copy(b, r.data[r.offset:])
+	r.offset = (r.offset + n) % len(r.data)
+	return n, nil
+}
+
+// benchmarkParseSet parses a SET command whose value has the given size
+func benchmarkParseSet(b *testing.B, size int) {
+	value := strings.Repeat("v", size)
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$" + strconv.Itoa(size) + "\r\n" + value + "\r\n"
+	parser := NewParser(&cyclicReader{data: []byte(input)})
+
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.ParseCommand(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseSet16B(b *testing.B)  { benchmarkParseSet(b, 16) }
+func BenchmarkParseSet16KB(b *testing.B) { benchmarkParseSet(b, 16*1024) }
+func BenchmarkParseSet1MB(b *testing.B)  { benchmarkParseSet(b, 1024*1024) }
+
+func TestParseCommandRecyclesArguments(t *testing.T) {
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nfirst\r\n" +
+		"*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$6\r\nsecond\r\n"
+	parser := NewParser(strings.NewReader(input))
+
+	first, err := parser.ParseCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	args, _ := first.AsArray()
+	kept := CopyMessages(args)
+	value := args[2].Value
+
+	if _, err := parser.ParseCommand(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Copies and bulk data outlive the next parse
+	if got, _ := kept[2].AsString(); got != "first" {
+		t.Errorf("Expected copied argument %q, got %q", "first", got)
+	}
+	if got := string(value.([]byte)); got != "first" {
+		t.Errorf("Expected bulk data %q, got %q", "first", got)
+	}
+}
+
+func TestParseCommandLargeArray(t *testing.T) {
+	var input strings.Builder
+	fmt.Fprintf(&input, "*%d\r\n", maxArenaArgs+1)
+	for i := 0; i <= maxArenaArgs; i++ {
+		input.WriteString("$1\r\nx\r\n")
+	}
+
+	parser := NewParser(strings.NewReader(input.String()))
+	msg, err := parser.ParseCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	elements, _ := msg.AsArray()
+	if len(elements) != maxArenaArgs+1 {
+		t.Fatalf("Expected %d elements, got %d", maxArenaArgs+1, len(elements))
+	}
+}
+
+func TestParseLengthsBeyondInput(t *testing.T) {
+	limits := Limits{
+		MaxBulkLength:      math.MaxInt,
+		MaxMultiBulkLength: math.MaxInt,
+		MaxDepth:           2,
+		MaxInlineSize:      DefaultMaxInlineSize,
+		Incremental:        true,
+	}
+	large := strings.Repeat("x", 3*bulkChunkSize)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Huge bulk length", "*1\r\n$999999999999999999\r\nabc\r\n"},
+		{"Bulk length past several chunks", "*1\r\n$80000000000\r\n" + large},
+		{"Huge multibulk length", "*999999999999999999\r\n$1\r\nx\r\n"},
+		{"Huge nested multibulk length", "*1\r\n*999999999999999999\r\n"},
+		{"Huge blob error length", "!999999999999999999\r\nERR\r\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := NewParser(strings.NewReader(test.input))
+			parser.SetLimits(limits)
+			_, err := parser.Parse()
+			if !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+				t.Errorf("Expected the input to end early, got %v", err)
+			}
+		})
+	}
+
+	// A bulk string longer than a chunk is read whole, incrementally or not
+	for _, incremental := range []bool{true, false} {
+		parser := NewParser(strings.NewReader("$" + strconv.Itoa(len(large)) + "\r\n" + large + "\r\n"))
+		parser.SetLimits(Limits{MaxBulkLength: len(large), MaxDepth: 1, MaxInlineSize: DefaultMaxInlineSize, Incremental: incremental})
+		msg, err := parser.Parse()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if data := msg.Value.([]byte); string(data) != large || cap(data) != len(large) {
+			t.Errorf("Expected a %d byte string, got %d bytes with capacity %d", len(large), len(data), cap(data))
+		}
+	}
+}
+
+func TestParserCommandBuffered(t *testing.T) {
+	tests := []struct {
+		name     string
+		buffered string
+		expected bool
+	}{
+		{"nothing", "", false},
+		{"command", "*1\r\n$4\r\nPING\r\n", true},
+		{"command with null", "*2\r\n$4\r\nECHO\r\n$-1\r\n", true},
+		{"partial length", "*2\r\n$4", false},
+		{"partial bulk", "*1\r\n$4\r\nPI", false},
+		{"missing CRLF", "*1\r\n$4\r\nPING", false},
+		{"missing argument", "*2\r\n$4\r\nECHO\r\n", false},
+		{"inline command", "PING\r\n", true},
+		{"partial inline command", "PI", false},
+		{"empty lines", "\r\n\r\n", false},
+		{"empty line before command", "\r\nPING\n", true},
+		{"nested array", "*1\r\n*1\r\n$4\r\nPING\r\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Parsing a first command fills the buffer with the rest
+			parser := NewParser(strings.NewReader("PING\r\n" + tt.buffered))
+			if _, err := parser.ParseCommand(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := parser.CommandBuffered(); got != tt.expected {
+				t.Errorf("Expected %v for %q, got %v", tt.expected, tt.buffered, got)
+			}
+		})
+	}
+}
@@ -1,25 +1,128 @@
 package resp
 
 import (
+	"bufio"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
 	"strings"
 )
 
-// Serializer handles serializing RESP messages to byte streams
+// Protocol versions understood by the serializer
+const (
+	RESP2 = 2
+	RESP3 = 3
+)
+
+// DefaultWriteBufferSize is the size of the serializer's output buffer
+const DefaultWriteBufferSize = 16 * 1024
+
+// Serializer handles serializing RESP messages to byte streams.
+//
+// Messages are written to an internal buffer and only reach the underlying
+// writer when the buffer fills up or Flush is called, so that several
+// replies can be sent with a single write. Callers must call Flush once
+// they have serialized everything they want to send.
 type Serializer struct {
-	writer io.Writer
+	writer *bufio.Writer
+	// protocol is the protocol version the peer speaks. Under RESP2 the
+	// RESP3 types are downgraded to their closest RESP2 equivalent, so
+	// commands can always reply with the richest type.
+	protocol int
+	// scratch holds length headers while they are written, to avoid
+	// allocating for each one
+	scratch []byte
 }
 
-// NewSerializer creates a new RESP serializer with the given writer
+// crlf terminates every RESP line
+var crlf = []byte("\r\n")
+
+// NewSerializer creates a new RESP serializer with the given writer.
+// It speaks RESP2 until SetProtocol is called.
 func NewSerializer(writer io.Writer) *Serializer {
 	return &Serializer{
-		writer: writer,
+		writer:   bufio.NewWriterSize(writer, DefaultWriteBufferSize),
+		protocol: RESP2,
+		scratch:  make([]byte, 0, 32),
 	}
 }
 
-// Serialize serializes a RESP message to the output stream
+// SetProtocol sets the protocol version used for subsequent messages
+func (s *Serializer) SetProtocol(version int) {
+	s.protocol = version
+}
+
+// Protocol returns the protocol version used by the serializer
+func (s *Serializer) Protocol() int {
+	return s.protocol
+}
+
+// Flush writes any buffered data to the underlying writer
+func (s *Serializer) Flush() error {
+	return s.writer.Flush()
+}
+
+// Buffered returns the number of bytes waiting to be flushed
+func (s *Serializer) Buffered() int {
+	return s.writer.Buffered()
+}
+
+// Serialize serializes a RESP message to the output buffer. The message is
+// not sent until Flush is called.
 func (s *Serializer) Serialize(message *Message) error {
+	if s.protocol < RESP3 {
+		return s.serializeRESP2(message)
+	}
+
+	switch message.Type {
+	case SimpleString:
+		return s.serializeSimpleString(message.Value.(string))
+	case Error:
+		return s.serializeError(message.Value.(string))
+	case Integer:
+		return s.serializeInteger(message.Value.(int64))
+	case BulkString:
+		if message.Value == nil {
+			return s.serializeNull()
+		}
+		return s.serializeBulkString(message.Value.([]byte))
+	case Array:
+		if message.Value == nil {
+			return s.serializeNull()
+		}
+		return s.serializeAggregate('*', message.Value.([]*Message))
+	case Null:
+		return s.serializeNull()
+	case Double:
+		return s.serializeDouble(message.Value.(float64))
+	case Boolean:
+		return s.serializeBoolean(message.Value.(bool))
+	case BlobError:
+		return s.serializeBlob('!', message.Value.(string))
+	case VerbatimString:
+		return s.serializeVerbatim(message.Value.(Verbatim))
+	case BigNumber:
+		return s.writeLine('(', message.Value.(string))
+	case Map:
+		return s.serializeMap('%', message.Value.([]*Message))
+	case Attribute:
+		return s.serializeMap('|', message.Value.([]*Message))
+	case Set:
+		return s.serializeAggregate('~', message.Value.([]*Message))
+	case Push:
+		return s.serializeAggregate('>', message.Value.([]*Message))
+	default:
+		return fmt.Errorf("unsupported message type: %s", message.Type)
+	}
+}
+
+// serializeRESP2 serializes a message for a RESP2 peer, downgrading the
+// RESP3 types: maps are flattened to arrays of alternating keys and values,
+// sets and pushes become arrays, booleans become 1 or 0, and doubles, big
+// numbers and verbatim strings become bulk strings. Attributes carry
+// optional metadata and are dropped.
+func (s *Serializer) serializeRESP2(message *Message) error {
 	switch message.Type {
 	case SimpleString:
 		return s.serializeSimpleString(message.Value.(string))
@@ -28,9 +131,31 @@ func (s *Serializer) Serialize(message *Message) error {
 	case Integer:
 		return s.serializeInteger(message.Value.(int64))
 	case BulkString:
-		return s.serializeBulkString(message.Value)
+		if message.Value == nil {
+			return s.serializeNullBulkString()
+		}
+		return s.serializeBulkString(message.Value.([]byte))
 	case Array:
 		return s.serializeArray(message.Value)
+	case Null:
+		return s.serializeNullBulkString()
+	case Double:
+		return s.serializeBulkString([]byte(formatDouble(message.Value.(float64))))
+	case Boolean:
+		if message.Value.(bool) {
+			return s.serializeInteger(1)
+		}
+		return s.serializeInteger(0)
+	case BlobError:
+		return s.serializeError(message.Value.(string))
+	case VerbatimString:
+		return s.serializeBulkString([]byte(message.Value.(Verbatim).Text))
+	case BigNumber:
+		return s.serializeBulkString([]byte(message.Value.(string)))
+	case Map, Set, Push:
+		return s.serializeAggregate('*', message.Value.([]*Message))
+	case Attribute:
+		return nil
 	default:
 		return fmt.Errorf("unsupported message type: %s", message.Type)
 	}
@@ -43,8 +168,7 @@ func (s *Serializer) serializeSimpleString(value string) error {
 		return fmt.Errorf("simple string cannot contain CR or LF characters")
 	}
 
-	_, err := fmt.Fprintf(s.writer, "+%s\r\n", value)
-	return err
+	return s.writeLine('+', value)
 }
 
 // serializeError serializes an error message (-Error message\r\n)
@@ -54,41 +178,59 @@ func (s *Serializer) serializeError(value string) error {
 		return fmt.Errorf("error message cannot contain CR or LF characters")
 	}
 
-	_, err := fmt.Fprintf(s.writer, "-%s\r\n", value)
-	return err
+	return s.writeLine('-', value)
 }
 
 // serializeInteger serializes an integer (:1000\r\n)
 func (s *Serializer) serializeInteger(value int64) error {
-	_, err := fmt.Fprintf(s.writer, ":%d\r\n", value)
+	s.scratch = append(s.scratch[:0], ':')
+	s.scratch = strconv.AppendInt(s.scratch, value, 10)
+	s.scratch = append(s.scratch, crlf...)
+
+	_, err := s.writer.Write(s.scratch)
 	return err
 }
 
-// serializeBulkString serializes a bulk string ($6\r\nfoobar\r\n or $-1\r\n for null)
-func (s *Serializer) serializeBulkString(value interface{}) error {
-	if value == nil {
-		// Null bulk string
-		_, err := s.writer.Write([]byte("$-1\r\n"))
-		return err
-	}
-
-	str := value.(string)
-	length := len(str)
+// writeLine writes a type prefix followed by a line of text and CRLF
+func (s *Serializer) writeLine(prefix byte, value string) error {
+	s.writer.WriteByte(prefix)
+	s.writer.WriteString(value)
+	_, err := s.writer.Write(crlf)
+	return err
+}
 
+// serializeBulkString serializes a bulk string ($6\r\nfoobar\r\n). Small
+// values are copied into the output buffer; values larger than the buffer
+// are written straight to the underlying writer.
+func (s *Serializer) serializeBulkString(value []byte) error {
 	// Write length header
-	_, err := fmt.Fprintf(s.writer, "$%d\r\n", length)
-	if err != nil {
+	if err := s.writeHeader('$', len(value)); err != nil {
 		return err
 	}
 
 	// Write the string data
-	_, err = s.writer.Write([]byte(str))
-	if err != nil {
+	if _, err := s.writer.Write(value); err != nil {
 		return err
 	}
 
 	// Write trailing CRLF
-	_, err = s.writer.Write([]byte("\r\n"))
+	_, err := s.writer.Write(crlf)
+	return err
+}
+
+// serializeNullBulkString serializes a null bulk string ($-1\r\n)
+func (s *Serializer) serializeNullBulkString() error {
+	_, err := s.writer.WriteString("$-1\r\n")
+	return err
+}
+
+// writeHeader writes a type prefix followed by a length and CRLF
+func (s *Serializer) writeHeader(prefix byte, length int) error {
+	s.scratch = append(s.scratch[:0], prefix)
+	s.scratch = strconv.AppendInt(s.scratch, int64(length), 10)
+	s.scratch = append(s.scratch, crlf...)
+
+	_, err := s.writer.Write(s.scratch)
 	return err
 }
 
@@ -96,21 +238,23 @@ func (s *Serializer) serializeBulkString(value interface{}) error {
 func (s *Serializer) serializeArray(value interface{}) error {
 	if value == nil {
 		// Null array
-		_, err := s.writer.Write([]byte("*-1\r\n"))
+		_, err := s.writer.WriteString("*-1\r\n")
 		return err
 	}
 
-	array := value.([]*Message)
-	length := len(array)
+	return s.serializeAggregate('*', value.([]*Message))
+}
 
+// serializeAggregate serializes an array, set or push: the prefix, the
+// number of elements and then each element
+func (s *Serializer) serializeAggregate(prefix byte, elements []*Message) error {
 	// Write length header
-	_, err := fmt.Fprintf(s.writer, "*%d\r\n", length)
-	if err != nil {
+	if err := s.writeHeader(prefix, len(elements)); err != nil {
 		return err
 	}
 
 	// Serialize each element
-	for i, element := range array {
+	for i, element := range elements {
 		if err := s.Serialize(element); err != nil {
 			return fmt.Errorf("failed to serialize array element %d: %w", i, err)
 		}
@@ -119,13 +263,97 @@ func (s *Serializer) serializeArray(value interface{}) error {
 	return nil
 }
 
+// serializeMap serializes a map (%1\r\n+key\r\n+value\r\n) or attribute,
+// whose header carries the number of pairs rather than elements
+func (s *Serializer) serializeMap(prefix byte, pairs []*Message) error {
+	if len(pairs)%2 != 0 {
+		return fmt.Errorf("%c map has an odd number of elements", prefix)
+	}
+
+	if err := s.writeHeader(prefix, len(pairs)/2); err != nil {
+		return err
+	}
+
+	for i, element := range pairs {
+		if err := s.Serialize(element); err != nil {
+			return fmt.Errorf("failed to serialize map element %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// serializeNull serializes a RESP3 null (_\r\n)
+func (s *Serializer) serializeNull() error {
+	_, err := s.writer.WriteString("_\r\n")
+	return err
+}
+
+// serializeDouble serializes a double (,3.14\r\n)
+func (s *Serializer) serializeDouble(value float64) error {
+	return s.writeLine(',', formatDouble(value))
+}
+
+// serializeBoolean serializes a boolean (#t\r\n or #f\r\n)
+func (s *Serializer) serializeBoolean(value bool) error {
+	if value {
+		_, err := s.writer.WriteString("#t\r\n")
+		return err
+	}
+	_, err := s.writer.WriteString("#f\r\n")
+	return err
+}
+
+// serializeBlob serializes a length-prefixed payload such as a blob error
+// (!10\r\nERR failed\r\n)
+func (s *Serializer) serializeBlob(prefix byte, value string) error {
+	if err := s.writeHeader(prefix, len(value)); err != nil {
+		return err
+	}
+
+	s.writer.WriteString(value)
+	_, err := s.writer.Write(crlf)
+	return err
+}
+
+// serializeVerbatim serializes a verbatim string (=15\r\ntxt:Some string\r\n)
+func (s *Serializer) serializeVerbatim(value Verbatim) error {
+	if err := s.writeHeader('=', len(value.Format)+1+len(value.Text)); err != nil {
+		return err
+	}
+
+	s.writer.WriteString(value.Format)
+	s.writer.WriteByte(':')
+	s.writer.WriteString(value.Text)
+	_, err := s.writer.Write(crlf)
+	return err
+}
+
+// formatDouble formats a double the way Redis does, using inf, -inf and nan
+// for the special values
+func formatDouble(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
 // SerializeToString is a convenience function to serialize a RESP message to a string
 func SerializeToString(message *Message) (string, error) {
 	var builder strings.Builder
 	serializer := NewSerializer(&builder)
 
-	err := serializer.Serialize(message)
-	if err != nil {
+	if err := serializer.Serialize(message); err != nil {
+		return "", err
+	}
+
+	if err := serializer.Flush(); err != nil {
 		return "", err
 	}
 
@@ -1,6 +1,10 @@
 package resp
 
 import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -337,7 +341,96 @@ func TestSerializeToBytes(t *testing.T) {
 	}
 }
 
+func TestSerializeBuffersUntilFlush(t *testing.T) {
+	var buffer bytes.Buffer
+	serializer := NewSerializer(&buffer)
+
+	if err := serializer.Serialize(NewSimpleString("OK")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := serializer.Serialize(NewInteger(1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if buffer.Len() != 0 {
+		t.Fatalf("Expected nothing written before Flush, got %q", buffer.String())
+	}
+	if serializer.Buffered() != 9 {
+		t.Errorf("Expected 9 buffered bytes, got %d", serializer.Buffered())
+	}
+
+	if err := serializer.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if buffer.String() != "+OK\r\n:1\r\n" {
+		t.Errorf("Expected %q, got %q", "+OK\r\n:1\r\n", buffer.String())
+	}
+}
+
 // Test round-trip serialization and parsing
+func TestSerializeProtocolVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *Message
+		resp2   string
+		resp3   string
+	}{
+		{"Null", NewNull(), "$-1\r\n", "_\r\n"},
+		{"Null bulk string", NewNullBulkString(), "$-1\r\n", "_\r\n"},
+		{"Null array", NewNullArray(), "*-1\r\n", "_\r\n"},
+		{"Double", NewDouble(1.5), "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{"Infinity", NewDouble(math.Inf(1)), "$3\r\ninf\r\n", ",inf\r\n"},
+		{"Boolean", NewBoolean(true), ":1\r\n", "#t\r\n"},
+		{"Blob error", NewBlobError("ERR failed"), "-ERR failed\r\n", "!10\r\nERR failed\r\n"},
+		{"Verbatim string", NewVerbatimString("txt", "hi"), "$2\r\nhi\r\n", "=6\r\ntxt:hi\r\n"},
+		{"Big number", NewBigNumber("123"), "$3\r\n123\r\n", "(123\r\n"},
+		{
+			name:    "Map",
+			message: NewMap([]*Message{NewBulkString("a"), NewInteger(1)}),
+			resp2:   "*2\r\n$1\r\na\r\n:1\r\n",
+			resp3:   "%1\r\n$1\r\na\r\n:1\r\n",
+		},
+		{"Set", NewSet([]*Message{NewInteger(1)}), "*1\r\n:1\r\n", "~1\r\n:1\r\n"},
+		{"Push", NewPush([]*Message{NewInteger(1)}), "*1\r\n:1\r\n", ">1\r\n:1\r\n"},
+		{"Attribute", NewAttribute([]*Message{NewBulkString("a"), NewInteger(1)}), "", "|1\r\n$1\r\na\r\n:1\r\n"},
+		{
+			name:    "Nested map in array",
+			message: NewArray([]*Message{NewMap([]*Message{NewBulkString("a"), NewBoolean(false)})}),
+			resp2:   "*1\r\n*2\r\n$1\r\na\r\n:0\r\n",
+			resp3:   "*1\r\n%1\r\n$1\r\na\r\n#f\r\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for version, expected := range map[int]string{RESP2: test.resp2, RESP3: test.resp3} {
+				var builder strings.Builder
+				serializer := NewSerializer(&builder)
+				serializer.SetProtocol(version)
+
+				if err := serializer.Serialize(test.message); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				serializer.Flush()
+
+				if builder.String() != expected {
+					t.Errorf("RESP%d: Expected %q, got %q", version, expected, builder.String())
+				}
+			}
+		})
+	}
+}
+
+func TestSerializeOddMap(t *testing.T) {
+	var builder strings.Builder
+	serializer := NewSerializer(&builder)
+	serializer.SetProtocol(RESP3)
+
+	if err := serializer.Serialize(NewMap([]*Message{NewBulkString("key")})); err == nil {
+		t.Error("Expected error for map with an odd number of elements")
+	}
+}
+
 func TestRoundTripSerialization(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -432,8 +525,8 @@ func compareMessages(t *testing.T, expected, actual *Message) {
 	case BulkString:
 		if expected.IsNull() != actual.IsNull() {
 			t.Errorf("Null mismatch: expected null=%v, got null=%v", expected.IsNull(), actual.IsNull())
-		} else if !expected.IsNull() && expected.Value != actual.Value {
-			t.Errorf("Value mismatch: expected %v, got %v", expected.Value, actual.Value)
+		} else if !expected.IsNull() && !bytes.Equal(expected.Value.([]byte), actual.Value.([]byte)) {
+			t.Errorf("Value mismatch: expected %q, got %q", expected.Value, actual.Value)
 		}
 	case Array:
 		if expected.IsNull() != actual.IsNull() {
@@ -451,3 +544,64 @@ func compareMessages(t *testing.T, expected, actual *Message) {
 		}
 	}
 }
+
+// benchmarkSerializeBulkString serializes a bulk string of the given size,
+// as in a GET reply
+func benchmarkSerializeBulkString(b *testing.B, size int) {
+	message := NewBulkString(strings.Repeat("v", size))
+	serializer := NewSerializer(io.Discard)
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := serializer.Serialize(message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSerializeBulkString16B(b *testing.B)  { benchmarkSerializeBulkString(b, 16) }
+func BenchmarkSerializeBulkString16KB(b *testing.B) { benchmarkSerializeBulkString(b, 16*1024) }
+func BenchmarkSerializeBulkString1MB(b *testing.B)  { benchmarkSerializeBulkString(b, 1024*1024) }
+
+// countingWriter counts the writes reaching the underlying stream, each of
+// which would be a system call on a network connection
+type countingWriter struct {
+	writes int
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.writes++
+	return len(b), nil
+}
+
+// BenchmarkSerializeReplies serializes a typical mix of replies: a status,
+// an integer, a bulk string and an array of bulk strings
+func BenchmarkSerializeReplies(b *testing.B) {
+	replies := []*Message{
+		NewSimpleString("OK"),
+		NewInteger(42),
+		NewBulkString("value"),
+		NewArray([]*Message{NewBulkString("first"), NewBulkString("second"), NewNullBulkString()}),
+	}
+	writer := &countingWriter{}
+	serializer := NewSerializer(writer)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, reply := range replies {
+			if err := serializer.Serialize(reply); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := serializer.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportMetric(float64(writer.writes)/float64(b.N), "writes/op")
+}
@@ -18,8 +18,38 @@ const (
 	BulkString
 	// Array represents an array (*)
 	Array
+
+	// The following types were introduced in RESP3
+
+	// Null represents a null value (_)
+	Null
+	// Double represents a floating point number (,)
+	Double
+	// Boolean represents a boolean (#)
+	Boolean
+	// BlobError represents a binary-safe error (!)
+	BlobError
+	// VerbatimString represents a string with a format hint (=)
+	VerbatimString
+	// BigNumber represents an arbitrarily large integer (()
+	BigNumber
+	// Map represents an ordered map of key-value pairs (%)
+	Map
+	// Set represents an unordered collection of distinct elements (~)
+	Set
+	// Attribute represents out-of-band metadata preceding a reply (|)
+	Attribute
+	// Push represents out-of-band data pushed by the server (>)
+	Push
 )
 
+// Verbatim is the value of a VerbatimString message
+type Verbatim struct {
+	// Format is the three character format hint, such as "txt" or "mkd"
+	Format string
+	Text   string
+}
+
 // String returns the string representation of the MessageType
 func (mt MessageType) String() string {
 	switch mt {
@@ -33,12 +63,49 @@ func (mt MessageType) String() string {
 		return "BulkString"
 	case Array:
 		return "Array"
+	case Null:
+		return "Null"
+	case Double:
+		return "Double"
+	case Boolean:
+		return "Boolean"
+	case BlobError:
+		return "BlobError"
+	case VerbatimString:
+		return "VerbatimString"
+	case BigNumber:
+		return "BigNumber"
+	case Map:
+		return "Map"
+	case Set:
+		return "Set"
+	case Attribute:
+		return "Attribute"
+	case Push:
+		return "Push"
 	default:
 		return "Unknown"
 	}
 }
 
-// Message represents a RESP message with its type and value
+// IsAggregate returns true for types whose value is a list of messages
+func (mt MessageType) IsAggregate() bool {
+	switch mt {
+	case Array, Map, Set, Attribute, Push:
+		return true
+	default:
+		return false
+	}
+}
+
+// Message represents a RESP message with its type and value.
+//
+// Bulk strings hold their data as a []byte so that values can travel from
+// the parser to storage and back to the serializer without being copied.
+// The slice is owned by the message: whoever receives a message, such as a
+// command handler, may pass the slice on (for example to storage.Store.Set,
+// which takes ownership), but must never modify it, since it may be shared
+// with stored values or other replies.
 type Message struct {
 	Type  MessageType
 	Value interface{}
@@ -68,8 +135,21 @@ func NewInteger(value int64) *Message {
 	}
 }
 
-// NewBulkString creates a new bulk string message
+// NewBulkString creates a new bulk string message holding a copy of value
 func NewBulkString(value string) *Message {
+	return &Message{
+		Type:  BulkString,
+		Value: []byte(value),
+	}
+}
+
+// NewBulkBytes creates a new bulk string message without copying value.
+// The message takes ownership of the slice, which must not be modified
+// afterwards. A nil slice is an empty bulk string, not a null one.
+func NewBulkBytes(value []byte) *Message {
+	if value == nil {
+		value = []byte{}
+	}
 	return &Message{
 		Type:  BulkString,
 		Value: value,
@@ -100,6 +180,102 @@ func NewNullArray() *Message {
 	}
 }
 
+// NewNull creates a new RESP3 null message
+func NewNull() *Message {
+	return &Message{
+		Type:  Null,
+		Value: nil,
+	}
+}
+
+// NewDouble creates a new double message
+func NewDouble(value float64) *Message {
+	return &Message{
+		Type:  Double,
+		Value: value,
+	}
+}
+
+// NewBoolean creates a new boolean message
+func NewBoolean(value bool) *Message {
+	return &Message{
+		Type:  Boolean,
+		Value: value,
+	}
+}
+
+// NewBlobError creates a new binary-safe error message
+func NewBlobError(value string) *Message {
+	return &Message{
+		Type:  BlobError,
+		Value: value,
+	}
+}
+
+// NewVerbatimString creates a new verbatim string message. format must be
+// exactly three characters, such as "txt" or "mkd".
+func NewVerbatimString(format, text string) *Message {
+	return &Message{
+		Type:  VerbatimString,
+		Value: Verbatim{Format: format, Text: text},
+	}
+}
+
+// NewBigNumber creates a new big number message from its decimal digits
+func NewBigNumber(value string) *Message {
+	return &Message{
+		Type:  BigNumber,
+		Value: value,
+	}
+}
+
+// NewMap creates a new map message. pairs holds the keys and values
+// alternately, so it always has an even number of elements.
+func NewMap(pairs []*Message) *Message {
+	return &Message{
+		Type:  Map,
+		Value: pairs,
+	}
+}
+
+// NewSet creates a new set message
+func NewSet(value []*Message) *Message {
+	return &Message{
+		Type:  Set,
+		Value: value,
+	}
+}
+
+// NewAttribute creates a new attribute message. Like a map, pairs holds
+// keys and values alternately.
+func NewAttribute(pairs []*Message) *Message {
+	return &Message{
+		Type:  Attribute,
+		Value: pairs,
+	}
+}
+
+// NewPush creates a new push message
+func NewPush(value []*Message) *Message {
+	return &Message{
+		Type:  Push,
+		Value: value,
+	}
+}
+
+// CopyMessages returns copies of the messages that stay valid after the
+// parser recycles the originals. The copies share the originals' values,
+// which are never modified.
+func CopyMessages(messages []*Message) []*Message {
+	copies := make([]Message, len(messages))
+	result := make([]*Message, len(messages))
+	for i, message := range messages {
+		copies[i] = *message
+		result[i] = &copies[i]
+	}
+	return result
+}
+
 // String returns a string representation of the message for debugging
 func (m *Message) String() string {
 	switch m.Type {
@@ -113,13 +289,30 @@ func (m *Message) String() string {
 		if m.Value == nil {
 			return "BulkString(null)"
 		}
-		return fmt.Sprintf("BulkString(%q)", m.Value)
+		return fmt.Sprintf("BulkString(%q)", m.Value.([]byte))
 	case Array:
 		if m.Value == nil {
 			return "Array(null)"
 		}
 		arr := m.Value.([]*Message)
 		return fmt.Sprintf("Array(%d elements)", len(arr))
+	case Null:
+		return "Null"
+	case Double:
+		return fmt.Sprintf("Double(%v)", m.Value)
+	case Boolean:
+		return fmt.Sprintf("Boolean(%v)", m.Value)
+	case BlobError:
+		return fmt.Sprintf("BlobError(%q)", m.Value)
+	case VerbatimString:
+		verbatim := m.Value.(Verbatim)
+		return fmt.Sprintf("VerbatimString(%s:%q)", verbatim.Format, verbatim.Text)
+	case BigNumber:
+		return fmt.Sprintf("BigNumber(%s)", m.Value)
+	case Map, Attribute:
+		return fmt.Sprintf("%s(%d pairs)", m.Type, len(m.Value.([]*Message))/2)
+	case Set, Push:
+		return fmt.Sprintf("%s(%d elements)", m.Type, len(m.Value.([]*Message)))
 	default:
 		return fmt.Sprintf("Unknown(%v)", m.Value)
 	}
@@ -133,18 +326,54 @@ func (m *Message) IsNull() bool {
 // AsString returns the string value of the message or an error if not a string type
 func (m *Message) AsString() (string, error) {
 	switch m.Type {
-	case SimpleString, Error:
+	case SimpleString, Error, BlobError, BigNumber:
 		return m.Value.(string), nil
+	case VerbatimString:
+		return m.Value.(Verbatim).Text, nil
 	case BulkString:
 		if m.Value == nil {
 			return "", fmt.Errorf("null bulk string")
 		}
-		return m.Value.(string), nil
+		return string(m.Value.([]byte)), nil
 	default:
 		return "", fmt.Errorf("message type %s cannot be converted to string", m.Type)
 	}
 }
 
+// AsBytes returns the value of a string message as bytes, or an error if
+// not a string type. For bulk strings the message's own slice is returned
+// without copying; it must not be modified.
+func (m *Message) AsBytes() ([]byte, error) {
+	if m.Type == BulkString {
+		if m.Value == nil {
+			return nil, fmt.Errorf("null bulk string")
+		}
+		return m.Value.([]byte), nil
+	}
+
+	value, err := m.AsString()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// AsFloat returns the value of a double message or an error if not a double
+func (m *Message) AsFloat() (float64, error) {
+	if m.Type != Double {
+		return 0, fmt.Errorf("message type %s cannot be converted to double", m.Type)
+	}
+	return m.Value.(float64), nil
+}
+
+// AsBool returns the value of a boolean message or an error if not a boolean
+func (m *Message) AsBool() (bool, error) {
+	if m.Type != Boolean {
+		return false, fmt.Errorf("message type %s cannot be converted to boolean", m.Type)
+	}
+	return m.Value.(bool), nil
+}
+
 // AsInteger returns the integer value of the message or an error if not an integer
 func (m *Message) AsInteger() (int64, error) {
 	if m.Type != Integer {
@@ -153,9 +382,10 @@ func (m *Message) AsInteger() (int64, error) {
 	return m.Value.(int64), nil
 }
 
-// AsArray returns the array value of the message or an error if not an array
+// AsArray returns the elements of an array, set or push message, or the
+// flattened key-value pairs of a map, and an error for other types
 func (m *Message) AsArray() ([]*Message, error) {
-	if m.Type != Array {
+	if !m.Type.IsAggregate() {
 		return nil, fmt.Errorf("message type %s cannot be converted to array", m.Type)
 	}
 	if m.Value == nil {
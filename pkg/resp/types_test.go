@@ -1,6 +1,7 @@
 package resp
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -14,6 +15,16 @@ func TestMessageType_String(t *testing.T) {
 		{Integer, "Integer"},
 		{BulkString, "BulkString"},
 		{Array, "Array"},
+		{Null, "Null"},
+		{Double, "Double"},
+		{Boolean, "Boolean"},
+		{BlobError, "BlobError"},
+		{VerbatimString, "VerbatimString"},
+		{BigNumber, "BigNumber"},
+		{Map, "Map"},
+		{Set, "Set"},
+		{Attribute, "Attribute"},
+		{Push, "Push"},
 		{MessageType(999), "Unknown"},
 	}
 
@@ -70,8 +81,35 @@ func TestNewBulkString(t *testing.T) {
 		t.Errorf("Expected type BulkString, got %s", msg.Type)
 	}
 
-	if msg.Value != "foobar" {
-		t.Errorf("Expected value 'foobar', got %v", msg.Value)
+	if value, _ := msg.AsString(); value != "foobar" {
+		t.Errorf("Expected value 'foobar', got %q", value)
+	}
+}
+
+func TestNewBulkBytes(t *testing.T) {
+	data := []byte("foobar")
+	msg := NewBulkBytes(data)
+
+	value, err := msg.AsBytes()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The message takes ownership of the slice instead of copying it
+	if &value[0] != &data[0] {
+		t.Error("Expected NewBulkBytes to share the slice")
+	}
+
+	if msg := NewBulkBytes(nil); msg.IsNull() {
+		t.Error("Expected a nil slice to be an empty bulk string, not null")
+	}
+
+	if _, err := NewNullBulkString().AsBytes(); err == nil {
+		t.Error("Expected error for null bulk string")
+	}
+
+	if value, err := NewSimpleString("OK").AsBytes(); err != nil || string(value) != "OK" {
+		t.Errorf("Expected OK, got %q (error: %v)", value, err)
 	}
 }
 
@@ -107,12 +145,12 @@ func TestNewArray(t *testing.T) {
 		t.Errorf("Expected array length 2, got %d", len(arr))
 	}
 
-	if arr[0].Value != "foo" {
-		t.Errorf("Expected first element 'foo', got %v", arr[0].Value)
+	if value, _ := arr[0].AsString(); value != "foo" {
+		t.Errorf("Expected first element 'foo', got %q", value)
 	}
 
-	if arr[1].Value != "bar" {
-		t.Errorf("Expected second element 'bar', got %v", arr[1].Value)
+	if value, _ := arr[1].AsString(); value != "bar" {
+		t.Errorf("Expected second element 'bar', got %q", value)
 	}
 }
 
@@ -353,7 +391,7 @@ func TestMessage_AsArray(t *testing.T) {
 					t.Errorf("Expected array length %d, got %d", len(test.expected), len(result))
 				}
 				for i, elem := range test.expected {
-					if result[i].Value != elem.Value {
+					if !reflect.DeepEqual(result[i].Value, elem.Value) {
 						t.Errorf("Expected element %d to be %v, got %v", i, elem.Value, result[i].Value)
 					}
 				}
@@ -361,3 +399,35 @@ func TestMessage_AsArray(t *testing.T) {
 		})
 	}
 }
+
+func TestRESP3Accessors(t *testing.T) {
+	if value, err := NewDouble(1.5).AsFloat(); err != nil || value != 1.5 {
+		t.Errorf("Expected 1.5, got %v (error: %v)", value, err)
+	}
+	if value, err := NewBoolean(true).AsBool(); err != nil || !value {
+		t.Errorf("Expected true, got %v (error: %v)", value, err)
+	}
+	if value, err := NewVerbatimString("txt", "hello").AsString(); err != nil || value != "hello" {
+		t.Errorf("Expected hello, got %q (error: %v)", value, err)
+	}
+	if value, err := NewBigNumber("12345678901234567890").AsString(); err != nil || value != "12345678901234567890" {
+		t.Errorf("Expected big number digits, got %q (error: %v)", value, err)
+	}
+	if !NewNull().IsNull() {
+		t.Error("Expected Null to be null")
+	}
+	if _, err := NewInteger(1).AsFloat(); err == nil {
+		t.Error("Expected error converting integer to double")
+	}
+	if _, err := NewInteger(1).AsBool(); err == nil {
+		t.Error("Expected error converting integer to boolean")
+	}
+
+	pairs := []*Message{NewBulkString("key"), NewInteger(1)}
+	for _, message := range []*Message{NewMap(pairs), NewSet(pairs), NewPush(pairs)} {
+		elements, err := message.AsArray()
+		if err != nil || len(elements) != 2 {
+			t.Errorf("Expected 2 elements from %s, got %d (error: %v)", message.Type, len(elements), err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/subtle"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// DefaultUser is the only user known to the server
+const DefaultUser = "default"
+
+// Authenticator checks the credentials presented with AUTH and HELLO. The
+// default user requires the password set with SetPassword, or accepts any
+// password while none is set, as with requirepass in Redis.
+type Authenticator struct {
+	password atomic.Pointer[string]
+}
+
+// NewAuthenticator creates an authenticator that requires no password
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{}
+}
+
+// SetPassword sets the password of the default user. An empty password
+// disables authentication. Connections that are already authenticated
+// stay authenticated.
+func (a *Authenticator) SetPassword(password string) {
+	a.password.Store(&password)
+}
+
+// Required reports whether new connections must authenticate
+func (a *Authenticator) Required() bool {
+	password := a.password.Load()
+	return password != nil && *password != ""
+}
+
+// Check reports whether the username and password are valid
+func (a *Authenticator) Check(username, password string) bool {
+	if username != DefaultUser {
+		return false
+	}
+
+	if !a.Required() {
+		return true
+	}
+
+	return subtle.ConstantTimeCompare([]byte(*a.password.Load()), []byte(password)) == 1
+}
+
+// allowedUnauthenticated lists the commands a connection may run before it
+// has authenticated
+var allowedUnauthenticated = map[string]bool{
+	"AUTH":  true,
+	"HELLO": true,
+	"QUIT":  true,
+}
+
+// auth processes AUTH [username] password
+func (c *Connection) auth(args []*resp.Message) *resp.Message {
+	if len(args) != 1 && len(args) != 2 {
+		return resp.NewError("ERR wrong number of arguments for 'auth' command")
+	}
+
+	credentials := make([]string, len(args))
+	for i, arg := range args {
+		value, err := arg.AsString()
+		if err != nil {
+			return resp.NewError("ERR invalid argument type for AUTH")
+		}
+		credentials[i] = value
+	}
+
+	username, password := DefaultUser, credentials[0]
+	if len(credentials) == 2 {
+		username, password = credentials[0], credentials[1]
+	} else if !c.authenticator.Required() {
+		return resp.NewError("ERR AUTH <password> called without any password configured for the default user. " +
+			"Are you sure your configuration is correct?")
+	}
+
+	if !c.authenticator.Check(username, password) {
+		return resp.NewError("WRONGPASS invalid username-password pair or user is disabled.")
+	}
+
+	c.authenticated = true
+	return resp.NewSimpleString("OK")
+}
+
+// validClientName reports whether a name may be set with SETNAME. Names
+// cannot contain spaces, newlines or other special characters.
+func validClientName(name string) bool {
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return r <= ' ' || r > '~'
+	})
+}
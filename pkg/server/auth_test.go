@@ -0,0 +1,121 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/commands"
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// newAuthConnection creates a connection to a server requiring the password
+func newAuthConnection(password string) *Connection {
+	handler := commands.NewCommandHandler()
+	handler.Register(commands.NewPingCommand())
+
+	authenticator := NewAuthenticator()
+	authenticator.SetPassword(password)
+
+	return NewConnection(newMockConn(""), handler, storage.NewMemoryStore(), pubsub.NewBroker(), authenticator)
+}
+
+func TestAuthenticator_Check(t *testing.T) {
+	authenticator := NewAuthenticator()
+
+	if authenticator.Required() {
+		t.Error("Expected no password to be required by default")
+	}
+	if !authenticator.Check("default", "anything") {
+		t.Error("Expected any password to be accepted without requirepass")
+	}
+	if authenticator.Check("alice", "anything") {
+		t.Error("Expected unknown users to be rejected")
+	}
+
+	authenticator.SetPassword("secret")
+	if !authenticator.Check("default", "secret") {
+		t.Error("Expected the correct password to be accepted")
+	}
+	if authenticator.Check("default", "wrong") {
+		t.Error("Expected a wrong password to be rejected")
+	}
+
+	authenticator.SetPassword("")
+	if authenticator.Required() {
+		t.Error("Expected an empty password to disable authentication")
+	}
+}
+
+func TestAuth_RequiresAuthentication(t *testing.T) {
+	connection := newAuthConnection("secret")
+
+	expectReply(t, connection.processCommand(command("PING")), resp.Error, "NOAUTH Authentication required.")
+
+	reply := connection.processCommand(command("HELLO", "3"))
+	if reply.Type != resp.Error || reply.Value.(string)[:6] != "NOAUTH" {
+		t.Fatalf("Expected NOAUTH error from HELLO, got %s", reply)
+	}
+
+	expectReply(t, connection.processCommand(command("AUTH", "wrong")), resp.Error,
+		"WRONGPASS invalid username-password pair or user is disabled.")
+	expectReply(t, connection.processCommand(command("AUTH", "secret")), resp.SimpleString, "OK")
+	expectReply(t, connection.processCommand(command("PING")), resp.SimpleString, "PONG")
+}
+
+func TestAuth_HelloAuthenticates(t *testing.T) {
+	connection := newAuthConnection("secret")
+
+	reply := connection.processCommand(command("HELLO", "3", "AUTH", "default", "secret", "SETNAME", "app"))
+	if reply.Type != resp.Map {
+		t.Fatalf("Expected Map reply, got %s", reply)
+	}
+
+	if !connection.authenticated {
+		t.Error("Expected HELLO AUTH to authenticate the connection")
+	}
+	expectReply(t, connection.processCommand(command("PING")), resp.SimpleString, "PONG")
+}
+
+func TestAuth_WithoutPassword(t *testing.T) {
+	connection := newTestConnection(storage.NewMemoryStore())
+
+	expectReply(t, connection.processCommand(command("AUTH", "secret")), resp.Error,
+		"ERR AUTH <password> called without any password configured for the default user. "+
+			"Are you sure your configuration is correct?")
+	expectReply(t, connection.processCommand(command("AUTH", "default", "anything")), resp.SimpleString, "OK")
+	expectReply(t, connection.processCommand(command("AUTH")), resp.Error,
+		"ERR wrong number of arguments for 'auth' command")
+}
+
+func TestAuth_UnauthenticatedLimits(t *testing.T) {
+	large := strings.Repeat("x", unauthenticatedMaxBulkLength+1)
+	echo := "*2\r\n$4\r\nECHO\r\n$" + strconv.Itoa(len(large)) + "\r\n" + large + "\r\n"
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"large bulk", echo, "-ERR Protocol error: invalid bulk length\r\n"},
+		{"many arguments", "*11\r\n", "-ERR Protocol error: invalid multibulk length\r\n"},
+		{"after AUTH", "*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n" + echo, "+OK\r\n$" + strconv.Itoa(len(large)) + "\r\n" + large + "\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newMockConn(tt.input)
+			connection := newTestConnectionWith(conn, storage.NewMemoryStore(), pubsub.NewBroker())
+			connection.authenticator.SetPassword("secret")
+			connection.authenticated = false
+
+			connection.Handle()
+
+			if got := conn.getWrittenData(); got != tt.expected {
+				t.Errorf("Expected %.60q, got %.60q", tt.expected, got)
+			}
+		})
+	}
+}
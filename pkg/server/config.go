@@ -0,0 +1,97 @@
+package server
+
+import (
+	"math"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// evictionPolicies maps the maxmemory-policy values to the store policies
+var evictionPolicies = map[string]storage.EvictionPolicy{
+	config.PolicyNoEviction:     storage.NoEviction,
+	config.PolicyAllKeysLRU:     storage.AllKeysLRU,
+	config.PolicyVolatileLRU:    storage.VolatileLRU,
+	config.PolicyAllKeysLFU:     storage.AllKeysLFU,
+	config.PolicyVolatileLFU:    storage.VolatileLFU,
+	config.PolicyAllKeysRandom:  storage.AllKeysRandom,
+	config.PolicyVolatileRandom: storage.VolatileRandom,
+	config.PolicyVolatileTTL:    storage.VolatileTTL,
+}
+
+// memoryLimit returns the memory limit of the store set by the
+// configuration
+func memoryLimit(c *config.Config) storage.MemoryLimit {
+	return storage.MemoryLimit{
+		MaxMemory: c.MaxMemory,
+		Policy:    evictionPolicies[c.MaxMemoryPolicy],
+		Samples:   c.MaxMemorySamples,
+	}
+}
+
+// watchConfig applies the initial configuration to the subsystems and
+// registers hooks keeping them up to date as it changes
+func (s *Server) watchConfig() {
+	watchers := []struct {
+		names []string
+		hook  config.Hook
+	}{
+		{[]string{"requirepass"}, func(c *config.Config) error {
+			s.authenticator.SetPassword(c.RequirePass)
+			return nil
+		}},
+		{[]string{"notify-keyspace-events"}, func(c *config.Config) error {
+			return s.notifier.SetFlags(c.NotifyKeyspaceEvents)
+		}},
+		{[]string{"proto-max-bulk-len"}, func(c *config.Config) error {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			s.parserLimits.MaxBulkLength = int(min(c.ProtoMaxBulkLen, math.MaxInt))
+			return nil
+		}},
+		{[]string{"maxclients", "tcp-keepalive"}, func(c *config.Config) error {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			s.maxClients = c.MaxClients
+			s.keepAlive = c.TCPKeepAlive
+			return nil
+		}},
+		{[]string{"timeout"}, func(c *config.Config) error {
+			s.idleTimeout.Store(int64(c.Timeout))
+			return nil
+		}},
+		{[]string{"maxmemory", "maxmemory-policy", "maxmemory-samples"}, func(c *config.Config) error {
+			s.store.SetMemoryLimit(memoryLimit(c))
+			return nil
+		}},
+		{[]string{"dir", "dbfilename"}, func(c *config.Config) error {
+			s.snapshotter.SetPath(snapshotPath(c))
+			return nil
+		}},
+		{[]string{"rdbcompression", "rdbchecksum", "appendfsync", "aof-use-rdb-preamble"}, func(c *config.Config) error {
+			s.snapshotter.SetOptions(rdbOptions(c))
+			if aof := s.aof.Load(); aof != nil {
+				aof.SetOptions(aofOptions(c))
+			}
+			return nil
+		}},
+	}
+
+	initial := s.config.Config()
+	for _, w := range watchers {
+		// The initial configuration has been validated
+		w.hook(initial)
+		s.config.OnChange(w.hook, w.names...)
+	}
+
+	// The append-only file is opened by LoadData, after it is replayed, so
+	// appendonly is only watched for changes
+	s.config.OnChange(func(c *config.Config) error {
+		if c.AppendOnly {
+			return s.startAOF(c)
+		}
+		return s.stopAOF()
+	}, "appendonly")
+}
@@ -1,15 +1,23 @@
 package server
 
 import (
+	"errors"
 	"log"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/tsinivuo/redis-lite/pkg/commands"
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
 	"github.com/tsinivuo/redis-lite/pkg/resp"
 	"github.com/tsinivuo/redis-lite/pkg/storage"
 )
 
+// nextClientID is the ID given to the next connection
+var nextClientID atomic.Int64
+
 // Connection represents a client connection to the server
 type Connection struct {
 	conn           net.Conn
@@ -17,16 +25,54 @@ type Connection struct {
 	serializer     *resp.Serializer
 	commandHandler *commands.CommandHandler
 	store          storage.Store
+	broker         *pubsub.Broker
+	authenticator  *Authenticator
+	tx             transaction
+	subs           subscriptions
+
+	// id uniquely identifies the connection, as reported by HELLO
+	id int64
+	// name is the client name set with HELLO SETNAME
+	name          string
+	authenticated bool
+	// limits are the protocol limits applied once the connection has
+	// authenticated
+	limits resp.Limits
+	// idleTimeout closes the connection once idle for this many
+	// nanoseconds, 0 to keep it open. It is shared with the server, which
+	// updates it on CONFIG SET timeout. Subscribed connections are never
+	// closed for idling.
+	idleTimeout *atomic.Int64
+	// flushAOF, if set, writes the changes made so far to the append-only
+	// file. It is called before replies are sent.
+	flushAOF func()
+
+	// writeMutex serializes replies and asynchronously delivered messages
+	writeMutex sync.Mutex
+	// outbox buffers published messages until deliverMessages writes them
+	outbox        chan *resp.Message
+	done          chan struct{}
+	startDelivery sync.Once
+	overflowOnce  sync.Once
 }
 
 // NewConnection creates a new connection handler
-func NewConnection(conn net.Conn, commandHandler *commands.CommandHandler, store storage.Store) *Connection {
+func NewConnection(conn net.Conn, commandHandler *commands.CommandHandler, store storage.Store, broker *pubsub.Broker,
+	authenticator *Authenticator) *Connection {
 	return &Connection{
 		conn:           conn,
 		parser:         resp.NewParser(conn),
 		serializer:     resp.NewSerializer(conn),
 		commandHandler: commandHandler,
 		store:          store,
+		broker:         broker,
+		authenticator:  authenticator,
+		id:             nextClientID.Add(1),
+		authenticated:  !authenticator.Required(),
+		limits:         resp.DefaultLimits(),
+		subs:           newSubscriptions(),
+		outbox:         make(chan *resp.Message, DefaultPubSubBufferSize),
+		done:           make(chan struct{}),
 	}
 }
 
@@ -34,27 +80,128 @@ func NewConnection(conn net.Conn, commandHandler *commands.CommandHandler, store
 func (c *Connection) Handle() {
 	log.Printf("New client connected: %s", c.conn.RemoteAddr())
 	defer log.Printf("Client disconnected: %s", c.conn.RemoteAddr())
+	defer c.cleanup()
 
 	for {
-		// Parse incoming RESP message
-		message, err := c.parser.Parse()
+		c.setIdleDeadline()
+		c.applyLimits()
+
+		// Parse the next command, either a RESP array or an inline command
+		message, err := c.parser.ParseCommand()
 		if err != nil {
 			log.Printf("Error parsing message: %v", err)
+
+			// The stream cannot be resynchronized after a protocol error, so
+			// report it and close the connection, as Redis does
+			var protocolErr *resp.ProtocolError
+			if errors.As(err, &protocolErr) {
+				c.write(resp.NewError("ERR " + protocolErr.Error()))
+			}
 			return
 		}
 
 		// Process the command
 		response := c.processCommand(message)
 
-		// Send response back to client
-		if err := c.serializer.Serialize(response); err != nil {
-			log.Printf("Error serializing response: %v", err)
+		// Commands that reply on their own return no response
+		if response != nil {
+			if err := c.queueReply(response); err != nil {
+				log.Printf("Error serializing response: %v", err)
+				return
+			}
+		}
+
+		// Pipelined commands already in the parser buffer are executed
+		// before any reply is sent, so a whole batch of replies goes out
+		// in a single write. Part of a command must wait for the network,
+		// so the replies so far are sent first.
+		if c.parser.CommandBuffered() {
+			continue
+		}
+
+		// Changes reach the append-only file before they are acknowledged
+		if c.flushAOF != nil {
+			c.flushAOF()
+		}
+
+		if err := c.flush(); err != nil {
+			log.Printf("Error writing response: %v", err)
 			return
 		}
 	}
 }
 
-// processCommand processes a command message and returns a response
+// setIdleDeadline bounds the wait for the next command by the idle
+// timeout, unless the connection is subscribed
+func (c *Connection) setIdleDeadline() {
+	if c.idleTimeout == nil || c.parser.Buffered() > 0 {
+		return
+	}
+
+	// A zero deadline clears the one set under a previous timeout
+	var deadline time.Time
+	if timeout := time.Duration(c.idleTimeout.Load()); timeout > 0 && c.subs.count() == 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	c.conn.SetReadDeadline(deadline)
+}
+
+// Until a connection authenticates, requests are limited as in Redis, so
+// that a client without the password cannot make the server buffer large
+// commands
+const (
+	unauthenticatedMaxBulkLength      = 16 * 1024
+	unauthenticatedMaxMultiBulkLength = 10
+)
+
+// applyLimits sets the protocol limits for the next command, which are
+// lower until the connection authenticates
+func (c *Connection) applyLimits() {
+	limits := c.limits
+	if !c.authenticated {
+		limits.MaxBulkLength = min(limits.MaxBulkLength, unauthenticatedMaxBulkLength)
+		limits.MaxMultiBulkLength = min(limits.MaxMultiBulkLength, unauthenticatedMaxMultiBulkLength)
+	}
+	c.parser.SetLimits(limits)
+}
+
+// write serializes a message to the client and flushes it. It is safe to
+// call concurrently with the delivery of published messages.
+func (c *Connection) write(message *resp.Message) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if err := c.serializer.Serialize(message); err != nil {
+		return err
+	}
+	return c.serializer.Flush()
+}
+
+// queueReply serializes a reply into the output buffer without flushing it
+func (c *Connection) queueReply(message *resp.Message) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	return c.serializer.Serialize(message)
+}
+
+// flush writes the buffered replies to the client
+func (c *Connection) flush() error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	return c.serializer.Flush()
+}
+
+// cleanup releases the per-connection state held in shared structures
+func (c *Connection) cleanup() {
+	close(c.done)
+	c.unwatchAll()
+	c.unsubscribeAll()
+}
+
+// processCommand processes a command message and returns a response, or nil
+// if the command has already written its replies
 func (c *Connection) processCommand(message *resp.Message) *resp.Message {
 	// Commands should be arrays in RESP protocol
 	if message.Type != resp.Array {
@@ -85,7 +232,7 @@ func (c *Connection) processCommand(message *resp.Message) *resp.Message {
 		if commandNameMsg.Value == nil {
 			return resp.NewError("ERR Protocol error: null command name")
 		}
-		commandName = commandNameMsg.Value.(string)
+		commandName = string(commandNameMsg.Value.([]byte))
 	case resp.SimpleString:
 		commandName = commandNameMsg.Value.(string)
 	}
@@ -96,6 +243,26 @@ func (c *Connection) processCommand(message *resp.Message) *resp.Message {
 	// Get command arguments (everything after the command name)
 	commandArgs := args[1:]
 
+	// Until authenticated, connections may only authenticate
+	if !c.authenticated && !allowedUnauthenticated[commandName] {
+		return resp.NewError("NOAUTH Authentication required.")
+	}
+
+	// Subscription commands, and the restrictions of subscribed mode
+	if response, handled := c.handlePubSub(commandName, commandArgs); handled {
+		return response
+	}
+
+	// HELLO and AUTH change the state of the connection
+	if response, handled := c.handleSession(commandName, commandArgs); handled {
+		return response
+	}
+
+	// Transaction commands and commands queued inside MULTI are handled separately
+	if response, handled := c.handleTransaction(commandName, commandArgs); handled {
+		return response
+	}
+
 	// Execute the command
 	response, err := c.commandHandler.Execute(commandName, commandArgs, c.store)
 	if err != nil {
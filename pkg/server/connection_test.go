@@ -2,11 +2,13 @@ package server
 
 import (
 	"bytes"
+	"io"
 	"net"
 	"testing"
 	"time"
 
 	"github.com/tsinivuo/redis-lite/pkg/commands"
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
 	"github.com/tsinivuo/redis-lite/pkg/resp"
 	"github.com/tsinivuo/redis-lite/pkg/storage"
 )
@@ -15,6 +17,8 @@ import (
 type mockConn struct {
 	readBuffer  *bytes.Buffer
 	writeBuffer *bytes.Buffer
+	// writes counts the calls to Write
+	writes int
 }
 
 func newMockConn(input string) *mockConn {
@@ -29,6 +33,7 @@ func (m *mockConn) Read(b []byte) (n int, err error) {
 }
 
 func (m *mockConn) Write(b []byte) (n int, err error) {
+	m.writes++
 	return m.writeBuffer.Write(b)
 }
 
@@ -60,12 +65,41 @@ func (m *mockConn) getWrittenData() string {
 	return m.writeBuffer.String()
 }
 
+// newTestConnection creates a connection with the built-in commands registered
+func newTestConnection(store storage.Store) *Connection {
+	return newTestConnectionWith(newMockConn(""), store, pubsub.NewBroker())
+}
+
+// newTestConnectionWith creates a connection on conn with the built-in
+// commands registered, sharing the given store and broker
+func newTestConnectionWith(conn net.Conn, store storage.Store, broker *pubsub.Broker) *Connection {
+	handler := commands.NewCommandHandler()
+	handler.Register(commands.NewPingCommand())
+	handler.Register(commands.NewEchoCommand())
+	handler.Register(commands.NewSetCommand())
+	handler.Register(commands.NewGetCommand())
+	handler.Register(commands.NewPublishCommand(broker))
+	handler.Register(commands.NewPubSubCommand(broker))
+	handler.Register(commands.NewSPublishCommand(broker))
+
+	return NewConnection(conn, handler, store, broker, NewAuthenticator())
+}
+
+// command builds a RESP command array from string arguments
+func command(args ...string) *resp.Message {
+	elements := make([]*resp.Message, len(args))
+	for i, arg := range args {
+		elements[i] = resp.NewBulkString(arg)
+	}
+	return resp.NewArray(elements)
+}
+
 func TestNewConnection(t *testing.T) {
 	conn := newMockConn("")
 	handler := commands.NewCommandHandler()
 	store := storage.NewMemoryStore()
 
-	connection := NewConnection(conn, handler, store)
+	connection := NewConnection(conn, handler, store, pubsub.NewBroker(), NewAuthenticator())
 
 	if connection == nil {
 		t.Fatal("NewConnection returned nil")
@@ -86,7 +120,7 @@ func TestConnection_processCommand_ValidPing(t *testing.T) {
 	handler.Register(commands.NewPingCommand())
 	store := storage.NewMemoryStore()
 
-	connection := NewConnection(conn, handler, store)
+	connection := NewConnection(conn, handler, store, pubsub.NewBroker(), NewAuthenticator())
 
 	// Create a PING command message: *1\r\n$4\r\nPING\r\n
 	pingArray := resp.NewArray([]*resp.Message{
@@ -110,7 +144,7 @@ func TestConnection_processCommand_ValidEcho(t *testing.T) {
 	handler.Register(commands.NewEchoCommand())
 	store := storage.NewMemoryStore()
 
-	connection := NewConnection(conn, handler, store)
+	connection := NewConnection(conn, handler, store, pubsub.NewBroker(), NewAuthenticator())
 
 	// Create an ECHO command message: *2\r\n$4\r\nECHO\r\n$5\r\nhello\r\n
 	echoArray := resp.NewArray([]*resp.Message{
@@ -124,8 +158,8 @@ func TestConnection_processCommand_ValidEcho(t *testing.T) {
 		t.Errorf("Expected BulkString response, got %s", response.Type)
 	}
 
-	if response.Value.(string) != "hello" {
-		t.Errorf("Expected 'hello' response, got '%s'", response.Value.(string))
+	if value, _ := response.AsString(); value != "hello" {
+		t.Errorf("Expected 'hello' response, got '%s'", value)
 	}
 }
 
@@ -135,7 +169,7 @@ func TestConnection_processCommand_CaseInsensitive(t *testing.T) {
 	handler.Register(commands.NewPingCommand())
 	store := storage.NewMemoryStore()
 
-	connection := NewConnection(conn, handler, store)
+	connection := NewConnection(conn, handler, store, pubsub.NewBroker(), NewAuthenticator())
 
 	// Test lowercase command
 	pingArray := resp.NewArray([]*resp.Message{
@@ -158,7 +192,7 @@ func TestConnection_processCommand_UnknownCommand(t *testing.T) {
 	handler := commands.NewCommandHandler()
 	store := storage.NewMemoryStore()
 
-	connection := NewConnection(conn, handler, store)
+	connection := NewConnection(conn, handler, store, pubsub.NewBroker(), NewAuthenticator())
 
 	// Create an unknown command message
 	unknownArray := resp.NewArray([]*resp.Message{
@@ -182,7 +216,7 @@ func TestConnection_processCommand_InvalidInput(t *testing.T) {
 	handler := commands.NewCommandHandler()
 	store := storage.NewMemoryStore()
 
-	connection := NewConnection(conn, handler, store)
+	connection := NewConnection(conn, handler, store, pubsub.NewBroker(), NewAuthenticator())
 
 	testCases := []struct {
 		name     string
@@ -234,3 +268,73 @@ func TestConnection_processCommand_InvalidInput(t *testing.T) {
 		})
 	}
 }
+
+func TestConnection_Handle_InlineCommands(t *testing.T) {
+	conn := newMockConn("PING\r\nECHO \"hello world\"\nSET key value\r\nGET key\n")
+	connection := newTestConnectionWith(conn, storage.NewMemoryStore(), pubsub.NewBroker())
+
+	connection.Handle()
+
+	expected := "+PONG\r\n$11\r\nhello world\r\n+OK\r\n$5\r\nvalue\r\n"
+	if got := conn.getWrittenData(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestConnection_Handle_ProtocolError(t *testing.T) {
+	// The PING after the oversized bulk string must never run
+	conn := newMockConn("*1\r\n$2000000000\r\n*1\r\n$4\r\nPING\r\n")
+	connection := newTestConnectionWith(conn, storage.NewMemoryStore(), pubsub.NewBroker())
+
+	connection.Handle()
+
+	expected := "-ERR Protocol error: invalid bulk length\r\n"
+	if got := conn.getWrittenData(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestConnection_Handle_Pipelining(t *testing.T) {
+	conn := newMockConn("*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n" +
+		"*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n" +
+		"PING\r\n" +
+		"*2\r\n$4\r\nECHO\r\n$2\r\nhi\r\n")
+	connection := newTestConnectionWith(conn, storage.NewMemoryStore(), pubsub.NewBroker())
+
+	connection.Handle()
+
+	expected := "+OK\r\n$5\r\nvalue\r\n+PONG\r\n$2\r\nhi\r\n"
+	if got := conn.getWrittenData(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// Every command arrived in one read, so the replies go out in one write
+	if conn.writes != 1 {
+		t.Errorf("Expected 1 write, got %d", conn.writes)
+	}
+}
+
+func TestConnection_Handle_PartialPipeline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	connection := newTestConnectionWith(server, storage.NewMemoryStore(), pubsub.NewBroker())
+	go connection.Handle()
+
+	// The reply to the complete command is sent without waiting for the
+	// rest of the next one
+	reply := make([]byte, len("+PONG\r\n"))
+	client.SetDeadline(time.Now().Add(time.Second))
+	if _, err := client.Write([]byte("PING\r\n*1\r\n$4\r\nPI")); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if _, err := io.ReadFull(client, reply); err != nil || string(reply) != "+PONG\r\n" {
+		t.Fatalf("Expected %q, got %q (err=%v)", "+PONG\r\n", reply, err)
+	}
+
+	if _, err := client.Write([]byte("NG\r\n")); err != nil {
+		t.Fatalf("Write() returned error: %v", err)
+	}
+	if _, err := io.ReadFull(client, reply); err != nil || string(reply) != "+PONG\r\n" {
+		t.Errorf("Expected %q, got %q (err=%v)", "+PONG\r\n", reply, err)
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// handleSession processes the commands that change the state of the
+// connection itself: HELLO and AUTH. It returns false for other commands.
+func (c *Connection) handleSession(commandName string, args []*resp.Message) (*resp.Message, bool) {
+	if commandName != "HELLO" && commandName != "AUTH" {
+		return nil, false
+	}
+
+	if c.tx.active {
+		c.tx.dirty = true
+		return resp.NewError("ERR Command not allowed inside a transaction"), true
+	}
+
+	if commandName == "AUTH" {
+		return c.auth(args), true
+	}
+	return c.hello(args), true
+}
+
+// hello processes HELLO [protover [AUTH username password] [SETNAME name]].
+// It switches the connection to the requested protocol version and replies
+// with a map describing the server, serialized in the new protocol.
+func (c *Connection) hello(args []*resp.Message) *resp.Message {
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		value, err := arg.AsString()
+		if err != nil {
+			return resp.NewError("ERR invalid argument type for HELLO")
+		}
+		strArgs[i] = value
+	}
+
+	protocol := c.serializer.Protocol()
+	if len(strArgs) > 0 {
+		version, err := strconv.ParseInt(strArgs[0], 10, 64)
+		if err != nil {
+			return resp.NewError("ERR Protocol version is not an integer or out of range")
+		}
+		if version != resp.RESP2 && version != resp.RESP3 {
+			return resp.NewError("NOPROTO unsupported protocol version")
+		}
+		protocol = int(version)
+	}
+
+	var username, password, name string
+	var hasAuth, hasName bool
+	for i := 1; i < len(strArgs); i++ {
+		remaining := len(strArgs) - i - 1
+		switch option := strings.ToUpper(strArgs[i]); {
+		case option == "AUTH" && remaining >= 2:
+			username, password, hasAuth = strArgs[i+1], strArgs[i+2], true
+			i += 2
+		case option == "SETNAME" && remaining >= 1:
+			name, hasName = strArgs[i+1], true
+			i++
+		default:
+			return resp.NewError("ERR Syntax error in HELLO option '" + strArgs[i] + "'")
+		}
+	}
+
+	if hasAuth {
+		if !c.authenticator.Check(username, password) {
+			return resp.NewError("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		c.authenticated = true
+	}
+
+	if !c.authenticated {
+		return resp.NewError("NOAUTH HELLO must be called with the client already authenticated, otherwise the " +
+			"HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP " +
+			"protocol version at the same time")
+	}
+
+	if hasName {
+		if !validClientName(name) {
+			return resp.NewError("ERR Client names cannot contain spaces, newlines or special characters.")
+		}
+		c.name = name
+	}
+
+	// Switch the protocol under the write lock so that published messages
+	// are never serialized with a mix of versions
+	c.writeMutex.Lock()
+	c.serializer.SetProtocol(protocol)
+	c.writeMutex.Unlock()
+
+	return resp.NewMap([]*resp.Message{
+		resp.NewBulkString("server"), resp.NewBulkString("redis-lite"),
+		resp.NewBulkString("version"), resp.NewBulkString(Version),
+		resp.NewBulkString("proto"), resp.NewInteger(int64(protocol)),
+		resp.NewBulkString("id"), resp.NewInteger(c.id),
+		resp.NewBulkString("mode"), resp.NewBulkString("standalone"),
+		resp.NewBulkString("role"), resp.NewBulkString("master"),
+		resp.NewBulkString("modules"), resp.NewArray([]*resp.Message{}),
+	})
+}
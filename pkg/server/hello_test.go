@@ -0,0 +1,146 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// helloFields returns the fields of a HELLO reply by name
+func helloFields(t *testing.T, reply *resp.Message) map[string]*resp.Message {
+	t.Helper()
+
+	elements, err := reply.AsArray()
+	if err != nil || len(elements)%2 != 0 {
+		t.Fatalf("Expected HELLO reply with key-value pairs, got %s", reply)
+	}
+
+	fields := make(map[string]*resp.Message)
+	for i := 0; i < len(elements); i += 2 {
+		key, _ := elements[i].AsString()
+		fields[key] = elements[i+1]
+	}
+	return fields
+}
+
+func TestHello_NegotiatesRESP3(t *testing.T) {
+	client := startTestClient(t, storage.NewMemoryStore(), pubsub.NewBroker())
+
+	client.send(t, "HELLO", "3")
+	reply := client.receive(t)
+	if reply.Type != resp.Map {
+		t.Fatalf("Expected Map reply, got %s", reply)
+	}
+
+	fields := helloFields(t, reply)
+	if proto, _ := fields["proto"].AsInteger(); proto != 3 {
+		t.Errorf("Expected proto 3, got %d", proto)
+	}
+	if server, _ := fields["server"].AsString(); server != "redis-lite" {
+		t.Errorf("Expected server redis-lite, got %q", server)
+	}
+	if id, _ := fields["id"].AsInteger(); id <= 0 {
+		t.Errorf("Expected positive client id, got %d", id)
+	}
+
+	// Missing keys are now RESP3 nulls
+	client.send(t, "GET", "missing")
+	if reply := client.receive(t); reply.Type != resp.Null {
+		t.Fatalf("Expected Null reply, got %s", reply)
+	}
+
+	// HELLO without a version reports the current protocol
+	client.send(t, "HELLO")
+	if proto, _ := helloFields(t, client.receive(t))["proto"].AsInteger(); proto != 3 {
+		t.Errorf("Expected proto 3, got %d", proto)
+	}
+
+	client.send(t, "HELLO", "2")
+	reply = client.receive(t)
+	if reply.Type != resp.Array {
+		t.Fatalf("Expected flat Array reply under RESP2, got %s", reply)
+	}
+	if proto, _ := helloFields(t, reply)["proto"].AsInteger(); proto != 2 {
+		t.Errorf("Expected proto 2, got %d", proto)
+	}
+}
+
+func TestHello_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"Unsupported version", []string{"HELLO", "4"}, "NOPROTO unsupported protocol version"},
+		{"Invalid version", []string{"HELLO", "three"}, "ERR Protocol version is not an integer or out of range"},
+		{"Unknown option", []string{"HELLO", "3", "FOO"}, "ERR Syntax error in HELLO option 'FOO'"},
+		{"Incomplete AUTH", []string{"HELLO", "3", "AUTH", "default"}, "ERR Syntax error in HELLO option 'AUTH'"},
+		{"Wrong user", []string{"HELLO", "3", "AUTH", "alice", "secret"}, "WRONGPASS invalid username-password pair or user is disabled."},
+		{"Invalid name", []string{"HELLO", "3", "SETNAME", "my client"}, "ERR Client names cannot contain spaces, newlines or special characters."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			connection := newTestConnection(storage.NewMemoryStore())
+
+			expectReply(t, connection.processCommand(command(test.args...)), resp.Error, test.expected)
+
+			if connection.serializer.Protocol() != resp.RESP2 {
+				t.Error("A failed HELLO must not switch the protocol")
+			}
+		})
+	}
+}
+
+func TestHello_SetName(t *testing.T) {
+	connection := newTestConnection(storage.NewMemoryStore())
+
+	reply := connection.processCommand(command("HELLO", "3", "SETNAME", "worker-1"))
+	if reply.Type != resp.Map {
+		t.Fatalf("Expected Map reply, got %s", reply)
+	}
+
+	if connection.name != "worker-1" {
+		t.Errorf("Expected client name worker-1, got %q", connection.name)
+	}
+}
+
+func TestHello_NotAllowedInTransaction(t *testing.T) {
+	connection := newTestConnection(storage.NewMemoryStore())
+
+	connection.processCommand(command("MULTI"))
+	expectReply(t, connection.processCommand(command("HELLO", "3")), resp.Error,
+		"ERR Command not allowed inside a transaction")
+}
+
+func TestHello_RESP3PubSub(t *testing.T) {
+	store := storage.NewMemoryStore()
+	broker := pubsub.NewBroker()
+	subscriber := startTestClient(t, store, broker)
+	publisher := startTestClient(t, store, broker)
+
+	subscriber.send(t, "HELLO", "3")
+	subscriber.receive(t)
+
+	subscriber.send(t, "SUBSCRIBE", "news")
+	if reply := subscriber.receive(t); reply.Type != resp.Push {
+		t.Fatalf("Expected Push confirmation, got %s", reply)
+	}
+
+	// RESP3 connections can run any command while subscribed
+	subscriber.send(t, "SET", "key", "value")
+	expectReply(t, subscriber.receive(t), resp.SimpleString, "OK")
+
+	subscriber.send(t, "PING")
+	expectReply(t, subscriber.receive(t), resp.SimpleString, "PONG")
+
+	publisher.send(t, "PUBLISH", "news", "hello")
+	publisher.receive(t)
+
+	reply := subscriber.receive(t)
+	if reply.Type != resp.Push {
+		t.Fatalf("Expected Push message, got %s", reply)
+	}
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// SavePointInterval is how often the server checks whether a save point
+// is reached
+const SavePointInterval = time.Second
+
+// AOFSyncInterval is how often the append-only file is synced under
+// appendfsync everysec
+const AOFSyncInterval = time.Second
+
+// snapshotPath returns the path of the snapshot file: dbfilename in dir
+func snapshotPath(cfg *config.Config) string {
+	return filepath.Join(cfg.Dir, cfg.DBFilename)
+}
+
+// rdbOptions returns how snapshots are written: rdbcompression and
+// rdbchecksum
+func rdbOptions(cfg *config.Config) persistence.RDBOptions {
+	return persistence.RDBOptions{Compression: cfg.RDBCompression, Checksum: cfg.RDBChecksum}
+}
+
+// aofDir returns the directory of the append-only files: appenddirname
+// in dir
+func aofDir(cfg *config.Config) string {
+	return filepath.Join(cfg.Dir, cfg.AppendDirname)
+}
+
+// aofOptions returns how the append-only file is written: appendfsync,
+// aof-use-rdb-preamble, and the RDB options for its base file
+func aofOptions(cfg *config.Config) persistence.AOFOptions {
+	return persistence.AOFOptions{Fsync: cfg.AppendFsync, RDBPreamble: cfg.AOFUseRDBPreamble, RDB: rdbOptions(cfg)}
+}
+
+// LoadData loads the keyspace saved by the previous run. It is meant to be
+// called before the server starts serving clients. With appendonly, the
+// append-only file is replayed, and logging to it starts; without one yet,
+// the snapshot is loaded and written to a new append-only file.
+func (s *Server) LoadData() error {
+	cfg := s.config.Config()
+	// Loading neither evicts keys nor fails for lack of memory, as in
+	// Redis: the writes that follow evict what exceeds maxmemory
+	s.store.SetMemoryLimit(storage.MemoryLimit{})
+	defer s.store.SetMemoryLimit(memoryLimit(cfg))
+
+	if !cfg.AppendOnly {
+		return s.loadSnapshot()
+	}
+
+	// A single append-only file, as written before multi-part files,
+	// becomes the base file of the directory
+	dir := aofDir(cfg)
+	manifest := filepath.Join(dir, persistence.ManifestName(cfg.AppendFilename))
+	if _, err := os.Stat(manifest); errors.Is(err, os.ErrNotExist) {
+		legacy := filepath.Join(cfg.Dir, cfg.AppendFilename)
+		if _, err := os.Stat(legacy); errors.Is(err, os.ErrNotExist) {
+			if err := s.loadSnapshot(); err != nil {
+				return err
+			}
+			return s.startAOF(cfg)
+		}
+		if err := persistence.UpgradeAOF(legacy, dir, cfg.AppendFilename); err != nil {
+			return fmt.Errorf("upgrading the append-only file: %w", err)
+		}
+		log.Printf("Moved the append-only file %s into %s", legacy, dir)
+	}
+
+	start := time.Now()
+	stats, err := persistence.LoadAOF(dir, cfg.AppendFilename, s.store, cfg.AOFLoadTruncated)
+	if err != nil {
+		return fmt.Errorf("loading the append-only file: %w", err)
+	}
+	if stats.Truncated {
+		log.Printf("!!! Warning: short read while loading the AOF file in %s !!! AOF truncated and loaded anyway because aof-load-truncated is enabled", dir)
+	}
+	log.Printf("DB loaded from append only file: %d keys and %d commands from %d files in %v",
+		stats.RDB.Loaded, stats.Commands, stats.Files, time.Since(start).Round(time.Millisecond))
+	if skipped := stats.RDB.Skipped(); skipped != "" {
+		log.Printf("Skipped keys that cannot be stored: %s", skipped)
+	}
+
+	aof, err := persistence.OpenAOF(dir, cfg.AppendFilename, s.store, aofOptions(cfg))
+	if err != nil {
+		return err
+	}
+	s.aof.Store(aof)
+	return nil
+}
+
+// loadSnapshot loads the snapshot file, if any, into the keyspace
+func (s *Server) loadSnapshot() error {
+	start := time.Now()
+	stats, err := s.snapshotter.Load()
+	if err != nil {
+		return err
+	}
+	if stats.Loaded > 0 {
+		log.Printf("DB loaded from disk: %d keys in %v", stats.Loaded, time.Since(start).Round(time.Millisecond))
+	}
+	// Only strings in database 0 can be stored, so the rest of an RDB file
+	// written by Redis is left out
+	if skipped := stats.Skipped(); skipped != "" {
+		log.Printf("Skipped keys that cannot be stored: %s", skipped)
+	}
+	return nil
+}
+
+// Shutdown stops the server, syncs the append-only file, then saves a
+// final snapshot if save points are configured, as Redis does on SHUTDOWN
+func (s *Server) Shutdown() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	if err := s.stopAOF(); err != nil {
+		log.Printf("Error closing the append-only file: %v", err)
+	}
+
+	// A background save may be running; the final save must come after it
+	s.snapshotter.Wait()
+	if len(s.config.Config().Save) == 0 {
+		return nil
+	}
+
+	log.Println("Saving the final snapshot before exiting")
+	return s.snapshotter.Save()
+}
+
+// Snapshotter returns the snapshotter saving the keyspace
+func (s *Server) Snapshotter() *persistence.Snapshotter {
+	return s.snapshotter
+}
+
+// checkSavePoints starts background saves as the save points are reached,
+// until the server stops
+func (s *Server) checkSavePoints() {
+	ticker := time.NewTicker(SavePointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.snapshotter.CheckSavePoints(s.config.Config().Save, now)
+		case <-s.shutdown:
+			return
+		}
+	}
+}
+
+// startAOF writes the keyspace to a new append-only file and logs every
+// change to it from then on, unless the AOF is already on
+func (s *Server) startAOF(cfg *config.Config) error {
+	s.aofMutex.Lock()
+	defer s.aofMutex.Unlock()
+
+	if s.aof.Load() != nil {
+		return nil
+	}
+
+	start := time.Now()
+	aof, err := persistence.CreateAOF(aofDir(cfg), cfg.AppendFilename, s.store, aofOptions(cfg))
+	if err != nil {
+		return fmt.Errorf("creating the append-only file: %w", err)
+	}
+	s.aof.Store(aof)
+	log.Printf("Append only file created in %v", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// stopAOF stops logging changes, then syncs and closes the append-only
+// file, if the AOF is on
+func (s *Server) stopAOF() error {
+	s.aofMutex.Lock()
+	defer s.aofMutex.Unlock()
+
+	aof := s.aof.Swap(nil)
+	if aof == nil {
+		return nil
+	}
+	s.store.SetChangeLog(nil)
+	return aof.Close()
+}
+
+// flushAOF writes the changes logged so far to the append-only file, if
+// the AOF is on. Connections call it before sending replies, so that
+// under appendfsync always a change is on disk before it is acknowledged.
+func (s *Server) flushAOF() {
+	if aof := s.aof.Load(); aof != nil {
+		if err := aof.Flush(); err != nil {
+			log.Printf("Error writing to the append-only file: %v", err)
+		}
+	}
+}
+
+// RewriteAOF starts rewriting the append-only file in the background, for
+// BGREWRITEAOF. With appendonly off, a new append-only file is written
+// from the keyspace, and not kept up to date.
+func (s *Server) RewriteAOF() error {
+	if aof := s.aof.Load(); aof != nil {
+		return aof.BackgroundRewrite()
+	}
+
+	s.aofMutex.Lock()
+	if s.rewritingAOF {
+		s.aofMutex.Unlock()
+		return persistence.ErrRewriteInProgress
+	}
+	s.rewritingAOF = true
+	s.aofMutex.Unlock()
+
+	go func() {
+		s.aofMutex.Lock()
+		defer s.aofMutex.Unlock()
+		defer func() { s.rewritingAOF = false }()
+
+		// The AOF may have been turned on meanwhile
+		if s.aof.Load() != nil {
+			return
+		}
+		cfg := s.config.Config()
+		aof, err := persistence.CreateAOF(aofDir(cfg), cfg.AppendFilename, s.store, aofOptions(cfg))
+		if err != nil {
+			log.Printf("Background AOF rewrite error: %v", err)
+			return
+		}
+		s.store.SetChangeLog(nil)
+		aof.Close()
+		log.Println("Background AOF rewrite terminated with success")
+	}()
+	return nil
+}
+
+// syncAOF syncs the append-only file every second under appendfsync
+// everysec, and rewrites it as it grows past auto-aof-rewrite-percentage,
+// until the server stops. Under the other policies it writes the changes
+// no client is waiting for, such as keys expired in the background.
+func (s *Server) syncAOF() {
+	ticker := time.NewTicker(AOFSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			aof := s.aof.Load()
+			if aof == nil {
+				continue
+			}
+			cfg := s.config.Config()
+			aof.CheckRewrite(cfg.AutoAOFRewritePercentage, cfg.AutoAOFRewriteMinSize, now)
+
+			var err error
+			if aof.Options().Fsync == config.FsyncEverySec {
+				err = aof.Sync()
+			} else {
+				err = aof.Flush()
+			}
+			if err != nil {
+				log.Printf("Error writing to the append-only file: %v", err)
+			}
+		case <-s.shutdown:
+			return
+		}
+	}
+}
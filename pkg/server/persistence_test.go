@@ -0,0 +1,279 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// persistentConfig returns a configuration saving snapshots to dir
+func persistentConfig(dir string) *config.Config {
+	cfg := config.Default()
+	cfg.Bind = "127.0.0.1"
+	cfg.Dir = dir
+	return cfg
+}
+
+func TestServer_SaveAndLoadData(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServerWithConfig(persistentConfig(dir))
+	handler := server.GetCommandHandler()
+
+	handler.Execute("SET", []*resp.Message{resp.NewBulkString("key"), resp.NewBulkString("value")}, server.store)
+	response, _ := handler.Execute("SAVE", nil, server.store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); err != nil {
+		t.Fatalf("Expected dump.rdb to be written: %v", err)
+	}
+
+	restarted := NewServerWithConfig(persistentConfig(dir))
+	if err := restarted.LoadData(); err != nil {
+		t.Fatalf("LoadData() returned error: %v", err)
+	}
+	if value, _ := restarted.store.Get("key"); string(value) != "value" {
+		t.Errorf("Expected 'value', got %q", value)
+	}
+}
+
+func TestServer_LoadDataOverMaxMemory(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServerWithConfig(persistentConfig(dir))
+	for _, key := range []string{"a", "b", "c"} {
+		server.store.Set(key, []byte("value"))
+	}
+	server.GetCommandHandler().Execute("SAVE", nil, server.store)
+
+	// Every key loads despite the limit, which the next write enforces
+	cfg := persistentConfig(dir)
+	cfg.MaxMemory = 1
+	cfg.MaxMemoryPolicy = config.PolicyAllKeysLRU
+	restarted := NewServerWithConfig(cfg)
+	if err := restarted.LoadData(); err != nil {
+		t.Fatalf("LoadData() returned error: %v", err)
+	}
+	if restarted.store.Size() != 3 {
+		t.Fatalf("Expected 3 keys loaded, got %d", restarted.store.Size())
+	}
+
+	restarted.store.Set("d", []byte("value"))
+	if restarted.store.Size() != 1 || !restarted.store.Exists("d") {
+		t.Errorf("Expected the loaded keys to be evicted, got %d keys", restarted.store.Size())
+	}
+}
+
+func TestServer_LoadDataCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "dump.rdb"), []byte("REDIS0009garbage"), 0644)
+
+	server := NewServerWithConfig(persistentConfig(dir))
+	if err := server.LoadData(); err == nil {
+		t.Error("Expected an error loading a corrupt snapshot")
+	}
+}
+
+func TestServer_ConfigSetDBFilename(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServerWithConfig(persistentConfig(dir))
+	handler := server.GetCommandHandler()
+
+	response, _ := handler.Execute("CONFIG", []*resp.Message{
+		resp.NewBulkString("SET"), resp.NewBulkString("dbfilename"), resp.NewBulkString("other.rdb"),
+	}, server.store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+
+	if path := server.Snapshotter().Path(); path != filepath.Join(dir, "other.rdb") {
+		t.Errorf("Expected snapshots to be saved to other.rdb, got %s", path)
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServerWithConfig(persistentConfig(dir))
+	serve(t, server)
+	server.store.Set("key", []byte("value"))
+
+	if err := server.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); err != nil {
+		t.Errorf("Expected a final snapshot: %v", err)
+	}
+
+	// Without save points, nothing is saved
+	dir = t.TempDir()
+	cfg := persistentConfig(dir)
+	cfg.Save = nil
+	server = NewServerWithConfig(cfg)
+	serve(t, server)
+
+	if err := server.Shutdown(); err != nil {
+		t.Fatalf("Shutdown() returned error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dump.rdb")); !os.IsNotExist(err) {
+		t.Errorf("Expected no snapshot, got %v", err)
+	}
+}
+
+func TestServer_AppendOnly(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	cfg.AppendOnly = true
+	cfg.AppendFsync = config.FsyncAlways
+	server := NewServerWithConfig(cfg)
+	if err := server.LoadData(); err != nil {
+		t.Fatalf("LoadData() returned error: %v", err)
+	}
+	address := serve(t, server)
+
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("*5\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nvalue\r\n$2\r\nEX\r\n$3\r\n100\r\n"))
+	if reply, _ := bufio.NewReader(conn).ReadString('\n'); reply != "+OK\r\n" {
+		t.Fatalf("Expected +OK, got %q", reply)
+	}
+
+	// Under appendfsync always, the change is written before the reply
+	data, _ := os.ReadFile(filepath.Join(dir, "appendonlydir", "appendonly.aof.1.incr.aof"))
+	if !bytes.Contains(data, []byte("PXAT")) {
+		t.Fatalf("Expected the SET to be logged with PXAT, got %q", data)
+	}
+
+	restarted := NewServerWithConfig(cfg)
+	if err := restarted.LoadData(); err != nil {
+		t.Fatalf("LoadData() returned error: %v", err)
+	}
+	defer restarted.stopAOF()
+	if value, _ := restarted.store.Get("key"); string(value) != "value" {
+		t.Errorf("Expected 'value', got %q", value)
+	}
+}
+
+func TestServer_ConfigSetAppendOnly(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServerWithConfig(persistentConfig(dir))
+	handler := server.GetCommandHandler()
+	server.store.Set("before", []byte("1"))
+
+	response, _ := handler.Execute("CONFIG", []*resp.Message{resp.NewBulkString("SET"), resp.NewBulkString("appendonly"), resp.NewBulkString("yes")}, server.store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+	server.store.Set("after", []byte("2"))
+
+	response, _ = handler.Execute("CONFIG", []*resp.Message{resp.NewBulkString("SET"), resp.NewBulkString("appendonly"), resp.NewBulkString("no")}, server.store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+	server.store.Set("ignored", []byte("3"))
+
+	store := storage.NewMemoryStore()
+	if _, err := persistence.LoadAOF(filepath.Join(dir, "appendonlydir"), "appendonly.aof", store, false); err != nil {
+		t.Fatalf("LoadAOF() returned error: %v", err)
+	}
+	if !store.Exists("before") || !store.Exists("after") || store.Exists("ignored") {
+		t.Errorf("Expected the keys set while the AOF was on, got %d keys", store.Size())
+	}
+}
+
+func TestServer_LoadDataTruncatedAOF(t *testing.T) {
+	for _, truncate := range []bool{true, false} {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "appendonly.aof"), []byte("*3\r\n$3\r\nSET\r\n$1\r\na\r\n$1\r\n1\r\n*3\r\n$3\r\nSET"), 0644)
+
+		cfg := persistentConfig(dir)
+		cfg.AppendOnly = true
+		cfg.AOFLoadTruncated = truncate
+		server := NewServerWithConfig(cfg)
+		err := server.LoadData()
+		server.stopAOF()
+
+		// The single file written before multi-part files is upgraded
+		// before being loaded
+		if truncate && (err != nil || !server.store.Exists("a")) {
+			t.Errorf("Expected the complete commands to be loaded, got %v", err)
+		}
+		if !truncate && err == nil {
+			t.Error("Expected an error loading a truncated AOF without aof-load-truncated")
+		}
+	}
+}
+
+func TestServer_BgRewriteAOF(t *testing.T) {
+	dir := t.TempDir()
+	cfg := persistentConfig(dir)
+	cfg.AppendOnly = true
+	server := NewServerWithConfig(cfg)
+	if err := server.LoadData(); err != nil {
+		t.Fatalf("LoadData() returned error: %v", err)
+	}
+	defer server.stopAOF()
+	handler := server.GetCommandHandler()
+
+	server.store.Set("key", []byte("1"))
+	server.store.Set("key", []byte("2"))
+	response, _ := handler.Execute("BGREWRITEAOF", nil, server.store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected the rewrite to start, got %s", response)
+	}
+	aof := server.aof.Load()
+	aof.Wait()
+	if err := aof.LastError(); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	if base := aof.Manifest().Base; base == nil || base.Name != "appendonly.aof.2.base.rdb" {
+		t.Errorf("Expected a second base file, got %+v", base)
+	}
+	store := storage.NewMemoryStore()
+	if _, err := persistence.LoadAOF(filepath.Join(dir, "appendonlydir"), "appendonly.aof", store, false); err != nil {
+		t.Fatalf("LoadAOF() returned error: %v", err)
+	}
+	if value, _ := store.Get("key"); string(value) != "2" {
+		t.Errorf("Expected '2', got %q", value)
+	}
+}
+
+func TestServer_BgRewriteAOFWithAppendOnlyOff(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServerWithConfig(persistentConfig(dir))
+	server.store.Set("key", []byte("value"))
+
+	if err := server.RewriteAOF(); err != nil {
+		t.Fatalf("RewriteAOF() returned error: %v", err)
+	}
+	for rewriting := true; rewriting; time.Sleep(time.Millisecond) {
+		server.aofMutex.Lock()
+		rewriting = server.rewritingAOF
+		server.aofMutex.Unlock()
+	}
+
+	store := storage.NewMemoryStore()
+	if _, err := persistence.LoadAOF(filepath.Join(dir, "appendonlydir"), "appendonly.aof", store, false); err != nil {
+		t.Fatalf("LoadAOF() returned error: %v", err)
+	}
+	if !store.Exists("key") {
+		t.Error("Expected the keyspace to be written")
+	}
+
+	// Changes are not logged afterwards
+	server.store.Set("later", []byte("1"))
+	if server.aof.Load() != nil {
+		t.Error("Expected appendonly to stay off")
+	}
+}
@@ -0,0 +1,335 @@
+package server
+
+import (
+	"log"
+	"strings"
+
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// DefaultPubSubBufferSize is the number of published messages that may wait
+// for delivery to a single subscriber before it is disconnected
+const DefaultPubSubBufferSize = 1024
+
+// subscriptionKind distinguishes channel, pattern and shard channel subscriptions
+type subscriptionKind int
+
+const (
+	channelSubscription subscriptionKind = iota
+	patternSubscription
+	shardSubscription
+)
+
+// subscriptions holds the pub/sub state of a single connection
+type subscriptions struct {
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	shards   map[string]struct{}
+}
+
+// newSubscriptions creates an empty subscription state
+func newSubscriptions() subscriptions {
+	return subscriptions{
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+		shards:   make(map[string]struct{}),
+	}
+}
+
+// count returns the total number of subscriptions of every kind
+func (s *subscriptions) count() int {
+	return len(s.channels) + len(s.patterns) + len(s.shards)
+}
+
+// set returns the subscriptions of the given kind
+func (s *subscriptions) set(kind subscriptionKind) map[string]struct{} {
+	switch kind {
+	case patternSubscription:
+		return s.patterns
+	case shardSubscription:
+		return s.shards
+	default:
+		return s.channels
+	}
+}
+
+// replyCount returns the subscription count reported in confirmations of
+// the given kind. Shard subscriptions are counted separately from classic
+// channel and pattern subscriptions, as in Redis.
+func (s *subscriptions) replyCount(kind subscriptionKind) int {
+	if kind == shardSubscription {
+		return len(s.shards)
+	}
+	return len(s.channels) + len(s.patterns)
+}
+
+// allowedWhileSubscribed lists the commands a connection may run while it
+// has at least one subscription
+var allowedWhileSubscribed = map[string]bool{
+	"SUBSCRIBE":    true,
+	"UNSUBSCRIBE":  true,
+	"PSUBSCRIBE":   true,
+	"PUNSUBSCRIBE": true,
+	"SSUBSCRIBE":   true,
+	"SUNSUBSCRIBE": true,
+	"PING":         true,
+	"QUIT":         true,
+	"RESET":        true,
+}
+
+// subscribedMode reports whether the connection is restricted to the
+// subscription commands. Only RESP2 connections are: under RESP3, published
+// messages are push messages that clients can tell apart from replies.
+func (c *Connection) subscribedMode() bool {
+	return c.subs.count() > 0 && c.serializer.Protocol() < resp.RESP3
+}
+
+// Deliver queues a published message for asynchronous delivery. It never
+// blocks: a subscriber whose buffer is full is too slow to keep up and is
+// disconnected, as Redis does when the pubsub output buffer limit is hit.
+func (c *Connection) Deliver(message *resp.Message) {
+	select {
+	case c.outbox <- message:
+	default:
+		c.overflowOnce.Do(func() {
+			log.Printf("Disconnecting slow subscriber %s: output buffer full", c.conn.RemoteAddr())
+			c.conn.Close()
+		})
+	}
+}
+
+// deliverMessages writes queued published messages until the connection closes
+func (c *Connection) deliverMessages() {
+	for {
+		select {
+		case message := <-c.outbox:
+			if err := c.writeMessages(message); err != nil {
+				log.Printf("Error delivering message: %v", err)
+				c.conn.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// writeMessages writes a published message together with any others already
+// waiting in the outbox, and flushes them with a single write
+func (c *Connection) writeMessages(message *resp.Message) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	for {
+		if err := c.serializer.Serialize(message); err != nil {
+			return err
+		}
+
+		select {
+		case message = <-c.outbox:
+		default:
+			return c.serializer.Flush()
+		}
+	}
+}
+
+// handlePubSub processes the subscription commands and PING in subscribed
+// mode. Subscription commands write their replies directly, one per channel,
+// and return a nil response. It returns false if the command is not a
+// pub/sub command.
+func (c *Connection) handlePubSub(commandName string, args []*resp.Message) (*resp.Message, bool) {
+	switch commandName {
+	case "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE", "SSUBSCRIBE", "SUNSUBSCRIBE":
+	case "PING":
+		if !c.subscribedMode() {
+			return nil, false
+		}
+		return c.subscribedPing(args), true
+	default:
+		if c.subscribedMode() && !allowedWhileSubscribed[commandName] {
+			return resp.NewError("ERR Can't execute '" + strings.ToLower(commandName) + "': only (P|S)SUBSCRIBE / " +
+				"(P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context"), true
+		}
+		return nil, false
+	}
+
+	if c.tx.active {
+		c.tx.dirty = true
+		return resp.NewError("ERR Command not allowed inside a transaction"), true
+	}
+
+	names := make([]string, len(args))
+	for i, arg := range args {
+		name, err := arg.AsString()
+		if err != nil {
+			return resp.NewError("ERR invalid channel type"), true
+		}
+		names[i] = name
+	}
+
+	var err error
+	switch commandName {
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		if len(names) == 0 {
+			return resp.NewError("ERR wrong number of arguments for '" + strings.ToLower(commandName) + "' command"), true
+		}
+		err = c.subscribe(subscriptionKinds[commandName], names)
+	case "UNSUBSCRIBE", "PUNSUBSCRIBE", "SUNSUBSCRIBE":
+		err = c.unsubscribe(subscriptionKinds[commandName], names)
+	}
+
+	if err != nil {
+		log.Printf("Error writing subscription reply: %v", err)
+	}
+	return nil, true
+}
+
+// subscriptionKinds maps the subscription commands to the kind they manage
+var subscriptionKinds = map[string]subscriptionKind{
+	"SUBSCRIBE":    channelSubscription,
+	"UNSUBSCRIBE":  channelSubscription,
+	"PSUBSCRIBE":   patternSubscription,
+	"PUNSUBSCRIBE": patternSubscription,
+	"SSUBSCRIBE":   shardSubscription,
+	"SUNSUBSCRIBE": shardSubscription,
+}
+
+// subscribe adds subscriptions of the given kind and writes one
+// confirmation per name
+func (c *Connection) subscribe(kind subscriptionKind, names []string) error {
+	c.startDelivery.Do(func() { go c.deliverMessages() })
+
+	// Hold the write lock while subscribing so that no published message
+	// can be written before the confirmation
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	set := c.subs.set(kind)
+	for _, name := range names {
+		if _, exists := set[name]; !exists {
+			set[name] = struct{}{}
+			c.brokerSubscribe(kind, name)
+		}
+
+		if err := c.serializer.Serialize(c.subscriptionReply(kind, true, resp.NewBulkString(name))); err != nil {
+			return err
+		}
+	}
+
+	return c.serializer.Flush()
+}
+
+// unsubscribe removes subscriptions of the given kind, or all of them if no
+// names are given, and writes one confirmation per name
+func (c *Connection) unsubscribe(kind subscriptionKind, names []string) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	set := c.subs.set(kind)
+
+	if len(names) == 0 {
+		for name := range set {
+			names = append(names, name)
+		}
+
+		// Unsubscribing from nothing still gets a single confirmation
+		if len(names) == 0 {
+			if err := c.serializer.Serialize(c.subscriptionReply(kind, false, resp.NewNullBulkString())); err != nil {
+				return err
+			}
+			return c.serializer.Flush()
+		}
+	}
+
+	for _, name := range names {
+		if _, exists := set[name]; exists {
+			delete(set, name)
+			c.brokerUnsubscribe(kind, name)
+		}
+
+		if err := c.serializer.Serialize(c.subscriptionReply(kind, false, resp.NewBulkString(name))); err != nil {
+			return err
+		}
+	}
+
+	return c.serializer.Flush()
+}
+
+// brokerSubscribe adds a subscription of the given kind to the broker
+func (c *Connection) brokerSubscribe(kind subscriptionKind, name string) {
+	switch kind {
+	case channelSubscription:
+		c.broker.Subscribe(c, name)
+	case patternSubscription:
+		c.broker.PSubscribe(c, name)
+	case shardSubscription:
+		c.broker.SSubscribe(c, name)
+	}
+}
+
+// brokerUnsubscribe removes a subscription of the given kind from the broker
+func (c *Connection) brokerUnsubscribe(kind subscriptionKind, name string) {
+	switch kind {
+	case channelSubscription:
+		c.broker.Unsubscribe(c, name)
+	case patternSubscription:
+		c.broker.PUnsubscribe(c, name)
+	case shardSubscription:
+		c.broker.SUnsubscribe(c, name)
+	}
+}
+
+// unsubscribeAll removes every subscription of the connection from the broker
+func (c *Connection) unsubscribeAll() {
+	for _, kind := range []subscriptionKind{channelSubscription, patternSubscription, shardSubscription} {
+		for name := range c.subs.set(kind) {
+			c.brokerUnsubscribe(kind, name)
+		}
+	}
+	c.subs = newSubscriptions()
+}
+
+// subscriptionReplyPrefixes holds the prefix of the confirmation names of
+// each kind, such as "p" in "psubscribe"
+var subscriptionReplyPrefixes = map[subscriptionKind]string{
+	channelSubscription: "",
+	patternSubscription: "p",
+	shardSubscription:   "s",
+}
+
+// subscriptionReply builds a (un)subscribe confirmation carrying the number
+// of subscriptions the connection still has
+func (c *Connection) subscriptionReply(kind subscriptionKind, subscribed bool, name *resp.Message) *resp.Message {
+	replyName := subscriptionReplyPrefixes[kind] + "unsubscribe"
+	if subscribed {
+		replyName = subscriptionReplyPrefixes[kind] + "subscribe"
+	}
+
+	return resp.NewPush([]*resp.Message{
+		resp.NewBulkString(replyName),
+		name,
+		resp.NewInteger(int64(c.subs.replyCount(kind))),
+	})
+}
+
+// subscribedPing replies to PING in subscribed mode, where the reply is an
+// array so it can be told apart from published messages
+func (c *Connection) subscribedPing(args []*resp.Message) *resp.Message {
+	if len(args) > 1 {
+		return resp.NewError("ERR wrong number of arguments for 'ping' command")
+	}
+
+	payload := ""
+	if len(args) == 1 {
+		value, err := args[0].AsString()
+		if err != nil {
+			return resp.NewError("ERR invalid argument type for PING")
+		}
+		payload = value
+	}
+
+	return resp.NewArray([]*resp.Message{
+		resp.NewBulkString("pong"),
+		resp.NewBulkString(payload),
+	})
+}
@@ -0,0 +1,320 @@
+package server
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+// testClient is the client side of a connection served over net.Pipe
+type testClient struct {
+	conn       net.Conn
+	parser     *resp.Parser
+	serializer *resp.Serializer
+}
+
+// startTestClient serves a connection sharing the store and broker and
+// returns a client connected to it
+func startTestClient(t *testing.T, store storage.Store, broker *pubsub.Broker) *testClient {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	connection := newTestConnectionWith(serverConn, store, broker)
+
+	go func() {
+		defer serverConn.Close()
+		connection.Handle()
+	}()
+
+	t.Cleanup(func() { clientConn.Close() })
+
+	return &testClient{
+		conn:       clientConn,
+		parser:     resp.NewParser(clientConn),
+		serializer: resp.NewSerializer(clientConn),
+	}
+}
+
+// send writes a command to the server
+func (c *testClient) send(t *testing.T, args ...string) {
+	t.Helper()
+
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	if err := c.serializer.Serialize(command(args...)); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+	if err := c.serializer.Flush(); err != nil {
+		t.Fatalf("Failed to send command: %v", err)
+	}
+}
+
+// receive reads a single message from the server
+func (c *testClient) receive(t *testing.T) *resp.Message {
+	t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	message, err := c.parser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	return message
+}
+
+// receiveStrings reads an array reply and returns its elements as strings,
+// rendering integers in decimal and nulls as "<nil>"
+func (c *testClient) receiveStrings(t *testing.T) []string {
+	t.Helper()
+
+	message := c.receive(t)
+	elements, err := message.AsArray()
+	if err != nil {
+		t.Fatalf("Expected array reply, got %s", message)
+	}
+
+	result := make([]string, len(elements))
+	for i, element := range elements {
+		switch {
+		case element.IsNull():
+			result[i] = "<nil>"
+		case element.Type == resp.Integer:
+			value, _ := element.AsInteger()
+			result[i] = strconv.FormatInt(value, 10)
+		default:
+			result[i], _ = element.AsString()
+		}
+	}
+	return result
+}
+
+func expectStrings(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestPubSub_SubscribeAndPublish(t *testing.T) {
+	store := storage.NewMemoryStore()
+	broker := pubsub.NewBroker()
+	subscriber := startTestClient(t, store, broker)
+	publisher := startTestClient(t, store, broker)
+
+	subscriber.send(t, "SUBSCRIBE", "news", "weather")
+	expectStrings(t, subscriber.receiveStrings(t), "subscribe", "news", "1")
+	expectStrings(t, subscriber.receiveStrings(t), "subscribe", "weather", "2")
+
+	publisher.send(t, "PUBLISH", "news", "hello")
+	if reply := publisher.receive(t); reply.Type != resp.Integer || reply.Value.(int64) != 1 {
+		t.Fatalf("Expected PUBLISH to reach 1 subscriber, got %s", reply)
+	}
+
+	expectStrings(t, subscriber.receiveStrings(t), "message", "news", "hello")
+
+	subscriber.send(t, "UNSUBSCRIBE")
+	first := subscriber.receiveStrings(t)
+	second := subscriber.receiveStrings(t)
+	if first[0] != "unsubscribe" || second[0] != "unsubscribe" || second[2] != "0" {
+		t.Fatalf("Unexpected unsubscribe replies %q and %q", first, second)
+	}
+
+	// Back in normal mode, regular commands are allowed again
+	subscriber.send(t, "PING")
+	if reply := subscriber.receive(t); reply.Type != resp.SimpleString || reply.Value.(string) != "PONG" {
+		t.Fatalf("Expected PONG after unsubscribing, got %s", reply)
+	}
+}
+
+func TestPubSub_PatternSubscribe(t *testing.T) {
+	store := storage.NewMemoryStore()
+	broker := pubsub.NewBroker()
+	subscriber := startTestClient(t, store, broker)
+	publisher := startTestClient(t, store, broker)
+
+	subscriber.send(t, "PSUBSCRIBE", "news.*")
+	expectStrings(t, subscriber.receiveStrings(t), "psubscribe", "news.*", "1")
+
+	publisher.send(t, "PUBLISH", "news.sports", "goal")
+	publisher.receive(t)
+
+	expectStrings(t, subscriber.receiveStrings(t), "pmessage", "news.*", "news.sports", "goal")
+
+	subscriber.send(t, "PUNSUBSCRIBE", "news.*")
+	expectStrings(t, subscriber.receiveStrings(t), "punsubscribe", "news.*", "0")
+}
+
+func TestPubSub_SubscribedModeRestrictions(t *testing.T) {
+	client := startTestClient(t, storage.NewMemoryStore(), pubsub.NewBroker())
+
+	client.send(t, "SUBSCRIBE", "news")
+	client.receive(t)
+
+	client.send(t, "GET", "key")
+	reply := client.receive(t)
+	want := "ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context"
+	if reply.Type != resp.Error || reply.Value.(string) != want {
+		t.Fatalf("Expected subscribed mode error, got %s", reply)
+	}
+
+	client.send(t, "PING")
+	expectStrings(t, client.receiveStrings(t), "pong", "")
+
+	client.send(t, "PING", "hi")
+	expectStrings(t, client.receiveStrings(t), "pong", "hi")
+}
+
+func TestPubSub_UnsubscribeWithoutSubscriptions(t *testing.T) {
+	client := startTestClient(t, storage.NewMemoryStore(), pubsub.NewBroker())
+
+	client.send(t, "UNSUBSCRIBE")
+	expectStrings(t, client.receiveStrings(t), "unsubscribe", "<nil>", "0")
+}
+
+func TestPubSub_DisconnectRemovesSubscriptions(t *testing.T) {
+	broker := pubsub.NewBroker()
+	client := startTestClient(t, storage.NewMemoryStore(), broker)
+
+	client.send(t, "SUBSCRIBE", "news")
+	client.receive(t)
+
+	if counts := broker.NumSub("news"); counts[0] != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d", counts[0])
+	}
+
+	client.conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for broker.NumSub("news")[0] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Subscription was not removed after disconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPubSub_SlowSubscriberIsDisconnected(t *testing.T) {
+	broker := pubsub.NewBroker()
+	client := startTestClient(t, storage.NewMemoryStore(), broker)
+
+	client.send(t, "SUBSCRIBE", "news")
+	client.receive(t)
+
+	// The client never reads, so the delivery goroutine blocks on the pipe
+	// and the bounded buffer eventually overflows
+	for i := 0; i < DefaultPubSubBufferSize+2; i++ {
+		broker.Publish("news", "flood")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for broker.NumSub("news")[0] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Slow subscriber was not disconnected")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPubSub_NotAllowedInTransaction(t *testing.T) {
+	connection := newTestConnection(storage.NewMemoryStore())
+
+	connection.processCommand(command("MULTI"))
+	expectReply(t, connection.processCommand(command("SUBSCRIBE", "news")), resp.Error,
+		"ERR Command not allowed inside a transaction")
+}
+
+func TestPubSub_ShardSubscribe(t *testing.T) {
+	store := storage.NewMemoryStore()
+	broker := pubsub.NewBroker()
+	subscriber := startTestClient(t, store, broker)
+	publisher := startTestClient(t, store, broker)
+
+	subscriber.send(t, "SUBSCRIBE", "orders")
+	expectStrings(t, subscriber.receiveStrings(t), "subscribe", "orders", "1")
+
+	// Shard subscriptions are counted separately from classic ones
+	subscriber.send(t, "SSUBSCRIBE", "orders", "{orders}.audit")
+	expectStrings(t, subscriber.receiveStrings(t), "ssubscribe", "orders", "1")
+	expectStrings(t, subscriber.receiveStrings(t), "ssubscribe", "{orders}.audit", "2")
+
+	publisher.send(t, "SPUBLISH", "orders", "created")
+	if reply := publisher.receive(t); reply.Type != resp.Integer || reply.Value.(int64) != 1 {
+		t.Fatalf("Expected SPUBLISH to reach 1 subscriber, got %s", reply)
+	}
+	expectStrings(t, subscriber.receiveStrings(t), "smessage", "orders", "created")
+
+	publisher.send(t, "PUBLISH", "orders", "classic")
+	publisher.receive(t)
+	expectStrings(t, subscriber.receiveStrings(t), "message", "orders", "classic")
+
+	subscriber.send(t, "SUNSUBSCRIBE", "orders")
+	expectStrings(t, subscriber.receiveStrings(t), "sunsubscribe", "orders", "1")
+
+	subscriber.send(t, "SUNSUBSCRIBE")
+	expectStrings(t, subscriber.receiveStrings(t), "sunsubscribe", "{orders}.audit", "0")
+
+	// Still subscribed to the classic channel, so still in subscribed mode
+	subscriber.send(t, "GET", "key")
+	if reply := subscriber.receive(t); reply.Type != resp.Error {
+		t.Fatalf("Expected subscribed mode error, got %s", reply)
+	}
+
+	subscriber.send(t, "SUNSUBSCRIBE")
+	expectStrings(t, subscriber.receiveStrings(t), "sunsubscribe", "<nil>", "0")
+}
+
+func TestPubSub_ShardSubscriptionEntersSubscribedMode(t *testing.T) {
+	broker := pubsub.NewBroker()
+	client := startTestClient(t, storage.NewMemoryStore(), broker)
+
+	client.send(t, "SSUBSCRIBE", "orders")
+	client.receive(t)
+
+	client.send(t, "PING")
+	expectStrings(t, client.receiveStrings(t), "pong", "")
+
+	client.conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for broker.ShardNumSub("orders")[0] != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Shard subscription was not removed after disconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPubSub_KeyspaceNotifications(t *testing.T) {
+	store := storage.NewMemoryStore()
+	broker := pubsub.NewBroker()
+	notifier := pubsub.NewKeyspaceNotifier(broker)
+	store.SetNotifier(notifier)
+
+	if err := notifier.SetFlags("KEA"); err != nil {
+		t.Fatalf("SetFlags returned error: %v", err)
+	}
+
+	subscriber := startTestClient(t, store, broker)
+	writer := startTestClient(t, store, broker)
+
+	subscriber.send(t, "SUBSCRIBE", "__keyevent@0__:expired", "__keyspace@0__:session")
+	subscriber.receive(t)
+	subscriber.receive(t)
+
+	writer.send(t, "SET", "session", "token", "PX", "20")
+	writer.receive(t)
+
+	expectStrings(t, subscriber.receiveStrings(t), "message", "__keyspace@0__:session", "set")
+	expectStrings(t, subscriber.receiveStrings(t), "message", "__keyspace@0__:session", "expire")
+
+	time.Sleep(40 * time.Millisecond)
+	store.DeleteExpired()
+
+	expectStrings(t, subscriber.receiveStrings(t), "message", "__keyspace@0__:session", "expired")
+	expectStrings(t, subscriber.receiveStrings(t), "message", "__keyevent@0__:expired", "session")
+}
@@ -5,40 +5,117 @@ import (
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/tsinivuo/redis-lite/pkg/commands"
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/persistence"
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
 	"github.com/tsinivuo/redis-lite/pkg/storage"
 )
 
+// Version is the server version reported by HELLO
+const Version = "0.1.0"
+
+// ExpiryInterval is how often the server removes expired keys that have
+// not been accessed
+const ExpiryInterval = 100 * time.Millisecond
+
 // Server represents the Redis-Lite TCP server
 type Server struct {
 	address        string
 	port           int
 	listener       net.Listener
 	commandHandler *commands.CommandHandler
-	store          storage.Store
+	store          *storage.MemoryStore
+	broker         *pubsub.Broker
+	notifier       *pubsub.KeyspaceNotifier
+	authenticator  *Authenticator
+	parserLimits   resp.Limits
 	connections    map[net.Conn]*Connection
 	mutex          sync.RWMutex
 	shutdown       chan struct{}
 	running        bool
+
+	// config holds the settings, changed at runtime by CONFIG SET
+	config *config.Manager
+	stats  stats
+	// maxClients bounds the number of connections (maxclients)
+	maxClients int
+	// idleTimeout closes connections idle for this long, in nanoseconds,
+	// 0 to keep them
+	idleTimeout atomic.Int64
+	// keepAlive is the TCP keepalive period, 0 to disable keepalives
+	keepAlive time.Duration
+	// snapshotter saves the keyspace to the snapshot file in dir
+	snapshotter *persistence.Snapshotter
+	// aof logs changes to the append-only file while appendonly is on.
+	// aofMutex serializes turning it on and off, and guards rewritingAOF,
+	// set while BGREWRITEAOF writes a file with appendonly off.
+	aof          atomic.Pointer[persistence.AOF]
+	aofMutex     sync.Mutex
+	rewritingAOF bool
 }
 
-// NewServer creates a new Redis-Lite server
+// NewServer creates a new Redis-Lite server with the default
+// configuration, listening on the given address and port
 func NewServer(address string, port int) *Server {
+	cfg := config.Default()
+	cfg.Bind = address
+	cfg.Port = port
+	return NewServerWithConfig(cfg)
+}
+
+// NewServerWithConfig creates a new Redis-Lite server from a validated
+// configuration
+func NewServerWithConfig(cfg *config.Config) *Server {
+	broker := pubsub.NewBroker()
+	store := storage.NewMemoryStore()
 	server := &Server{
-		address:        address,
-		port:           port,
+		address:        cfg.Bind,
+		port:           cfg.Port,
 		commandHandler: commands.NewCommandHandler(),
-		store:          storage.NewMemoryStore(),
+		store:          store,
+		broker:         broker,
+		notifier:       pubsub.NewKeyspaceNotifier(broker),
+		authenticator:  NewAuthenticator(),
+		parserLimits:   resp.DefaultLimits(),
 		connections:    make(map[net.Conn]*Connection),
 		shutdown:       make(chan struct{}),
+		config:         config.NewManager(cfg),
+		snapshotter:    persistence.NewSnapshotter(store, snapshotPath(cfg), rdbOptions(cfg)),
 	}
 
+	// Subsystems follow the configuration as CONFIG SET changes it
+	server.watchConfig()
+
+	// Keyspace events are published on the broker, subject to the
+	// notify-keyspace-events flags (disabled by default)
+	server.store.SetNotifier(server.notifier)
+
 	// Register built-in commands
 	server.commandHandler.Register(commands.NewPingCommand())
 	server.commandHandler.Register(commands.NewEchoCommand())
 	server.commandHandler.Register(commands.NewSetCommand())
 	server.commandHandler.Register(commands.NewGetCommand())
+	server.commandHandler.Register(commands.NewScanCommand())
+	server.commandHandler.Register(commands.NewExportCommand(server.store))
+	server.commandHandler.Register(commands.NewImportCommand())
+	server.commandHandler.Register(commands.NewDumpCommand())
+	server.commandHandler.Register(commands.NewRestoreCommand())
+	server.commandHandler.Register(commands.NewMigrateCommand())
+	server.commandHandler.Register(commands.NewObjectCommand())
+	server.commandHandler.Register(commands.NewMemoryCommand(server.store))
+	server.commandHandler.Register(commands.NewPublishCommand(server.broker))
+	server.commandHandler.Register(commands.NewPubSubCommand(server.broker))
+	server.commandHandler.Register(commands.NewSPublishCommand(server.broker))
+	server.commandHandler.Register(commands.NewConfigCommand(server.config, server.ResetStats))
+	server.commandHandler.Register(commands.NewSaveCommand(server.snapshotter))
+	server.commandHandler.Register(commands.NewBgSaveCommand(server.snapshotter))
+	server.commandHandler.Register(commands.NewLastSaveCommand(server.snapshotter))
+	server.commandHandler.Register(commands.NewBgRewriteAOFCommand(server))
 
 	return server
 }
@@ -51,13 +128,31 @@ func (s *Server) Start() error {
 		return fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
 
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on the listener until the server is stopped.
+// It lets the server be embedded, for example in tests listening on a
+// random port.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mutex.Lock()
 	s.listener = listener
 	s.running = true
+	s.mutex.Unlock()
 
-	log.Printf("Redis-Lite server started on %s", addr)
+	log.Printf("Redis-Lite server started on %s", listener.Addr())
 
 	// Accept connections in a goroutine
-	go s.acceptConnections()
+	go s.acceptConnections(listener)
+
+	// Remove expired keys in the background
+	go s.expireKeys()
+
+	// Save snapshots as the save points are reached
+	go s.checkSavePoints()
+
+	// Sync the append-only file under appendfsync everysec
+	go s.syncAOF()
 
 	// Wait for shutdown signal
 	<-s.shutdown
@@ -67,14 +162,15 @@ func (s *Server) Start() error {
 
 // Stop gracefully stops the server
 func (s *Server) Stop() error {
+	s.mutex.Lock()
 	if !s.running {
+		s.mutex.Unlock()
 		return nil
 	}
 
 	s.running = false
 
 	// Close all active connections
-	s.mutex.Lock()
 	for conn := range s.connections {
 		conn.Close()
 	}
@@ -94,19 +190,55 @@ func (s *Server) Stop() error {
 	return nil
 }
 
+// isRunning reports whether the server is accepting connections
+func (s *Server) isRunning() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.running
+}
+
 // acceptConnections accepts incoming connections and handles them
-func (s *Server) acceptConnections() {
-	for s.running {
-		conn, err := s.listener.Accept()
+func (s *Server) acceptConnections(listener net.Listener) {
+	for s.isRunning() {
+		conn, err := listener.Accept()
 		if err != nil {
-			if s.running {
+			if s.isRunning() {
 				log.Printf("Error accepting connection: %v", err)
 			}
 			continue
 		}
 
+		s.mutex.RLock()
+		full := len(s.connections) >= s.maxClients
+		s.mutex.RUnlock()
+
+		// Like Redis, clients beyond maxclients are told why before being
+		// disconnected
+		s.stats.connectionsReceived.Add(1)
+		if full {
+			s.stats.rejectedConnections.Add(1)
+			serializer := resp.NewSerializer(conn)
+			if serializer.Serialize(resp.NewError("ERR max number of clients reached")) == nil {
+				serializer.Flush()
+			}
+			conn.Close()
+			continue
+		}
+
+		s.mutex.RLock()
+		keepAlive := s.keepAlive
+		s.mutex.RUnlock()
+		setKeepAlive(conn, keepAlive)
+
 		// Create connection handler
-		connection := NewConnection(conn, s.commandHandler, s.store)
+		connection := NewConnection(conn, s.commandHandler, s.store, s.broker, s.authenticator)
+
+		s.mutex.RLock()
+		connection.limits = s.parserLimits
+		connection.idleTimeout = &s.idleTimeout
+		connection.flushAOF = s.flushAOF
+		s.mutex.RUnlock()
 
 		// Track the connection
 		s.mutex.Lock()
@@ -130,6 +262,61 @@ func (s *Server) acceptConnections() {
 	}
 }
 
+// setKeepAlive applies the tcp-keepalive period to a TCP connection
+func setKeepAlive(conn net.Conn, period time.Duration) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+
+	if period <= 0 {
+		tcpConn.SetKeepAlive(false)
+		return
+	}
+	tcpConn.SetKeepAlive(true)
+	tcpConn.SetKeepAlivePeriod(period)
+}
+
+// expireKeys periodically removes expired keys until the server stops
+func (s *Server) expireKeys() {
+	ticker := time.NewTicker(ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.store.DeleteExpired()
+		case <-s.shutdown:
+			return
+		}
+	}
+}
+
+// SetKeyspaceEvents sets the notify-keyspace-events flags, such as "KEA"
+func (s *Server) SetKeyspaceEvents(flags string) error {
+	return s.notifier.SetFlags(flags)
+}
+
+// SetParserLimits sets the protocol limits, such as proto-max-bulk-len,
+// applied to connections accepted from now on
+func (s *Server) SetParserLimits(limits resp.Limits) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.parserLimits = limits
+}
+
+// SetPassword sets the password clients must authenticate with, as
+// requirepass does in Redis. An empty password disables authentication.
+func (s *Server) SetPassword(password string) {
+	s.authenticator.SetPassword(password)
+}
+
+// Config returns the configuration of the server
+func (s *Server) Config() *config.Manager {
+	return s.config
+}
+
 // GetCommandHandler returns the command handler for testing purposes
 func (s *Server) GetCommandHandler() *commands.CommandHandler {
 	return s.commandHandler
@@ -1,9 +1,29 @@
 package server
 
 import (
+	"bufio"
+	"net"
 	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/config"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
 )
 
+// serve serves the server on a random local port and returns its address
+func serve(t *testing.T, server *Server) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Stop() })
+
+	return listener.Addr().String()
+}
+
 func TestNewServer(t *testing.T) {
 	address := "127.0.0.1"
 	port := 6379
@@ -81,3 +101,241 @@ func TestServer_Stop_WhenNotRunning(t *testing.T) {
 		t.Errorf("Stop() returned error when server not running: %v", err)
 	}
 }
+
+func TestServer_SetKeyspaceEvents(t *testing.T) {
+	server := NewServer("127.0.0.1", 6379)
+
+	if err := server.SetKeyspaceEvents("KEA"); err != nil {
+		t.Errorf("SetKeyspaceEvents returned error: %v", err)
+	}
+
+	if server.notifier.Flags() != "AKE" {
+		t.Errorf("Expected flags 'AKE', got %q", server.notifier.Flags())
+	}
+
+	if err := server.SetKeyspaceEvents("invalid"); err == nil {
+		t.Error("Expected error for invalid flags")
+	}
+}
+
+func TestServer_SetParserLimits(t *testing.T) {
+	server := NewServer("127.0.0.1", 6379)
+
+	if server.parserLimits != resp.DefaultLimits() {
+		t.Errorf("Expected default parser limits, got %+v", server.parserLimits)
+	}
+
+	limits := resp.DefaultLimits()
+	limits.MaxBulkLength = 1024
+	server.SetParserLimits(limits)
+
+	if server.parserLimits.MaxBulkLength != 1024 {
+		t.Errorf("Expected max bulk length 1024, got %d", server.parserLimits.MaxBulkLength)
+	}
+}
+
+func TestNewServerWithConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Bind = "0.0.0.0"
+	cfg.Port = 7000
+	cfg.RequirePass = "secret"
+	cfg.ProtoMaxBulkLen = 1024 * 1024
+	cfg.NotifyKeyspaceEvents = "Ex"
+	cfg.Timeout = time.Minute
+	cfg.MaxClients = 5
+
+	server := NewServerWithConfig(cfg)
+
+	if server.address != "0.0.0.0" || server.port != 7000 {
+		t.Errorf("Expected 0.0.0.0:7000, got %s:%d", server.address, server.port)
+	}
+	if !server.authenticator.Required() {
+		t.Error("Expected authentication to be required")
+	}
+	if server.parserLimits.MaxBulkLength != 1024*1024 {
+		t.Errorf("Expected max bulk length 1mb, got %d", server.parserLimits.MaxBulkLength)
+	}
+	if server.notifier.Flags() != "xE" {
+		t.Errorf("Expected flags 'xE', got %q", server.notifier.Flags())
+	}
+	if time.Duration(server.idleTimeout.Load()) != time.Minute || server.maxClients != 5 {
+		t.Errorf("Expected timeout 1m and 5 clients, got %v and %d", time.Duration(server.idleTimeout.Load()), server.maxClients)
+	}
+}
+
+func TestServer_MaxClients(t *testing.T) {
+	cfg := config.Default()
+	cfg.MaxClients = 1
+	addr := serve(t, NewServerWithConfig(cfg))
+
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer first.Close()
+
+	// The first client is tracked once it has been served
+	first.Write([]byte("PING\r\n"))
+	if line, err := bufio.NewReader(first).ReadString('\n'); err != nil || line != "+PONG\r\n" {
+		t.Fatalf("Expected PONG, got %q, %v", line, err)
+	}
+
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer second.Close()
+
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(second).ReadString('\n')
+	if err != nil || line != "-ERR max number of clients reached\r\n" {
+		t.Errorf("Expected max clients error, got %q, %v", line, err)
+	}
+}
+
+func TestServer_IdleTimeout(t *testing.T) {
+	cfg := config.Default()
+	cfg.Timeout = 50 * time.Millisecond
+	addr := serve(t, NewServerWithConfig(cfg))
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// The server closes the idle connection, ending the read
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1)
+	if _, err := conn.Read(buf); err == nil {
+		t.Error("Expected the idle connection to be closed")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Error("Expected the server to close the connection before the client deadline")
+	}
+}
+
+func TestServer_ConfigSet(t *testing.T) {
+	server := NewServer("127.0.0.1", 6379)
+	handler := server.GetCommandHandler()
+
+	response, _ := handler.Execute("CONFIG", []*resp.Message{
+		resp.NewBulkString("SET"),
+		resp.NewBulkString("requirepass"), resp.NewBulkString("secret"),
+		resp.NewBulkString("timeout"), resp.NewBulkString("5"),
+		resp.NewBulkString("proto-max-bulk-len"), resp.NewBulkString("2mb"),
+		resp.NewBulkString("maxclients"), resp.NewBulkString("3"),
+	}, server.store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+
+	if !server.authenticator.Check("default", "secret") || server.authenticator.Check("default", "other") {
+		t.Error("Expected the new password to be required")
+	}
+	if time.Duration(server.idleTimeout.Load()) != 5*time.Second {
+		t.Errorf("Expected timeout 5s, got %v", time.Duration(server.idleTimeout.Load()))
+	}
+	if server.parserLimits.MaxBulkLength != 2*1024*1024 || server.maxClients != 3 {
+		t.Errorf("Expected limits to follow, got %d and %d", server.parserLimits.MaxBulkLength, server.maxClients)
+	}
+
+	response, _ = handler.Execute("CONFIG", []*resp.Message{
+		resp.NewBulkString("SET"), resp.NewBulkString("notify-keyspace-events"), resp.NewBulkString("KEA"),
+	}, server.store)
+	if response.Type != resp.SimpleString || server.notifier.Flags() != "AKE" {
+		t.Errorf("Expected keyspace events to follow, got %s and %q", response, server.notifier.Flags())
+	}
+}
+
+func TestServer_ResetStats(t *testing.T) {
+	cfg := config.Default()
+	cfg.MaxClients = 1
+	server := NewServerWithConfig(cfg)
+	addr := serve(t, server)
+
+	first, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer first.Close()
+	first.Write([]byte("PING\r\n"))
+	bufio.NewReader(first).ReadString('\n')
+
+	second, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer second.Close()
+	bufio.NewReader(second).ReadString('\n')
+
+	if stats := server.Stats(); stats.ConnectionsReceived != 2 || stats.RejectedConnections != 1 {
+		t.Errorf("Expected 2 connections and 1 rejected, got %+v", stats)
+	}
+
+	first.Write([]byte("CONFIG RESETSTAT\r\n"))
+	first.SetReadDeadline(time.Now().Add(time.Second))
+	if line, _ := bufio.NewReader(first).ReadString('\n'); line != "+OK\r\n" {
+		t.Fatalf("Expected OK, got %q", line)
+	}
+	if stats := server.Stats(); stats != (Stats{}) {
+		t.Errorf("Expected reset stats, got %+v", stats)
+	}
+}
+
+func TestServer_Migrate(t *testing.T) {
+	source := NewServer("127.0.0.1", 0)
+	target := NewServer("127.0.0.1", 0)
+	target.SetPassword("secret")
+	host, port, _ := net.SplitHostPort(serve(t, target))
+
+	source.store.Set("user:1", []byte("alice"))
+	source.store.Set("user:2", []byte("bob"))
+	target.store.Set("user:2", []byte("old"))
+
+	response, _ := source.GetCommandHandler().Execute("MIGRATE", []*resp.Message{
+		resp.NewBulkString(host), resp.NewBulkString(port), resp.NewBulkString(""),
+		resp.NewBulkString("0"), resp.NewBulkString("1000"), resp.NewBulkString("REPLACE"),
+		resp.NewBulkString("AUTH"), resp.NewBulkString("secret"),
+		resp.NewBulkString("KEYS"), resp.NewBulkString("user:1"), resp.NewBulkString("user:2"),
+	}, source.store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+
+	if source.store.Size() != 0 {
+		t.Errorf("Expected the keys to leave the source, got %d left", source.store.Size())
+	}
+	for key, expected := range map[string]string{"user:1": "alice", "user:2": "bob"} {
+		if value, _ := target.store.Get(key); string(value) != expected {
+			t.Errorf("Expected %s to be %q on the target, got %q", key, expected, value)
+		}
+	}
+}
+
+func TestServer_ConfigSetMaxMemory(t *testing.T) {
+	server := NewServer("127.0.0.1", 6379)
+	handler := server.GetCommandHandler()
+	set := func(key string) *resp.Message {
+		response, _ := handler.Execute("SET", []*resp.Message{resp.NewBulkString(key), resp.NewBulkString("value")}, server.store)
+		return response
+	}
+	set("a")
+	set("b")
+
+	response, _ := handler.Execute("CONFIG", []*resp.Message{
+		resp.NewBulkString("SET"), resp.NewBulkString("maxmemory"), resp.NewBulkString("1"),
+	}, server.store)
+	if response.Type != resp.SimpleString {
+		t.Fatalf("Expected OK, got %s", response)
+	}
+	if response := set("c"); response.Type != resp.Error {
+		t.Errorf("Expected an OOM error under noeviction, got %s", response)
+	}
+
+	handler.Execute("CONFIG", []*resp.Message{
+		resp.NewBulkString("SET"), resp.NewBulkString("maxmemory-policy"), resp.NewBulkString("allkeys-random"),
+	}, server.store)
+	if response := set("c"); response.Type != resp.SimpleString || server.store.Size() != 1 {
+		t.Errorf("Expected the other keys to be evicted, got %s and %d keys", response, server.store.Size())
+	}
+}
@@ -0,0 +1,34 @@
+package server
+
+import "sync/atomic"
+
+// Stats are counters about the server since it started or since CONFIG
+// RESETSTAT
+type Stats struct {
+	// ConnectionsReceived counts the connections accepted, including the
+	// rejected ones
+	ConnectionsReceived int64
+	// RejectedConnections counts the connections refused because of
+	// maxclients
+	RejectedConnections int64
+}
+
+// stats holds the counters behind Stats
+type stats struct {
+	connectionsReceived atomic.Int64
+	rejectedConnections atomic.Int64
+}
+
+// Stats returns the server counters
+func (s *Server) Stats() Stats {
+	return Stats{
+		ConnectionsReceived: s.stats.connectionsReceived.Load(),
+		RejectedConnections: s.stats.rejectedConnections.Load(),
+	}
+}
+
+// ResetStats resets the server counters, as CONFIG RESETSTAT does
+func (s *Server) ResetStats() {
+	s.stats.connectionsReceived.Store(0)
+	s.stats.rejectedConnections.Store(0)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"github.com/tsinivuo/redis-lite/pkg/commands"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+)
+
+// transaction holds the MULTI/EXEC state of a single connection
+type transaction struct {
+	// active is true between MULTI and EXEC/DISCARD
+	active bool
+	// dirty is set when a command failed validation while queueing,
+	// which makes the following EXEC abort
+	dirty bool
+	queue []commands.QueuedCommand
+	// watched maps each WATCHed key to its version at WATCH time
+	watched map[string]uint64
+}
+
+// reset clears the MULTI state. Watched keys are released separately.
+func (t *transaction) reset() {
+	t.active = false
+	t.dirty = false
+	t.queue = nil
+}
+
+// handleTransaction processes MULTI, EXEC, DISCARD, WATCH and UNWATCH and
+// queues any other command while a transaction is active. It returns false
+// if the command should be executed normally.
+func (c *Connection) handleTransaction(commandName string, args []*resp.Message) (*resp.Message, bool) {
+	switch commandName {
+	case "MULTI":
+		return c.multi(args), true
+	case "EXEC":
+		return c.exec(args), true
+	case "DISCARD":
+		return c.discard(args), true
+	case "WATCH":
+		return c.watch(args), true
+	case "UNWATCH":
+		return c.unwatch(args), true
+	}
+
+	if !c.tx.active {
+		return nil, false
+	}
+
+	if reply := c.commandHandler.Check(commandName, args); reply != nil {
+		c.tx.dirty = true
+		return reply, true
+	}
+
+	// The parser recycles the argument messages for the next command
+	c.tx.queue = append(c.tx.queue, commands.QueuedCommand{Name: commandName, Args: resp.CopyMessages(args)})
+	return resp.NewSimpleString("QUEUED"), true
+}
+
+// multi starts a transaction
+func (c *Connection) multi(args []*resp.Message) *resp.Message {
+	if len(args) != 0 {
+		return resp.NewError("ERR wrong number of arguments for 'multi' command")
+	}
+
+	if c.tx.active {
+		return resp.NewError("ERR MULTI calls can not be nested")
+	}
+
+	c.tx.active = true
+	return resp.NewSimpleString("OK")
+}
+
+// exec runs all queued commands atomically and returns their replies
+func (c *Connection) exec(args []*resp.Message) *resp.Message {
+	if len(args) != 0 {
+		c.tx.dirty = c.tx.active
+		return resp.NewError("ERR wrong number of arguments for 'exec' command")
+	}
+
+	if !c.tx.active {
+		return resp.NewError("ERR EXEC without MULTI")
+	}
+
+	defer c.tx.reset()
+	defer c.unwatchAll()
+
+	if c.tx.dirty {
+		return resp.NewError("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	replies, ok := c.commandHandler.ExecuteTransaction(c.tx.queue, c.tx.watched, c.store)
+	if !ok {
+		// A watched key was modified, so the transaction is not executed
+		return resp.NewNullArray()
+	}
+	return resp.NewArray(replies)
+}
+
+// discard aborts the transaction and drops all queued commands
+func (c *Connection) discard(args []*resp.Message) *resp.Message {
+	if len(args) != 0 {
+		c.tx.dirty = c.tx.active
+		return resp.NewError("ERR wrong number of arguments for 'discard' command")
+	}
+
+	if !c.tx.active {
+		return resp.NewError("ERR DISCARD without MULTI")
+	}
+
+	c.tx.reset()
+	c.unwatchAll()
+	return resp.NewSimpleString("OK")
+}
+
+// watch records the current version of each key so EXEC can detect changes
+func (c *Connection) watch(args []*resp.Message) *resp.Message {
+	if len(args) == 0 {
+		return resp.NewError("ERR wrong number of arguments for 'watch' command")
+	}
+
+	if c.tx.active {
+		return resp.NewError("ERR WATCH inside MULTI is not allowed")
+	}
+
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		key, err := arg.AsString()
+		if err != nil {
+			return resp.NewError("ERR invalid key type")
+		}
+		keys[i] = key
+	}
+
+	if c.tx.watched == nil {
+		c.tx.watched = make(map[string]uint64)
+	}
+
+	for _, key := range keys {
+		// Watching a key twice keeps the version from the first WATCH
+		if _, exists := c.tx.watched[key]; exists {
+			continue
+		}
+		c.tx.watched[key] = c.store.Watch(key)
+	}
+
+	return resp.NewSimpleString("OK")
+}
+
+// unwatch forgets all watched keys
+func (c *Connection) unwatch(args []*resp.Message) *resp.Message {
+	if len(args) != 0 {
+		return resp.NewError("ERR wrong number of arguments for 'unwatch' command")
+	}
+
+	c.unwatchAll()
+	return resp.NewSimpleString("OK")
+}
+
+// unwatchAll releases every key watched by this connection
+func (c *Connection) unwatchAll() {
+	for key := range c.tx.watched {
+		c.store.Unwatch(key)
+	}
+	c.tx.watched = nil
+}
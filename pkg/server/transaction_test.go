@@ -0,0 +1,281 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/pubsub"
+	"github.com/tsinivuo/redis-lite/pkg/resp"
+	"github.com/tsinivuo/redis-lite/pkg/storage"
+)
+
+func expectReply(t *testing.T, got *resp.Message, wantType resp.MessageType, wantValue string) {
+	t.Helper()
+
+	if got.Type != wantType {
+		t.Fatalf("Expected %s reply, got %s", wantType, got)
+	}
+
+	if value, _ := got.AsString(); value != wantValue {
+		t.Fatalf("Expected %q, got %q", wantValue, value)
+	}
+}
+
+func TestTransaction_MultiExec(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+
+	expectReply(t, connection.processCommand(command("MULTI")), resp.SimpleString, "OK")
+	expectReply(t, connection.processCommand(command("SET", "key", "value")), resp.SimpleString, "QUEUED")
+	expectReply(t, connection.processCommand(command("GET", "key")), resp.SimpleString, "QUEUED")
+
+	// Queued commands must not run before EXEC
+	if store.Exists("key") {
+		t.Fatal("Queued SET was executed before EXEC")
+	}
+
+	response := connection.processCommand(command("EXEC"))
+	replies, err := response.AsArray()
+	if err != nil {
+		t.Fatalf("Expected array reply from EXEC, got %s", response)
+	}
+
+	if len(replies) != 2 {
+		t.Fatalf("Expected 2 replies, got %d", len(replies))
+	}
+
+	expectReply(t, replies[0], resp.SimpleString, "OK")
+	expectReply(t, replies[1], resp.BulkString, "value")
+
+	// The transaction is over, so commands run immediately again
+	expectReply(t, connection.processCommand(command("PING")), resp.SimpleString, "PONG")
+}
+
+func TestTransaction_EmptyExec(t *testing.T) {
+	connection := newTestConnection(storage.NewMemoryStore())
+
+	connection.processCommand(command("MULTI"))
+	response := connection.processCommand(command("EXEC"))
+
+	replies, err := response.AsArray()
+	if err != nil {
+		t.Fatalf("Expected array reply from EXEC, got %s", response)
+	}
+
+	if len(replies) != 0 {
+		t.Errorf("Expected empty array, got %d elements", len(replies))
+	}
+}
+
+func TestTransaction_ExecAbortAfterQueueError(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+
+	connection.processCommand(command("MULTI"))
+	connection.processCommand(command("SET", "key", "value"))
+
+	expectReply(t, connection.processCommand(command("GET")), resp.Error, "ERR wrong number of arguments for 'get' command")
+	expectReply(t, connection.processCommand(command("NOPE")), resp.Error, "ERR unknown command 'NOPE'")
+
+	expectReply(t, connection.processCommand(command("EXEC")), resp.Error,
+		"EXECABORT Transaction discarded because of previous errors.")
+
+	if store.Exists("key") {
+		t.Error("Aborted transaction must not execute any command")
+	}
+
+	// The aborted transaction must be cleared
+	expectReply(t, connection.processCommand(command("EXEC")), resp.Error, "ERR EXEC without MULTI")
+}
+
+func TestTransaction_Discard(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+
+	connection.processCommand(command("MULTI"))
+	connection.processCommand(command("SET", "key", "value"))
+
+	expectReply(t, connection.processCommand(command("DISCARD")), resp.SimpleString, "OK")
+
+	if store.Exists("key") {
+		t.Error("Discarded transaction must not execute any command")
+	}
+
+	expectReply(t, connection.processCommand(command("EXEC")), resp.Error, "ERR EXEC without MULTI")
+}
+
+func TestTransaction_Errors(t *testing.T) {
+	connection := newTestConnection(storage.NewMemoryStore())
+
+	expectReply(t, connection.processCommand(command("EXEC")), resp.Error, "ERR EXEC without MULTI")
+	expectReply(t, connection.processCommand(command("DISCARD")), resp.Error, "ERR DISCARD without MULTI")
+	expectReply(t, connection.processCommand(command("MULTI", "extra")), resp.Error,
+		"ERR wrong number of arguments for 'multi' command")
+
+	connection.processCommand(command("MULTI"))
+	expectReply(t, connection.processCommand(command("MULTI")), resp.Error, "ERR MULTI calls can not be nested")
+
+	// A nested MULTI does not abort the transaction
+	response := connection.processCommand(command("EXEC"))
+	if response.Type != resp.Array {
+		t.Errorf("Expected array reply from EXEC, got %s", response)
+	}
+}
+
+func TestTransaction_CaseInsensitive(t *testing.T) {
+	connection := newTestConnection(storage.NewMemoryStore())
+
+	expectReply(t, connection.processCommand(command("multi")), resp.SimpleString, "OK")
+	expectReply(t, connection.processCommand(command("ping")), resp.SimpleString, "QUEUED")
+
+	response := connection.processCommand(command("exec"))
+	replies, err := response.AsArray()
+	if err != nil || len(replies) != 1 {
+		t.Fatalf("Expected one reply from EXEC, got %s", response)
+	}
+
+	expectReply(t, replies[0], resp.SimpleString, "PONG")
+}
+
+func TestTransaction_WatchUnmodified(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+
+	store.Set("key", []byte("1"))
+
+	expectReply(t, connection.processCommand(command("WATCH", "key")), resp.SimpleString, "OK")
+	expectReply(t, connection.processCommand(command("GET", "key")), resp.BulkString, "1")
+
+	connection.processCommand(command("MULTI"))
+	connection.processCommand(command("SET", "key", "2"))
+
+	response := connection.processCommand(command("EXEC"))
+	if response.Type != resp.Array || response.IsNull() {
+		t.Fatalf("Expected EXEC to run, got %s", response)
+	}
+
+	if value, _ := store.Get("key"); string(value) != "2" {
+		t.Errorf("Expected key to be '2', got '%s'", value)
+	}
+}
+
+func TestTransaction_WatchedKeyModified(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+	other := newTestConnection(store)
+
+	store.Set("key", []byte("1"))
+
+	connection.processCommand(command("WATCH", "key"))
+	other.processCommand(command("SET", "key", "other"))
+
+	connection.processCommand(command("MULTI"))
+	connection.processCommand(command("SET", "key", "2"))
+
+	response := connection.processCommand(command("EXEC"))
+	if response.Type != resp.Array || !response.IsNull() {
+		t.Fatalf("Expected null array from EXEC, got %s", response)
+	}
+
+	if value, _ := store.Get("key"); string(value) != "other" {
+		t.Errorf("Expected key to keep 'other', got '%s'", value)
+	}
+
+	// EXEC unwatches all keys, so the next transaction runs
+	connection.processCommand(command("MULTI"))
+	connection.processCommand(command("SET", "key", "3"))
+	response = connection.processCommand(command("EXEC"))
+	if response.IsNull() {
+		t.Error("Expected keys to be unwatched after EXEC")
+	}
+}
+
+func TestTransaction_WatchedKeyCreated(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+
+	connection.processCommand(command("WATCH", "missing"))
+	store.Set("missing", []byte("now here"))
+
+	connection.processCommand(command("MULTI"))
+	response := connection.processCommand(command("EXEC"))
+	if !response.IsNull() {
+		t.Errorf("Expected null array after watched key was created, got %s", response)
+	}
+}
+
+func TestTransaction_WatchedKeyExpired(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+
+	connection.processCommand(command("SET", "key", "value", "PX", "20"))
+	connection.processCommand(command("WATCH", "key"))
+
+	time.Sleep(40 * time.Millisecond)
+
+	connection.processCommand(command("MULTI"))
+	response := connection.processCommand(command("EXEC"))
+	if !response.IsNull() {
+		t.Errorf("Expected null array after watched key expired, got %s", response)
+	}
+}
+
+func TestTransaction_WatchedKeyFlushed(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+
+	store.Set("key", []byte("value"))
+	connection.processCommand(command("WATCH", "key"))
+	store.Clear()
+
+	connection.processCommand(command("MULTI"))
+	response := connection.processCommand(command("EXEC"))
+	if !response.IsNull() {
+		t.Errorf("Expected null array after flush, got %s", response)
+	}
+}
+
+func TestTransaction_Unwatch(t *testing.T) {
+	store := storage.NewMemoryStore()
+	connection := newTestConnection(store)
+
+	connection.processCommand(command("WATCH", "key"))
+	expectReply(t, connection.processCommand(command("UNWATCH")), resp.SimpleString, "OK")
+	store.Set("key", []byte("value"))
+
+	connection.processCommand(command("MULTI"))
+	response := connection.processCommand(command("EXEC"))
+	if response.IsNull() {
+		t.Error("Expected EXEC to run after UNWATCH")
+	}
+}
+
+func TestTransaction_WatchErrors(t *testing.T) {
+	connection := newTestConnection(storage.NewMemoryStore())
+
+	expectReply(t, connection.processCommand(command("WATCH")), resp.Error,
+		"ERR wrong number of arguments for 'watch' command")
+
+	connection.processCommand(command("MULTI"))
+	expectReply(t, connection.processCommand(command("WATCH", "key")), resp.Error,
+		"ERR WATCH inside MULTI is not allowed")
+}
+
+func TestTransaction_QueuedArgumentsSurviveParsing(t *testing.T) {
+	// Each command is parsed into the same recycled argument slots, so
+	// the queued commands must keep their own copies
+	conn := newMockConn("*1\r\n$5\r\nMULTI\r\n" +
+		"*3\r\n$3\r\nSET\r\n$5\r\nfirst\r\n$1\r\n1\r\n" +
+		"*3\r\n$3\r\nSET\r\n$6\r\nsecond\r\n$1\r\n2\r\n" +
+		"*1\r\n$4\r\nEXEC\r\n")
+	store := storage.NewMemoryStore()
+	connection := newTestConnectionWith(conn, store, pubsub.NewBroker())
+
+	connection.Handle()
+
+	for key, want := range map[string]string{"first": "1", "second": "2"} {
+		if value, exists := store.Get(key); !exists || string(value) != want {
+			t.Errorf("Expected %s=%q, got %q", key, want, value)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
+)
+
+// ErrOutOfMemory is returned by writes while the store uses more memory
+// than its limit and no key can be evicted
+var ErrOutOfMemory = errors.New("OOM command not allowed when used memory > 'maxmemory'.")
+
+// EvictionPolicy selects the keys evicted once the memory limit is reached
+type EvictionPolicy int
+
+const (
+	// NoEviction evicts nothing: writes fail with ErrOutOfMemory instead
+	NoEviction EvictionPolicy = iota
+	// AllKeysLRU evicts the least recently used keys
+	AllKeysLRU
+	// VolatileLRU evicts the least recently used keys with an expiry
+	VolatileLRU
+	// AllKeysLFU evicts the least frequently used keys
+	AllKeysLFU
+	// VolatileLFU evicts the least frequently used keys with an expiry
+	VolatileLFU
+	// AllKeysRandom evicts random keys
+	AllKeysRandom
+	// VolatileRandom evicts random keys with an expiry
+	VolatileRandom
+	// VolatileTTL evicts the keys with an expiry that expire first
+	VolatileTTL
+)
+
+// Volatile reports whether the policy only evicts keys with an expiry
+func (p EvictionPolicy) Volatile() bool {
+	return p == VolatileLRU || p == VolatileLFU || p == VolatileRandom || p == VolatileTTL
+}
+
+// MemoryLimit bounds the memory used by a store, as estimated by its
+// per-key accounting
+type MemoryLimit struct {
+	// MaxMemory is the limit in bytes, 0 for no limit
+	MaxMemory int64
+	Policy    EvictionPolicy
+	// Samples is the number of keys sampled for each key evicted by the
+	// LRU, LFU and TTL policies. More samples approximate the exact
+	// policy more closely, at the cost of CPU.
+	Samples int
+}
+
+// The access frequency of a key is a logarithmic counter, as in Redis with
+// the default lfu-log-factor and lfu-decay-time: it takes about a hundred
+// accesses to reach 10 and a million to reach 255, and it is decremented
+// for every minute without access.
+const (
+	lfuInitialFreq = 5
+	lfuLogFactor   = 10
+	lfuDecayTime   = time.Minute
+)
+
+// evictionPoolSize is the number of candidates kept between evictions, the
+// best ones sampled so far
+const evictionPoolSize = 16
+
+// evictionCandidate is a sampled key, scored by how good a choice evicting
+// it is
+type evictionCandidate struct {
+	key   string
+	entry *entry
+	score int64
+}
+
+// frequency returns the access frequency of the entry at now, decayed by
+// the time since its last access
+func (e *entry) frequency(now time.Time) int {
+	idle := now.Sub(time.UnixMilli(e.accessed.Load()))
+	decay := int64(max(idle/lfuDecayTime, 0))
+	return int(max(int64(e.freq.Load())-decay, 0))
+}
+
+// idleTime returns the time since the last access of the entry
+func (e *entry) idleTime(now time.Time) time.Duration {
+	return max(now.Sub(time.UnixMilli(e.accessed.Load())), 0)
+}
+
+// touch records an access to the entry. It is called under the read lock,
+// so concurrent accesses may each miss the other's increment, which an
+// approximate counter tolerates.
+func (e *entry) touch(now time.Time) {
+	freq := e.frequency(now)
+	if freq < math.MaxUint8 {
+		base := float64(max(freq-lfuInitialFreq, 0))
+		if rand.Float64() < 1/(base*lfuLogFactor+1) {
+			freq++
+		}
+	}
+	e.freq.Store(uint32(freq))
+	e.accessed.Store(now.UnixMilli())
+}
+
+// SetMemoryLimit sets the memory limit and the eviction policy enforced by
+// the following writes
+func (s *MemoryStore) SetMemoryLimit(limit MemoryLimit) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if limit.Policy != s.limit.Policy {
+		s.pool = nil
+	}
+	s.limit = limit
+}
+
+// UsedMemory returns the estimated memory used by the keys and their values
+func (s *MemoryStore) UsedMemory() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.used
+}
+
+// evict evicts keys until the memory used is within the limit, as writes
+// do before storing anything, and returns ErrOutOfMemory if it cannot.
+// Must be called with the write lock held.
+func (s *MemoryStore) evict() error {
+	limit := s.limit
+	if limit.MaxMemory <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for s.used > limit.MaxMemory {
+		if limit.Policy == NoEviction {
+			return ErrOutOfMemory
+		}
+
+		keys := s.data
+		if limit.Policy.Volatile() {
+			keys = s.expiring
+		}
+		var key string
+		found := false
+		if limit.Policy == AllKeysRandom || limit.Policy == VolatileRandom {
+			// Go starts iterating over a map at a random position
+			for key = range keys {
+				found = true
+				break
+			}
+		} else {
+			key, found = s.bestCandidate(keys, now)
+		}
+		if !found {
+			return ErrOutOfMemory
+		}
+
+		s.remove(key)
+		if s.notifier != nil {
+			s.notifier.Notify(events.Evicted, "evicted", key)
+		}
+	}
+	return nil
+}
+
+// bestCandidate samples keys into the eviction pool and takes the best
+// candidate out of it, as Redis approximates LRU and LFU: the pool keeps
+// the best keys of previous samples, so that the keys evicted get closer
+// to the ones the exact policy would evict.
+func (s *MemoryStore) bestCandidate(keys map[string]*entry, now time.Time) (string, bool) {
+	// Candidates changed or removed since they were sampled are dropped,
+	// so once the pool is empty the sampled keys are all candidates
+	for len(keys) > 0 {
+		s.sample(keys, now)
+		for len(s.pool) > 0 {
+			best := s.pool[len(s.pool)-1]
+			s.pool = s.pool[:len(s.pool)-1]
+			if keys[best.key] == best.entry {
+				return best.key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// sample adds a sample of keys to the eviction pool, keeping the best
+// candidates, sorted by score. Must be called with the write lock held.
+func (s *MemoryStore) sample(keys map[string]*entry, now time.Time) {
+	sampled := 0
+	for key, e := range keys {
+		if sampled++; sampled > max(s.limit.Samples, 1) {
+			break
+		}
+		if slices.ContainsFunc(s.pool, func(c evictionCandidate) bool { return c.entry == e }) {
+			continue
+		}
+		candidate := evictionCandidate{key: key, entry: e, score: s.evictionScore(e, now)}
+		i, _ := slices.BinarySearchFunc(s.pool, candidate.score, func(c evictionCandidate, score int64) int {
+			return cmp.Compare(c.score, score)
+		})
+		if len(s.pool) == evictionPoolSize {
+			// The pool is full: drop its worst candidate if this one is better
+			if i == 0 {
+				continue
+			}
+			s.pool = slices.Delete(s.pool, 0, 1)
+			i--
+		}
+		s.pool = slices.Insert(s.pool, i, candidate)
+	}
+}
+
+// evictionScore scores an entry for the eviction policy; the higher the
+// score, the sooner the entry is evicted
+func (s *MemoryStore) evictionScore(e *entry, now time.Time) int64 {
+	switch s.limit.Policy {
+	case AllKeysLFU, VolatileLFU:
+		return math.MaxUint8 - int64(e.frequency(now))
+	case VolatileTTL:
+		return -e.expiresAt.UnixMilli()
+	default:
+		return int64(e.idleTime(now))
+	}
+}
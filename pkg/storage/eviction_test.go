@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fillStore sets keys k0 to k9: key ki was last accessed i minutes ago,
+// with a frequency of 100-10i after decay, and expires in 10-i hours if i
+// is even. It returns the memory they use.
+func fillStore(store *MemoryStore) int64 {
+	now := time.Now()
+	for i := range 10 {
+		key := fmt.Sprintf("k%d", i)
+		if i%2 == 0 {
+			store.SetWithExpiry(key, []byte("value"), now.Add(time.Duration(10-i)*time.Hour))
+		} else {
+			store.Set(key, []byte("value"))
+		}
+		e := store.data[key]
+		e.accessed.Store(now.Add(-time.Duration(i) * time.Minute).UnixMilli())
+		e.freq.Store(uint32(100 - 10*i + i))
+	}
+	return store.UsedMemory()
+}
+
+func TestMemoryStore_Eviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  EvictionPolicy
+		evicted []string
+	}{
+		{"allkeys-lru", AllKeysLRU, []string{"k9", "k8"}},
+		{"volatile-lru", VolatileLRU, []string{"k8", "k6"}},
+		// New keys have the lowest frequency
+		{"allkeys-lfu", AllKeysLFU, []string{"k9", "new1"}},
+		{"volatile-lfu", VolatileLFU, []string{"k8", "new1"}},
+		{"volatile-ttl", VolatileTTL, []string{"k8", "k6"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			notifier := &recordingNotifier{}
+			store.SetNotifier(notifier)
+			limit := fillStore(store)
+
+			// Sampling every key makes the policies exact. Each write
+			// evicts a key, as the limit is exceeded before it.
+			store.SetMemoryLimit(MemoryLimit{MaxMemory: limit - 1, Policy: tt.policy, Samples: 10})
+			for _, key := range []string{"new1", "new2"} {
+				if err := store.SetWithExpiry(key, []byte("value"), time.Now().Add(100*time.Hour)); err != nil {
+					t.Fatalf("Set() returned error: %v", err)
+				}
+			}
+
+			var expected []string
+			for _, key := range tt.evicted {
+				expected = append(expected, "evicted:"+key)
+			}
+			if recorded := notifier.recorded(); !slices.Equal(recorded, expected) {
+				t.Errorf("Expected %v, got %v", expected, recorded)
+			}
+			if store.Size() != 10 {
+				t.Errorf("Expected 10 keys, got %d", store.Size())
+			}
+		})
+	}
+}
+
+func TestMemoryStore_EvictionRandom(t *testing.T) {
+	for _, policy := range []EvictionPolicy{AllKeysRandom, VolatileRandom} {
+		store := NewMemoryStore()
+		limit := fillStore(store)
+		store.SetMemoryLimit(MemoryLimit{MaxMemory: limit / 2, Policy: policy, Samples: 5})
+
+		if err := store.Set("new", []byte("value")); err != nil {
+			t.Fatalf("Set() returned error: %v", err)
+		}
+		if store.UsedMemory() > limit/2+store.data["new"].size {
+			t.Errorf("Expected at most %d bytes used, got %d", limit/2, store.UsedMemory())
+		}
+		if policy == VolatileRandom {
+			for _, key := range []string{"k1", "k3", "k5", "k7", "k9"} {
+				if !store.Exists(key) {
+					t.Errorf("Expected key %s without expiry to be kept", key)
+				}
+			}
+		}
+	}
+}
+
+func TestMemoryStore_OutOfMemory(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy EvictionPolicy
+		// expiring sets the keys with an expiry
+		expiring bool
+	}{
+		{"noeviction", NoEviction, true},
+		{"no volatile keys", VolatileLRU, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			for i := range 3 {
+				if tt.expiring {
+					store.SetWithExpiry(fmt.Sprint(i), []byte("value"), time.Now().Add(time.Hour))
+				} else {
+					store.Set(fmt.Sprint(i), []byte("value"))
+				}
+			}
+			store.SetMemoryLimit(MemoryLimit{MaxMemory: store.UsedMemory() - 1, Policy: tt.policy, Samples: 5})
+
+			if err := store.Set("new", []byte("value")); !errors.Is(err, ErrOutOfMemory) {
+				t.Errorf("Expected ErrOutOfMemory, got %v", err)
+			}
+			if store.Size() != 3 || store.Exists("new") {
+				t.Errorf("Expected the keys to be kept and nothing added, got %d keys", store.Size())
+			}
+
+			// Deleting makes room again
+			store.Delete("0")
+			if err := store.Set("new", []byte("value")); err != nil {
+				t.Errorf("Expected the write to succeed once under the limit, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEntry_Frequency(t *testing.T) {
+	now := time.Now()
+	e := &entry{}
+	e.accessed.Store(now.UnixMilli())
+	e.freq.Store(lfuInitialFreq)
+
+	for range 1000 {
+		e.touch(now)
+	}
+	if freq := e.frequency(now); freq < 10 || freq > 30 {
+		t.Errorf("Expected a logarithmic frequency after 1000 accesses, got %d", freq)
+	}
+
+	freq := e.frequency(now)
+	if decayed := e.frequency(now.Add(3 * lfuDecayTime)); decayed != freq-3 {
+		t.Errorf("Expected the frequency to decay to %d after 3 periods, got %d", freq-3, decayed)
+	}
+	// Access times are kept in milliseconds
+	if idle := e.idleTime(now.Add(time.Minute)); idle < time.Minute || idle > time.Minute+time.Millisecond {
+		t.Errorf("Expected an idle time of 1m, got %v", idle)
+	}
+}
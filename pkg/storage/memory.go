@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"slices"
+	"unsafe"
+)
+
+// sizeClasses are the sizes of the small allocations of the Go runtime, up
+// to 32 KiB, as listed in runtime/sizeclasses.go. An allocation takes the
+// smallest class it fits in.
+var sizeClasses = [...]int64{
+	8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224,
+	240, 256, 288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768,
+	896, 1024, 1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200,
+	3456, 4096, 4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240,
+	10880, 12288, 13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576,
+	27264, 28672, 32768,
+}
+
+// pageSize is the unit larger allocations are rounded up to
+const pageSize = 8192
+
+// mapSlotSize is the memory a key takes in a map of strings to pointers:
+// a slot of a string header and a pointer, 24 bytes, and a control byte,
+// at the typical load of a Go map of about 2/3
+const mapSlotSize = (16 + 8 + 1) * 3 / 2
+
+// entrySize is the size of the allocation of an entry
+var entrySize = AllocSize(int64(unsafe.Sizeof(entry{})))
+
+// AllocSize returns the memory the Go runtime takes to allocate n bytes,
+// rounded up to its size class, or to whole pages past 32 KiB
+func AllocSize(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+	if n > sizeClasses[len(sizeClasses)-1] {
+		return (n + pageSize - 1) / pageSize * pageSize
+	}
+	i, _ := slices.BinarySearch(sizeClasses[:], n)
+	return sizeClasses[i]
+}
+
+// memoryUsage estimates the memory held by a key and its entry: their
+// allocations, and the slots they take in the maps and scan index of the
+// store
+func memoryUsage(key string, e *entry) int64 {
+	size := mapSlotSize + scanEntrySize + AllocSize(int64(len(key))) + entrySize + AllocSize(int64(cap(e.value)))
+	if !e.expiresAt.IsZero() {
+		// A key with an expiry is also in the map of expiring keys
+		size += mapSlotSize
+	}
+	return size
+}
+
+// MemoryStats describes the memory used by a store
+type MemoryStats struct {
+	// Keys is the number of keys, and Expiring the number of them that
+	// have an expiry
+	Keys     int
+	Expiring int
+	// Used is the estimated memory held by the keys and their values, of
+	// which Overhead is taken by the maps and entries holding them
+	Used     int64
+	Overhead int64
+	Limit    MemoryLimit
+}
+
+// MemoryStats returns the memory used by the store and its limit. Keys
+// expired but not yet removed are counted.
+func (s *MemoryStore) MemoryStats() MemoryStats {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return MemoryStats{
+		Keys:     len(s.data),
+		Expiring: len(s.expiring),
+		Used:     s.used,
+		Overhead: int64(len(s.data))*(mapSlotSize+scanEntrySize+entrySize) + int64(len(s.expiring))*mapSlotSize,
+		Limit:    s.limit,
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllocSize(t *testing.T) {
+	tests := []struct {
+		size     int64
+		expected int64
+	}{
+		{0, 0},
+		{1, 8},
+		{8, 8},
+		{9, 16},
+		{33, 48},
+		{1000, 1024},
+		{32768, 32768},
+		{32769, 40960},
+	}
+
+	for _, tt := range tests {
+		if got := AllocSize(tt.size); got != tt.expected {
+			t.Errorf("AllocSize(%d): expected %d, got %d", tt.size, tt.expected, got)
+		}
+	}
+}
+
+func TestMemoryStore_UsedMemory(t *testing.T) {
+	store := NewMemoryStore()
+	if store.UsedMemory() != 0 {
+		t.Fatalf("Expected an empty store to use no memory, got %d", store.UsedMemory())
+	}
+
+	store.Set("key", make([]byte, 1000))
+	expected := mapSlotSize + scanEntrySize + 8 + entrySize + 1024
+	if store.UsedMemory() != expected {
+		t.Errorf("Expected %d bytes, got %d", expected, store.UsedMemory())
+	}
+
+	// Overwriting replaces the accounting of the old value
+	store.Set("key", make([]byte, 10))
+	if expected = mapSlotSize + scanEntrySize + 8 + entrySize + 16; store.UsedMemory() != expected {
+		t.Errorf("Expected %d bytes after overwriting, got %d", expected, store.UsedMemory())
+	}
+
+	store.Set("other", nil)
+	store.Delete("key")
+	if expected = mapSlotSize + scanEntrySize + 8 + entrySize; store.UsedMemory() != expected {
+		t.Errorf("Expected %d bytes after deleting, got %d", expected, store.UsedMemory())
+	}
+
+	store.Clear()
+	if store.UsedMemory() != 0 {
+		t.Errorf("Expected no memory used after clearing, got %d", store.UsedMemory())
+	}
+}
+
+func TestMemoryStore_MemoryStats(t *testing.T) {
+	store := NewMemoryStore()
+	store.Set("a", make([]byte, 100))
+	store.SetWithExpiry("b", make([]byte, 100), time.Now().Add(time.Hour))
+	limit := MemoryLimit{MaxMemory: 1 << 20, Policy: VolatileTTL, Samples: 5}
+	store.SetMemoryLimit(limit)
+
+	stats := store.MemoryStats()
+	if stats.Keys != 2 || stats.Expiring != 1 || stats.Limit != limit {
+		t.Errorf("Expected 2 keys, 1 expiring and the limit, got %+v", stats)
+	}
+	if stats.Used != store.UsedMemory() {
+		t.Errorf("Expected %d bytes used, got %d", store.UsedMemory(), stats.Used)
+	}
+	// The keys and values take a 8-byte and a 112-byte allocation each
+	if expected := stats.Used - 2*(8+112); stats.Overhead != expected {
+		t.Errorf("Expected an overhead of %d bytes, got %d", expected, stats.Overhead)
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+	"unsafe"
+)
+
+// scanBucketLoad is the average number of keys per bucket of the scan
+// index above which the buckets are doubled
+const scanBucketLoad = 8
+
+// scanEntrySize is the memory a key takes in the scan index
+var scanEntrySize = int64(unsafe.Sizeof(scanEntry{}))
+
+// scanPosition returns the position of a key in scan order. Positions are
+// never 0, which is the cursor that starts and ends an iteration.
+func scanPosition(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()>>1 + 1
+}
+
+// scanEntry is a key of the scan index
+type scanEntry struct {
+	position uint64
+	key      string
+}
+
+// compareScanEntries orders entries by position, then by key for the keys
+// sharing a position
+func compareScanEntries(a, b scanEntry) int {
+	if c := cmp.Compare(a.position, b.position); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.key, b.key)
+}
+
+// scanIndex orders the keys by scan position, so that a scan finds the
+// keys after its cursor without visiting the whole keyspace. Positions are
+// hashes, spread evenly, so the index splits them by their top bits into
+// buckets of a few keys, each a sorted slice, and doubles the buckets as
+// keys are added: adding, removing and finding a key take constant time
+// on average.
+type scanIndex struct {
+	buckets [][]scanEntry
+	// shift maps a position to its bucket, as (position-1) >> shift
+	shift uint
+	size  int
+}
+
+// newScanIndex creates an empty scan index
+func newScanIndex() *scanIndex {
+	return &scanIndex{buckets: make([][]scanEntry, 1), shift: 63}
+}
+
+// bucket returns the index of the bucket holding position, which may be
+// past the last bucket for cursors beyond every position
+func (x *scanIndex) bucket(position uint64) uint64 {
+	return (max(position, 1) - 1) >> x.shift
+}
+
+// add adds a key at its position
+func (x *scanIndex) add(position uint64, key string) {
+	entry := scanEntry{position: position, key: key}
+	bucket := &x.buckets[x.bucket(position)]
+	i, _ := slices.BinarySearchFunc(*bucket, entry, compareScanEntries)
+	*bucket = slices.Insert(*bucket, i, entry)
+
+	x.size++
+	if x.size > len(x.buckets)*scanBucketLoad && x.shift > 0 {
+		x.grow()
+	}
+}
+
+// remove removes a key from its position
+func (x *scanIndex) remove(position uint64, key string) {
+	entry := scanEntry{position: position, key: key}
+	bucket := &x.buckets[x.bucket(position)]
+	if i, found := slices.BinarySearchFunc(*bucket, entry, compareScanEntries); found {
+		*bucket = slices.Delete(*bucket, i, i+1)
+		x.size--
+	}
+}
+
+// grow doubles the buckets, splitting each in two by the next bit of the
+// positions
+func (x *scanIndex) grow() {
+	x.shift--
+	buckets := make([][]scanEntry, 2*len(x.buckets))
+	for i, bucket := range x.buckets {
+		split, _ := slices.BinarySearchFunc(bucket, uint64(2*i+1), func(e scanEntry, target uint64) int {
+			return cmp.Compare(x.bucket(e.position), target)
+		})
+		buckets[2*i] = slices.Clone(bucket[:split])
+		buckets[2*i+1] = slices.Clone(bucket[split:])
+	}
+	x.buckets = buckets
+}
+
+// ascend calls visit with the keys at or after cursor in scan order, until
+// visit returns false
+func (x *scanIndex) ascend(cursor uint64, visit func(position uint64, key string) bool) {
+	first := x.bucket(cursor)
+	if first >= uint64(len(x.buckets)) {
+		return
+	}
+
+	for b := first; b < uint64(len(x.buckets)); b++ {
+		bucket := x.buckets[b]
+		start := 0
+		if b == first {
+			start, _ = slices.BinarySearchFunc(bucket, cursor, func(e scanEntry, cursor uint64) int {
+				return cmp.Compare(e.position, cursor)
+			})
+		}
+		for _, e := range bucket[start:] {
+			if !visit(e.position, e.key) {
+				return
+			}
+		}
+	}
+}
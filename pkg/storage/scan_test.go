@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestScanIndex(t *testing.T) {
+	index := newScanIndex()
+	for i := 0; i < 10000; i++ {
+		key := "key" + strconv.Itoa(i)
+		index.add(scanPosition(key), key)
+	}
+	for i := 0; i < 10000; i += 2 {
+		key := "key" + strconv.Itoa(i)
+		index.remove(scanPosition(key), key)
+	}
+	index.remove(scanPosition("missing"), "missing")
+
+	if index.size != 5000 {
+		t.Errorf("Expected 5000 keys, got %d", index.size)
+	}
+	if len(index.buckets)*scanBucketLoad < index.size {
+		t.Errorf("Expected the buckets to grow with the keys, got %d buckets", len(index.buckets))
+	}
+
+	// The keys are visited once each, in position order
+	var positions []uint64
+	seen := make(map[string]bool)
+	index.ascend(0, func(position uint64, key string) bool {
+		positions = append(positions, position)
+		seen[key] = true
+		return true
+	})
+	if len(positions) != 5000 || len(seen) != 5000 || !slices.IsSorted(positions) {
+		t.Errorf("Expected 5000 distinct keys in order, got %d visits of %d keys", len(positions), len(seen))
+	}
+	for i := 1; i < 10000; i += 2 {
+		if key := "key" + strconv.Itoa(i); !seen[key] {
+			t.Errorf("Expected %s to be visited", key)
+		}
+	}
+
+	// A cursor starts the visit at its position
+	middle := positions[2500]
+	var first uint64
+	index.ascend(middle, func(position uint64, key string) bool {
+		first = position
+		return false
+	})
+	if first != middle {
+		t.Errorf("Expected the visit to start at %d, got %d", middle, first)
+	}
+
+	visited := false
+	index.ascend(math.MaxUint64, func(uint64, string) bool {
+		visited = true
+		return true
+	})
+	if visited {
+		t.Error("Expected no key past the last position")
+	}
+}
+
+func BenchmarkMemoryStore_Scan(b *testing.B) {
+	store := NewMemoryStore()
+	for i := 0; i < 100000; i++ {
+		store.Set("key"+strconv.Itoa(i), nil)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	cursor := uint64(0)
+	for i := 0; i < b.N; i++ {
+		_, cursor = store.Scan(cursor, 10)
+	}
+}
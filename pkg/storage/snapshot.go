@@ -0,0 +1,129 @@
+package storage
+
+import "time"
+
+// SnapshotBatchSize is the number of keys a snapshot visits at a time. The
+// store is locked while a batch is collected, so writes wait at most that
+// long.
+const SnapshotBatchSize = 1024
+
+// Record is a key with its value and expiry, as saved in a snapshot
+type Record struct {
+	Key       string
+	Value     []byte
+	ExpiresAt time.Time
+}
+
+// snapshot tracks the keys changed while a snapshot is taken, so that it
+// sees the keyspace as it was when it started
+type snapshot struct {
+	id uint64
+	// preserved holds the entries, as they were when the snapshot started,
+	// of the keys changed since then before the snapshot visited them. A
+	// nil entry marks a key that must not be saved: it was created after
+	// the snapshot started, or removed after being visited and created
+	// again.
+	preserved map[string]*entry
+	// frozen is set when Clear replaces the map the snapshot iterates
+	// over. The old map no longer changes, so nothing needs preserving.
+	frozen bool
+}
+
+// Snapshot calls visit with batches of the keys as they were when the
+// snapshot started, skipping the expired ones, in no particular order.
+// Writes are not blocked while visit runs: the entries they replace are
+// kept aside until the snapshot has saved them, which is cheap since
+// values are never modified in place. visit must not retain the batch.
+// Snapshots run one at a time; Snapshot waits for the previous one.
+func (s *MemoryStore) Snapshot(visit func([]Record) error) error {
+	s.snapshotMutex.Lock()
+	defer s.snapshotMutex.Unlock()
+
+	s.mutex.Lock()
+	s.snapshots++
+	snap := &snapshot{id: s.snapshots, preserved: make(map[string]*entry)}
+	s.snapshot = snap
+	data := s.data
+	start := time.Now()
+
+	batch := make([]Record, 0, SnapshotBatchSize)
+	var err error
+	steps := 0
+
+	// Go allows a map to change between the steps of a range loop, so
+	// the lock can be released between batches. The changes that matter
+	// are recorded in the preserved entries.
+	for key, e := range data {
+		if _, changed := snap.preserved[key]; !changed {
+			e.savedIn = snap.id
+			if !e.expired(start) {
+				batch = append(batch, Record{Key: key, Value: e.value, ExpiresAt: e.expiresAt})
+			}
+		}
+
+		if steps++; steps%SnapshotBatchSize == 0 || len(batch) == SnapshotBatchSize {
+			s.mutex.Unlock()
+			if len(batch) > 0 {
+				err = visit(batch)
+				batch = batch[:0]
+			}
+			s.mutex.Lock()
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	// Once the snapshot stops recording changes, the preserved entries are
+	// its own
+	s.snapshot = nil
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for key, e := range snap.preserved {
+		if e == nil || e.expired(start) {
+			continue
+		}
+		batch = append(batch, Record{Key: key, Value: e.value, ExpiresAt: e.expiresAt})
+		if len(batch) == SnapshotBatchSize {
+			if err := visit(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		return visit(batch)
+	}
+	return nil
+}
+
+// preserve keeps the entry of a key about to change if a snapshot that has
+// not visited it yet is running. Must be called with the write lock held.
+func (s *MemoryStore) preserve(key string) {
+	snap := s.snapshot
+	if snap == nil || snap.frozen {
+		return
+	}
+	if _, done := snap.preserved[key]; done {
+		return
+	}
+
+	e := s.data[key]
+	if e != nil && e.savedIn == snap.id {
+		return
+	}
+	snap.preserved[key] = e
+}
+
+// Changes returns a counter incremented by every change to the keyspace,
+// so that the number of changes since a point in time can be measured
+func (s *MemoryStore) Changes() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.clock
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// collect takes a snapshot and returns the saved values by key. change is
+// called with the lock released after the first batch.
+func collect(t *testing.T, store *MemoryStore, change func()) map[string]string {
+	t.Helper()
+
+	saved := make(map[string]string)
+	first := true
+	err := store.Snapshot(func(records []Record) error {
+		for _, record := range records {
+			if _, exists := saved[record.Key]; exists {
+				t.Errorf("Key %q saved twice", record.Key)
+			}
+			saved[record.Key] = string(record.Value)
+		}
+		if first && change != nil {
+			first = false
+			change()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Snapshot() returned error: %v", err)
+	}
+	return saved
+}
+
+func TestMemoryStore_Snapshot(t *testing.T) {
+	store := NewMemoryStore()
+	store.Set("a", []byte("1"))
+	store.SetWithExpiry("b", []byte("2"), time.Now().Add(time.Hour))
+	store.SetWithExpiry("gone", []byte("3"), time.Now().Add(-time.Second))
+
+	saved := collect(t, store, nil)
+	if len(saved) != 2 || saved["a"] != "1" || saved["b"] != "2" {
+		t.Errorf("Expected a=1 and b=2, got %v", saved)
+	}
+}
+
+func TestMemoryStore_SnapshotIsPointInTime(t *testing.T) {
+	store := NewMemoryStore()
+	keys := 3 * SnapshotBatchSize
+	for i := 0; i < keys; i++ {
+		store.Set(fmt.Sprintf("key:%d", i), []byte("old"))
+	}
+
+	// Change every key while the snapshot runs, whether it was visited
+	// yet or not
+	saved := collect(t, store, func() {
+		for i := 0; i < keys; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			switch i % 3 {
+			case 0:
+				store.Set(key, []byte("new"))
+			case 1:
+				store.Delete(key)
+			case 2:
+				store.Delete(key)
+				store.Set(key, []byte("again"))
+			}
+		}
+		store.Set("created", []byte("new"))
+	})
+
+	if len(saved) != keys {
+		t.Errorf("Expected %d keys, got %d", keys, len(saved))
+	}
+	for key, value := range saved {
+		if value != "old" {
+			t.Errorf("Expected %q to be saved as 'old', got %q", key, value)
+		}
+	}
+	if _, exists := saved["created"]; exists {
+		t.Error("Expected key created during the snapshot not to be saved")
+	}
+
+	// The store has the new values
+	if value, _ := store.Get("key:0"); string(value) != "new" {
+		t.Errorf("Expected 'new', got %q", value)
+	}
+	if store.Exists("key:1") {
+		t.Error("Expected key:1 to be deleted")
+	}
+}
+
+func TestMemoryStore_SnapshotWithClear(t *testing.T) {
+	store := NewMemoryStore()
+	keys := 2 * SnapshotBatchSize
+	for i := 0; i < keys; i++ {
+		store.Set(fmt.Sprintf("key:%d", i), []byte("old"))
+	}
+
+	saved := collect(t, store, func() {
+		store.Set("key:0", []byte("new"))
+		store.Clear()
+		for i := 0; i < keys; i++ {
+			store.Set(fmt.Sprintf("key:%d", i), []byte("new"))
+		}
+	})
+
+	if len(saved) != keys {
+		t.Errorf("Expected %d keys, got %d", keys, len(saved))
+	}
+	for key, value := range saved {
+		if value != "old" {
+			t.Errorf("Expected %q to be saved as 'old', got %q", key, value)
+		}
+	}
+}
+
+func TestMemoryStore_SnapshotError(t *testing.T) {
+	store := NewMemoryStore()
+	store.Set("a", []byte("1"))
+
+	failure := errors.New("disk full")
+	err := store.Snapshot(func(records []Record) error {
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected %v, got %v", failure, err)
+	}
+
+	// The store still works, and changes are no longer tracked
+	store.Set("a", []byte("2"))
+	if store.snapshot != nil {
+		t.Error("Expected the snapshot to be over")
+	}
+}
+
+func TestMemoryStore_Changes(t *testing.T) {
+	store := NewMemoryStore()
+	before := store.Changes()
+
+	store.Set("a", []byte("1"))
+	store.Delete("a")
+	store.Clear()
+
+	if changes := store.Changes() - before; changes != 3 {
+		t.Errorf("Expected 3 changes, got %d", changes)
+	}
+}
+
+func TestMemoryStore_SnapshotConcurrentWrites(t *testing.T) {
+	store := NewMemoryStore()
+	keys := 4 * SnapshotBatchSize
+	for i := 0; i < keys; i++ {
+		store.Set(fmt.Sprintf("key:%d", i), []byte("old"))
+	}
+
+	// The writes start once the snapshot has, and race with the rest of it
+	done := make(chan struct{})
+	saved := collect(t, store, func() {
+		go func() {
+			defer close(done)
+			for i := 0; i < keys; i++ {
+				store.Set(fmt.Sprintf("key:%d", (i*7)%keys), []byte("new"))
+				store.Delete(fmt.Sprintf("key:%d", (i*13)%keys))
+			}
+		}()
+	})
+	<-done
+
+	if len(saved) != keys {
+		t.Errorf("Expected %d keys, got %d", keys, len(saved))
+	}
+	for key, value := range saved {
+		if value != "old" {
+			t.Errorf("Expected %q to be saved as 'old', got %q", key, value)
+		}
+	}
+}
@@ -1,16 +1,42 @@
 package storage
 
 import (
+	"math"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
 )
 
-// Store defines the interface for data storage operations
+// Store defines the interface for data storage operations.
+//
+// Values are byte slices and are never copied by the store. Set and
+// SetWithExpiry take ownership of the value: the caller must not modify it
+// afterwards. The slice returned by Get is shared with the store and must
+// not be modified either. Because stored values are never modified in
+// place, a value returned by Get stays valid even after the key changes.
 type Store interface {
-	// Set stores a key-value pair
-	Set(key, value string) error
+	// Set stores a key-value pair, removing any expiry the key had
+	Set(key string, value []byte) error
+
+	// SetWithExpiry stores a key-value pair that expires at the given time
+	SetWithExpiry(key string, value []byte, expiresAt time.Time) error
 
 	// Get retrieves a value by key
-	Get(key string) (string, bool)
+	Get(key string) ([]byte, bool)
+
+	// GetWithExpiry retrieves a value by key along with its expiry, the
+	// zero time if it has none
+	GetWithExpiry(key string) ([]byte, time.Time, bool)
+
+	// Inspect describes a key without counting as an access to it
+	Inspect(key string) (KeyInfo, bool)
+
+	// SetAccess sets the time since a key was last accessed, and its access
+	// frequency, as restored along with its value. Negative values are left
+	// unchanged.
+	SetAccess(key string, idle time.Duration, freq int) bool
 
 	// Delete removes a key-value pair
 	Delete(key string) bool
@@ -23,37 +49,234 @@ type Store interface {
 
 	// Clear removes all keys
 	Clear()
+
+	// Scan returns about count keys starting at cursor, and the cursor to
+	// continue from, which is 0 once every key has been returned
+	Scan(cursor uint64, count int) ([]string, uint64)
+
+	// Version returns the modification version of a key. The version changes
+	// whenever the key is set, deleted, expired or flushed.
+	Version(key string) uint64
+
+	// Watch registers interest in a key and returns its current version
+	Watch(key string) uint64
+
+	// Unwatch releases a reference taken by Watch
+	Unwatch(key string)
+
+	// Notify emits a keyspace event. Commands call it after modifying a key;
+	// the store emits expired events itself.
+	Notify(class events.Class, event, key string)
+}
+
+// KeyInfo describes a stored key
+type KeyInfo struct {
+	Value     []byte
+	ExpiresAt time.Time
+	// Size is the estimated memory held by the key and its value
+	Size int64
+	// Idle is the time since the key was last read or written, and Freq
+	// its access frequency, a logarithmic counter from 0 to 255
+	Idle time.Duration
+	Freq int
+}
+
+// ChangeLog receives every change made to a store, in order, such as an
+// append-only file. Its methods are called with the store locked, so they
+// must be quick and must not call back into the store.
+type ChangeLog interface {
+	// LogSet records that a key was set, expiring at expiresAt unless zero
+	LogSet(key string, value []byte, expiresAt time.Time)
+	// LogDelete records that a key was deleted or expired
+	LogDelete(key string)
+	// LogClear records that every key was removed
+	LogClear()
+}
+
+// entry is a stored value together with its metadata
+type entry struct {
+	value []byte
+	// expiresAt is the zero time for keys without expiry
+	expiresAt time.Time
+	version   uint64
+	// savedIn is the id of the last snapshot that visited the entry
+	savedIn uint64
+	// size is the memory held by the key and the entry
+	size int64
+	// accessed is the time of the last access in Unix milliseconds, and
+	// freq the access frequency then. Reads update them under the read
+	// lock.
+	accessed atomic.Int64
+	freq     atomic.Uint32
+}
+
+// expired reports whether the entry has expired at the given time
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
 }
 
 // MemoryStore implements Store interface with in-memory storage
 type MemoryStore struct {
-	data  map[string]string
-	mutex sync.RWMutex
+	data map[string]*entry
+	// index orders the keys of data for Scan
+	index *scanIndex
+	// expiring holds the entries of data that have an expiry, from which
+	// the volatile eviction policies pick
+	expiring map[string]*entry
+	// used is the memory held by the entries, and limit the limit on it
+	used  int64
+	limit MemoryLimit
+	// pool holds the best candidates for eviction sampled so far
+	pool []evictionCandidate
+	// clock is incremented on every modification and stamped on the modified key
+	clock uint64
+	// watched counts WATCH references per key. While a key is watched, its
+	// removal is recorded in tombstones so the change stays visible.
+	watched    map[string]int
+	tombstones map[string]uint64
+	notifier   events.Notifier
+	changeLog  ChangeLog
+	mutex      sync.RWMutex
+
+	// snapshot is the snapshot in progress, if any. snapshotMutex lets only
+	// one run at a time, and snapshots counts them to give each an id.
+	snapshot      *snapshot
+	snapshotMutex sync.Mutex
+	snapshots     uint64
 }
 
 // NewMemoryStore creates a new in-memory store
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		data: make(map[string]string),
+		data:       make(map[string]*entry),
+		index:      newScanIndex(),
+		expiring:   make(map[string]*entry),
+		watched:    make(map[string]int),
+		tombstones: make(map[string]uint64),
 	}
 }
 
 // Set stores a key-value pair
-func (s *MemoryStore) Set(key, value string) error {
+func (s *MemoryStore) Set(key string, value []byte) error {
+	return s.SetWithExpiry(key, value, time.Time{})
+}
+
+// SetWithExpiry stores a key-value pair that expires at the given time.
+// A zero expiresAt stores the key without expiry. While more memory than
+// the limit is used, keys are evicted first, and ErrOutOfMemory is
+// returned if none can be.
+func (s *MemoryStore) SetWithExpiry(key string, value []byte, expiresAt time.Time) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.data[key] = value
+	if err := s.evict(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	s.preserve(key)
+	s.clock++
+	e := &entry{value: value, expiresAt: expiresAt, version: s.clock}
+	e.accessed.Store(now.UnixMilli())
+	e.freq.Store(lfuInitialFreq)
+	if old, exists := s.data[key]; exists {
+		// Overwriting a key is an access, and keeps its frequency. A
+		// snapshot that visited the key has saved it already.
+		e.freq.Store(uint32(old.frequency(now)))
+		e.savedIn = old.savedIn
+		s.used -= old.size
+		delete(s.expiring, key)
+	} else {
+		s.index.add(scanPosition(key), key)
+	}
+	e.touch(now)
+	e.size = memoryUsage(key, e)
+	s.used += e.size
+	s.data[key] = e
+	if !expiresAt.IsZero() {
+		s.expiring[key] = e
+	}
+	delete(s.tombstones, key)
+	if s.changeLog != nil {
+		s.changeLog.LogSet(key, value, expiresAt)
+	}
 	return nil
 }
 
 // Get retrieves a value by key, returns value and whether the key exists
-func (s *MemoryStore) Get(key string) (string, bool) {
+func (s *MemoryStore) Get(key string) ([]byte, bool) {
+	value, _, exists := s.GetWithExpiry(key)
+	return value, exists
+}
+
+// GetWithExpiry retrieves a value by key with its expiry, the zero time if
+// it has none, and whether the key exists
+func (s *MemoryStore) GetWithExpiry(key string) ([]byte, time.Time, bool) {
+	now := time.Now()
+	s.mutex.RLock()
+	e, exists := s.data[key]
+	live := exists && !e.expired(now)
+	if live {
+		e.touch(now)
+	}
+	s.mutex.RUnlock()
+
+	if !live {
+		if exists {
+			s.expireIfNeeded(key)
+		}
+		return nil, time.Time{}, false
+	}
+	return e.value, e.expiresAt, true
+}
+
+// Inspect describes a key, without counting as an access, and reports
+// whether it exists
+func (s *MemoryStore) Inspect(key string) (KeyInfo, bool) {
+	now := time.Now()
+	s.mutex.RLock()
+	e, exists := s.data[key]
+	live := exists && !e.expired(now)
+	s.mutex.RUnlock()
+
+	if !live {
+		if exists {
+			s.expireIfNeeded(key)
+		}
+		return KeyInfo{}, false
+	}
+	return KeyInfo{
+		Value:     e.value,
+		ExpiresAt: e.expiresAt,
+		Size:      e.size,
+		Idle:      e.idleTime(now),
+		Freq:      e.frequency(now),
+	}, true
+}
+
+// SetAccess sets the idle time and the access frequency of a key, leaving
+// negative values unchanged, and reports whether the key exists. Since the
+// frequency decays with the idle time, an idle time set alone lowers it.
+func (s *MemoryStore) SetAccess(key string, idle time.Duration, freq int) bool {
+	now := time.Now()
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	value, exists := s.data[key]
-	return value, exists
+	e, exists := s.data[key]
+	if !exists || e.expired(now) {
+		return false
+	}
+	if idle >= 0 {
+		e.accessed.Store(now.Add(-idle).UnixMilli())
+	}
+	if freq >= 0 {
+		e.freq.Store(uint32(min(freq, math.MaxUint8)))
+		if idle < 0 {
+			// The frequency is the one of now
+			e.accessed.Store(now.UnixMilli())
+		}
+	}
+	return true
 }
 
 // Delete removes a key-value pair, returns true if key existed
@@ -61,20 +284,31 @@ func (s *MemoryStore) Delete(key string) bool {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	_, exists := s.data[key]
-	if exists {
-		delete(s.data, key)
+	e, exists := s.data[key]
+	if !exists {
+		return false
+	}
+
+	if e.expired(time.Now()) {
+		s.expire(key)
+		return false
 	}
-	return exists
+
+	s.remove(key)
+	return true
 }
 
 // Exists checks if a key exists
 func (s *MemoryStore) Exists(key string) bool {
 	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	e, exists := s.data[key]
+	live := exists && !e.expired(time.Now())
+	s.mutex.RUnlock()
 
-	_, exists := s.data[key]
-	return exists
+	if exists && !live {
+		s.expireIfNeeded(key)
+	}
+	return live
 }
 
 // Size returns the number of stored keys
@@ -82,7 +316,14 @@ func (s *MemoryStore) Size() int {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	return len(s.data)
+	now := time.Now()
+	size := 0
+	for _, e := range s.data {
+		if !e.expired(now) {
+			size++
+		}
+	}
+	return size
 }
 
 // Clear removes all keys
@@ -90,5 +331,221 @@ func (s *MemoryStore) Clear() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.data = make(map[string]string)
+	for key := range s.watched {
+		if _, exists := s.data[key]; exists {
+			s.clock++
+			s.tombstones[key] = s.clock
+		}
+	}
+
+	// A running snapshot keeps iterating over the old map, which no longer
+	// changes
+	if s.snapshot != nil {
+		s.snapshot.frozen = true
+	}
+
+	s.clock++
+	s.data = make(map[string]*entry)
+	s.index = newScanIndex()
+	s.expiring = make(map[string]*entry)
+	s.used = 0
+	s.pool = nil
+	if s.changeLog != nil {
+		s.changeLog.LogClear()
+	}
+}
+
+// Scan returns the live keys at or after cursor in scan order, at least
+// count of them unless fewer remain, and the cursor to continue from, 0
+// once every key has been returned.
+//
+// Keys are visited in the order of a hash of their name rather than in map
+// order, so a key that exists for the whole iteration is always returned
+// however the keyspace changes in between, as SCAN guarantees. A key may be
+// returned more than once if it is deleted and added back. The keys are
+// kept in that order by the scan index, so a call takes time in proportion
+// to count rather than to the keyspace.
+func (s *MemoryStore) Scan(cursor uint64, count int) ([]string, uint64) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	count = max(count, 1)
+
+	// Keys sharing the last position are returned together, so the next
+	// cursor never falls between them
+	var keys []string
+	next, last := uint64(0), uint64(0)
+	s.index.ascend(cursor, func(position uint64, key string) bool {
+		if len(keys) >= count && position != last {
+			next = position
+			return false
+		}
+		if !s.data[key].expired(now) {
+			keys = append(keys, key)
+			last = position
+		}
+		return true
+	})
+	return keys, next
+}
+
+// Version returns the modification version of a key
+func (s *MemoryStore) Version(key string) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.version(key)
+}
+
+// Watch registers interest in a key and returns its current version
+func (s *MemoryStore) Watch(key string) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.watched[key]++
+	return s.version(key)
+}
+
+// Unwatch releases a reference taken by Watch
+func (s *MemoryStore) Unwatch(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.watched[key]--
+	if s.watched[key] <= 0 {
+		delete(s.watched, key)
+		delete(s.tombstones, key)
+	}
+}
+
+// version returns the version of a key, expiring it first if needed.
+// Must be called with the write lock held.
+func (s *MemoryStore) version(key string) uint64 {
+	if e, exists := s.data[key]; exists {
+		if !e.expired(time.Now()) {
+			return e.version
+		}
+		s.expire(key)
+	}
+
+	// Keys that never existed, or were removed while nobody watched them, have version 0
+	return s.tombstones[key]
+}
+
+// remove deletes a key and records a tombstone if the key is watched.
+// Must be called with the write lock held.
+func (s *MemoryStore) remove(key string) {
+	s.preserve(key)
+	if e, exists := s.data[key]; exists {
+		s.used -= e.size
+		s.index.remove(scanPosition(key), key)
+	}
+	delete(s.data, key)
+	delete(s.expiring, key)
+
+	s.clock++
+	if s.watched[key] > 0 {
+		s.tombstones[key] = s.clock
+	}
+	if s.changeLog != nil {
+		s.changeLog.LogDelete(key)
+	}
+}
+
+// Expired keys are removed actively as Redis does: each loop samples
+// expireSamples keys with an expiry and removes the expired ones, and loops
+// are repeated while more than expireStalePercent of the sampled keys had
+// expired, until expireCycleBudget is spent. The write lock is released
+// between loops, so clients are not stalled by a pass over the keyspace.
+const (
+	expireSamples      = 20
+	expireStalePercent = 25
+	expireCycleBudget  = 25 * time.Millisecond
+)
+
+// DeleteExpired removes keys whose expiry time has passed and returns the
+// number of keys removed. It is run periodically so that expired keys are
+// reclaimed, and their expired events emitted, even if never accessed. It
+// samples the keys with an expiry rather than checking them all, so a
+// few expired keys may remain until a later run.
+func (s *MemoryStore) DeleteExpired() int {
+	start := time.Now()
+	removed := 0
+	for {
+		sampled, expired := s.expireSample(time.Now())
+		removed += expired
+		if expired*100 <= sampled*expireStalePercent || time.Since(start) > expireCycleBudget {
+			return removed
+		}
+	}
+}
+
+// expireSample checks up to expireSamples keys with an expiry, starting at
+// a random one, and removes those that have expired
+func (s *MemoryStore) expireSample(now time.Time) (sampled, expired int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Go starts iterating over a map at a random position
+	for key, e := range s.expiring {
+		if sampled == expireSamples {
+			break
+		}
+		sampled++
+		if e.expired(now) {
+			s.expire(key)
+			expired++
+		}
+	}
+	return sampled, expired
+}
+
+// SetNotifier sets the notifier receiving keyspace events
+func (s *MemoryStore) SetNotifier(notifier events.Notifier) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.notifier = notifier
+}
+
+// SetChangeLog sets the change log receiving every change from now on,
+// nil to stop logging
+func (s *MemoryStore) SetChangeLog(changeLog ChangeLog) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.changeLog = changeLog
+}
+
+// Notify emits a keyspace event through the configured notifier, if any
+func (s *MemoryStore) Notify(class events.Class, event, key string) {
+	s.mutex.RLock()
+	notifier := s.notifier
+	s.mutex.RUnlock()
+
+	if notifier != nil {
+		notifier.Notify(class, event, key)
+	}
+}
+
+// expireIfNeeded removes a key if it has expired. Lookups find expired keys
+// under the read lock and call it to remove them under the write lock.
+func (s *MemoryStore) expireIfNeeded(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, exists := s.data[key]; exists && e.expired(time.Now()) {
+		s.expire(key)
+	}
+}
+
+// expire removes an expired key and emits an expired event.
+// Must be called with the write lock held.
+func (s *MemoryStore) expire(key string) {
+	s.remove(key)
+
+	if s.notifier != nil {
+		s.notifier.Notify(events.Expired, "expired", key)
+	}
 }
@@ -1,14 +1,19 @@
 package storage
 
 import (
+	"slices"
+	"strconv"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/tsinivuo/redis-lite/pkg/events"
 )
 
 func TestMemoryStore_Set(t *testing.T) {
 	store := NewMemoryStore()
 
-	err := store.Set("key1", "value1")
+	err := store.Set("key1", []byte("value1"))
 	if err != nil {
 		t.Errorf("Set() returned error: %v", err)
 	}
@@ -18,7 +23,7 @@ func TestMemoryStore_Set(t *testing.T) {
 	if !exists {
 		t.Error("Key was not stored")
 	}
-	if value != "value1" {
+	if string(value) != "value1" {
 		t.Errorf("Expected value 'value1', got '%s'", value)
 	}
 }
@@ -31,17 +36,17 @@ func TestMemoryStore_Get(t *testing.T) {
 	if exists {
 		t.Error("Expected key to not exist")
 	}
-	if value != "" {
+	if string(value) != "" {
 		t.Errorf("Expected empty value for non-existent key, got '%s'", value)
 	}
 
 	// Test getting existing key
-	store.Set("key1", "value1")
+	store.Set("key1", []byte("value1"))
 	value, exists = store.Get("key1")
 	if !exists {
 		t.Error("Expected key to exist")
 	}
-	if value != "value1" {
+	if string(value) != "value1" {
 		t.Errorf("Expected value 'value1', got '%s'", value)
 	}
 }
@@ -56,7 +61,7 @@ func TestMemoryStore_Delete(t *testing.T) {
 	}
 
 	// Test deleting existing key
-	store.Set("key1", "value1")
+	store.Set("key1", []byte("value1"))
 	deleted = store.Delete("key1")
 	if !deleted {
 		t.Error("Expected delete to return true for existing key")
@@ -78,7 +83,7 @@ func TestMemoryStore_Exists(t *testing.T) {
 	}
 
 	// Test existing key
-	store.Set("key1", "value1")
+	store.Set("key1", []byte("value1"))
 	if !store.Exists("key1") {
 		t.Error("Expected key to exist")
 	}
@@ -99,8 +104,8 @@ func TestMemoryStore_Size(t *testing.T) {
 	}
 
 	// Test after adding keys
-	store.Set("key1", "value1")
-	store.Set("key2", "value2")
+	store.Set("key1", []byte("value1"))
+	store.Set("key2", []byte("value2"))
 	if store.Size() != 2 {
 		t.Errorf("Expected size 2, got %d", store.Size())
 	}
@@ -116,9 +121,9 @@ func TestMemoryStore_Clear(t *testing.T) {
 	store := NewMemoryStore()
 
 	// Add some keys
-	store.Set("key1", "value1")
-	store.Set("key2", "value2")
-	store.Set("key3", "value3")
+	store.Set("key1", []byte("value1"))
+	store.Set("key2", []byte("value2"))
+	store.Set("key3", []byte("value3"))
 
 	// Clear the store
 	store.Clear()
@@ -137,17 +142,17 @@ func TestMemoryStore_OverwriteValue(t *testing.T) {
 	store := NewMemoryStore()
 
 	// Set initial value
-	store.Set("key1", "value1")
+	store.Set("key1", []byte("value1"))
 
 	// Overwrite with new value
-	store.Set("key1", "value2")
+	store.Set("key1", []byte("value2"))
 
 	// Verify new value
 	value, exists := store.Get("key1")
 	if !exists {
 		t.Error("Key should exist")
 	}
-	if value != "value2" {
+	if string(value) != "value2" {
 		t.Errorf("Expected value 'value2', got '%s'", value)
 	}
 
@@ -161,12 +166,12 @@ func TestMemoryStore_EmptyValues(t *testing.T) {
 	store := NewMemoryStore()
 
 	// Test empty string value
-	store.Set("empty", "")
+	store.Set("empty", []byte(""))
 	value, exists := store.Get("empty")
 	if !exists {
 		t.Error("Key with empty value should exist")
 	}
-	if value != "" {
+	if string(value) != "" {
 		t.Errorf("Expected empty string, got '%s'", value)
 	}
 }
@@ -189,7 +194,7 @@ func TestMemoryStore_ThreadSafety(t *testing.T) {
 				value := "value"
 
 				// Perform various operations
-				store.Set(key, value)
+				store.Set(key, []byte(value))
 				store.Get(key)
 				store.Exists(key)
 				store.Size()
@@ -206,3 +211,380 @@ func TestMemoryStore_ThreadSafety(t *testing.T) {
 	// The test passes if no race conditions are detected
 	// (run with -race flag to verify)
 }
+
+func TestMemoryStore_SetWithExpiry(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.SetWithExpiry("key", []byte("value"), time.Now().Add(20*time.Millisecond))
+	if value, exists := store.Get("key"); !exists || string(value) != "value" {
+		t.Fatalf("Expected 'value' before expiry, got '%s' (exists=%v)", value, exists)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, exists := store.Get("key"); exists {
+		t.Error("Key should not exist after expiry")
+	}
+	if store.Exists("key") {
+		t.Error("Exists should report false after expiry")
+	}
+	if store.Size() != 0 {
+		t.Errorf("Expected size 0 after expiry, got %d", store.Size())
+	}
+}
+
+func TestMemoryStore_GetWithExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	expiresAt := time.Now().Add(time.Hour)
+	store.SetWithExpiry("expiring", []byte("1"), expiresAt)
+	store.Set("persistent", []byte("2"))
+
+	if value, at, exists := store.GetWithExpiry("expiring"); !exists || string(value) != "1" || !at.Equal(expiresAt) {
+		t.Errorf("Expected '1' expiring at %v, got '%s' at %v (exists=%v)", expiresAt, value, at, exists)
+	}
+	if _, at, exists := store.GetWithExpiry("persistent"); !exists || !at.IsZero() {
+		t.Errorf("Expected no expiry, got %v (exists=%v)", at, exists)
+	}
+	if _, _, exists := store.GetWithExpiry("missing"); exists {
+		t.Error("Expected a missing key not to exist")
+	}
+}
+
+func TestMemoryStore_SetRemovesExpiry(t *testing.T) {
+	store := NewMemoryStore()
+
+	store.SetWithExpiry("key", []byte("value1"), time.Now().Add(20*time.Millisecond))
+	store.Set("key", []byte("value2"))
+
+	time.Sleep(40 * time.Millisecond)
+
+	if value, exists := store.Get("key"); !exists || string(value) != "value2" {
+		t.Errorf("Expected SET to remove the expiry, got '%s' (exists=%v)", value, exists)
+	}
+}
+
+func TestMemoryStore_Version(t *testing.T) {
+	store := NewMemoryStore()
+
+	if store.Version("key") != 0 {
+		t.Errorf("Expected version 0 for missing key, got %d", store.Version("key"))
+	}
+
+	store.Set("key", []byte("value1"))
+	v1 := store.Version("key")
+	if v1 == 0 {
+		t.Fatal("Expected non-zero version after Set")
+	}
+
+	if store.Version("key") != v1 {
+		t.Error("Version changed without modification")
+	}
+
+	store.Get("key")
+	if store.Version("key") != v1 {
+		t.Error("Get must not change the version")
+	}
+
+	store.Set("key", []byte("value2"))
+	if store.Version("key") == v1 {
+		t.Error("Expected version to change after overwrite")
+	}
+}
+
+func TestMemoryStore_WatchDetectsRemoval(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(store *MemoryStore)
+	}{
+		{
+			name:   "delete",
+			modify: func(store *MemoryStore) { store.Delete("key") },
+		},
+		{
+			name:   "clear",
+			modify: func(store *MemoryStore) { store.Clear() },
+		},
+		{
+			name: "set and delete",
+			modify: func(store *MemoryStore) {
+				store.Set("key", []byte("other"))
+				store.Delete("key")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			store.Set("key", []byte("value"))
+
+			version := store.Watch("key")
+			tt.modify(store)
+
+			if store.Version("key") == version {
+				t.Error("Expected version to change")
+			}
+
+			store.Unwatch("key")
+		})
+	}
+}
+
+func TestMemoryStore_WatchDetectsExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	store.SetWithExpiry("key", []byte("value"), time.Now().Add(20*time.Millisecond))
+
+	version := store.Watch("key")
+	time.Sleep(40 * time.Millisecond)
+
+	if store.Version("key") == version {
+		t.Error("Expected version to change after expiry")
+	}
+}
+
+func TestMemoryStore_WatchMissingKeyStaysMissing(t *testing.T) {
+	store := NewMemoryStore()
+
+	version := store.Watch("key")
+	store.Clear()
+
+	if store.Version("key") != version {
+		t.Error("Flushing must not touch watched keys that do not exist")
+	}
+}
+
+func TestMemoryStore_UnwatchDropsTombstones(t *testing.T) {
+	store := NewMemoryStore()
+	store.Set("key", []byte("value"))
+
+	store.Watch("key")
+	store.Delete("key")
+	store.Unwatch("key")
+
+	if len(store.tombstones) != 0 || len(store.watched) != 0 {
+		t.Errorf("Expected no watch state after Unwatch, got %d tombstones and %d watched keys",
+			len(store.tombstones), len(store.watched))
+	}
+}
+
+// recordingNotifier records every keyspace event as "event:key"
+type recordingNotifier struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *recordingNotifier) Notify(class events.Class, event, key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, event+":"+key)
+}
+
+func (r *recordingNotifier) recorded() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string(nil), r.events...)
+}
+
+func TestMemoryStore_Notify(t *testing.T) {
+	store := NewMemoryStore()
+
+	// Without a notifier, Notify is a no-op
+	store.Notify(events.String, "set", "key")
+
+	notifier := &recordingNotifier{}
+	store.SetNotifier(notifier)
+	store.Notify(events.String, "set", "key")
+
+	if got := notifier.recorded(); len(got) != 1 || got[0] != "set:key" {
+		t.Errorf("Expected [set:key], got %v", got)
+	}
+}
+
+func TestMemoryStore_ExpiredEventOnAccess(t *testing.T) {
+	store := NewMemoryStore()
+	notifier := &recordingNotifier{}
+	store.SetNotifier(notifier)
+
+	store.SetWithExpiry("key", []byte("value"), time.Now().Add(20*time.Millisecond))
+	time.Sleep(40 * time.Millisecond)
+
+	if _, exists := store.Get("key"); exists {
+		t.Fatal("Key should not exist after expiry")
+	}
+
+	// A second lookup must not emit the event again
+	store.Exists("key")
+
+	if got := notifier.recorded(); len(got) != 1 || got[0] != "expired:key" {
+		t.Errorf("Expected [expired:key], got %v", got)
+	}
+}
+
+func TestMemoryStore_DeleteExpired(t *testing.T) {
+	store := NewMemoryStore()
+	notifier := &recordingNotifier{}
+	store.SetNotifier(notifier)
+
+	store.SetWithExpiry("short", []byte("value"), time.Now().Add(20*time.Millisecond))
+	store.SetWithExpiry("long", []byte("value"), time.Now().Add(time.Hour))
+	store.Set("persistent", []byte("value"))
+
+	time.Sleep(40 * time.Millisecond)
+
+	if removed := store.DeleteExpired(); removed != 1 {
+		t.Errorf("Expected 1 removed key, got %d", removed)
+	}
+
+	if len(store.data) != 2 {
+		t.Errorf("Expected 2 keys to remain, got %d", len(store.data))
+	}
+
+	if got := notifier.recorded(); len(got) != 1 || got[0] != "expired:short" {
+		t.Errorf("Expected [expired:short], got %v", got)
+	}
+}
+
+func TestMemoryStore_DeleteExpiredSamples(t *testing.T) {
+	store := NewMemoryStore()
+	past := time.Now().Add(-time.Second)
+	for i := 0; i < 1000; i++ {
+		store.Set("persistent:"+strconv.Itoa(i), []byte("value"))
+		store.SetWithExpiry("expired:"+strconv.Itoa(i), []byte("value"), past)
+	}
+
+	// Every sample is all expired keys, so sampling goes on until none is
+	// left, and only the keys with an expiry are checked
+	if removed := store.DeleteExpired(); removed != 1000 {
+		t.Errorf("Expected 1000 removed keys, got %d", removed)
+	}
+	if len(store.data) != 1000 || len(store.expiring) != 0 {
+		t.Errorf("Expected only the 1000 persistent keys to remain, got %d keys and %d expiring", len(store.data), len(store.expiring))
+	}
+}
+
+func TestMemoryStore_Scan(t *testing.T) {
+	store := NewMemoryStore()
+	for i := 0; i < 50; i++ {
+		store.Set("key"+strconv.Itoa(i), []byte("value"))
+	}
+	store.SetWithExpiry("expired", []byte("value"), time.Now().Add(-time.Second))
+
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for iterations := 0; ; iterations++ {
+		keys, next := store.Scan(cursor, 7)
+		if next != 0 && len(keys) < 7 {
+			t.Errorf("Expected at least 7 keys before the end, got %d", len(keys))
+		}
+
+		for _, key := range keys {
+			seen[key]++
+		}
+
+		// Keys added and removed mid-iteration must not disturb the others
+		if iterations == 2 {
+			store.Set("added", []byte("value"))
+			store.Delete("key0")
+			store.Set("key0", []byte("value"))
+		}
+
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 0; i < 50; i++ {
+		if key := "key" + strconv.Itoa(i); seen[key] == 0 {
+			t.Errorf("Expected %s to be returned", key)
+		}
+	}
+	if seen["expired"] != 0 {
+		t.Error("Expected expired keys to be skipped")
+	}
+}
+
+func TestMemoryStore_ScanEmpty(t *testing.T) {
+	store := NewMemoryStore()
+
+	if keys, next := store.Scan(0, 10); len(keys) != 0 || next != 0 {
+		t.Errorf("Expected no keys and cursor 0, got %v and %d", keys, next)
+	}
+}
+
+// recordingChangeLog records every change as "set:key", "del:key" or "clear"
+type recordingChangeLog struct {
+	changes []string
+}
+
+func (r *recordingChangeLog) LogSet(key string, value []byte, expiresAt time.Time) {
+	r.changes = append(r.changes, "set:"+key)
+}
+
+func (r *recordingChangeLog) LogDelete(key string) {
+	r.changes = append(r.changes, "del:"+key)
+}
+
+func (r *recordingChangeLog) LogClear() {
+	r.changes = append(r.changes, "clear")
+}
+
+func TestMemoryStore_ChangeLog(t *testing.T) {
+	store := NewMemoryStore()
+	changeLog := &recordingChangeLog{}
+	store.SetChangeLog(changeLog)
+
+	store.Set("a", []byte("1"))
+	store.SetWithExpiry("b", []byte("2"), time.Now().Add(-time.Second))
+	store.Delete("a")
+	store.Delete("missing")
+	store.Get("b")
+	store.Clear()
+
+	store.SetChangeLog(nil)
+	store.Set("c", []byte("3"))
+
+	expected := []string{"set:a", "set:b", "del:a", "del:b", "clear"}
+	if !slices.Equal(changeLog.changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changeLog.changes)
+	}
+}
+
+func TestMemoryStore_Inspect(t *testing.T) {
+	store := NewMemoryStore()
+	expiresAt := time.Now().Add(time.Hour)
+	store.SetWithExpiry("key", []byte("value"), expiresAt)
+
+	info, exists := store.Inspect("key")
+	if !exists || string(info.Value) != "value" || !info.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("Expected the key to be described, got %+v", info)
+	}
+	if info.Size != store.UsedMemory() || info.Freq < lfuInitialFreq || info.Idle > time.Second {
+		t.Errorf("Expected the size and access of a new key, got %+v", info)
+	}
+	if _, exists := store.Inspect("missing"); exists {
+		t.Error("Expected a missing key not to be described")
+	}
+
+	// Inspecting is not an access, while reading is
+	store.SetAccess("key", time.Hour, -1)
+	if info, _ := store.Inspect("key"); info.Idle < time.Hour {
+		t.Errorf("Expected an idle time of 1h, got %v", info.Idle)
+	}
+	store.Get("key")
+	if info, _ := store.Inspect("key"); info.Idle > time.Second {
+		t.Errorf("Expected reading to reset the idle time, got %v", info.Idle)
+	}
+
+	if !store.SetAccess("key", -1, 100) {
+		t.Fatal("Expected SetAccess() to find the key")
+	}
+	if info, _ := store.Inspect("key"); info.Freq != 100 {
+		t.Errorf("Expected frequency 100, got %d", info.Freq)
+	}
+	if store.SetAccess("missing", time.Hour, -1) {
+		t.Error("Expected SetAccess() not to find a missing key")
+	}
+}